@@ -772,20 +772,27 @@ func retryWithBackoff(operation func() error, maxRetries int) error {
 	return lastErr
 }
 
+// listCapabilitiesArgs are the arguments of list_capabilities
+// None are advertised: _sources and _disable_cache are test overrides
+type listCapabilitiesArgs struct {
+	Type         string `json:"type" mcp:"hidden"`
+	Sources      string `json:"_sources" mcp:"hidden"`
+	DisableCache bool   `json:"_disable_cache" mcp:"hidden"`
+}
+
 // executeListCapabilitiesTool handles the list_capabilities MCP tool
-func executeListCapabilitiesTool(cfg *config.Config, args map[string]interface{}) (string, error) {
+func executeListCapabilitiesTool(cfg *config.Config, args listCapabilitiesArgs) (string, error) {
 	// Parse sources (test override or centralized config)
 	sourcesEnv := cfg.Capability.Sources
-	if override, ok := args["_sources"].(string); ok && override != "" {
-		sourcesEnv = override
+	if args.Sources != "" {
+		sourcesEnv = args.Sources
 	}
 
 	// Parse type parameter (defaults to "commands")
-	capTypeStr := string(DefaultCapabilityType)
-	if typeParam, ok := args["type"].(string); ok && typeParam != "" {
-		capTypeStr = typeParam
+	capType := DefaultCapabilityType
+	if args.Type != "" {
+		capType = CapabilityType(args.Type)
 	}
-	capType := CapabilityType(capTypeStr)
 
 	// Validate capability type
 	if err := validateCapabilityType(capType); err != nil {
@@ -805,10 +812,7 @@ func executeListCapabilitiesTool(cfg *config.Config, args map[string]interface{}
 	}
 
 	// Check cache control (hidden test parameter)
-	disableCache := false
-	if disable, ok := args["_disable_cache"].(bool); ok {
-		disableCache = disable
-	}
+	disableCache := args.DisableCache
 
 	slog.Debug("listing capabilities",
 		"source_count", len(sources),
@@ -1091,25 +1095,20 @@ func enhanceNotFoundError(name string, source GitHubSource) error {
 	return fmt.Errorf("%s: %w", msg, mcerrors.ErrNotFound)
 }
 
-// executeGetCapabilityTool handles the get_capability MCP tool
-func executeGetCapabilityTool(cfg *config.Config, args map[string]interface{}) (string, error) {
-	// Get capability name
-	name, ok := args["name"].(string)
-	if !ok || name == "" {
-		slog.Error("missing required parameter: name",
-			"error_type", "validation_error",
-		)
-		return "", fmt.Errorf("missing required parameter 'name' for get_capability tool: %w", mcerrors.ErrMissingParameter)
-	}
+// getCapabilityArgs are the arguments of get_capability
+// type is optional since the name may carry it ("prompts/name"); _sources is a test override
+type getCapabilityArgs struct {
+	Name    string `json:"name" mcp:"required" desc:"Name of the capability to retrieve (without .md extension)"`
+	Type    string `json:"type" mcp:"hidden"`
+	Sources string `json:"_sources" mcp:"hidden"`
+}
 
-	// Parse type parameter (optional, supports smart parsing from name)
-	typeParam := ""
-	if typeArg, ok := args["type"].(string); ok {
-		typeParam = typeArg
-	}
+// executeGetCapabilityTool handles the get_capability MCP tool
+func executeGetCapabilityTool(cfg *config.Config, args getCapabilityArgs) (string, error) {
+	name := args.Name
 
 	// Smart parse capability reference (supports "prompts/name" format)
-	capType, cleanName := parseCapabilityReference(name, typeParam)
+	capType, cleanName := parseCapabilityReference(name, args.Type)
 
 	// Validate capability type
 	if err := validateCapabilityType(capType); err != nil {
@@ -1118,8 +1117,8 @@ func executeGetCapabilityTool(cfg *config.Config, args map[string]interface{}) (
 
 	// Parse sources (test override or centralized config)
 	sourcesEnv := cfg.Capability.Sources
-	if override, ok := args["_sources"].(string); ok && override != "" {
-		sourcesEnv = override
+	if args.Sources != "" {
+		sourcesEnv = args.Sources
 	}
 
 	// Parse sources
@@ -62,9 +62,9 @@ This is a test capability from the second source.
 	}
 
 	// Call list_capabilities via executeListCapabilitiesTool
-	args := map[string]interface{}{
-		"_sources":       tmpDir1 + string(os.PathListSeparator) + tmpDir2,
-		"_disable_cache": true,
+	args := listCapabilitiesArgs{
+		Sources:      tmpDir1 + string(os.PathListSeparator) + tmpDir2,
+		DisableCache: true,
 	}
 
 	result, err := executeListCapabilitiesTool(cfg, args)
@@ -157,9 +157,9 @@ Content from low priority source.
 	}
 
 	// Call list_capabilities (high priority first)
-	args := map[string]interface{}{
-		"_sources":       tmpDirHigh + string(os.PathListSeparator) + tmpDirLow,
-		"_disable_cache": true,
+	args := listCapabilitiesArgs{
+		Sources:      tmpDirHigh + string(os.PathListSeparator) + tmpDirLow,
+		DisableCache: true,
 	}
 
 	result, err := executeListCapabilitiesTool(cfg, args)
@@ -186,10 +186,9 @@ Content from low priority source.
 	}
 
 	// Get full capability content
-	getArgs := map[string]interface{}{
-		"name":           "duplicate",
-		"_sources":       tmpDirHigh + string(os.PathListSeparator) + tmpDirLow,
-		"_disable_cache": true,
+	getArgs := getCapabilityArgs{
+		Name:    "duplicate",
+		Sources: tmpDirHigh + string(os.PathListSeparator) + tmpDirLow,
 	}
 
 	contentResult, err := executeGetCapabilityTool(cfg, getArgs)
@@ -237,9 +236,9 @@ category: diagnostics
 	}
 
 	// First load
-	args1 := map[string]interface{}{
-		"_sources":       tmpDir,
-		"_disable_cache": false, // Enable cache to test local bypass
+	args1 := listCapabilitiesArgs{
+		Sources:      tmpDir,
+		DisableCache: false, // Enable cache to test local bypass
 	}
 
 	result1, err := executeListCapabilitiesTool(cfg, args1)
@@ -277,9 +276,9 @@ category: diagnostics
 	}
 
 	// Second load (should reflect changes immediately for local sources, even with cache enabled)
-	args2 := map[string]interface{}{
-		"_sources":       tmpDir,
-		"_disable_cache": false, // Cache enabled, but local sources bypass
+	args2 := listCapabilitiesArgs{
+		Sources:      tmpDir,
+		DisableCache: false, // Cache enabled, but local sources bypass
 	}
 
 	result2, err := executeListCapabilitiesTool(cfg, args2)
@@ -340,9 +339,9 @@ func TestIntegrationEndToEnd(t *testing.T) {
 	}
 
 	// Step 1: List capabilities
-	listArgs := map[string]interface{}{
-		"_sources":       tmpDir,
-		"_disable_cache": true,
+	listArgs := listCapabilitiesArgs{
+		Sources:      tmpDir,
+		DisableCache: true,
 	}
 
 	listResult, err := executeListCapabilitiesTool(cfg, listArgs)
@@ -387,10 +386,9 @@ func TestIntegrationEndToEnd(t *testing.T) {
 		}
 
 		// Get full capability content
-		getArgs := map[string]interface{}{
-			"name":           capData.name,
-			"_sources":       tmpDir,
-			"_disable_cache": true,
+		getArgs := getCapabilityArgs{
+			Name:    capData.name,
+			Sources: tmpDir,
 		}
 
 		contentResult, err := executeGetCapabilityTool(cfg, getArgs)
@@ -3,6 +3,7 @@ package main
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -680,7 +681,7 @@ func TestExecuteGetCapabilityTool(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := executeGetCapabilityTool(cfg, tt.args)
+			result, err := NewToolExecutor().ExecuteTool(context.Background(), cfg, "get_capability", tt.args)
 			if tt.expectError {
 				if err == nil {
 					t.Error("expected error, got nil")
@@ -925,8 +926,7 @@ func TestDefaultSourceIsPackage(t *testing.T) {
 
 	// Test list_capabilities - should use package default
 	// This will actually try to download from GitHub releases
-	args := map[string]interface{}{}
-	result, err := executeListCapabilitiesTool(cfg, args)
+	result, err := executeListCapabilitiesTool(cfg, listCapabilitiesArgs{})
 
 	// Since we're actually connecting to GitHub releases in the test, check the result
 	if err != nil {
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/yaleh/meta-cc/internal/sessionio"
+	"github.com/yaleh/meta-cc/pkg/output"
+)
+
+// completion.go implements completion/complete for tool arguments
+//
+// MCP defines completion references for prompts and resources. meta-cc has
+// neither, so it also accepts {"type": "ref/tool", "name": "<tool>"} and
+// otherwise completes by argument name regardless of the reference type.
+
+// maxCompletionValues is the MCP limit on values per completion response
+const maxCompletionValues = 100
+
+// knownClaudeTools lists built-in Claude Code tool names for tool_name arguments
+var knownClaudeTools = []string{
+	"Bash", "BashOutput", "Edit", "ExitPlanMode", "Glob", "Grep", "KillShell",
+	"MultiEdit", "NotebookEdit", "Read", "SlashCommand", "Task", "TodoWrite",
+	"WebFetch", "WebSearch", "Write",
+}
+
+// completionProvider returns candidate values for an argument of a given tool
+type completionProvider func(toolName string) []string
+
+// completionProviders maps argument names to their candidate sources
+var completionProviders = map[string]completionProvider{
+	"scope":         func(string) []string { return []string{"project", "session", scopeAll} },
+	"output_format": func(string) []string { return output.RecordFormats },
+	"tool_name":     func(string) []string { return knownClaudeTools },
+	"session_id":    func(string) []string { return completeSessionIDs() },
+	"type": func(toolName string) []string {
+		if toolName == "list_capabilities" || toolName == "get_capability" {
+			return []string{string(CapabilityTypeCommands), string(CapabilityTypePrompts)}
+		}
+		return nil
+	},
+	"name": func(toolName string) []string {
+		if toolName == "get_capability" {
+			return completeCapabilityNames()
+		}
+		return nil
+	},
+}
+
+// handleCompletionComplete implements completion/complete
+func handleCompletionComplete(ctx context.Context, req JSONRPCRequest) {
+	ref, _ := req.Params["ref"].(map[string]interface{})
+	argument, ok := req.Params["argument"].(map[string]interface{})
+	if !ok {
+		RecordRequest("completion", req.Method, "invalid")
+		writeError(req.ID, -32602, "Invalid params: missing argument")
+		return
+	}
+
+	argName, _ := argument["name"].(string)
+	prefix, _ := argument["value"].(string)
+	toolName, _ := ref["name"].(string)
+
+	values := completeArgument(toolName, argName, prefix)
+	total := len(values)
+	hasMore := false
+	if total > maxCompletionValues {
+		values = values[:maxCompletionValues]
+		hasMore = true
+	}
+
+	RecordRequest("completion", req.Method, "success")
+	writeResponse(req.ID, map[string]interface{}{
+		"completion": map[string]interface{}{
+			"values":  values,
+			"total":   total,
+			"hasMore": hasMore,
+		},
+	})
+}
+
+// completionCandidates returns the enum declared in the tool's schema, falling
+// back to the provider registered for argName
+func completionCandidates(toolName, argName string) []string {
+	if tool, ok := builtinTools.lookup(toolName); ok {
+		if prop, ok := tool.schema.Properties[argName]; ok && len(prop.Enum) > 0 {
+			return prop.Enum
+		}
+	}
+	if provider, ok := completionProviders[argName]; ok {
+		return provider(toolName)
+	}
+	return nil
+}
+
+// completeArgument returns sorted candidates for argName that start with prefix
+func completeArgument(toolName, argName, prefix string) []string {
+	lowerPrefix := strings.ToLower(prefix)
+	values := make([]string, 0)
+	for _, candidate := range completionCandidates(toolName, argName) {
+		if strings.HasPrefix(strings.ToLower(candidate), lowerPrefix) {
+			values = append(values, candidate)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// completeSessionIDs lists session IDs (JSONL basenames) for the current project
+func completeSessionIDs() []string {
+	baseDir, err := getQueryBaseDir("project")
+	if err != nil {
+		return nil
+	}
+	files, err := getJSONLFiles(baseDir)
+	if err != nil {
+		return nil
+	}
+
+	ids := make([]string, 0, len(files))
+	for _, file := range files {
+		ids = append(ids, sessionio.SessionID(file))
+	}
+	return ids
+}
+
+// completeCapabilityNames lists capability names from configured sources.
+// The default remote package is skipped so completion never triggers a download.
+func completeCapabilityNames() []string {
+	if cfg == nil {
+		return nil
+	}
+	sources := parseCapabilitySources(cfg.Capability.Sources)
+	if len(sources) == 0 {
+		return nil
+	}
+
+	index, err := getCapabilityIndex(sources, DefaultCapabilityType, false)
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(index))
+	for name := range index {
+		names = append(names, name)
+	}
+	return names
+}
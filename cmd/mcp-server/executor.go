@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -11,46 +10,35 @@ import (
 	"github.com/yaleh/meta-cc/internal/config"
 	mcerrors "github.com/yaleh/meta-cc/internal/errors"
 	querypkg "github.com/yaleh/meta-cc/internal/query"
+	"github.com/yaleh/meta-cc/internal/redact"
 )
 
 type ToolExecutor struct{}
 
 type toolPipelineConfig struct {
-	jqFilter         string
-	statsOnly        bool
-	statsFirst       bool
-	outputFormat     string
-	maxMessageLength int
-	contentSummary   bool
+	jqFilter     string
+	statsOnly    bool
+	statsFirst   bool
+	outputFormat string
+	// redactions counts what was masked in the records, reported in the response
+	redactions redact.Counts
+	// sampling describes how the records were sampled, if they were
+	sampling *querypkg.SampleReport
 }
 
 func newToolPipelineConfig(args map[string]interface{}) toolPipelineConfig {
 	return toolPipelineConfig{
-		jqFilter:         getStringParam(args, "jq_filter", ".[]"),
-		statsOnly:        getBoolParam(args, "stats_only", false),
-		statsFirst:       getBoolParam(args, "stats_first", false),
-		outputFormat:     getStringParam(args, "output_format", "jsonl"),
-		maxMessageLength: getIntParam(args, "max_message_length", 0),
-		contentSummary:   getBoolParam(args, "content_summary", false),
+		jqFilter:     getStringParam(args, "jq_filter", ".[]"),
+		statsOnly:    getBoolParam(args, "stats_only", false),
+		statsFirst:   getBoolParam(args, "stats_first", false),
+		outputFormat: getStringParam(args, "output_format", "jsonl"),
 	}
 }
 
-func (c toolPipelineConfig) requiresMessageFilters() bool {
-	return c.maxMessageLength > 0 || c.contentSummary
-}
-
 func NewToolExecutor() *ToolExecutor {
 	return &ToolExecutor{}
 }
 
-func determineScope(toolName string, args map[string]interface{}) string {
-	defaultScope := "project"
-	if toolName == "get_session_stats" {
-		defaultScope = "session"
-	}
-	return getStringParam(args, "scope", defaultScope)
-}
-
 func recordToolSuccess(toolName, scope string, start time.Time) {
 	elapsed := time.Since(start)
 	RecordToolCall(toolName, scope, "success")
@@ -64,200 +52,26 @@ func recordToolFailure(toolName, scope string, start time.Time, errorType string
 	RecordError(toolName, errorType, GetErrorSeverity(errorType))
 }
 
-func (e *ToolExecutor) executeSpecialTool(cfg *config.Config, toolName, scope string, args map[string]interface{}, start time.Time) (string, bool, error) {
-	switch toolName {
-	case "cleanup_temp_files":
-		output, err := executeCleanupTool(args)
-		if err != nil {
-			errorType := classifyError(err)
-			recordToolFailure(toolName, scope, start, errorType)
-			return "", true, err
-		}
-		recordToolSuccess(toolName, scope, start)
-		return output, true, nil
-
-	case "list_capabilities":
-		output, err := executeListCapabilitiesTool(cfg, args)
-		if err != nil {
-			errorType := classifyError(err)
-			recordToolFailure(toolName, scope, start, errorType)
-			return "", true, err
-		}
-		recordToolSuccess(toolName, scope, start)
-		return output, true, nil
-
-	case "get_capability":
-		output, err := executeGetCapabilityTool(cfg, args)
-		if err != nil {
-			errorType := classifyError(err)
-			recordToolFailure(toolName, scope, start, errorType)
-			return "", true, err
-		}
-		recordToolSuccess(toolName, scope, start)
-		return output, true, nil
-
-	case "get_session_directory":
-		result, err := handleGetSessionDirectory(context.Background(), args)
-		if err != nil {
-			errorType := classifyError(err)
-			recordToolFailure(toolName, scope, start, errorType)
-			return "", true, err
-		}
-		// Convert result to JSON string
-		jsonData, err := json.Marshal(result)
-		if err != nil {
-			errorType := classifyError(err)
-			recordToolFailure(toolName, scope, start, errorType)
-			return "", true, fmt.Errorf("failed to marshal result: %w", err)
-		}
-		recordToolSuccess(toolName, scope, start)
-		return string(jsonData), true, nil
-
-	case "inspect_session_files":
-		result, err := handleInspectSessionFiles(context.Background(), args)
-		if err != nil {
-			errorType := classifyError(err)
-			recordToolFailure(toolName, scope, start, errorType)
-			return "", true, err
-		}
-		// Convert result to JSON string
-		jsonData, err := json.Marshal(result)
-		if err != nil {
-			errorType := classifyError(err)
-			recordToolFailure(toolName, scope, start, errorType)
-			return "", true, fmt.Errorf("failed to marshal result: %w", err)
-		}
-		recordToolSuccess(toolName, scope, start)
-		return string(jsonData), true, nil
-
-	case "execute_stage2_query":
-		result, err := handleExecuteStage2Query(context.Background(), args)
-		if err != nil {
-			errorType := classifyError(err)
-			recordToolFailure(toolName, scope, start, errorType)
-			return "", true, err
-		}
-		// Convert result to JSON string
-		jsonData, err := json.Marshal(result)
-		if err != nil {
-			errorType := classifyError(err)
-			recordToolFailure(toolName, scope, start, errorType)
-			return "", true, fmt.Errorf("failed to marshal result: %w", err)
-		}
-		recordToolSuccess(toolName, scope, start)
-		return string(jsonData), true, nil
-
-	case "get_session_metadata":
-		result, err := handleGetSessionMetadata(context.Background(), args)
-		if err != nil {
-			errorType := classifyError(err)
-			recordToolFailure(toolName, scope, start, errorType)
-			return "", true, err
-		}
-		// Convert result to JSON string
-		jsonData, err := json.Marshal(result)
-		if err != nil {
-			errorType := classifyError(err)
-			recordToolFailure(toolName, scope, start, errorType)
-			return "", true, fmt.Errorf("failed to marshal result: %w", err)
-		}
-		recordToolSuccess(toolName, scope, start)
-		return string(jsonData), true, nil
-
-	default:
-		return "", false, nil
-	}
-}
-
-// ExecuteTool executes a meta-cc command and applies jq filtering
-func (e *ToolExecutor) ExecuteTool(cfg *config.Config, toolName string, args map[string]interface{}) (string, error) {
-	scope := determineScope(toolName, args)
-	start := time.Now()
-
-	if output, handled, err := e.executeSpecialTool(cfg, toolName, scope, args, start); handled {
-		return output, err
-	}
-
-	config := newToolPipelineConfig(args)
-	var parsedData []interface{}
-	var err error
-
-	switch toolName {
-	// Phase 27 Stage 27.1: query and query_raw tools removed
-	// Use the 10 shortcut query tools instead
-
-	// Layer 1: Convenience Tools (10 high-frequency queries)
-	// Phase 27 Stage 27.5: These tools now return []interface{} directly
-	case "query_user_messages":
-		parsedData, err = e.handleQueryUserMessages(cfg, scope, args)
-	case "query_tools":
-		parsedData, err = e.handleQueryTools(cfg, scope, args)
-	case "query_tool_errors":
-		parsedData, err = e.handleQueryToolErrors(cfg, scope, args)
-	case "query_token_usage":
-		parsedData, err = e.handleQueryTokenUsage(cfg, scope, args)
-	case "query_conversation_flow":
-		parsedData, err = e.handleQueryConversationFlow(cfg, scope, args)
-	case "query_system_errors":
-		parsedData, err = e.handleQuerySystemErrors(cfg, scope, args)
-	case "query_file_snapshots":
-		parsedData, err = e.handleQueryFileSnapshots(cfg, scope, args)
-	case "query_timestamps":
-		parsedData, err = e.handleQueryTimestamps(cfg, scope, args)
-	case "query_summaries":
-		parsedData, err = e.handleQuerySummaries(cfg, scope, args)
-	case "query_tool_blocks":
-		parsedData, err = e.handleQueryToolBlocks(cfg, scope, args)
-	default:
-		// All query tools must be handled explicitly above.
-		// No CLI fallback - all tools use internal/query library.
-		recordToolFailure(toolName, scope, start, "validation_error")
-		return "", fmt.Errorf("unknown tool %s in executor: %w", toolName, mcerrors.ErrUnknownTool)
-	}
-
-	if err != nil {
-		errorType := classifyError(err)
-		slog.Error("tool execution failed",
-			"tool_name", toolName,
-			"error", err.Error(),
-			"error_type", errorType,
-		)
-		recordToolFailure(toolName, scope, start, errorType)
-		return "", err
-	}
-
-	// Phase 27 Stage 27.5: Convenience tools now return []interface{} directly
-	// No need to parse JSONL or apply jq filters (filters are already applied internally)
-	// Note: Phase 25 originally introduced these convenience tools with jq execution
-
-	if toolName == "query_user_messages" && config.requiresMessageFilters() {
-		parsedData = e.applyMessageFiltersToData(parsedData, config.maxMessageLength, config.contentSummary)
-	}
-
-	output, err := e.buildResponse(cfg, parsedData, args, toolName, config)
-	if err != nil {
-		return "", err
-	}
-
-	slog.Debug("tool execution pipeline completed successfully",
-		"tool_name", toolName,
-		"output_length", len(output),
-	)
-
-	recordToolSuccess(toolName, scope, start)
-	return output, nil
-}
-
+// buildResponse renders query tool records for the client.
+// Convenience tools (introduced in Phase 25) apply their jq filters internally,
+// so jq_filter is never applied a second time here.
 func (e *ToolExecutor) buildResponse(cfg *config.Config, parsedData []interface{}, args map[string]interface{}, toolName string, pipeline toolPipelineConfig) (string, error) {
 	if pipeline.statsOnly {
 		return e.buildStatsOnlyResponse(parsedData, toolName)
 	}
 
 	if pipeline.statsFirst {
-		return e.buildStatsFirstResponse(cfg, parsedData, args, toolName)
+		return e.buildStatsFirstResponse(cfg, parsedData, args, toolName, pipeline)
 	}
 
-	return e.buildStandardResponse(cfg, parsedData, args, toolName)
+	return e.buildStandardResponse(cfg, parsedData, args, toolName, pipeline)
+}
+
+// addRedactions reports the redactions of the records in an adapted response
+func addRedactions(response interface{}, counts redact.Counts) {
+	if m, ok := response.(map[string]interface{}); ok && counts.Total() > 0 {
+		m["redactions"] = redactionMetadata(counts)
+	}
 }
 
 func (e *ToolExecutor) buildStatsOnlyResponse(parsedData []interface{}, toolName string) (string, error) {
@@ -284,7 +98,7 @@ func (e *ToolExecutor) buildStatsOnlyResponse(parsedData []interface{}, toolName
 	return output, nil
 }
 
-func (e *ToolExecutor) buildStatsFirstResponse(cfg *config.Config, parsedData []interface{}, args map[string]interface{}, toolName string) (string, error) {
+func (e *ToolExecutor) buildStatsFirstResponse(cfg *config.Config, parsedData []interface{}, args map[string]interface{}, toolName string, pipeline toolPipelineConfig) (string, error) {
 	jsonlData, err := e.dataToJSONL(parsedData)
 	if err != nil {
 		slog.Error("dataToJSONL conversion failed (stats_first)",
@@ -297,6 +111,8 @@ func (e *ToolExecutor) buildStatsFirstResponse(cfg *config.Config, parsedData []
 
 	stats, _ := querypkg.GenerateStats(jsonlData)
 	response, err := adaptResponse(cfg, parsedData, args, toolName)
+	addRedactions(response, pipeline.redactions)
+	addSampling(response, pipeline.sampling)
 	if err != nil {
 		slog.Error("response adaptation failed (stats_first)",
 			"tool_name", toolName,
@@ -319,8 +135,10 @@ func (e *ToolExecutor) buildStatsFirstResponse(cfg *config.Config, parsedData []
 	return stats + "\n---\n" + serialized, nil
 }
 
-func (e *ToolExecutor) buildStandardResponse(cfg *config.Config, parsedData []interface{}, args map[string]interface{}, toolName string) (string, error) {
+func (e *ToolExecutor) buildStandardResponse(cfg *config.Config, parsedData []interface{}, args map[string]interface{}, toolName string, pipeline toolPipelineConfig) (string, error) {
 	response, err := adaptResponse(cfg, parsedData, args, toolName)
+	addRedactions(response, pipeline.redactions)
+	addSampling(response, pipeline.sampling)
 	if err != nil {
 		slog.Error("response adaptation failed",
 			"tool_name", toolName,
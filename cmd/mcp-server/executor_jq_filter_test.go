@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -47,7 +48,7 @@ func TestConvenienceToolsExecuteCorrectly(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			output, err := executor.ExecuteTool(cfg, tt.toolName, tt.args)
+			output, err := executor.ExecuteTool(context.Background(), cfg, tt.toolName, tt.args)
 
 			if tt.expectError {
 				if err == nil {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"strings"
 	"testing"
 
@@ -69,7 +70,7 @@ func TestQueryToolsDoNotUseCLI(t *testing.T) {
 
 	for _, tc := range queryTools {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := executor.ExecuteTool(cfg, tc.name, tc.args)
+			_, err := executor.ExecuteTool(context.Background(), cfg, tc.name, tc.args)
 
 			// The tool may fail for legitimate reasons (e.g., session not found),
 			// but it must NOT fail because it tried to execute the non-existent CLI binary.
@@ -115,7 +116,7 @@ func TestUnknownToolReturnsError(t *testing.T) {
 				"scope": "session",
 			}
 
-			_, err := executor.ExecuteTool(cfg, toolName, args)
+			_, err := executor.ExecuteTool(context.Background(), cfg, toolName, args)
 
 			if err == nil {
 				t.Errorf("Expected error for unknown tool %s, got nil", toolName)
@@ -177,7 +178,7 @@ func TestSpecialToolsDoNotUseCLI(t *testing.T) {
 
 	for _, tc := range specialTools {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := executor.ExecuteTool(cfg, tc.name, tc.args)
+			_, err := executor.ExecuteTool(context.Background(), cfg, tc.name, tc.args)
 
 			// May fail for other reasons, but not CLI execution
 			if err != nil {
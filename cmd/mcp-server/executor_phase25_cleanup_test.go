@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -118,7 +119,7 @@ func TestPhase25ToolsNoDoubleJQApplication(t *testing.T) {
 			args["scope"] = "session"
 
 			// Execute tool
-			result, err := executor.ExecuteTool(cfg, tc.name, args)
+			result, err := executor.ExecuteTool(context.Background(), cfg, tc.name, args)
 
 			// Should NOT get "expected an object but got: array" error
 			// This error indicates double jq application
@@ -159,7 +160,7 @@ func TestLegacyToolsRemoved(t *testing.T) {
 
 	for _, toolName := range legacyTools {
 		t.Run(toolName, func(t *testing.T) {
-			_, err := executor.ExecuteTool(cfg, toolName, map[string]interface{}{"scope": "project"})
+			_, err := executor.ExecuteTool(context.Background(), cfg, toolName, map[string]interface{}{"scope": "project"})
 
 			// Should return "unknown tool" error
 			require.Error(t, err)
@@ -173,16 +174,19 @@ func TestLegacyToolsRemoved(t *testing.T) {
 func TestPhase25ToolCount(t *testing.T) {
 	tools := getToolDefinitions()
 
-	// Expected: 17 tools total (Phase 27 Stage 27.5 update)
-	// - 10 convenience tools (Layer 1)
-	// - 3 utility tools (cleanup_temp_files, list_capabilities, get_capability)
+	// Expected: 25 tools total
+	// - 11 convenience tools (Layer 1, including query_security_audit)
+	// - 1 result tool (query_result)
+	// - 7 utility tools (cleanup_temp_files, list_capabilities, get_capability, get_server_metrics,
+	//   archive_sessions, export_sessions, import_sessions)
 	// - 4 two-stage query tools (get_session_directory, inspect_session_files, execute_stage2_query, get_session_metadata)
+	// - 2 catalog tools (list_projects, list_sessions)
 	//
 	// Phase 27 Removed: query, query_raw (simplified query interface)
 	// Phase 27 Added: inspect_session_files (Stage 27.3), execute_stage2_query (Stage 27.4), get_session_metadata (Stage 27.5)
 	// Phase 25 Removed: 5 legacy tools (query_tool_sequences, query_file_access, get_session_stats,
 	//                    query_project_state, query_successful_prompts)
-	expectedCount := 17
+	expectedCount := 25
 
 	actualCount := len(tools)
 	require.Equal(t, expectedCount, actualCount,
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -86,12 +87,10 @@ func TestNewToolExecutor(t *testing.T) {
 
 func TestNewToolPipelineConfig(t *testing.T) {
 	args := map[string]interface{}{
-		"jq_filter":          ".[] | .name",
-		"stats_only":         true,
-		"stats_first":        false,
-		"output_format":      "json",
-		"max_message_length": float64(120),
-		"content_summary":    true,
+		"jq_filter":     ".[] | .name",
+		"stats_only":    true,
+		"stats_first":   false,
+		"output_format": "json",
 	}
 
 	config := newToolPipelineConfig(args)
@@ -108,12 +107,6 @@ func TestNewToolPipelineConfig(t *testing.T) {
 	if config.outputFormat != "json" {
 		t.Fatalf("unexpected outputFormat: %s", config.outputFormat)
 	}
-	if config.maxMessageLength != 120 {
-		t.Fatalf("expected maxMessageLength to be 120, got %d", config.maxMessageLength)
-	}
-	if !config.contentSummary {
-		t.Fatal("expected contentSummary to be true")
-	}
 
 	defaults := newToolPipelineConfig(map[string]interface{}{})
 	if defaults.jqFilter != ".[]" {
@@ -122,33 +115,30 @@ func TestNewToolPipelineConfig(t *testing.T) {
 	if defaults.outputFormat != "jsonl" {
 		t.Fatalf("unexpected default outputFormat: %s", defaults.outputFormat)
 	}
-	if defaults.maxMessageLength != 0 {
-		t.Fatalf("expected default maxMessageLength to be 0, got %d", defaults.maxMessageLength)
-	}
 }
 
-func TestToolPipelineConfigRequiresMessageFilters(t *testing.T) {
+func TestQueryUserMessagesArgsRequiresMessageFilters(t *testing.T) {
 	cases := []struct {
 		name   string
-		cfg    toolPipelineConfig
+		args   queryUserMessagesArgs
 		expect bool
 	}{
 		{
 			name:   "no filters",
-			cfg:    toolPipelineConfig{},
+			args:   queryUserMessagesArgs{},
 			expect: false,
 		},
 		{
 			name: "max length",
-			cfg: toolPipelineConfig{
-				maxMessageLength: 80,
+			args: queryUserMessagesArgs{
+				MaxMessageLength: 80,
 			},
 			expect: true,
 		},
 		{
 			name: "content summary",
-			cfg: toolPipelineConfig{
-				contentSummary: true,
+			args: queryUserMessagesArgs{
+				ContentSummary: true,
 			},
 			expect: true,
 		},
@@ -156,7 +146,7 @@ func TestToolPipelineConfigRequiresMessageFilters(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			if got := tc.cfg.requiresMessageFilters(); got != tc.expect {
+			if got := tc.args.requiresMessageFilters(); got != tc.expect {
 				t.Fatalf("requiresMessageFilters() = %v, expect %v", got, tc.expect)
 			}
 		})
@@ -740,51 +730,6 @@ func TestApplyMessageFiltersToData(t *testing.T) {
 
 // TestBuildCommandAdditional removed - buildCommand function deleted as part of Phase 23 CLI removal
 
-// Test getSessionHash fallback behavior (env vars no longer used)
-func TestGetSessionHash(t *testing.T) {
-	// Save original env vars
-	origSessionID := os.Getenv("CC_SESSION_ID")
-	origProjectHash := os.Getenv("CC_PROJECT_HASH")
-	defer func() {
-		if origSessionID != "" {
-			os.Setenv("CC_SESSION_ID", origSessionID)
-		} else {
-			os.Unsetenv("CC_SESSION_ID")
-		}
-		if origProjectHash != "" {
-			os.Setenv("CC_PROJECT_HASH", origProjectHash)
-		} else {
-			os.Unsetenv("CC_PROJECT_HASH")
-		}
-	}()
-
-	// Clear env vars
-	os.Unsetenv("CC_SESSION_ID")
-	os.Unsetenv("CC_PROJECT_HASH")
-
-	cfg, _ := config.Load()
-
-	tests := []struct {
-		name         string
-		expectedHash string
-	}{
-		{
-			name:         "should return unknown when env vars not set",
-			expectedHash: "unknown",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := getSessionHash(cfg)
-
-			if result != tt.expectedHash {
-				t.Errorf("expected session hash '%s', got '%s'", tt.expectedHash, result)
-			}
-		})
-	}
-}
-
 // TestExecuteMetaCC removed - executeMetaCC function deleted as part of Phase 23 CLI removal
 // All query tools now use internal/query library directly. See executor_no_cli_test.go for
 // tests verifying that tools don't attempt CLI execution.
@@ -799,7 +744,7 @@ func TestQueryToolsNotRegistered(t *testing.T) {
 	}
 
 	// Test that query tool returns unknown tool error
-	_, err = executor.ExecuteTool(cfg, "query", map[string]interface{}{})
+	_, err = executor.ExecuteTool(context.Background(), cfg, "query", map[string]interface{}{})
 	if err == nil {
 		t.Error("expected error for query tool, got nil")
 	}
@@ -808,7 +753,7 @@ func TestQueryToolsNotRegistered(t *testing.T) {
 	}
 
 	// Test that query_raw tool returns unknown tool error
-	_, err = executor.ExecuteTool(cfg, "query_raw", map[string]interface{}{"jq_expression": "."})
+	_, err = executor.ExecuteTool(context.Background(), cfg, "query_raw", map[string]interface{}{"jq_expression": "."})
 	if err == nil {
 		t.Error("expected error for query_raw tool, got nil")
 	}
@@ -843,7 +788,7 @@ func TestShortcutQueryToolsRegistered(t *testing.T) {
 		t.Run(toolName, func(t *testing.T) {
 			// These tools should be registered, but will fail with "no sessions found"
 			// in test environment. We just need to verify they don't return "unknown tool" error.
-			_, err := executor.ExecuteTool(cfg, toolName, map[string]interface{}{})
+			_, err := executor.ExecuteTool(context.Background(), cfg, toolName, map[string]interface{}{})
 
 			// The error should NOT be "unknown tool"
 			if err != nil && strings.Contains(err.Error(), "unknown tool") {
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// field_stats.go summarizes the values of each field of a result set, so a
+// response can describe records it does not include
+
+const (
+	// maxTrackedValues is how many distinct values of a field are counted;
+	// fields with more are reported as high cardinality without top values
+	maxTrackedValues = 50
+	// topValuesPerField is how many of the most frequent values are listed
+	topValuesPerField = 5
+	// maxTopValueRunes truncates long string values in top value lists
+	maxTopValueRunes = 80
+)
+
+// timeRange is the earliest and latest value of a timestamp field
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// valueCount is a field value with the number of records holding it
+type valueCount struct {
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+}
+
+// fieldStats describes the values of one field across records
+type fieldStats struct {
+	// Type is string, number, boolean, object, array or mixed
+	Type string `json:"type"`
+	// Count is the number of records with the field, nulls included
+	Count int `json:"count"`
+	Nulls int `json:"nulls,omitempty"`
+	// NullRate is the share of records where the field is missing or null
+	NullRate float64 `json:"null_rate,omitempty"`
+	// Distinct is the number of distinct values, unless HighCardinality
+	Distinct        int          `json:"distinct,omitempty"`
+	HighCardinality bool         `json:"high_cardinality,omitempty"`
+	Top             []valueCount `json:"top,omitempty"`
+	Min             *float64     `json:"min,omitempty"`
+	Max             *float64     `json:"max,omitempty"`
+	Mean            *float64     `json:"mean,omitempty"`
+	// TimeRange is set when every value is an RFC 3339 timestamp
+	TimeRange *timeRange `json:"time_range,omitempty"`
+	// AvgBytes is the average JSON size of the field's values
+	AvgBytes int `json:"avg_bytes"`
+}
+
+// fieldAccumulator collects the values of one field
+type fieldAccumulator struct {
+	types   map[string]bool
+	count   int
+	nulls   int
+	bytes   int
+	values  map[string]*valueCount // keyed by JSON encoding
+	tooMany bool
+	numbers int
+	sum     float64
+	min     float64
+	max     float64
+	// Timestamps, until a value is not one
+	notTime  bool
+	earliest time.Time
+	latest   time.Time
+}
+
+// add records one value of the field
+func (a *fieldAccumulator) add(value interface{}) {
+	a.count++
+	encoded, _ := json.Marshal(value)
+	a.bytes += len(encoded)
+	if value == nil {
+		a.nulls++
+		return
+	}
+	a.types[jsonType(value)] = true
+
+	if n, ok := value.(float64); ok {
+		if a.numbers == 0 || n < a.min {
+			a.min = n
+		}
+		if a.numbers == 0 || n > a.max {
+			a.max = n
+		}
+		a.numbers++
+		a.sum += n
+	}
+	a.addTime(value)
+
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return // Only scalars have meaningful top values
+	}
+	if a.tooMany {
+		return
+	}
+	key := string(encoded)
+	if vc, ok := a.values[key]; ok {
+		vc.Count++
+		return
+	}
+	if len(a.values) == maxTrackedValues {
+		a.tooMany = true
+		a.values = nil
+		return
+	}
+	a.values[key] = &valueCount{Value: value, Count: 1}
+}
+
+// addTime tracks the range of a timestamp field
+func (a *fieldAccumulator) addTime(value interface{}) {
+	if a.notTime {
+		return
+	}
+	str, ok := value.(string)
+	if !ok {
+		a.notTime = true
+		return
+	}
+	t, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		a.notTime = true
+		return
+	}
+	if a.earliest.IsZero() || t.Before(a.earliest) {
+		a.earliest = t
+	}
+	if a.latest.IsZero() || t.After(a.latest) {
+		a.latest = t
+	}
+}
+
+// stats returns the summary of the collected values; total is the number of
+// records inspected
+func (a *fieldAccumulator) stats(total int) *fieldStats {
+	s := &fieldStats{
+		Type:     "null",
+		Count:    a.count,
+		Nulls:    a.nulls,
+		AvgBytes: a.bytes / max(a.count, 1),
+	}
+	if total > 0 {
+		s.NullRate = math.Round(float64(total-a.count+a.nulls)/float64(total)*100) / 100
+	}
+	if !a.notTime && !a.earliest.IsZero() {
+		s.TimeRange = &timeRange{
+			From: a.earliest.Format(time.RFC3339Nano),
+			To:   a.latest.Format(time.RFC3339Nano),
+		}
+	}
+	switch len(a.types) {
+	case 0:
+	case 1:
+		for t := range a.types {
+			s.Type = t
+		}
+	default:
+		s.Type = "mixed"
+	}
+
+	if a.numbers > 0 {
+		minimum, maximum := a.min, a.max
+		mean := math.Round(a.sum/float64(a.numbers)*100) / 100
+		s.Min, s.Max, s.Mean = &minimum, &maximum, &mean
+	}
+
+	if a.tooMany {
+		s.HighCardinality = true
+		return s
+	}
+	s.Distinct = len(a.values)
+	// Top values only help when values repeat
+	if s.Distinct == 0 || s.Distinct == a.count-a.nulls && s.Distinct > topValuesPerField {
+		return s
+	}
+	top := make([]valueCount, 0, len(a.values))
+	for _, vc := range a.values {
+		top = append(top, *vc)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return fmt.Sprint(top[i].Value) < fmt.Sprint(top[j].Value)
+	})
+	if len(top) > topValuesPerField {
+		top = top[:topValuesPerField]
+	}
+	for i := range top {
+		if str, ok := top[i].Value.(string); ok {
+			if runes := []rune(str); len(runes) > maxTopValueRunes {
+				top[i].Value = string(runes[:maxTopValueRunes]) + "..."
+			}
+		}
+	}
+	s.Top = top
+	return s
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64, int, int64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return "unknown"
+	}
+}
+
+// collectFieldStats summarizes the fields of object records. With depth > 1,
+// fields of nested objects are summarized too, under dotted paths (e.g.
+// "input.command") down to depth levels.
+func collectFieldStats(records []interface{}, depth int) map[string]*fieldStats {
+	accumulators := make(map[string]*fieldAccumulator)
+	var walk func(m map[string]interface{}, prefix string, level int)
+	walk = func(m map[string]interface{}, prefix string, level int) {
+		for field, value := range m {
+			path := prefix + field
+			a, ok := accumulators[path]
+			if !ok {
+				a = &fieldAccumulator{types: make(map[string]bool), values: make(map[string]*valueCount)}
+				accumulators[path] = a
+			}
+			a.add(value)
+			if nested, ok := value.(map[string]interface{}); ok && level < depth {
+				walk(nested, path+".", level+1)
+			}
+		}
+	}
+
+	total := 0
+	for _, record := range records {
+		if m, ok := record.(map[string]interface{}); ok {
+			total++
+			walk(m, "", 1)
+		}
+	}
+
+	stats := make(map[string]*fieldStats, len(accumulators))
+	for field, a := range accumulators {
+		stats[field] = a.stats(total)
+	}
+	return stats
+}
@@ -3,33 +3,63 @@ package main
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
+
+	"github.com/yaleh/meta-cc/pkg/output"
+)
+
+// file_reference.go describes a stored result without its records: the
+// summary carries per-field statistics (see field_stats.go) and a few sample
+// records, often enough to answer without opening the file.
+
+const (
+	// fieldStatsDepth is how many levels of nested objects get dotted paths
+	fieldStatsDepth = 3
+	// summarySampleRecords is how many sample records a summary shows
+	summarySampleRecords = 3
+	// maxSampleBytes skips sample records larger than this after truncation
+	maxSampleBytes = 600
+	// fileRefSummaryBytes bounds the JSON size of a summary; statistics of
+	// the deepest fields are dropped first when it is exceeded
+	fileRefSummaryBytes = 4096
+	// maxListedChunks caps the chunks listed in a response; the manifest
+	// lists all of them
+	maxListedChunks = 20
 )
 
-// FileReference provides metadata about a temporary JSONL file.
+// FileReference provides metadata about a stored JSONL file.
 // It helps Claude understand the structure and content of large query results
 // without loading the entire file into memory.
 type FileReference struct {
-	// Path is the absolute file path to the JSONL file
+	// Path is the absolute file path to the JSONL file, or to the manifest
+	// of a chunked result
 	Path string `json:"path"`
 
-	// SizeBytes is the file size in bytes
+	// SizeBytes is the file size in bytes (of all chunks when chunked)
 	SizeBytes int64 `json:"size_bytes"`
 
 	// LineCount is the number of JSONL records
 	LineCount int `json:"line_count"`
 
-	// Fields is an alphabetically sorted list of unique field names
+	// Fields is an alphabetically sorted list of unique field paths; fields of
+	// nested objects appear as dotted paths (e.g. "input.command")
 	Fields []string `json:"fields"`
 
 	// Summary contains statistics and preview data
 	Summary map[string]interface{} `json:"summary"`
+
+	// Chunks lists the chunk files of a chunked result, split along
+	// GroupedBy (session_id or day) where possible
+	Chunks    []output.ChunkMetadata `json:"chunks,omitempty"`
+	GroupedBy string                 `json:"grouped_by,omitempty"`
 }
 
 // generateFileReference creates a FileReference with metadata for a JSONL file.
 //
 // Parameters:
-//   - filePath: Absolute path to the JSONL file
+//   - filePath: Absolute path to the JSONL file or chunk manifest
 //   - data: Array of records written to the file
 //
 // Returns:
@@ -45,29 +75,44 @@ func generateFileReference(filePath string, data []interface{}) (*FileReference,
 		return nil, err
 	}
 
-	// Extract fields and summary
+	// Extract fields and summary from the JSON form of the records
+	data = normalizeRecords(data)
 	fields := extractFields(data)
 	summary := generateSummary(data)
 
-	return &FileReference{
+	fileRef := &FileReference{
 		Path:      filePath,
 		SizeBytes: stat.Size(),
 		LineCount: len(data),
 		Fields:    fields,
 		Summary:   summary,
-	}, nil
+	}
+	if filepath.Base(filePath) == chunkManifestFile {
+		manifest, err := readChunkManifest(filePath)
+		if err != nil {
+			return nil, err
+		}
+		fileRef.SizeBytes = 0
+		for _, chunk := range manifest.Chunks {
+			fileRef.SizeBytes += chunk.SizeBytes
+		}
+		fileRef.Chunks = manifest.Chunks
+		fileRef.GroupedBy = manifest.GroupedBy
+	}
+	return fileRef, nil
 }
 
-// extractFields extracts unique field names from JSONL records.
+// extractFields extracts unique field paths from JSONL records.
 //
 // Parameters:
 //   - records: Array of records (typically []map[string]interface{})
 //
 // Returns:
-//   - Alphabetically sorted list of unique field names
+//   - Alphabetically sorted list of unique field paths
 //
 // The function handles diverse schemas where different records may have
-// different fields. It collects all unique field names across all records.
+// different fields. It collects all unique field names across all records,
+// and the fields of nested objects as dotted paths down to fieldStatsDepth.
 func extractFields(records []interface{}) []string {
 	if len(records) == 0 {
 		return []string{}
@@ -75,13 +120,20 @@ func extractFields(records []interface{}) []string {
 
 	// Collect unique fields
 	fieldSet := make(map[string]bool)
+	var walk func(m map[string]interface{}, prefix string, level int)
+	walk = func(m map[string]interface{}, prefix string, level int) {
+		for field, value := range m {
+			fieldSet[prefix+field] = true
+			if nested, ok := value.(map[string]interface{}); ok && level < fieldStatsDepth {
+				walk(nested, prefix+field+".", level+1)
+			}
+		}
+	}
 
 	for _, record := range records {
 		// Try to cast to map
 		if recordMap, ok := record.(map[string]interface{}); ok {
-			for field := range recordMap {
-				fieldSet[field] = true
-			}
+			walk(recordMap, "", 1)
 		}
 	}
 
@@ -105,29 +157,146 @@ func extractFields(records []interface{}) []string {
 //   - Map containing:
 //   - record_count: Total number of records
 //   - preview: Compact JSON string of first record (max 100 chars)
+//   - field_stats: Per-field type, null rate, top values with counts,
+//     numeric min/max/mean and timestamp range, keyed by field path
+//   - samples: The first record, the first error and the last record, with
+//     long strings truncated
+//   - field_stats_omitted: Number of fields dropped to fit the size bound
 //
 // The summary provides Claude with context about the data without loading
-// the entire dataset. It's designed to serialize to <500 bytes total.
-// Preview is truncated to keep the FileReference size under constraint.
+// the entire dataset. Its JSON stays within fileRefSummaryBytes whatever the
+// number of records: samples and then the statistics of the deepest fields
+// are dropped first.
 func generateSummary(records []interface{}) map[string]interface{} {
 	summary := make(map[string]interface{})
 
 	// Always include record count
 	summary["record_count"] = len(records)
+	if len(records) == 0 {
+		return summary
+	}
 
-	// Add compact preview of first record (if records exist)
-	if len(records) > 0 {
-		// Serialize first record to compact JSON
-		firstJSON, err := json.Marshal(records[0])
-		if err == nil {
-			preview := string(firstJSON)
-			// Truncate if too long to stay under 500 byte constraint
-			if len(preview) > 100 {
-				preview = preview[:97] + "..."
-			}
-			summary["preview"] = preview
+	// Add compact preview of first record
+	firstJSON, err := json.Marshal(records[0])
+	if err == nil {
+		preview := string(firstJSON)
+		// Truncate if too long
+		if len(preview) > 100 {
+			preview = preview[:97] + "..."
 		}
+		summary["preview"] = preview
 	}
 
+	stats := collectFieldStats(records, fieldStatsDepth)
+	samples := sampleSummaryRecords(records)
+	if len(stats) > 0 {
+		summary["field_stats"] = stats
+	}
+	if len(samples) > 0 {
+		summary["samples"] = samples
+	}
+	fitSummary(summary, stats, samples)
 	return summary
 }
+
+// sampleSummaryRecords picks representative records: the first, the first
+// error and the last, in their original order
+func sampleSummaryRecords(records []interface{}) []interface{} {
+	picked := []int{0}
+	for i, record := range records {
+		if output.IsErrorRecord(record) {
+			picked = append(picked, i)
+			break
+		}
+	}
+	picked = append(picked, len(records)-1)
+	sort.Ints(picked)
+
+	samples := make([]interface{}, 0, summarySampleRecords)
+	for i, index := range picked {
+		if i > 0 && index == picked[i-1] || len(samples) == summarySampleRecords {
+			continue
+		}
+		sample := truncateStrings(records[index], maxTopValueRunes)
+		if encoded, err := json.Marshal(sample); err != nil || len(encoded) > maxSampleBytes {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// truncateStrings returns a copy of a JSON value with long strings shortened
+func truncateStrings(value interface{}, maxRunes int) interface{} {
+	switch v := value.(type) {
+	case string:
+		if runes := []rune(v); len(runes) > maxRunes {
+			return string(runes[:maxRunes]) + "..."
+		}
+		return v
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = truncateStrings(item, maxRunes)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = truncateStrings(item, maxRunes)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+// fitSummary drops samples, then the statistics of the deepest fields, until
+// the summary fits fileRefSummaryBytes
+func fitSummary(summary map[string]interface{}, stats map[string]*fieldStats, samples []interface{}) {
+	encoded, _ := json.Marshal(summary)
+	size := len(encoded)
+
+	for len(samples) > 0 && size > fileRefSummaryBytes {
+		last, _ := json.Marshal(samples[len(samples)-1])
+		samples = samples[:len(samples)-1]
+		size -= len(last) + 1
+		if len(samples) == 0 {
+			delete(summary, "samples")
+		} else {
+			summary["samples"] = samples
+		}
+	}
+	if size <= fileRefSummaryBytes {
+		return
+	}
+
+	paths := make([]string, 0, len(stats))
+	for path := range stats {
+		paths = append(paths, path)
+	}
+	// Deepest first, then reverse alphabetical
+	sort.Slice(paths, func(i, j int) bool {
+		di, dj := strings.Count(paths[i], "."), strings.Count(paths[j], ".")
+		if di != dj {
+			return di > dj
+		}
+		return paths[i] > paths[j]
+	})
+	omitted := 0
+	for _, path := range paths {
+		if size <= fileRefSummaryBytes {
+			break
+		}
+		entry, _ := json.Marshal(map[string]*fieldStats{path: stats[path]})
+		delete(stats, path)
+		// The entry's braces stand in for the separating comma and the
+		// field_stats_omitted counter added below
+		size -= len(entry) - 2
+		omitted++
+	}
+	summary["field_stats_omitted"] = omitted
+	if len(stats) == 0 {
+		delete(summary, "field_stats")
+	}
+}
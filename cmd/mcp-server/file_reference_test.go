@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -119,7 +120,7 @@ func TestGenerateFileReference(t *testing.T) {
 	}
 }
 
-// TestFileReferenceSize verifies <500 byte constraint
+// TestFileReferenceSize verifies the summary stays bounded whatever the data
 func TestFileReferenceSize(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -191,9 +192,10 @@ func TestFileReferenceSize(t *testing.T) {
 			t.Logf("FileReference JSON size: %d bytes", size)
 			t.Logf("FileReference JSON: %s", string(jsonBytes))
 
-			// Verify size constraint
-			if size > 500 {
-				t.Errorf("FileReference JSON size = %d bytes, want ≤500 bytes", size)
+			// Verify size constraint: the summary is bounded, the rest is
+			// path and field names
+			if size > fileRefSummaryBytes+500 {
+				t.Errorf("FileReference JSON size = %d bytes, want ≤%d bytes", size, fileRefSummaryBytes+500)
 			}
 		})
 	}
@@ -243,7 +245,7 @@ func TestExtractFields(t *testing.T) {
 					"user": map[string]interface{}{"name": "john"},
 				},
 			},
-			wantFields: []string{"id", "user"},
+			wantFields: []string{"id", "user", "user.name"},
 		},
 	}
 
@@ -371,6 +373,96 @@ func TestSummaryStatistics(t *testing.T) {
 	}
 }
 
+// TestSummaryFieldStats verifies per-field statistics and samples
+func TestSummaryFieldStats(t *testing.T) {
+	summary := generateSummary(normalizeRecords(bulkyToolCalls(30)))
+
+	stats := summary["field_stats"].(map[string]*fieldStats)
+	status := stats["status"]
+	if status.Type != "string" || len(status.Top) != 2 || status.Top[0] != (valueCount{Value: "success", Count: 24}) {
+		t.Errorf("unexpected status stats: %+v", status)
+	}
+	if stats["input"].NullRate != 1 || stats["error"].NullRate != 0 {
+		t.Errorf("unexpected null rates: input %v, error %v", stats["input"].NullRate, stats["error"].NullRate)
+	}
+	timestamps := stats["timestamp"].TimeRange
+	if timestamps == nil || timestamps.From != "2025-10-02T10:00:00Z" || timestamps.To != "2025-10-02T10:29:00Z" {
+		t.Errorf("unexpected timestamp range: %+v", timestamps)
+	}
+	if stats["tool_name"].TimeRange != nil {
+		t.Error("tool_name should have no time range")
+	}
+
+	// First record (an error) and last record
+	samples := summary["samples"].([]interface{})
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(samples))
+	}
+	last := samples[1].(map[string]interface{})
+	if last["uuid"] != "u029" || len(last["output"].(string)) != maxTopValueRunes+3 {
+		t.Errorf("unexpected last sample: %v", last)
+	}
+}
+
+// TestSummaryNestedFields verifies dotted paths of nested objects
+func TestSummaryNestedFields(t *testing.T) {
+	records := []interface{}{
+		map[string]interface{}{"input": map[string]interface{}{"command": "make", "timeout": 30.0}},
+		map[string]interface{}{"input": map[string]interface{}{"command": "make"}},
+		map[string]interface{}{"input": nil},
+	}
+	stats := generateSummary(records)["field_stats"].(map[string]*fieldStats)
+
+	if stats["input"].Type != "object" || stats["input"].Nulls != 1 {
+		t.Errorf("unexpected input stats: %+v", stats["input"])
+	}
+	command := stats["input.command"]
+	if command == nil || command.Count != 2 || command.Top[0] != (valueCount{Value: "make", Count: 2}) {
+		t.Errorf("unexpected input.command stats: %+v", command)
+	}
+	timeout := stats["input.timeout"]
+	if timeout == nil || *timeout.Max != 30 || timeout.NullRate != 0.67 {
+		t.Errorf("unexpected input.timeout stats: %+v", timeout)
+	}
+}
+
+// TestSummaryBounded verifies wide records are cut to the size bound
+func TestSummaryBounded(t *testing.T) {
+	records := make([]interface{}, 100)
+	for i := range records {
+		record := map[string]interface{}{"id": float64(i)}
+		nested := map[string]interface{}{}
+		for f := 0; f < 60; f++ {
+			record[fmt.Sprintf("field_%02d", f)] = fmt.Sprintf("value-%d", i%3)
+			nested[fmt.Sprintf("inner_%02d", f)] = float64(f)
+		}
+		record["nested"] = nested
+		records[i] = record
+	}
+
+	summary := generateSummary(records)
+	encoded, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if len(encoded) > fileRefSummaryBytes {
+		t.Errorf("summary is %d bytes, want ≤%d", len(encoded), fileRefSummaryBytes)
+	}
+	if _, ok := summary["samples"]; ok {
+		t.Error("samples should be dropped before field statistics")
+	}
+	stats := summary["field_stats"].(map[string]*fieldStats)
+	if _, ok := stats["field_00"]; !ok {
+		t.Error("top-level fields should be kept before nested ones")
+	}
+	if _, ok := stats["nested.inner_59"]; ok {
+		t.Error("deepest fields should be dropped first")
+	}
+	if summary["field_stats_omitted"].(int) != 122-len(stats) {
+		t.Errorf("field_stats_omitted = %v, kept %d of 122", summary["field_stats_omitted"], len(stats))
+	}
+}
+
 // Helper functions
 
 // writeTestJSONL writes test data to a JSONL file
@@ -1,5 +1,7 @@
 package main
 
+import "github.com/yaleh/meta-cc/internal/query"
+
 const (
 	// DefaultMaxMessageLength is the default for max_message_length parameter.
 	// Set to 0 to disable truncation (use hybrid output mode for large results).
@@ -96,12 +98,18 @@ func ApplyContentSummary(messages []interface{}) []interface{} {
 			}
 		}
 
-		// Create summary object
-		summary[i] = map[string]interface{}{
+		// Create summary object, keeping provenance of cross-project results
+		entry := map[string]interface{}{
 			"turn_sequence":   msgMap["turn_sequence"],
 			"timestamp":       msgMap["timestamp"],
 			"content_preview": preview,
 		}
+		for _, field := range []string{query.ProjectPathField, query.SessionIDField} {
+			if v, ok := msgMap[field]; ok {
+				entry[field] = v
+			}
+		}
+		summary[i] = entry
 	}
 
 	return summary
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yaleh/meta-cc/internal/bundle"
+	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/locator"
+)
+
+// handlers_bundle.go implements the tools that move sessions between machines
+// as tar.gz bundles
+
+// exportSessionsArgs are the arguments of export_sessions
+type exportSessionsArgs struct {
+	Sessions []string `json:"sessions" desc:"Session IDs to export (default: sessions of the project)"`
+	Project  string   `json:"project" desc:"Project path, directory name or glob (default: current project)"`
+	Limit    int      `json:"limit" default:"0" desc:"Export only the newest N sessions (default: 0 for all)"`
+	Output   string   `json:"output" path:"write" desc:"Bundle file name, written to the exports directory of the output store (default: a new .tar.gz there)"`
+	Name     string   `json:"name" desc:"Label stored in the bundle manifest"`
+}
+
+// handleExportSessions implements export_sessions tool
+// Packs the selected sessions and a manifest into a tar.gz bundle
+func handleExportSessions(ctx context.Context, args exportSessionsArgs) (interface{}, error) {
+	if args.Limit < 0 {
+		return nil, fmt.Errorf("limit must be non-negative (got %d): %w", args.Limit, mcerrors.ErrInvalidInput)
+	}
+	if len(args.Sessions) > 0 && args.Project != "" {
+		return nil, fmt.Errorf("sessions and project are mutually exclusive: %w", mcerrors.ErrInvalidInput)
+	}
+
+	files, err := exportFiles(args.Sessions, args.Project)
+	if err != nil {
+		return nil, err
+	}
+	if args.Limit > 0 && len(files) > args.Limit {
+		files = files[:args.Limit]
+	}
+
+	// A given output was confined to the exports directory before the call
+	output := args.Output
+	if output == "" {
+		dir := exportDir()
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create exports directory %s: %w", dir, mcerrors.ErrFileIO)
+		}
+		output = filepath.Join(dir, bundle.DefaultFileName(time.Now()))
+	}
+	manifest, err := bundle.ExportFile(output, args.Name, files)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat bundle %s: %w", output, mcerrors.ErrFileIO)
+	}
+
+	return map[string]interface{}{
+		"bundle":        output,
+		"size_bytes":    info.Size(),
+		"session_count": len(manifest.Sessions),
+		"manifest":      manifest,
+	}, nil
+}
+
+// exportDir is where export_sessions writes bundles: a directory of the
+// output store, which tools may read but the store never evicts
+func exportDir() string {
+	return filepath.Join(outputStoreFor(cfg).dir, "exports")
+}
+
+// exportFiles resolves the session files export_sessions packs, newest first
+func exportFiles(ids []string, project string) ([]string, error) {
+	if len(ids) > 0 {
+		loc := locator.NewSessionLocator()
+		files := make([]string, 0, len(ids))
+		for _, id := range ids {
+			path, err := loc.FromSessionID(id)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", err, mcerrors.ErrNotFound)
+			}
+			files = append(files, path)
+		}
+		return files, nil
+	}
+
+	src := querySource{scope: "project"}
+	if project != "" {
+		src = querySource{scope: scopeAll, projects: []string{project}}
+	}
+	_, files, err := src.files()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sessions: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no sessions to export: %w", mcerrors.ErrNotFound)
+	}
+	return files, nil
+}
+
+// importSessionsArgs are the arguments of import_sessions
+type importSessionsArgs struct {
+	Bundle string `json:"bundle" mcp:"required" path:"read" desc:"Path of a bundle created by export_sessions; it must be under the exports directory, the Claude projects root or META_CC_ALLOWED_ROOTS"`
+}
+
+// handleImportSessions implements import_sessions tool
+// Extracts a bundle into the bundles directory, where every tool finds its
+// sessions next to the local projects
+func handleImportSessions(ctx context.Context, cfg *config.Config, args importSessionsArgs) (interface{}, error) {
+	if args.Bundle == "" {
+		return nil, fmt.Errorf("bundle is required: %w", mcerrors.ErrInvalidInput)
+	}
+	dir := locator.BundlesDir()
+	if dir == "" {
+		return nil, fmt.Errorf("bundles directory not configured (set META_CC_BUNDLES_DIR): %w", mcerrors.ErrInvalidInput)
+	}
+
+	imported, err := bundle.Import(args.Bundle, dir, bundleImportLimit(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return imported, nil
+}
+
+// bundleImportLimit returns the import size limit configured by cfg. A nil cfg
+// or one whose bundle settings were never loaded (MaxImportMB is validated to
+// be positive) uses the environment and its defaults.
+func bundleImportLimit(cfg *config.Config) int64 {
+	if cfg == nil || cfg.Bundle.MaxImportMB <= 0 {
+		if loaded, err := config.Load(); err == nil {
+			cfg = loaded
+		} else {
+			cfg = &config.Config{Bundle: config.BundleConfig{MaxImportMB: 1024}}
+		}
+	}
+	return cfg.Bundle.MaxImportBytes()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yaleh/meta-cc/internal/bundle"
+	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/query"
+)
+
+func TestExportImportSessions(t *testing.T) {
+	root := setupProjectsRoot(t)
+	t.Setenv("META_CC_BUNDLES_DIR", t.TempDir())
+	t.Setenv("META_CC_OUTPUT_DIR", t.TempDir())
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	// A bare file name lands in the exports directory
+	text, err := NewToolExecutor().ExecuteTool(context.Background(), cfg, "export_sessions", map[string]interface{}{
+		"project": "-work-api",
+		"output":  "api.tar.gz",
+		"name":    "api errors",
+	})
+	require.NoError(t, err)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(text), &response))
+	output := response["bundle"].(string)
+	require.Equal(t, filepath.Join(exportDir(), "api.tar.gz"), output)
+	require.Equal(t, 1.0, response["session_count"])
+	manifest := response["manifest"].(map[string]interface{})
+	require.Equal(t, "/work/api", manifest["sessions"].([]interface{})[0].(map[string]interface{})["project_path"])
+
+	// The teammate's machine has no local copy of the project
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "-work-api")))
+
+	// Exported bundles pass the path policy of import_sessions
+	text, err = NewToolExecutor().ExecuteTool(context.Background(), cfg, "import_sessions", map[string]interface{}{"bundle": output})
+	require.NoError(t, err)
+	var imported bundle.Imported
+	require.NoError(t, json.Unmarshal([]byte(text), &imported))
+	require.False(t, imported.Existing)
+
+	// Imported sessions are found by catalog and query tools
+	result, err := handleListSessions(context.Background(), listSessionsArgs{Project: "-work-api"})
+	require.NoError(t, err)
+	headers := result.(map[string]interface{})["sessions"].([]query.SessionHeader)
+	require.Len(t, headers, 1)
+	require.Equal(t, "s-api", headers[0].SessionID)
+
+	results, err := NewToolExecutor().handleQueryToolErrors(context.Background(), nil, querySource{scope: scopeAll}, queryLimitArgs{})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	result, err = handleListProjects(context.Background(), listProjectsArgs{Projects: []string{"-work-api"}})
+	require.NoError(t, err)
+	projects := result.(map[string]interface{})["projects"].([]projectSummary)
+	require.Len(t, projects, 1)
+	require.Equal(t, imported.Dir, projects[0].Bundle)
+
+	// Bundles are read-only: archiving skips them
+	result, err = handleArchiveSessions(context.Background(), archiveSessionsArgs{Days: 1, Project: "-work-api"})
+	require.NoError(t, err)
+	require.Equal(t, 0, result.(map[string]interface{})["session_count"])
+}
+
+func TestExportSessionsByID(t *testing.T) {
+	setupProjectsRoot(t)
+	t.Setenv("META_CC_OUTPUT_DIR", t.TempDir())
+
+	// Without output, bundles are written to the exports directory
+	result, err := handleExportSessions(context.Background(), exportSessionsArgs{Sessions: []string{"s-web"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.(map[string]interface{})["session_count"])
+	require.Equal(t, exportDir(), filepath.Dir(result.(map[string]interface{})["bundle"].(string)))
+
+	_, err = handleExportSessions(context.Background(), exportSessionsArgs{Sessions: []string{"missing"}})
+	require.True(t, errors.Is(err, mcerrors.ErrNotFound), "got %v", err)
+
+	_, err = handleExportSessions(context.Background(), exportSessionsArgs{Sessions: []string{"s-web"}, Project: "web"})
+	require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "got %v", err)
+}
+
+func TestImportSessionsRejectsInvalidBundle(t *testing.T) {
+	t.Setenv("META_CC_BUNDLES_DIR", t.TempDir())
+	t.Setenv("META_CC_OUTPUT_DIR", t.TempDir())
+	require.NoError(t, os.MkdirAll(exportDir(), 0700))
+	path := filepath.Join(exportDir(), "bad.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("not a bundle"), 0644))
+
+	_, err := handleImportSessions(context.Background(), nil, importSessionsArgs{Bundle: path})
+	require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "got %v", err)
+}
+
+func TestBundleToolsStayInAllowedRoots(t *testing.T) {
+	setupProjectsRoot(t)
+	t.Setenv("META_CC_OUTPUT_DIR", t.TempDir())
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	executor := NewToolExecutor()
+	bashrc := filepath.Join(t.TempDir(), ".bashrc")
+	require.NoError(t, os.WriteFile(bashrc, []byte("export PATH"), 0644))
+
+	for _, output := range []string{bashrc, "../.bashrc", filepath.Join(exportDir(), "..", "x.tar.gz")} {
+		_, err := executor.ExecuteTool(context.Background(), cfg, "export_sessions", map[string]interface{}{"sessions": []interface{}{"s-web"}, "output": output})
+		require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "output %s: got %v", output, err)
+	}
+	data, err := os.ReadFile(bashrc)
+	require.NoError(t, err)
+	require.Equal(t, "export PATH", string(data))
+
+	_, err = executor.ExecuteTool(context.Background(), cfg, "import_sessions", map[string]interface{}{"bundle": bashrc})
+	require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "got %v", err)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/locator"
+	"github.com/yaleh/meta-cc/internal/query"
+	"github.com/yaleh/meta-cc/internal/sessionio"
+)
+
+// handlers_catalog.go implements the catalog tools used to browse projects and
+// sessions before choosing files for Stage 2 queries
+
+// listProjectsArgs are the arguments of list_projects
+type listProjectsArgs struct {
+	Projects []string `json:"projects" desc:"Only these projects (paths, names or globs, e.g. '/home/me/work/*' or 'meta-cc')"`
+}
+
+// projectSummary describes one project directory under the projects root
+type projectSummary struct {
+	Name           string `json:"name"`
+	Path           string `json:"path"`
+	PathFromName   bool   `json:"path_from_name,omitempty"`
+	Directory      string `json:"directory"`
+	Bundle         string `json:"bundle,omitempty"`
+	SessionCount   int    `json:"session_count"`
+	TotalSizeBytes int64  `json:"total_size_bytes"`
+	LastModified   string `json:"last_modified,omitempty"`
+}
+
+// handleListProjects implements list_projects tool
+// Returns every project with sessions, most recently active first
+func handleListProjects(ctx context.Context, args listProjectsArgs) (interface{}, error) {
+	loc := locator.NewSessionLocator()
+	projects, err := loc.ListProjects(args.Projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+
+	summaries := make([]projectSummary, 0, len(projects))
+	for _, p := range projects {
+		files, err := getJSONLFilesIn(p.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list sessions of %s: %w", p.Name, err)
+		}
+		metadata := collectFilesMetadata(files)
+
+		summary := projectSummary{
+			Name:           p.Name,
+			Directory:      p.Path,
+			Bundle:         p.Bundle,
+			SessionCount:   metadata.FileCount,
+			TotalSizeBytes: metadata.TotalSize,
+			LastModified:   metadata.NewestFile,
+		}
+		// The directory name is a lossy hash of the project path; the cwd
+		// recorded in the newest session is the exact original path
+		if len(files) > 0 {
+			summary.Path = query.ReadSessionCWD(files[0])
+		}
+		if summary.Path == "" {
+			summary.Path = decodeProjectName(p.Name)
+			summary.PathFromName = true
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].LastModified > summaries[j].LastModified
+	})
+
+	return map[string]interface{}{
+		"projects_root": loc.ProjectsRoot(),
+		"project_count": len(summaries),
+		"projects":      summaries,
+	}, nil
+}
+
+// decodeProjectName guesses a project path from its directory name. Dashes in
+// the original path are indistinguishable from separators, so this is a
+// best-effort fallback for projects whose sessions record no cwd.
+func decodeProjectName(name string) string {
+	return strings.ReplaceAll(name, "-", string(filepath.Separator))
+}
+
+// listSessionsArgs are the arguments of list_sessions
+type listSessionsArgs struct {
+	Project string `json:"project" desc:"Project path, directory name or glob (default: current project)"`
+	Limit   int    `json:"limit" default:"20" desc:"Max sessions, newest first (default: 20, 0 for all)"`
+}
+
+// handleListSessions implements list_sessions tool
+// Returns a header summary per session (prompt, title, time range, turns,
+// tokens, branches, versions), newest first
+func handleListSessions(ctx context.Context, args listSessionsArgs) (interface{}, error) {
+	if args.Limit < 0 {
+		return nil, fmt.Errorf("limit must be non-negative (got %d): %w", args.Limit, mcerrors.ErrInvalidInput)
+	}
+
+	src := querySource{scope: "project"}
+	if args.Project != "" {
+		src = querySource{scope: scopeAll, projects: []string{args.Project}}
+	}
+	_, files, err := src.files()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sessions: %w", err)
+	}
+
+	total := len(files)
+	if args.Limit > 0 && len(files) > args.Limit {
+		files = files[:args.Limit]
+	}
+
+	headers, err := query.ScanSessionHeaders(files, scanProgressFunc(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"session_count": total,
+		"returned":      len(headers),
+		"sessions":      headers,
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// archiveSessionsArgs are the arguments of archive_sessions
+type archiveSessionsArgs struct {
+	Days    int    `json:"days" default:"30" desc:"Archive sessions last modified more than this many days ago (default: 30)"`
+	Project string `json:"project" desc:"Project path, directory name or glob (default: current project)"`
+	DryRun  bool   `json:"dry_run" desc:"List the sessions that would be archived without compressing them (default: false)"`
+}
+
+// projectArchive reports the sessions archived in one project directory
+type projectArchive struct {
+	Directory string                      `json:"directory"`
+	Manifest  string                      `json:"manifest,omitempty"`
+	Sessions  []sessionio.ArchivedSession `json:"sessions"`
+}
+
+// handleArchiveSessions implements archive_sessions tool
+// Gzips sessions older than the given age in place and records them in a
+// per-project manifest; archived sessions stay readable by every tool
+func handleArchiveSessions(ctx context.Context, args archiveSessionsArgs) (interface{}, error) {
+	if args.Days < 1 {
+		return nil, fmt.Errorf("days must be at least 1 (got %d): %w", args.Days, mcerrors.ErrInvalidInput)
+	}
+
+	dirs, err := archiveDirs(args.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := sessionio.ArchiveOptions{
+		OlderThan: time.Duration(args.Days) * 24 * time.Hour,
+		DryRun:    args.DryRun,
+	}
+	projects := make([]projectArchive, 0, len(dirs))
+	var count int
+	var originalBytes, archivedBytes int64
+	for _, dir := range dirs {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		sessions, err := sessionio.ArchiveDir(dir, opts)
+		for _, s := range sessions {
+			count++
+			originalBytes += s.OriginalSize
+			archivedBytes += s.ArchivedSize
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to archive sessions in %s after %d archived: %v: %w", dir, count, err, mcerrors.ErrFileIO)
+		}
+		if len(sessions) == 0 {
+			continue
+		}
+		archive := projectArchive{Directory: dir, Sessions: sessions}
+		if !args.DryRun {
+			archive.Manifest = filepath.Join(dir, sessionio.ManifestName)
+		}
+		projects = append(projects, archive)
+	}
+
+	result := map[string]interface{}{
+		"days":           args.Days,
+		"dry_run":        args.DryRun,
+		"session_count":  count,
+		"original_bytes": originalBytes,
+		"projects":       projects,
+	}
+	if !args.DryRun {
+		result["archived_bytes"] = archivedBytes
+		result["saved_bytes"] = originalBytes - archivedBytes
+	}
+	return result, nil
+}
+
+// archiveDirs resolves the project directories archive_sessions works on
+func archiveDirs(project string) ([]string, error) {
+	loc := locator.NewSessionLocator()
+	if project == "" {
+		dir, err := getQueryBaseDir("project")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get base directory: %w", err)
+		}
+		// Imported bundles are read-only
+		if filepath.Dir(dir) != loc.ProjectsRoot() {
+			return nil, fmt.Errorf("current project has no local sessions in %s: %w", loc.ProjectsRoot(), mcerrors.ErrNotFound)
+		}
+		return []string{dir}, nil
+	}
+
+	projects, err := loc.ListProjects([]string{project})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("no projects with sessions found in %s matching %q: %w", loc.ProjectsRoot(), project, mcerrors.ErrNotFound)
+	}
+	dirs := make([]string, 0, len(projects))
+	for _, p := range projects {
+		// Imported bundles are read-only
+		if p.Bundle == "" {
+			dirs = append(dirs, p.Path)
+		}
+	}
+	return dirs, nil
+}
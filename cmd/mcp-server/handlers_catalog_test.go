@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/query"
+)
+
+func TestListProjects(t *testing.T) {
+	root := setupProjectsRoot(t)
+
+	// A project whose sessions record no cwd falls back to its directory name
+	noCWD := filepath.Join(root, "-tmp-scratch")
+	require.NoError(t, os.MkdirAll(noCWD, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(noCWD, "s.jsonl"), []byte(`{"type":"summary","summary":"x"}`+"\n"), 0644))
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(noCWD, "s.jsonl"), old, old))
+
+	result, err := handleListProjects(context.Background(), listProjectsArgs{})
+	require.NoError(t, err)
+	response := result.(map[string]interface{})
+	require.Equal(t, 3, response["project_count"])
+
+	projects := response["projects"].([]projectSummary)
+	byName := map[string]projectSummary{}
+	for _, p := range projects {
+		byName[p.Name] = p
+	}
+	require.Equal(t, "/work/api", byName["-work-api"].Path)
+	require.False(t, byName["-work-api"].PathFromName)
+	require.Equal(t, 1, byName["-work-api"].SessionCount)
+	require.Equal(t, filepath.FromSlash("/tmp/scratch"), byName["-tmp-scratch"].Path)
+	require.True(t, byName["-tmp-scratch"].PathFromName)
+	require.Equal(t, "-tmp-scratch", projects[len(projects)-1].Name, "least recently active project comes last")
+
+	result, err = handleListProjects(context.Background(), listProjectsArgs{Projects: []string{"web"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.(map[string]interface{})["project_count"])
+}
+
+func TestListSessions(t *testing.T) {
+	testData := `{"type":"user","cwd":"/work/app","gitBranch":"main","version":"1.0.0","timestamp":"2025-10-02T10:00:00Z","message":{"role":"user","content":"Refactor the parser"}}
+{"type":"assistant","timestamp":"2025-10-02T10:05:00Z","message":{"id":"m1","role":"assistant","content":[],"usage":{"input_tokens":4,"output_tokens":2}}}
+`
+	projectPath := setupTestSessionDir(t, testData)
+	originalWd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(projectPath))
+	defer func() { require.NoError(t, os.Chdir(originalWd)) }()
+
+	result, err := handleListSessions(context.Background(), listSessionsArgs{})
+	require.NoError(t, err)
+	response := result.(map[string]interface{})
+	require.Equal(t, 1, response["session_count"])
+
+	sessions := response["sessions"].([]query.SessionHeader)
+	require.Len(t, sessions, 1)
+	require.Equal(t, "test-session", sessions[0].SessionID)
+	require.Equal(t, "Refactor the parser", sessions[0].FirstPrompt)
+	require.Equal(t, int64(300), sessions[0].DurationSeconds)
+	require.Equal(t, int64(6), sessions[0].Tokens.Total())
+	require.Equal(t, []string{"main"}, sessions[0].GitBranches)
+
+	_, err = handleListSessions(context.Background(), listSessionsArgs{Limit: -1})
+	require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "unexpected error: %v", err)
+}
+
+func TestListSessionsOfNamedProjectViaExecutor(t *testing.T) {
+	setupProjectsRoot(t)
+
+	output, err := NewToolExecutor().ExecuteTool(context.Background(), nil, "list_sessions", map[string]interface{}{
+		"project": "api",
+		"limit":   float64(1),
+	})
+	require.NoError(t, err)
+
+	var response struct {
+		SessionCount int                   `json:"session_count"`
+		Sessions     []query.SessionHeader `json:"sessions"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(output), &response))
+	require.Equal(t, 1, response.SessionCount)
+	require.Equal(t, "/work/api", response.Sessions[0].ProjectPath)
+	require.Equal(t, "s-api", response.Sessions[0].SessionID)
+
+	_, err = handleListSessions(context.Background(), listSessionsArgs{Project: "missing"})
+	require.True(t, errors.Is(err, mcerrors.ErrNotFound), "unexpected error: %v", err)
+}
+
+func TestArchiveSessions(t *testing.T) {
+	root := setupProjectsRoot(t)
+	apiSession := filepath.Join(root, "-work-api", "s-api.jsonl")
+	old := time.Now().Add(-40 * 24 * time.Hour)
+	require.NoError(t, os.Chtimes(apiSession, old, old))
+
+	// Dry run lists the old session and leaves it alone
+	result, err := handleArchiveSessions(context.Background(), archiveSessionsArgs{Days: 30, Project: "*", DryRun: true})
+	require.NoError(t, err)
+	response := result.(map[string]interface{})
+	require.Equal(t, 1, response["session_count"])
+	require.NotContains(t, response, "saved_bytes")
+	require.FileExists(t, apiSession)
+
+	result, err = handleArchiveSessions(context.Background(), archiveSessionsArgs{Days: 30, Project: "*"})
+	require.NoError(t, err)
+	response = result.(map[string]interface{})
+	require.Equal(t, 1, response["session_count"])
+	projects := response["projects"].([]projectArchive)
+	require.Len(t, projects, 1)
+	require.Equal(t, "s-api.jsonl.gz", projects[0].Sessions[0].Archive)
+	require.FileExists(t, filepath.Join(root, "-work-api", "meta-cc-archive.json"))
+	require.NoFileExists(t, apiSession)
+
+	// Archived sessions are still read by queries, catalog and Stage 1 tools
+	results, err := NewToolExecutor().handleQueryToolErrors(context.Background(), nil, querySource{scope: scopeAll}, queryLimitArgs{})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	result, err = handleListSessions(context.Background(), listSessionsArgs{Project: "-work-api"})
+	require.NoError(t, err)
+	headers := result.(map[string]interface{})["sessions"].([]query.SessionHeader)
+	require.Len(t, headers, 1)
+	require.Equal(t, "s-api", headers[0].SessionID)
+	require.True(t, headers[0].Archived)
+	require.Equal(t, "/work/api", headers[0].ProjectPath)
+
+	metadata, err := collectDirectoryMetadata(filepath.Join(root, "-work-api"))
+	require.NoError(t, err)
+	require.Equal(t, 1, metadata.FileCount)
+}
+
+func TestArchiveSessionsRejectsInvalidDays(t *testing.T) {
+	setupProjectsRoot(t)
+
+	_, err := handleArchiveSessions(context.Background(), archiveSessionsArgs{Days: 0, Project: "*"})
+	require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "got %v", err)
+
+	_, err = handleArchiveSessions(context.Background(), archiveSessionsArgs{Days: 30, Project: "no-such-project"})
+	require.True(t, errors.Is(err, mcerrors.ErrNotFound), "got %v", err)
+}
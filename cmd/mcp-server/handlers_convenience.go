@@ -1,177 +1,195 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"strings"
 
 	"github.com/yaleh/meta-cc/internal/config"
+	"github.com/yaleh/meta-cc/internal/query"
 )
 
 // handlers_convenience.go implements the 10 convenience tools (Layer 1)
 // These tools wrap executeQuery() with pre-configured jq expressions
 // Phase 27 Stage 27.1: Updated to use executeQuery() instead of handleQuery()
 
+// queryLimitArgs are the arguments that bound the results of query tools: a
+// limit, or a random sample of every match (see sampling.go)
+type queryLimitArgs struct {
+	Limit      int    `json:"limit" desc:"Max results (no limit by default, rely on hybrid output mode). With sample, caps the sample."`
+	Sample     int    `json:"sample" desc:"Return N records drawn uniformly at random from all matches, in their original order, instead of the first ones. The response reports the population and how the sample was drawn."`
+	StratifyBy string `json:"stratify_by" desc:"Stratify sample by this field or jq expression (e.g. 'tool_name', 'sessionId', '.message.content[0].name'): every value gets a share proportional to its count, at least one record"`
+	Seed       int    `json:"seed" desc:"Seed of sample; the same seed over the same data draws the same records (default: 0)"`
+}
+
+// queryUserMessagesArgs are the arguments of query_user_messages
+type queryUserMessagesArgs struct {
+	Pattern          string `json:"pattern" mcp:"required" desc:"Regex pattern to match (required)"`
+	ContentType      string `json:"content_type" mcp:"hidden" enum:"string,array" default:"string"`
+	MaxMessageLength int    `json:"max_message_length" desc:"Max chars per message content (default: 0 = no truncation, rely on hybrid mode for large results)"`
+	ContentSummary   bool   `json:"content_summary" desc:"Return only turn/timestamp/preview (100 chars), skip full content. Use hybrid mode instead for better information preservation."`
+	queryLimitArgs
+}
+
+// requiresMessageFilters reports whether results need truncation or summarizing
+func (a queryUserMessagesArgs) requiresMessageFilters() bool {
+	return a.MaxMessageLength > 0 || a.ContentSummary
+}
+
+// queryToolsArgs are the arguments of query_tools
+type queryToolsArgs struct {
+	Tool   string `json:"tool" desc:"Filter by tool name"`
+	Status string `json:"status" enum:"error,success" desc:"Filter by status (error/success)"`
+	queryLimitArgs
+}
+
+// querySummariesArgs are the arguments of query_summaries
+type querySummariesArgs struct {
+	Keyword string `json:"keyword" desc:"Keyword to search in summary (case-insensitive)"`
+	queryLimitArgs
+}
+
+// queryToolBlocksArgs are the arguments of query_tool_blocks
+type queryToolBlocksArgs struct {
+	BlockType string `json:"block_type" mcp:"required" enum:"tool_use,tool_result" desc:"Block type: 'tool_use' or 'tool_result' (required)"`
+	queryLimitArgs
+}
+
 // handleQueryUserMessages implements query_user_messages convenience tool
 // Maps to Query 1 from frequent-jsonl-queries.md
-func (e *ToolExecutor) handleQueryUserMessages(cfg *config.Config, scope string, args map[string]interface{}) ([]interface{}, error) {
-	pattern := getStringParam(args, "pattern", "")
-	contentType := getStringParam(args, "content_type", "string")
-	limit := getIntParam(args, "limit", 0)
-
+func (e *ToolExecutor) handleQueryUserMessages(ctx context.Context, cfg *config.Config, src querySource, args queryUserMessagesArgs) ([]interface{}, error) {
 	// Build jq filter based on content type
 	var jqFilter string
-	if contentType == "string" {
-		jqFilter = `select(.type == "user" and (.message.content | type == "string"))`
-	} else {
+	if args.ContentType == "array" {
 		jqFilter = `select(.type == "user" and (.message.content | type == "array"))`
+	} else {
+		jqFilter = `select(.type == "user" and (.message.content | type == "string"))`
 	}
 
 	// Add pattern filter if provided
-	if pattern != "" {
-		escapedPattern := escapeJQ(pattern)
+	if args.Pattern != "" {
+		escapedPattern := query.EscapeJQ(args.Pattern)
 		jqFilter = fmt.Sprintf(`%s | select(.message.content | test("%s"))`, jqFilter, escapedPattern)
 	}
 
-	// Call executeQuery directly
-	return e.executeQuery(scope, jqFilter, limit)
+	messages, err := e.executeQuery(ctx, src, jqFilter, args.queryLimitArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.requiresMessageFilters() {
+		messages = e.applyMessageFiltersToData(messages, args.MaxMessageLength, args.ContentSummary)
+	}
+	return messages, nil
 }
 
 // handleQueryTools implements query_tools convenience tool
 // Maps to Query 2 from frequent-jsonl-queries.md
-func (e *ToolExecutor) handleQueryTools(cfg *config.Config, scope string, args map[string]interface{}) ([]interface{}, error) {
-	toolName := getStringParam(args, "tool_name", "")
-	limit := getIntParam(args, "limit", 0)
+func (e *ToolExecutor) handleQueryTools(ctx context.Context, cfg *config.Config, src querySource, args queryToolsArgs) ([]interface{}, error) {
+	jqFilter := query.ToolCallsFilter(args.Tool)
 
-	// Base filter for all tool_use blocks
-	jqFilter := `select(.type == "assistant") | select(.message.content[] | .type == "tool_use")`
+	if args.Status == "" {
+		return e.executeQuery(ctx, src, jqFilter, args.queryLimitArgs, query.ToolCallTokens...)
+	}
 
-	// Add tool name filter if provided
-	if toolName != "" {
-		escapedTool := escapeJQ(toolName)
-		jqFilter = fmt.Sprintf(`%s | select(.message.content[] | select(.type == "tool_use" and .name == "%s"))`, jqFilter, escapedTool)
+	// Status is recorded on the tool_result in the following user message,
+	// so collect failed tool_use IDs first and filter calls against them
+	errorIDs, err := e.executeQuery(ctx, src, query.ToolErrorIDsFilter, queryLimitArgs{}, query.ToolErrorTokens...)
+	if err != nil {
+		return nil, err
 	}
+	failed := query.FailedToolUseIDs(errorIDs)
 
-	return e.executeQuery(scope, jqFilter, limit)
+	calls, err := e.executeQuery(ctx, src, jqFilter, queryLimitArgs{}, query.ToolCallTokens...)
+	if err != nil {
+		return nil, err
+	}
+	if args.Sample > 0 {
+		calls = query.FilterToolCallsByStatus(calls, args.Tool, args.Status == "error", failed, 0)
+		return sampleRecords(ctx, calls, args.queryLimitArgs, args.Limit)
+	}
+	return query.FilterToolCallsByStatus(calls, args.Tool, args.Status == "error", failed, args.Limit), nil
 }
 
 // handleQueryToolErrors implements query_tool_errors convenience tool
 // Maps to Query 3 from frequent-jsonl-queries.md
-func (e *ToolExecutor) handleQueryToolErrors(cfg *config.Config, scope string, args map[string]interface{}) ([]interface{}, error) {
-	limit := getIntParam(args, "limit", 0)
-
-	// Fixed jq filter for tool errors
-	jqFilter := `select(.type == "user" and (.message.content | type == "array")) | ` +
-		`select(.message.content[] | select(.type == "tool_result" and .is_error == true))`
-
-	return e.executeQuery(scope, jqFilter, limit)
+func (e *ToolExecutor) handleQueryToolErrors(ctx context.Context, cfg *config.Config, src querySource, args queryLimitArgs) ([]interface{}, error) {
+	return e.executeQuery(ctx, src, query.ToolErrorsFilter, args, query.ToolErrorTokens...)
 }
 
 // handleQueryTokenUsage implements query_token_usage convenience tool
 // Maps to Query 4 from frequent-jsonl-queries.md
-func (e *ToolExecutor) handleQueryTokenUsage(cfg *config.Config, scope string, args map[string]interface{}) ([]interface{}, error) {
-	limit := getIntParam(args, "limit", 0)
-
+func (e *ToolExecutor) handleQueryTokenUsage(ctx context.Context, cfg *config.Config, src querySource, args queryLimitArgs) ([]interface{}, error) {
 	// Filter for assistant messages with usage information
 	jqFilter := `select(.type == "assistant" and has("message")) | select(.message | has("usage"))`
 
-	return e.executeQuery(scope, jqFilter, limit)
+	return e.executeQuery(ctx, src, jqFilter, args, `"usage"`)
 }
 
 // handleQueryConversationFlow implements query_conversation_flow convenience tool
 // Maps to Query 5 from frequent-jsonl-queries.md
-func (e *ToolExecutor) handleQueryConversationFlow(cfg *config.Config, scope string, args map[string]interface{}) ([]interface{}, error) {
-	limit := getIntParam(args, "limit", 0)
-
+func (e *ToolExecutor) handleQueryConversationFlow(ctx context.Context, cfg *config.Config, src querySource, args queryLimitArgs) ([]interface{}, error) {
 	// Filter for user and assistant messages only
+	// Note: jq_transform was removed in Phase 27 - use jq_filter for transformations instead
 	jqFilter := `select(.type == "user" or .type == "assistant")`
 
-	// Note: jq_transform was removed in Phase 27 - transform parameter is ignored
-	// Users should use jq_filter for transformations instead
-
-	return e.executeQuery(scope, jqFilter, limit)
+	return e.executeQuery(ctx, src, jqFilter, args)
 }
 
 // handleQuerySystemErrors implements query_system_errors convenience tool
 // Maps to Query 6 from frequent-jsonl-queries.md
-func (e *ToolExecutor) handleQuerySystemErrors(cfg *config.Config, scope string, args map[string]interface{}) ([]interface{}, error) {
-	limit := getIntParam(args, "limit", 0)
-
+func (e *ToolExecutor) handleQuerySystemErrors(ctx context.Context, cfg *config.Config, src querySource, args queryLimitArgs) ([]interface{}, error) {
 	// Filter for system API errors
 	jqFilter := `select(.type == "system" and .subtype == "api_error")`
 
-	return e.executeQuery(scope, jqFilter, limit)
+	return e.executeQuery(ctx, src, jqFilter, args, `"api_error"`)
 }
 
 // handleQueryFileSnapshots implements query_file_snapshots convenience tool
 // Maps to Query 7 from frequent-jsonl-queries.md
-func (e *ToolExecutor) handleQueryFileSnapshots(cfg *config.Config, scope string, args map[string]interface{}) ([]interface{}, error) {
-	limit := getIntParam(args, "limit", 0)
-
+func (e *ToolExecutor) handleQueryFileSnapshots(ctx context.Context, cfg *config.Config, src querySource, args queryLimitArgs) ([]interface{}, error) {
 	// Filter for file history snapshots with messageId
 	jqFilter := `select(.type == "file-history-snapshot" and has("messageId"))`
 
-	return e.executeQuery(scope, jqFilter, limit)
+	return e.executeQuery(ctx, src, jqFilter, args, `"messageId"`)
 }
 
 // handleQueryTimestamps implements query_timestamps convenience tool
 // Maps to Query 8 from frequent-jsonl-queries.md
-func (e *ToolExecutor) handleQueryTimestamps(cfg *config.Config, scope string, args map[string]interface{}) ([]interface{}, error) {
-	limit := getIntParam(args, "limit", 0)
-
+func (e *ToolExecutor) handleQueryTimestamps(ctx context.Context, cfg *config.Config, src querySource, args queryLimitArgs) ([]interface{}, error) {
 	// Filter for entries with timestamp
 	jqFilter := `select(.timestamp != null)`
 
-	return e.executeQuery(scope, jqFilter, limit)
+	return e.executeQuery(ctx, src, jqFilter, args, `"timestamp"`)
 }
 
 // handleQuerySummaries implements query_summaries convenience tool
 // Maps to Query 9 from frequent-jsonl-queries.md
-func (e *ToolExecutor) handleQuerySummaries(cfg *config.Config, scope string, args map[string]interface{}) ([]interface{}, error) {
-	keyword := getStringParam(args, "keyword", "")
-	limit := getIntParam(args, "limit", 0)
-
+func (e *ToolExecutor) handleQuerySummaries(ctx context.Context, cfg *config.Config, src querySource, args querySummariesArgs) ([]interface{}, error) {
 	// Base filter for summary entries
 	jqFilter := `select(.type == "summary")`
 
 	// Add keyword filter if provided
-	if keyword != "" {
-		escapedKeyword := escapeJQ(keyword)
+	if args.Keyword != "" {
+		escapedKeyword := query.EscapeJQ(args.Keyword)
 		jqFilter = fmt.Sprintf(`%s | select(.summary | test("%s"; "i"))`, jqFilter, escapedKeyword)
 	}
 
-	return e.executeQuery(scope, jqFilter, limit)
+	return e.executeQuery(ctx, src, jqFilter, args.queryLimitArgs)
 }
 
 // handleQueryToolBlocks implements query_tool_blocks convenience tool
 // Maps to Query 10 from frequent-jsonl-queries.md
-func (e *ToolExecutor) handleQueryToolBlocks(cfg *config.Config, scope string, args map[string]interface{}) ([]interface{}, error) {
-	blockType := getStringParam(args, "block_type", "tool_use")
-	limit := getIntParam(args, "limit", 0)
-
-	// Validate block_type
-	if blockType != "tool_use" && blockType != "tool_result" {
-		return nil, fmt.Errorf("invalid block_type: %s (must be 'tool_use' or 'tool_result')", blockType)
-	}
-
+// block_type is validated against its enum before the handler runs
+func (e *ToolExecutor) handleQueryToolBlocks(ctx context.Context, cfg *config.Config, src querySource, args queryToolBlocksArgs) ([]interface{}, error) {
 	var jqFilter string
-	if blockType == "tool_use" {
-		// Extract tool_use blocks from assistant messages
-		jqFilter = `select(.type == "assistant") | .message.content[] | select(.type == "tool_use")`
-	} else {
+	if args.BlockType == "tool_result" {
 		// Extract tool_result blocks from user messages
 		jqFilter = `select(.type == "user" and (.message.content | type == "array")) | .message.content[] | select(.type == "tool_result")`
+	} else {
+		// Extract tool_use blocks from assistant messages
+		jqFilter = `select(.type == "assistant") | .message.content[] | select(.type == "tool_use")`
 	}
 
-	return e.executeQuery(scope, jqFilter, limit)
-}
-
-// escapeJQ escapes special characters in strings for jq expressions
-func escapeJQ(s string) string {
-	// Escape backslashes first
-	s = strings.ReplaceAll(s, `\`, `\\`)
-	// Escape double quotes
-	s = strings.ReplaceAll(s, `"`, `\"`)
-	// Escape regex special chars for test() function
-	// Note: This is basic escaping - for complex patterns, users should use query_raw
-	return s
+	// Only lines holding a block of the requested type can match
+	return e.executeQuery(ctx, src, jqFilter, args.queryLimitArgs, `"`+args.BlockType+`"`)
 }
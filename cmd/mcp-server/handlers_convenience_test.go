@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -122,7 +123,7 @@ func TestHandleQueryUserMessages(t *testing.T) {
 	executor, cfg, cleanup := setupConvenienceToolTest(t)
 	defer cleanup()
 
-	results, err := executor.handleQueryUserMessages(cfg, "project", map[string]interface{}{})
+	results, err := executor.handleQueryUserMessages(context.Background(), cfg, querySource{scope: "project"}, queryUserMessagesArgs{})
 	if err != nil {
 		t.Fatalf("handleQueryUserMessages() error = %v", err)
 	}
@@ -140,7 +141,7 @@ func TestHandleQueryTools(t *testing.T) {
 	executor, cfg, cleanup := setupConvenienceToolTest(t)
 	defer cleanup()
 
-	results, err := executor.handleQueryTools(cfg, "project", map[string]interface{}{})
+	results, err := executor.handleQueryTools(context.Background(), cfg, querySource{scope: "project"}, queryToolsArgs{})
 	if err != nil {
 		t.Fatalf("handleQueryTools() error = %v", err)
 	}
@@ -158,7 +159,7 @@ func TestHandleQueryToolErrors(t *testing.T) {
 	executor, cfg, cleanup := setupConvenienceToolTest(t)
 	defer cleanup()
 
-	results, err := executor.handleQueryToolErrors(cfg, "project", map[string]interface{}{})
+	results, err := executor.handleQueryToolErrors(context.Background(), cfg, querySource{scope: "project"}, queryLimitArgs{})
 	if err != nil {
 		t.Fatalf("handleQueryToolErrors() error = %v", err)
 	}
@@ -176,7 +177,7 @@ func TestHandleQueryTokenUsage(t *testing.T) {
 	executor, cfg, cleanup := setupConvenienceToolTest(t)
 	defer cleanup()
 
-	results, err := executor.handleQueryTokenUsage(cfg, "project", map[string]interface{}{})
+	results, err := executor.handleQueryTokenUsage(context.Background(), cfg, querySource{scope: "project"}, queryLimitArgs{})
 	if err != nil {
 		t.Fatalf("handleQueryTokenUsage() error = %v", err)
 	}
@@ -194,7 +195,7 @@ func TestHandleQueryConversationFlow(t *testing.T) {
 	executor, cfg, cleanup := setupConvenienceToolTest(t)
 	defer cleanup()
 
-	results, err := executor.handleQueryConversationFlow(cfg, "project", map[string]interface{}{})
+	results, err := executor.handleQueryConversationFlow(context.Background(), cfg, querySource{scope: "project"}, queryLimitArgs{})
 	if err != nil {
 		t.Fatalf("handleQueryConversationFlow() error = %v", err)
 	}
@@ -212,7 +213,7 @@ func TestHandleQuerySystemErrors(t *testing.T) {
 	executor, cfg, cleanup := setupConvenienceToolTest(t)
 	defer cleanup()
 
-	results, err := executor.handleQuerySystemErrors(cfg, "project", map[string]interface{}{})
+	results, err := executor.handleQuerySystemErrors(context.Background(), cfg, querySource{scope: "project"}, queryLimitArgs{})
 	if err != nil {
 		t.Fatalf("handleQuerySystemErrors() error = %v", err)
 	}
@@ -230,7 +231,7 @@ func TestHandleQueryFileSnapshots(t *testing.T) {
 	executor, cfg, cleanup := setupConvenienceToolTest(t)
 	defer cleanup()
 
-	results, err := executor.handleQueryFileSnapshots(cfg, "project", map[string]interface{}{})
+	results, err := executor.handleQueryFileSnapshots(context.Background(), cfg, querySource{scope: "project"}, queryLimitArgs{})
 	if err != nil {
 		t.Fatalf("handleQueryFileSnapshots() error = %v", err)
 	}
@@ -248,7 +249,7 @@ func TestHandleQueryTimestamps(t *testing.T) {
 	executor, cfg, cleanup := setupConvenienceToolTest(t)
 	defer cleanup()
 
-	results, err := executor.handleQueryTimestamps(cfg, "project", map[string]interface{}{})
+	results, err := executor.handleQueryTimestamps(context.Background(), cfg, querySource{scope: "project"}, queryLimitArgs{})
 	if err != nil {
 		t.Fatalf("handleQueryTimestamps() error = %v", err)
 	}
@@ -266,7 +267,7 @@ func TestHandleQuerySummaries(t *testing.T) {
 	executor, cfg, cleanup := setupConvenienceToolTest(t)
 	defer cleanup()
 
-	results, err := executor.handleQuerySummaries(cfg, "project", map[string]interface{}{})
+	results, err := executor.handleQuerySummaries(context.Background(), cfg, querySource{scope: "project"}, querySummariesArgs{})
 	if err != nil {
 		t.Fatalf("handleQuerySummaries() error = %v", err)
 	}
@@ -296,18 +297,18 @@ func TestHandleQueryToolBlocks(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			results, err := executor.handleQueryToolBlocks(cfg, "project", map[string]interface{}{
+			// block_type is validated by the registry, so go through ExecuteTool
+			results, err := executor.ExecuteTool(context.Background(), cfg, "query_tool_blocks", map[string]interface{}{
 				"block_type": tt.blockType,
 			})
 
 			if (err != nil) != tt.wantErr {
-				t.Errorf("handleQueryToolBlocks() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("query_tool_blocks error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
 			if !tt.wantErr {
-				// Results is now []interface{} directly, no need to unmarshal
-				if results == nil {
+				if results == "" {
 					t.Error("expected non-nil results for valid block type")
 				}
 			}
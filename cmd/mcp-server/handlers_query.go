@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 
 	"github.com/yaleh/meta-cc/internal/locator"
+	"github.com/yaleh/meta-cc/internal/query"
 )
 
 // handleQuery and handleQueryRaw deleted in Phase 27 Stage 27.1
@@ -17,15 +17,26 @@ import (
 // executeQuery is an internal helper for convenience tools
 // It executes a jq query and returns results as []interface{}
 // This allows proper JSONL formatting by response adapters
-func (e *ToolExecutor) executeQuery(scope string, jqFilter string, limit int) ([]interface{}, error) {
-	// Get base directory using pipeline infrastructure
-	baseDir, err := getQueryBaseDir(scope)
+// Lines lacking any of tokens (literal text every matching record contains,
+// e.g. "tool_use") are skipped without being decoded
+// bound limits the results, or asks for a sample drawn while scanning
+func (e *ToolExecutor) executeQuery(ctx context.Context, src querySource, jqFilter string, bound queryLimitArgs, tokens ...string) ([]interface{}, error) {
+	sampler, err := bound.newSampler()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get base directory: %w", err)
+		return nil, err
+	}
+
+	// Resolve the session files for the scope using pipeline infrastructure
+	baseDir, files, err := src.files()
+	if err != nil {
+		return nil, err
 	}
 
 	// Create query executor
 	executor := NewQueryExecutor(baseDir)
+	executor.TagSources = src.tagSources()
+	executor.Prefilter = query.NewLinePrefilter(query.FilterRecordTypes(jqFilter), tokens)
+	executor.Sampler = sampler
 
 	// Compile expression
 	code, err := executor.compileExpression(jqFilter)
@@ -33,19 +44,23 @@ func (e *ToolExecutor) executeQuery(scope string, jqFilter string, limit int) ([
 		return nil, fmt.Errorf("invalid jq expression: %w", err)
 	}
 
-	// Get all JSONL files in directory
-	files, err := getJSONLFiles(baseDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list JSONL files: %w", err)
-	}
-
 	if len(files) == 0 {
 		return nil, fmt.Errorf("no JSONL files found in %s", baseDir)
 	}
 
-	// Execute query with streaming
-	ctx := context.Background()
-	results := executor.streamFiles(ctx, files, code, limit)
+	// Report progress against file totals when the client asked for it
+	if reporter := progressReporterFromContext(ctx); reporter != nil {
+		reporter.start(collectFilesMetadata(files))
+	}
+	executor.Progress = scanProgressFunc(ctx)
+
+	// Execute query with streaming; a sample is drawn from every match, so
+	// the scan must not stop at limit
+	if sampler != nil {
+		executor.streamFiles(ctx, files, code, 0)
+		return finishSample(ctx, sampler, bound.Limit), nil
+	}
+	results := executor.streamFiles(ctx, files, code, bound.Limit)
 
 	// Return results directly as []interface{}
 	// Response adapters will handle serialization (inline or file_ref)
@@ -101,49 +116,5 @@ func getQueryBaseDir(scope string) (string, error) {
 // getJSONLFiles returns all .jsonl files in a directory (non-recursive)
 // Files are sorted by modification time (newest first) to prioritize recent sessions
 func getJSONLFiles(dir string) ([]string, error) {
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %w", err)
-	}
-
-	// Collect file info with modification times
-	type fileInfo struct {
-		path    string
-		modTime int64 // Unix timestamp for easier sorting
-	}
-	var fileInfos []fileInfo
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		if filepath.Ext(entry.Name()) == ".jsonl" {
-			fullPath := filepath.Join(dir, entry.Name())
-
-			// Get file stat for modification time
-			info, err := entry.Info()
-			if err != nil {
-				// Skip files we can't stat
-				continue
-			}
-
-			fileInfos = append(fileInfos, fileInfo{
-				path:    fullPath,
-				modTime: info.ModTime().Unix(),
-			})
-		}
-	}
-
-	// Sort by modification time (newest first = descending order)
-	sort.Slice(fileInfos, func(i, j int) bool {
-		return fileInfos[i].modTime > fileInfos[j].modTime
-	})
-
-	// Extract paths
-	var files []string
-	for _, fi := range fileInfos {
-		files = append(files, fi.path)
-	}
-
-	return files, nil
+	return getJSONLFilesIn(dir)
 }
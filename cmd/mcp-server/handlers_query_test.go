@@ -56,7 +56,7 @@ func TestExecuteQuery_ReturnsSliceNotString(t *testing.T) {
 
 	// Execute: Create executor and run query
 	executor := &ToolExecutor{}
-	results, err := executor.executeQuery("session", `.[] | select(.type == "user")`, 0)
+	results, err := executor.executeQuery(context.Background(), querySource{scope: "session"}, `.[] | select(.type == "user")`, queryLimitArgs{})
 
 	// Assert: Verify return type is []interface{}
 	require.NoError(t, err, "executeQuery should not return error")
@@ -158,12 +158,10 @@ func TestConvenienceTool_HandlesSliceReturnType(t *testing.T) {
 
 	// Execute: Call convenience tool (which calls executeQuery internally)
 	executor := &ToolExecutor{}
-	args := map[string]interface{}{
-		"pattern": "test",
-	}
+	args := queryUserMessagesArgs{Pattern: "test"}
 
 	// Use session scope since we've set up CLAUDE_SESSION_DIR
-	result, err := executor.handleQueryUserMessages(nil, "session", args)
+	result, err := executor.handleQueryUserMessages(context.Background(), nil, querySource{scope: "session"}, args)
 
 	// Assert: Should successfully return results
 	require.NoError(t, err, "handleQueryUserMessages should not return error")
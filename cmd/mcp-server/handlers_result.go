@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/query"
+	"github.com/yaleh/meta-cc/pkg/output"
+)
+
+// handlers_result.go implements query_result, which refines a result set
+// stored by an earlier response (see output_store.go) without rescanning
+// sessions. Steps run in a fixed order: diff, intersect, jq_filter, sort_by,
+// sample, offset/limit; fields and output_format then apply as for every
+// tool (see response_adapter.go).
+
+// queryResultArgs are the arguments of query_result
+type queryResultArgs struct {
+	ResultID  string   `json:"result_id" mcp:"required" desc:"Result to refine: the result_id of an earlier response or a save_as name"`
+	JQFilter  string   `json:"jq_filter" desc:"jq expression applied to the stored records. Defaults to '.[]' when omitted. Do NOT wrap in quotes: .[] | select(.status == \"error\")"`
+	Diff      string   `json:"diff" desc:"Drop records that are also in this result (result_id or name)"`
+	Intersect string   `json:"intersect" desc:"Keep only records that are also in this result (result_id or name)"`
+	Key       []string `json:"key" desc:"Fields identifying a record for diff and intersect (default: the whole record)"`
+	SortBy    string   `json:"sort_by" desc:"Sort by this field; dotted paths reach nested fields (e.g. 'input.command')"`
+	Desc      bool     `json:"desc" desc:"Sort descending (default: false)"`
+	Offset    int      `json:"offset" desc:"Skip this many records (paging)"`
+	queryLimitArgs
+}
+
+// handleQueryResult implements query_result tool
+func handleQueryResult(ctx context.Context, cfg *config.Config, args queryResultArgs) ([]interface{}, error) {
+	if args.Offset < 0 || args.Limit < 0 {
+		return nil, fmt.Errorf("offset and limit must not be negative: %w", mcerrors.ErrInvalidInput)
+	}
+	if _, err := args.newSampler(); err != nil {
+		return nil, err
+	}
+
+	store := outputStoreFor(cfg)
+	records, _, err := store.Load(args.ResultID)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Diff != "" {
+		other, _, err := store.Load(args.Diff)
+		if err != nil {
+			return nil, err
+		}
+		records = filterByMembership(records, other, args.Key, false)
+	}
+	if args.Intersect != "" {
+		other, _, err := store.Load(args.Intersect)
+		if err != nil {
+			return nil, err
+		}
+		records = filterByMembership(records, other, args.Key, true)
+	}
+
+	if args.JQFilter != "" {
+		if records, err = applyRecordsJQ(ctx, args.JQFilter, records); err != nil {
+			return nil, err
+		}
+	}
+	if args.SortBy != "" {
+		sortRecords(records, args.SortBy, args.Desc)
+	}
+	if records, err = sampleRecords(ctx, records, args.queryLimitArgs, 0); err != nil {
+		return nil, err
+	}
+
+	if args.Offset >= len(records) {
+		records = records[:0]
+	} else {
+		records = records[args.Offset:]
+	}
+	if args.Limit > 0 && len(records) > args.Limit {
+		records = records[:args.Limit]
+	}
+	return records, nil
+}
+
+// applyRecordsJQ runs a jq expression over the records as one array, as the
+// jq_filter of query tools does
+func applyRecordsJQ(ctx context.Context, expr string, records []interface{}) ([]interface{}, error) {
+	code, err := query.CompileJQ(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jq expression: %v: %w", err, mcerrors.ErrParseError)
+	}
+
+	results := make([]interface{}, 0)
+	iter := code.RunWithContext(ctx, records)
+	for {
+		value, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := value.(error); ok {
+			return nil, fmt.Errorf("jq_filter failed: %v: %w", err, mcerrors.ErrInvalidInput)
+		}
+		results = append(results, value)
+	}
+	return results, nil
+}
+
+// recordKey identifies a record for set operations: the JSON encoding of its
+// key fields, or of the whole record without key fields
+func recordKey(record interface{}, key []string) string {
+	value := record
+	if len(key) > 0 {
+		values := make([]interface{}, len(key))
+		for i, field := range key {
+			values[i], _ = output.RecordField(record, field)
+		}
+		value = values
+	}
+	// Map keys are encoded sorted, so equal records give equal keys
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// filterByMembership keeps the records that are (keep true) or are not
+// (keep false) in other
+func filterByMembership(records, other []interface{}, key []string, keep bool) []interface{} {
+	members := make(map[string]bool, len(other))
+	for _, record := range other {
+		members[recordKey(record, key)] = true
+	}
+	filtered := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		if members[recordKey(record, key)] == keep {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// sortRecords sorts records stably by a field. Numbers compare numerically,
+// other values as text; records without the field come last in both
+// directions.
+func sortRecords(records []interface{}, field string, desc bool) {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, okA := output.RecordField(records[i], field)
+		b, okB := output.RecordField(records[j], field)
+		okA = okA && a != nil
+		okB = okB && b != nil
+		if !okA || !okB {
+			return okA && !okB
+		}
+		if desc {
+			return compareValues(b, a) < 0
+		}
+		return compareValues(a, b) < 0
+	})
+}
+
+// compareValues orders two JSON values
+func compareValues(a, b interface{}) int {
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(valueText(a), valueText(b))
+}
+
+// valueText renders a value for text comparison
+func valueText(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
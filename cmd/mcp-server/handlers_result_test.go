@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+)
+
+// toolCalls returns query_tools-like records
+func toolCalls() []interface{} {
+	return []interface{}{
+		map[string]interface{}{"uuid": "c1", "tool_name": "Bash", "status": "error", "duration": 30.0, "input": map[string]interface{}{"command": "make"}},
+		map[string]interface{}{"uuid": "c2", "tool_name": "Read", "status": "success", "duration": 5.0},
+		map[string]interface{}{"uuid": "c3", "tool_name": "Bash", "status": "error", "duration": 120.0, "input": map[string]interface{}{"command": "go test"}},
+		map[string]interface{}{"uuid": "c4", "tool_name": "Edit", "status": "error"},
+	}
+}
+
+// resultResponse is a decoded inline response of a records tool
+type resultResponse struct {
+	Mode     string                   `json:"mode"`
+	ResultID string                   `json:"result_id"`
+	Data     []map[string]interface{} `json:"data"`
+}
+
+// runQueryResult runs query_result and decodes its inline response
+func runQueryResult(t *testing.T, cfg *config.Config, args map[string]interface{}) resultResponse {
+	t.Helper()
+	output, err := NewToolExecutor().ExecuteTool(context.Background(), cfg, "query_result", args)
+	require.NoError(t, err)
+
+	var response resultResponse
+	require.NoError(t, json.Unmarshal([]byte(output), &response), output)
+	require.Equal(t, "inline", response.Mode)
+	return response
+}
+
+// storeResult stores records as a response of toolName would, returning the result_id
+func storeResult(t *testing.T, cfg *config.Config, toolName string, data []interface{}, params map[string]interface{}) string {
+	t.Helper()
+	response, err := adaptResponse(cfg, data, params, toolName)
+	require.NoError(t, err)
+	id, ok := response.(map[string]interface{})["result_id"].(string)
+	require.True(t, ok, "response has no result_id: %v", response)
+	return id
+}
+
+func uuids(records []map[string]interface{}) string {
+	var ids []string
+	for _, r := range records {
+		ids = append(ids, r["uuid"].(string))
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestResponsesCarryResultID(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	inline := storeResult(t, cfg, "query_tools", toolCalls(), nil)
+	require.True(t, strings.HasPrefix(inline, "query_tools-"), inline)
+
+	response, err := adaptResponse(cfg, toolCalls(), map[string]interface{}{"output_mode": "file_ref"}, "query_tools")
+	require.NoError(t, err)
+	m := response.(map[string]interface{})
+	require.Equal(t, OutputModeFileRef, m["mode"])
+	// Identical records share one stored result
+	require.Equal(t, inline, m["result_id"])
+}
+
+func TestQueryResultRefinesStoredResult(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	id := storeResult(t, cfg, "query_tools", toolCalls(), nil)
+
+	response := runQueryResult(t, cfg, map[string]interface{}{
+		"result_id": id,
+		"jq_filter": `.[] | select(.status == "error")`,
+		"sort_by":   "duration",
+		"desc":      true,
+	})
+	// Records without the sort field come last
+	require.Equal(t, "c3,c1,c4", uuids(response.Data))
+	require.True(t, strings.HasPrefix(response.ResultID, "query_result-"), response.ResultID)
+
+	// Refinements chain through the new result_id
+	response = runQueryResult(t, cfg, map[string]interface{}{
+		"result_id": response.ResultID,
+		"offset":    1,
+		"limit":     1,
+		"fields":    []interface{}{"uuid", "input.command"},
+	})
+	require.Equal(t, []map[string]interface{}{{"uuid": "c1", "input.command": "make"}}, response.Data)
+}
+
+func TestQueryResultSample(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	id := storeResult(t, cfg, "query_tools", toolCalls(), nil)
+
+	first := runQueryResult(t, cfg, map[string]interface{}{"result_id": id, "sample": 2, "seed": 7})
+	again := runQueryResult(t, cfg, map[string]interface{}{"result_id": id, "sample": 2, "seed": 7})
+	require.Len(t, first.Data, 2)
+	require.Equal(t, uuids(first.Data), uuids(again.Data))
+	// Sampled records keep their order
+	require.Less(t, first.Data[0]["uuid"], first.Data[1]["uuid"])
+
+	all := runQueryResult(t, cfg, map[string]interface{}{"result_id": id, "sample": 10})
+	require.Equal(t, "c1,c2,c3,c4", uuids(all.Data))
+
+	// Every tool is represented in a stratified sample
+	stratified := runQueryResult(t, cfg, map[string]interface{}{"result_id": id, "sample": 3, "stratify_by": "tool_name"})
+	tools := map[interface{}]bool{}
+	for _, record := range stratified.Data {
+		tools[record["tool_name"]] = true
+	}
+	require.Equal(t, map[interface{}]bool{"Bash": true, "Read": true, "Edit": true}, tools)
+}
+
+func TestQueryResultSetOperations(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	before := toolCalls()
+	after := []interface{}{
+		before[1],
+		before[2],
+		map[string]interface{}{"uuid": "c5", "tool_name": "Bash", "status": "error"},
+		// Same call as c1 with a different duration
+		map[string]interface{}{"uuid": "c1", "tool_name": "Bash", "status": "error", "duration": 31.0},
+	}
+	storeResult(t, cfg, "query_tools", before, map[string]interface{}{"save_as": "before"})
+	storeResult(t, cfg, "query_tools", after, map[string]interface{}{"save_as": "after"})
+
+	response := runQueryResult(t, cfg, map[string]interface{}{"result_id": "after", "diff": "before"})
+	require.Equal(t, "c5,c1", uuids(response.Data))
+	response = runQueryResult(t, cfg, map[string]interface{}{"result_id": "after", "diff": "before", "key": []interface{}{"uuid"}})
+	require.Equal(t, "c5", uuids(response.Data))
+	response = runQueryResult(t, cfg, map[string]interface{}{"result_id": "after", "intersect": "before", "key": []interface{}{"uuid"}})
+	require.Equal(t, "c2,c3,c1", uuids(response.Data))
+
+	// Naming again points the name at the new result
+	storeResult(t, cfg, "query_tools", after[:1], map[string]interface{}{"save_as": "after"})
+	response = runQueryResult(t, cfg, map[string]interface{}{"result_id": "after"})
+	require.Equal(t, "c2", uuids(response.Data))
+}
+
+func TestQueryResultErrors(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	executor := NewToolExecutor()
+
+	_, err = executor.ExecuteTool(context.Background(), cfg, "query_result", map[string]interface{}{"result_id": "no-such-name"})
+	require.True(t, errors.Is(err, mcerrors.ErrNotFound), "unexpected error: %v", err)
+
+	// Well-formed IDs of results that were evicted or never stored
+	_, err = executor.ExecuteTool(context.Background(), cfg, "query_result", map[string]interface{}{"result_id": "query_tools-0123456789abcdef"})
+	require.True(t, errors.Is(err, mcerrors.ErrNotFound), "unexpected error: %v", err)
+
+	// IDs never reach outside the store
+	_, err = executor.ExecuteTool(context.Background(), cfg, "query_result", map[string]interface{}{"result_id": "../../etc/passwd"})
+	require.True(t, errors.Is(err, mcerrors.ErrNotFound), "unexpected error: %v", err)
+
+	_, err = executor.ExecuteTool(context.Background(), cfg, "query_result", map[string]interface{}{})
+	require.True(t, errors.Is(err, mcerrors.ErrMissingParameter), "unexpected error: %v", err)
+
+	id := storeResult(t, cfg, "query_tools", toolCalls(), nil)
+	_, err = executor.ExecuteTool(context.Background(), cfg, "query_result", map[string]interface{}{"result_id": id, "jq_filter": ".[] | "})
+	require.True(t, errors.Is(err, mcerrors.ErrParseError), "unexpected error: %v", err)
+	_, err = executor.ExecuteTool(context.Background(), cfg, "query_result", map[string]interface{}{"result_id": id, "offset": -1})
+	require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "unexpected error: %v", err)
+
+	_, err = adaptResponse(cfg, toolCalls(), map[string]interface{}{"save_as": "../names"}, "query_tools")
+	require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "unexpected error: %v", err)
+	_, err = adaptResponse(cfg, toolCalls(), map[string]interface{}{"save_as": id}, "query_tools")
+	require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "unexpected error: %v", err)
+}
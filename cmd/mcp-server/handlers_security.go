@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/yaleh/meta-cc/internal/analyzer"
+	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/parser"
+	"github.com/yaleh/meta-cc/internal/query"
+)
+
+// handlers_security.go implements query_security_audit, which classifies the
+// commands and file writes of sessions with the rules of internal/analyzer
+
+// querySecurityAuditArgs are the arguments of query_security_audit
+type querySecurityAuditArgs struct {
+	MinSeverity string `json:"min_severity" enum:"low,medium,high,critical" desc:"Only findings at or above this severity (default: low)"`
+	Category    string `json:"category" desc:"Only this category: destructive, privilege_escalation, remote_script, force_push, credential_access, network, sensitive_path or a custom one"`
+	queryLimitArgs
+}
+
+// securityPrefilter skips sessions without tool calls, and securityLines the
+// lines that are neither prompts, tool calls nor tool results
+var (
+	securityPrefilter = query.NewLinePrefilter(nil, []string{`"tool_use"`})
+	securityLines     = query.NewLinePrefilter([]string{"user", "assistant"}, nil)
+)
+
+// loadSecurityRules returns the built-in rules merged with META_CC_SECURITY_RULES
+func loadSecurityRules(cfg *config.Config) (*analyzer.SecurityRules, error) {
+	if cfg == nil {
+		return analyzer.DefaultSecurityRules(), nil
+	}
+	return analyzer.LoadSecurityRules(cfg.Security.RulesFile)
+}
+
+// handleQuerySecurityAudit implements query_security_audit tool
+// Findings are ordered by session (newest first), then by time within a session
+func (e *ToolExecutor) handleQuerySecurityAudit(ctx context.Context, cfg *config.Config, src querySource, args querySecurityAuditArgs) ([]interface{}, error) {
+	minRank := 0
+	if args.MinSeverity != "" {
+		if minRank = analyzer.SeverityRank(args.MinSeverity); minRank < 0 {
+			return nil, fmt.Errorf("invalid min_severity %q (must be one of: %v): %w", args.MinSeverity, analyzer.Severities, mcerrors.ErrInvalidInput)
+		}
+	}
+
+	sampler, err := args.newSampler()
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := loadSecurityRules(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load security rules: %w", err)
+	}
+
+	baseDir, files, err := src.files()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no JSONL files found in %s", baseDir)
+	}
+
+	if reporter := progressReporterFromContext(ctx); reporter != nil {
+		reporter.start(collectFilesMetadata(files))
+	}
+	progress := scanProgressFunc(ctx)
+
+	scan := func(ctx context.Context, path string) query.FileScan {
+		// Sessions the index shows have no tool call cannot yield findings
+		if records, size, skipped := securityPrefilter.SkipIndexed(path); skipped {
+			return query.FileScan{Records: records, Bytes: size}
+		}
+
+		tag := query.NewSourceTag(path, nil)
+		cwd := ""
+		auditor := analyzer.NewSecurityAuditor(rules)
+		records, size, err := query.ForEachIndexedLine(ctx, path, func(_ int, line []byte) bool {
+			if !securityLines.Match(line) {
+				return true
+			}
+			var entry parser.SessionEntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return true
+			}
+			if cwd == "" {
+				cwd = entry.CWD
+			}
+			auditor.Add(entry)
+			return true
+		})
+		if err != nil {
+			return query.FileScan{Records: records, Bytes: size, Err: err}
+		}
+		if cwd != "" {
+			tag.ProjectPath = cwd
+		}
+
+		results := make([]interface{}, 0)
+		for _, f := range auditor.Findings() {
+			if analyzer.SeverityRank(f.Severity) < minRank {
+				continue
+			}
+			if args.Category != "" && f.Category != args.Category {
+				continue
+			}
+			if f.SessionID == "" {
+				f.SessionID = tag.SessionID
+			}
+			if src.tagSources() {
+				f.ProjectPath = tag.ProjectPath
+			}
+			results = append(results, f)
+		}
+		return query.FileScan{Results: results, Records: records, Bytes: size}
+	}
+
+	results := make([]interface{}, 0)
+	recordsScanned := 0
+	var bytesRead int64
+	query.ScanFilesOrdered(ctx, files, 0, scan, func(i int, r query.FileScan) bool {
+		recordsScanned += r.Records
+		bytesRead += r.Bytes
+		if progress != nil {
+			progress(query.ScanProgress{
+				FilesProcessed: i + 1,
+				TotalFiles:     len(files),
+				RecordsScanned: recordsScanned,
+				BytesRead:      bytesRead,
+			})
+		}
+		if r.Err != nil {
+			// Skip files that fail but continue processing other files
+			return true
+		}
+		if sampler != nil {
+			for _, f := range r.Results {
+				sampler.Add(f)
+			}
+			return true
+		}
+		results = append(results, r.Results...)
+		if args.Limit > 0 && len(results) >= args.Limit {
+			results = results[:args.Limit]
+			return false
+		}
+		return true
+	})
+
+	if sampler != nil {
+		return finishSample(ctx, sampler, args.Limit), nil
+	}
+	return results, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yaleh/meta-cc/internal/config"
+)
+
+// setupAuditSession creates a project whose session force-pushes after a
+// prompt and edits a shell startup file, and returns the session directory
+func setupAuditSession(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("META_CC_PROJECTS_ROOT", root)
+
+	path := filepath.Join(root, "-work-api", "s-audit.jsonl")
+	data := `{"type":"user","uuid":"u1","sessionId":"s-audit","timestamp":"2025-10-02T10:00:00Z","cwd":"/work/api","message":{"role":"user","content":"ship it"}}
+{"type":"assistant","uuid":"a1","sessionId":"s-audit","timestamp":"2025-10-02T10:00:01Z","cwd":"/work/api","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"git push --force origin main"}}]}}
+{"type":"user","uuid":"u2","sessionId":"s-audit","timestamp":"2025-10-02T10:00:02Z","cwd":"/work/api","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}}
+{"type":"assistant","uuid":"a2","sessionId":"s-audit","timestamp":"2025-10-02T10:00:03Z","cwd":"/work/api","message":{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"Edit","input":{"file_path":"/home/me/.bashrc","old_string":"a","new_string":"b"}}]}}
+`
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	return filepath.Dir(path)
+}
+
+// auditFindings runs query_security_audit and decodes its inline findings
+func auditFindings(t *testing.T, cfg *config.Config, args map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+	output, err := NewToolExecutor().ExecuteTool(context.Background(), cfg, "query_security_audit", args)
+	require.NoError(t, err)
+
+	var response struct {
+		Mode string                   `json:"mode"`
+		Data []map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(output), &response), output)
+	require.Equal(t, "inline", response.Mode)
+	return response.Data
+}
+
+func TestQuerySecurityAudit(t *testing.T) {
+	setupAuditSession(t)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	findings := auditFindings(t, cfg, map[string]interface{}{"scope": "all"})
+	require.Len(t, findings, 2)
+	push := findings[0]
+	require.Equal(t, "git-force-push", push["rule_id"])
+	require.Equal(t, "high", push["severity"])
+	require.Equal(t, "s-audit", push["session_id"])
+	require.Equal(t, "/work/api", push["project_path"])
+	require.Equal(t, "ship it", push["user_prompt"])
+	require.Equal(t, "2025-10-02T10:00:01Z", push["timestamp"])
+	require.Equal(t, "write-shell-startup", findings[1]["rule_id"])
+
+	findings = auditFindings(t, cfg, map[string]interface{}{"scope": "all", "min_severity": "high"})
+	require.Len(t, findings, 1)
+	findings = auditFindings(t, cfg, map[string]interface{}{"scope": "all", "category": "sensitive_path"})
+	require.Len(t, findings, 1)
+
+	_, err = NewToolExecutor().ExecuteTool(context.Background(), cfg, "query_security_audit", map[string]interface{}{"scope": "all", "min_severity": "severe"})
+	require.Error(t, err)
+}
+
+func TestQuerySecurityAuditLocalRules(t *testing.T) {
+	setupAuditSession(t)
+	rules := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(rules, []byte(`rules:
+  - id: write-shell-startup
+    disabled: true
+  - id: push-main
+    category: policy
+    severity: critical
+    description: Pushes to main
+    command: 'git\s+push\b.*\bmain\b'
+`), 0644))
+	t.Setenv("META_CC_SECURITY_RULES", rules)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	var ids []string
+	for _, f := range auditFindings(t, cfg, map[string]interface{}{"scope": "all"}) {
+		ids = append(ids, f["rule_id"].(string))
+	}
+	require.Equal(t, "git-force-push,push-main", strings.Join(ids, ","))
+}
+
+func TestQuerySecurityAuditIndexedSessions(t *testing.T) {
+	dir := setupAuditSession(t)
+	chat := `{"type":"user","uuid":"u1","sessionId":"s-chat","timestamp":"2025-10-01T09:00:00Z","cwd":"/work/api","message":{"role":"user","content":"git push --force?"}}
+{"type":"assistant","uuid":"a1","sessionId":"s-chat","timestamp":"2025-10-01T09:00:01Z","cwd":"/work/api","message":{"role":"assistant","content":[{"type":"text","text":"No."}]}}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "s-chat.jsonl"), []byte(chat), 0644))
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	// The first audit indexes both sessions; the second skips the one
+	// without tool calls and must report the same findings
+	first := auditFindings(t, cfg, map[string]interface{}{"scope": "all"})
+	second := auditFindings(t, cfg, map[string]interface{}{"scope": "all"})
+	require.Len(t, first, 2)
+	require.Equal(t, first, second)
+	require.Equal(t, "success", first[0]["status"])
+}
@@ -1,33 +1,53 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/index"
 	"github.com/yaleh/meta-cc/internal/locator"
 	"github.com/yaleh/meta-cc/internal/query"
+	"github.com/yaleh/meta-cc/internal/sessionio"
 )
 
 // handlers_stage1.go implements Stage 1 tools of the two-stage query architecture
 // Stage 1: Metadata and directory inspection tools for query planning
 // Stage 2: File-level inspection tools (implemented in future stages)
 
+// sessionDirectoryArgs are the arguments of get_session_directory
+type sessionDirectoryArgs struct {
+	Scope    string   `json:"scope" mcp:"required" enum:"session,project,all" desc:"Query scope: 'session' (current session only), 'project' (all sessions) or 'all' (projects root)"`
+	Projects []string `json:"projects" desc:"With scope 'all': only these projects (paths, names or globs)"`
+}
+
 // handleGetSessionDirectory implements get_session_directory tool
 // Returns session directory path and metadata based on scope
-func handleGetSessionDirectory(ctx context.Context, args map[string]interface{}) (interface{}, error) {
-	// Parse scope parameter
-	scope, ok := args["scope"].(string)
-	if !ok || scope == "" {
-		return nil, fmt.Errorf("scope parameter is required")
-	}
+func handleGetSessionDirectory(ctx context.Context, args sessionDirectoryArgs) (interface{}, error) {
+	scope := args.Scope
 
-	// Validate scope
-	if scope != "session" && scope != "project" {
-		return nil, fmt.Errorf("invalid scope: %s (must be 'session' or 'project')", scope)
+	if scope == scopeAll {
+		// Sessions live in one subdirectory per project under the projects root
+		root, files, err := querySource{scope: scope, projects: args.Projects}.files()
+		if err != nil {
+			return nil, err
+		}
+		metadata := collectFilesMetadata(files)
+		return map[string]interface{}{
+			"directory":        root,
+			"scope":            scope,
+			"project_count":    countProjectDirs(files),
+			"file_count":       metadata.FileCount,
+			"total_size_bytes": metadata.TotalSize,
+			"oldest_file":      metadata.OldestFile,
+			"newest_file":      metadata.NewestFile,
+		}, nil
+	}
+	if len(args.Projects) > 0 {
+		return nil, fmt.Errorf("projects requires scope '%s' (got '%s'): %w", scopeAll, scope, mcerrors.ErrInvalidInput)
 	}
 
 	// Get directory path based on scope
@@ -55,6 +75,15 @@ func handleGetSessionDirectory(ctx context.Context, args map[string]interface{})
 	return response, nil
 }
 
+// countProjectDirs counts the distinct project directories of session files
+func countProjectDirs(files []string) int {
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		dirs[filepath.Dir(f)] = true
+	}
+	return len(dirs)
+}
+
 // directoryMetadata holds metadata about a session directory
 type directoryMetadata struct {
 	FileCount  int
@@ -96,21 +125,19 @@ func getDirectoryForScope(scope string) (string, error) {
 	return filepath.Dir(sessionFiles[0]), nil
 }
 
-// collectDirectoryMetadata scans a directory and collects metadata about .jsonl files
+// collectDirectoryMetadata scans a directory and collects metadata about session files
 func collectDirectoryMetadata(directory string) (*directoryMetadata, error) {
-	metadata := &directoryMetadata{
-		FileCount:  0,
-		TotalSize:  0,
-		OldestFile: "",
-		NewestFile: "",
-	}
-
-	// Find all .jsonl files in the directory
-	pattern := filepath.Join(directory, "*.jsonl")
-	files, err := filepath.Glob(pattern)
+	// Find all session files in the directory, archived ones included
+	files, err := sessionio.Glob(directory)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan directory: %w", err)
 	}
+	return collectFilesMetadata(files), nil
+}
+
+// collectFilesMetadata collects count, size and age range of session files
+func collectFilesMetadata(files []string) *directoryMetadata {
+	metadata := &directoryMetadata{}
 
 	// Track oldest and newest modification times
 	var oldestTime, newestTime time.Time
@@ -143,48 +170,24 @@ func collectDirectoryMetadata(directory string) (*directoryMetadata, error) {
 		metadata.NewestFile = newestTime.Format(time.RFC3339)
 	}
 
-	return metadata, nil
+	return metadata
+}
+
+// inspectSessionFilesArgs are the arguments of inspect_session_files
+type inspectSessionFilesArgs struct {
+	Files          []string `json:"files" mcp:"required" path:"read" desc:"Array of absolute file paths to inspect"`
+	IncludeSamples bool     `json:"include_samples" desc:"If true, include 1-2 sample records per type (default: false)"`
 }
 
 // handleInspectSessionFiles implements inspect_session_files tool
 // Returns detailed metadata about specified session files
-func handleInspectSessionFiles(ctx context.Context, args map[string]interface{}) (interface{}, error) {
-	// Parse files parameter
-	filesRaw, ok := args["files"]
-	if !ok {
-		return nil, fmt.Errorf("files parameter is required")
-	}
-
-	filesInterface, ok := filesRaw.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("files must be an array")
-	}
-
-	// Convert to string array
-	files := make([]string, 0, len(filesInterface))
-	for i, fileRaw := range filesInterface {
-		file, ok := fileRaw.(string)
-		if !ok {
-			return nil, fmt.Errorf("file at index %d is not a string", i)
-		}
-		files = append(files, file)
-	}
-
-	if len(files) == 0 {
+func handleInspectSessionFiles(ctx context.Context, args inspectSessionFilesArgs) (interface{}, error) {
+	if len(args.Files) == 0 {
 		return nil, fmt.Errorf("files array cannot be empty")
 	}
 
-	// Parse include_samples parameter (optional, defaults to false)
-	includeSamples := false
-	if samplesRaw, ok := args["include_samples"]; ok {
-		includeSamples, ok = samplesRaw.(bool)
-		if !ok {
-			return nil, fmt.Errorf("include_samples must be a boolean")
-		}
-	}
-
-	// Call the file inspector
-	result, err := query.InspectFiles(files, includeSamples)
+	// Files were resolved through the path policy before the call
+	result, err := query.InspectFilesWithProgress(args.Files, args.IncludeSamples, scanProgressFunc(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to inspect files: %w", err)
 	}
@@ -192,30 +195,21 @@ func handleInspectSessionFiles(ctx context.Context, args map[string]interface{})
 	return result, nil
 }
 
+// sessionMetadataArgs are the arguments of get_session_metadata
+type sessionMetadataArgs struct {
+	Scope    string   `json:"scope" enum:"project,session,all" default:"project" desc:"Query scope: 'project' (default), 'session' or 'all' (every project)"`
+	Projects []string `json:"projects" desc:"With scope 'all': only these projects (paths, names or globs)"`
+}
+
 // handleGetSessionMetadata implements get_session_metadata tool
 // Returns metadata needed for constructing queries including JSONL schema, file info, and query templates
-func handleGetSessionMetadata(ctx context.Context, args map[string]interface{}) (interface{}, error) {
-	// Parse scope parameter (defaults to "project")
-	scope := "project"
-	if scopeRaw, ok := args["scope"].(string); ok && scopeRaw != "" {
-		scope = scopeRaw
-	}
+func handleGetSessionMetadata(ctx context.Context, args sessionMetadataArgs) (interface{}, error) {
+	scope := args.Scope
 
-	// Validate scope
-	if scope != "session" && scope != "project" {
-		return nil, fmt.Errorf("invalid scope: %s (must be 'session' or 'project')", scope)
-	}
-
-	// Get base directory for the scope
-	baseDir, err := getQueryBaseDir(scope)
+	// Get base directory and JSONL files for the scope
+	baseDir, files, err := querySource{scope: scope, projects: args.Projects}.files()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get base directory for scope %s: %w", scope, err)
-	}
-
-	// Get JSONL files in directory
-	files, err := getJSONLFiles(baseDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list JSONL files: %w", err)
+		return nil, fmt.Errorf("failed to resolve files for scope %s: %w", scope, err)
 	}
 
 	// Collect file metadata
@@ -229,17 +223,21 @@ func handleGetSessionMetadata(ctx context.Context, args map[string]interface{})
 		}
 
 		// Estimate record count by counting lines (approximate)
-		recordCount, err := countLines(file)
-		if err != nil {
-			recordCount = 0 // Use 0 if we can't count lines
+		recordCount := 0
+		if fi, err := index.Default().File(file); err == nil {
+			recordCount = fi.Lines
 		}
 
-		fileMetadata = append(fileMetadata, map[string]interface{}{
+		entry := map[string]interface{}{
 			"path":        file,
 			"size_bytes":  info.Size(),
 			"modified_at": info.ModTime().Format(time.RFC3339),
 			"records":     recordCount,
-		})
+		}
+		if scope == scopeAll {
+			entry["project"] = filepath.Base(filepath.Dir(file))
+		}
+		fileMetadata = append(fileMetadata, entry)
 	}
 
 	// Define JSONL schema (simplified version)
@@ -337,24 +335,3 @@ func handleGetSessionMetadata(ctx context.Context, args map[string]interface{})
 
 	return result, nil
 }
-
-// countLines counts the number of lines in a file (approximate record count)
-func countLines(filepath string) (int, error) {
-	file, err := os.Open(filepath)
-	if err != nil {
-		return 0, err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	lineCount := 0
-	for scanner.Scan() {
-		lineCount++
-	}
-
-	if err := scanner.Err(); err != nil {
-		return 0, err
-	}
-
-	return lineCount, nil
-}
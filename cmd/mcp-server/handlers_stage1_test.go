@@ -10,6 +10,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/yaleh/meta-cc/internal/config"
 )
 
 // TestHandleGetSessionDirectory_ProjectScope tests project scope with existing files
@@ -51,9 +52,7 @@ func TestHandleGetSessionDirectory_ProjectScope(t *testing.T) {
 	require.NoError(t, os.WriteFile(file2, []byte(`{"type":"assistant","timestamp":"2025-10-26T14:20:00Z"}`+"\n"), 0644))
 
 	// Execute
-	args := map[string]interface{}{
-		"scope": "project",
-	}
+	args := sessionDirectoryArgs{Scope: "project"}
 
 	result, err := handleGetSessionDirectory(context.Background(), args)
 	require.NoError(t, err)
@@ -124,9 +123,7 @@ func TestHandleGetSessionDirectory_SessionScope(t *testing.T) {
 	require.NoError(t, os.WriteFile(sessionFile, []byte(testData), 0644))
 
 	// Execute
-	args := map[string]interface{}{
-		"scope": "session",
-	}
+	args := sessionDirectoryArgs{Scope: "session"}
 
 	result, err := handleGetSessionDirectory(context.Background(), args)
 	require.NoError(t, err)
@@ -180,9 +177,7 @@ func TestHandleGetSessionDirectory_EmptyDirectory(t *testing.T) {
 	require.NoError(t, os.WriteFile(dummyFile, []byte{}, 0644))
 
 	// Execute
-	args := map[string]interface{}{
-		"scope": "project",
-	}
+	args := sessionDirectoryArgs{Scope: "project"}
 
 	result, err := handleGetSessionDirectory(context.Background(), args)
 	require.NoError(t, err)
@@ -205,7 +200,7 @@ func TestHandleGetSessionDirectory_InvalidScope(t *testing.T) {
 		"scope": "invalid",
 	}
 
-	_, err := handleGetSessionDirectory(context.Background(), args)
+	_, err := NewToolExecutor().ExecuteTool(context.Background(), &config.Config{}, "get_session_directory", args)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid scope")
 }
@@ -214,9 +209,9 @@ func TestHandleGetSessionDirectory_InvalidScope(t *testing.T) {
 func TestHandleGetSessionDirectory_MissingScope(t *testing.T) {
 	args := map[string]interface{}{}
 
-	_, err := handleGetSessionDirectory(context.Background(), args)
+	_, err := NewToolExecutor().ExecuteTool(context.Background(), &config.Config{}, "get_session_directory", args)
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "scope parameter is required")
+	assert.Contains(t, err.Error(), "missing required parameter 'scope'")
 }
 
 // TestHandleGetSessionDirectory_DirectoryNotFound tests non-existent directory
@@ -240,9 +235,7 @@ func TestHandleGetSessionDirectory_DirectoryNotFound(t *testing.T) {
 
 	// Don't create any session files
 
-	args := map[string]interface{}{
-		"scope": "project",
-	}
+	args := sessionDirectoryArgs{Scope: "project"}
 
 	_, err2 := handleGetSessionDirectory(context.Background(), args)
 	require.Error(t, err2)
@@ -280,9 +273,7 @@ func TestGetSessionDirectory_JSON(t *testing.T) {
 	require.NoError(t, os.MkdirAll(sessionDir, 0755))
 	require.NoError(t, os.WriteFile(filepath.Join(sessionDir, "test.jsonl"), []byte(`{"test":"data"}`+"\n"), 0644))
 
-	args := map[string]interface{}{
-		"scope": "project",
-	}
+	args := sessionDirectoryArgs{Scope: "project"}
 
 	result, err := handleGetSessionDirectory(context.Background(), args)
 	require.NoError(t, err)
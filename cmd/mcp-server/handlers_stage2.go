@@ -10,69 +10,43 @@ import (
 // handlers_stage2.go implements Stage 2 tools of the two-stage query architecture
 // Stage 2: Actual query execution on selected files with filtering, sorting, transformation, and limits
 
+// stage2QueryArgs are the arguments of execute_stage2_query
+type stage2QueryArgs struct {
+	Files      []string `json:"files" mcp:"required" path:"read" desc:"Array of absolute file paths to query (from Stage 1 inspection). Object results gain project_path and session_id when files span several projects."`
+	Filter     string   `json:"filter" mcp:"required" desc:"jq filter expression (e.g., 'select(.type == \"user\")'). Required."`
+	Sort       string   `json:"sort" desc:"jq sort expression (e.g., 'sort_by(.timestamp)'). Optional."`
+	Transform  string   `json:"transform" desc:"jq transform expression (e.g., '{type, timestamp}'). Optional."`
+	Limit      int      `json:"limit" desc:"Maximum number of results to return. Optional (default: no limit). With sample, caps the sample."`
+	Sample     int      `json:"sample" desc:"Return N results drawn uniformly at random from all matches, in their original order, instead of the first ones. Optional."`
+	StratifyBy string   `json:"stratify_by" desc:"Stratify sample by this field or jq expression of the results (e.g. 'tool_name', 'session_id'): every value gets a share proportional to its count, at least one result. Optional."`
+	Seed       int      `json:"seed" desc:"Seed of sample; the same seed over the same data draws the same results (default: 0)"`
+}
+
 // handleExecuteStage2Query implements execute_stage2_query tool
 // Executes queries on selected files with jq filtering, sorting, transformation, and result limits
-func handleExecuteStage2Query(ctx context.Context, args map[string]interface{}) (interface{}, error) {
-	// Parse files parameter
-	filesRaw, ok := args["files"]
-	if !ok {
-		return nil, fmt.Errorf("files parameter is required")
-	}
-
-	filesInterface, ok := filesRaw.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("files must be an array")
-	}
-
-	// Convert to string array
-	files := make([]string, 0, len(filesInterface))
-	for i, fileRaw := range filesInterface {
-		file, ok := fileRaw.(string)
-		if !ok {
-			return nil, fmt.Errorf("file at index %d is not a string", i)
-		}
-		files = append(files, file)
-	}
-
-	if len(files) == 0 {
+func handleExecuteStage2Query(ctx context.Context, args stage2QueryArgs) (interface{}, error) {
+	if len(args.Files) == 0 {
 		return nil, fmt.Errorf("files array cannot be empty")
 	}
 
-	// Parse filter parameter (required)
-	filter, ok := args["filter"].(string)
-	if !ok || filter == "" {
-		return nil, fmt.Errorf("filter parameter is required")
-	}
-
-	// Parse optional parameters
-	sort := ""
-	if sortRaw, ok := args["sort"]; ok {
-		sort, _ = sortRaw.(string)
-	}
-
-	transform := ""
-	if transformRaw, ok := args["transform"]; ok {
-		transform, _ = transformRaw.(string)
-	}
-
-	limit := 0
-	if limitRaw, ok := args["limit"]; ok {
-		// Handle both float64 (from JSON) and int
-		switch v := limitRaw.(type) {
-		case float64:
-			limit = int(v)
-		case int:
-			limit = v
-		}
+	sample, err := queryLimitArgs{Sample: args.Sample, StratifyBy: args.StratifyBy, Seed: args.Seed}.sampleConfig()
+	if err != nil {
+		return nil, err
 	}
 
 	// Build query object
 	stage2Query := &query.Stage2Query{
-		Files:     files,
-		Filter:    filter,
-		Sort:      sort,
-		Transform: transform,
-		Limit:     limit,
+		Files:     args.Files, // resolved through the path policy before the call
+		Filter:    args.Filter,
+		Sort:      args.Sort,
+		Transform: args.Transform,
+		Limit:     args.Limit,
+		Sample:    sample,
+
+		// Files from several projects are ambiguous without provenance
+		TagSources: query.SpansProjects(args.Files),
+		OnProgress: scanProgressFunc(ctx),
+		Context:    ctx,
 	}
 
 	// Execute Stage 2 query
@@ -92,6 +66,9 @@ func handleExecuteStage2Query(ctx context.Context, args map[string]interface{})
 			"truncated":             result.Metadata.Truncated,
 		},
 	}
+	if result.Sampling != nil {
+		response["sampling"] = result.Sampling
+	}
 
 	return response, nil
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -62,6 +63,8 @@ func TestQueryToolsInlineMode(t *testing.T) {
 // TestQueryToolsFileRefMode tests large result sets use file_ref mode
 func TestQueryToolsFileRefMode(t *testing.T) {
 	cfg, _ := config.Load()
+	// One file: chunking is covered by TestFileRefListsChunks
+	cfg.Output.ChunkThreshold = 0
 	// Generate large dataset (>8KB, ~250KB)
 	data := make([]interface{}, 0, 5000)
 	longString := strings.Repeat("test-data-padding-", 20) // 360 chars to ensure >8KB
@@ -148,12 +151,15 @@ func TestQueryToolsFileRefMode(t *testing.T) {
 
 // TestCleanupTempFilesE2E tests the cleanup_temp_files tool end-to-end
 func TestCleanupTempFilesE2E(t *testing.T) {
-	// Create test temp files with different ages
-	sessionHash := "test-session"
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	dir := outputStoreFor(cfg).dir
 	now := time.Now()
 
 	// Create old file (10 days old)
-	oldFilePath := filepath.Join(os.TempDir(), "meta-cc-mcp-"+sessionHash+"-old-query_tools.jsonl")
+	oldFilePath := filepath.Join(dir, "query_tools-old.jsonl")
 	oldData := []interface{}{map[string]interface{}{"old": "data"}}
 	if err := writeJSONLFile(oldFilePath, oldData); err != nil {
 		t.Fatalf("failed to create old test file: %v", err)
@@ -165,7 +171,7 @@ func TestCleanupTempFilesE2E(t *testing.T) {
 	}
 
 	// Create recent file (2 days old)
-	recentFilePath := filepath.Join(os.TempDir(), "meta-cc-mcp-"+sessionHash+"-recent-query_tools.jsonl")
+	recentFilePath := filepath.Join(dir, "query_tools-recent.jsonl")
 	recentData := []interface{}{map[string]interface{}{"recent": "data"}}
 	if err := writeJSONLFile(recentFilePath, recentData); err != nil {
 		t.Fatalf("failed to create recent test file: %v", err)
@@ -177,11 +183,7 @@ func TestCleanupTempFilesE2E(t *testing.T) {
 	}
 
 	// Execute cleanup (7 day threshold)
-	args := map[string]interface{}{
-		"max_age_days": 7,
-	}
-
-	result, err := executeCleanupTool(args)
+	result, err := executeCleanupTool(cfg, cleanupArgs{MaxAgeDays: 7})
 	if err != nil {
 		t.Fatalf("executeCleanupTool failed: %v", err)
 	}
@@ -211,6 +213,9 @@ func TestCleanupTempFilesE2E(t *testing.T) {
 	if int(removedCount) < 1 {
 		t.Errorf("expected at least 1 file removed, got %d", int(removedCount))
 	}
+	if cleanupResult["output_dir"] != dir {
+		t.Errorf("expected output_dir=%s, got %v", dir, cleanupResult["output_dir"])
+	}
 
 	// Clean up remaining test file
 	os.Remove(recentFilePath)
@@ -995,7 +1000,7 @@ func TestJSONLOutputFormatE2E(t *testing.T) {
 			"scope":   "session",
 		}
 
-		result, err := executor.ExecuteTool(cfg, "query_user_messages", args)
+		result, err := executor.ExecuteTool(context.Background(), cfg, "query_user_messages", args)
 		if err != nil {
 			t.Fatalf("ExecuteTool failed: %v", err)
 		}
@@ -1101,7 +1106,7 @@ func TestExecuteToolE2E_AllTools(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := executor.ExecuteTool(cfg, tt.toolName, tt.args)
+			result, err := executor.ExecuteTool(context.Background(), cfg, tt.toolName, tt.args)
 			if err != nil {
 				t.Fatalf("ExecuteTool(%s) failed: %v", tt.toolName, err)
 			}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/yaleh/meta-cc/internal/version"
+)
+
+// lifecycle.go implements the MCP base protocol outside of tools:
+// version negotiation, ping, notifications and logging/setLevel
+
+// serverName is reported in serverInfo and logs
+const serverName = "meta-cc-mcp"
+
+// supportedProtocolVersions lists published MCP revisions, newest first
+var supportedProtocolVersions = []string{
+	"2025-06-18",
+	"2025-03-26",
+	"2024-11-05",
+}
+
+// negotiateProtocolVersion returns the client's requested version when we
+// support it, otherwise our latest version (the client decides whether to proceed)
+func negotiateProtocolVersion(requested string) string {
+	for _, v := range supportedProtocolVersions {
+		if v == requested {
+			return v
+		}
+	}
+	return supportedProtocolVersions[0]
+}
+
+// serverInfo returns the implementation info sent during initialize
+func serverInfo() map[string]string {
+	return map[string]string{
+		"name":    serverName,
+		"version": version.String(),
+	}
+}
+
+// serverCapabilities returns the capabilities advertised during initialize
+func serverCapabilities() map[string]interface{} {
+	return map[string]interface{}{
+		"tools":       map[string]bool{},
+		"logging":     map[string]bool{},
+		"completions": map[string]bool{},
+	}
+}
+
+// isNotification reports whether a message is a JSON-RPC notification (no response expected)
+func isNotification(req JSONRPCRequest) bool {
+	return req.ID == nil && strings.HasPrefix(req.Method, "notifications/")
+}
+
+// handleNotification acknowledges client notifications; none produce a response
+func handleNotification(ctx context.Context, req JSONRPCRequest) {
+	switch req.Method {
+	case "notifications/initialized":
+		slog.Info("client initialized",
+			"trace_id", GetTraceID(ctx),
+		)
+	case "notifications/cancelled":
+		// Requests are processed sequentially, so there is nothing in flight to cancel
+		slog.Debug("cancellation received",
+			"request_id", req.Params["requestId"],
+			"reason", req.Params["reason"],
+		)
+	default:
+		slog.Debug("ignoring notification",
+			"method", req.Method,
+		)
+	}
+}
+
+// handlePing answers the ping utility with an empty result
+func handlePing(ctx context.Context, req JSONRPCRequest) {
+	writeResponse(req.ID, map[string]interface{}{})
+}
+
+// mcpLogLevels maps RFC 5424 severities used by MCP to slog levels
+var mcpLogLevels = map[string]slog.Level{
+	"debug":     slog.LevelDebug,
+	"info":      slog.LevelInfo,
+	"notice":    slog.LevelInfo,
+	"warning":   slog.LevelWarn,
+	"error":     slog.LevelError,
+	"critical":  slog.LevelError,
+	"alert":     slog.LevelError,
+	"emergency": slog.LevelError,
+}
+
+// parseMCPLogLevel converts an MCP logging level to a slog level
+func parseMCPLogLevel(level string) (slog.Level, error) {
+	l, ok := mcpLogLevels[strings.ToLower(level)]
+	if !ok {
+		return slog.LevelInfo, fmt.Errorf("invalid log level: %s", level)
+	}
+	return l, nil
+}
+
+// handleLoggingSetLevel implements logging/setLevel by adjusting the global slog level
+func handleLoggingSetLevel(ctx context.Context, req JSONRPCRequest) {
+	levelName, _ := req.Params["level"].(string)
+	level, err := parseMCPLogLevel(levelName)
+	if err != nil {
+		RecordRequest("logging", req.Method, "invalid")
+		writeError(req.ID, -32602, "Invalid params: "+err.Error())
+		return
+	}
+
+	logLevel.Set(level)
+	slog.Info("log level changed",
+		"level", level.String(),
+		"trace_id", GetTraceID(ctx),
+	)
+
+	RecordRequest("logging", req.Method, "success")
+	writeResponse(req.ID, map[string]interface{}{})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// captureResponse runs handleRequest and returns the raw output
+func captureResponse(t *testing.T, req JSONRPCRequest) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	origStdout := outputWriter
+	outputWriter = &buf
+	defer func() { outputWriter = origStdout }()
+
+	handleRequest(req)
+	return buf.Bytes()
+}
+
+// decodeResult parses a response and fails on JSON-RPC errors
+func decodeResult(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	var resp JSONRPCResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("failed to parse response: %v (%s)", err, data)
+	}
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	result, ok := resp.Result.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected result map, got %T", resp.Result)
+	}
+	return result
+}
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"2024-11-05", "2024-11-05"},
+		{"2025-03-26", "2025-03-26"},
+		{"2025-06-18", "2025-06-18"},
+		{"2099-01-01", supportedProtocolVersions[0]},
+		{"", supportedProtocolVersions[0]},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateProtocolVersion(tt.requested); got != tt.want {
+			t.Errorf("negotiateProtocolVersion(%q) = %s, want %s", tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestHandleInitializeNegotiatesVersion(t *testing.T) {
+	result := decodeResult(t, captureResponse(t, JSONRPCRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "initialize",
+		Params:  map[string]interface{}{"protocolVersion": "2025-03-26"},
+	}))
+
+	if result["protocolVersion"] != "2025-03-26" {
+		t.Errorf("expected negotiated 2025-03-26, got %v", result["protocolVersion"])
+	}
+
+	caps, _ := result["capabilities"].(map[string]interface{})
+	for _, name := range []string{"tools", "logging", "completions"} {
+		if _, ok := caps[name]; !ok {
+			t.Errorf("expected %s capability", name)
+		}
+	}
+
+	info, _ := result["serverInfo"].(map[string]interface{})
+	if info["name"] != serverName || info["version"] == "" {
+		t.Errorf("unexpected serverInfo: %v", info)
+	}
+}
+
+func TestHandlePing(t *testing.T) {
+	result := decodeResult(t, captureResponse(t, JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: "ping"}))
+	if len(result) != 0 {
+		t.Errorf("expected empty ping result, got %v", result)
+	}
+}
+
+func TestNotificationsProduceNoResponse(t *testing.T) {
+	for _, method := range []string{"notifications/initialized", "notifications/cancelled", "notifications/unknown"} {
+		out := captureResponse(t, JSONRPCRequest{JSONRPC: "2.0", Method: method})
+		if len(out) != 0 {
+			t.Errorf("%s: expected no output, got %s", method, out)
+		}
+	}
+}
+
+func TestHandleLoggingSetLevel(t *testing.T) {
+	orig := logLevel.Level()
+	defer logLevel.Set(orig)
+
+	decodeResult(t, captureResponse(t, JSONRPCRequest{
+		JSONRPC: "2.0",
+		ID:      3,
+		Method:  "logging/setLevel",
+		Params:  map[string]interface{}{"level": "warning"},
+	}))
+	if logLevel.Level() != slog.LevelWarn {
+		t.Errorf("expected WARN level, got %s", logLevel.Level())
+	}
+
+	var resp JSONRPCResponse
+	out := captureResponse(t, JSONRPCRequest{
+		JSONRPC: "2.0",
+		ID:      4,
+		Method:  "logging/setLevel",
+		Params:  map[string]interface{}{"level": "verbose"},
+	})
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("expected invalid params error, got %+v", resp.Error)
+	}
+}
+
+func TestHandleCompletionComplete(t *testing.T) {
+	var buf bytes.Buffer
+	origStdout := outputWriter
+	outputWriter = &buf
+	defer func() { outputWriter = origStdout }()
+
+	handleCompletionComplete(context.Background(), JSONRPCRequest{
+		JSONRPC: "2.0",
+		ID:      5,
+		Method:  "completion/complete",
+		Params: map[string]interface{}{
+			"ref":      map[string]interface{}{"type": "ref/tool", "name": "query_tools"},
+			"argument": map[string]interface{}{"name": "tool_name", "value": "we"},
+		},
+	})
+
+	result := decodeResult(t, buf.Bytes())
+	completion, _ := result["completion"].(map[string]interface{})
+	values, _ := completion["values"].([]interface{})
+	if len(values) != 2 || values[0] != "WebFetch" || values[1] != "WebSearch" {
+		t.Errorf("expected [WebFetch WebSearch], got %v", values)
+	}
+	if completion["hasMore"] != false {
+		t.Errorf("expected hasMore=false, got %v", completion["hasMore"])
+	}
+}
+
+func TestCompleteArgument(t *testing.T) {
+	if got := completeArgument("get_capability", "type", "p"); len(got) != 1 || got[0] != "prompts" {
+		t.Errorf("expected [prompts], got %v", got)
+	}
+	if got := completeArgument("query_tools", "type", ""); len(got) != 0 {
+		t.Errorf("type should only complete for capability tools, got %v", got)
+	}
+	if got := completeArgument("query_tools", "unknown_arg", ""); len(got) != 0 {
+		t.Errorf("expected no values for unknown argument, got %v", got)
+	}
+	// Enums declared on argument structs complete without a dedicated provider
+	if got := completeArgument("query_tool_blocks", "block_type", "tool_r"); len(got) != 1 || got[0] != "tool_result" {
+		t.Errorf("expected [tool_result], got %v", got)
+	}
+}
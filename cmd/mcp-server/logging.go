@@ -13,11 +13,15 @@ import (
 // loggerContextKey is the context key for storing the logger
 type loggerContextKey struct{}
 
+// logLevel is the shared minimum level for the global logger.
+// It starts from configuration and can be changed at runtime via logging/setLevel.
+var logLevel = new(slog.LevelVar)
+
 // InitLogger initializes the global slog logger with centralized configuration
 func InitLogger(cfg *config.Config) {
 	// Use log level from centralized config
 	// Config handles LOG_LEVEL fallback for backward compatibility
-	logLevel := cfg.Log.Level
+	logLevel.Set(cfg.Log.Level)
 
 	// Create JSON handler for structured logging
 	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
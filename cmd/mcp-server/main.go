@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/yaleh/meta-cc/internal/config"
+	"github.com/yaleh/meta-cc/internal/version"
 )
 
 // Global configuration (loaded at startup)
@@ -28,8 +29,10 @@ func main() {
 	InitLogger(cfg)
 
 	slog.Info("MCP server starting",
-		"server_name", "meta-cc-mcp",
-		"version", "1.0.0",
+		"server_name", serverName,
+		"version", version.String(),
+		"commit", version.Commit,
+		"build_time", version.BuildTime,
 	)
 
 	// Initialize distributed tracing
@@ -47,6 +50,24 @@ func main() {
 	// Start resource monitoring (USE metrics)
 	StartResourceMonitoring(10 * time.Second)
 
+	// Start optional metrics/pprof listener (opt-in via META_CC_METRICS_ADDR)
+	if cfg.Metrics.Enabled() {
+		metricsCleanup, err := StartMetricsServer(cfg.Metrics.Addr)
+		if err != nil {
+			slog.Error("failed to start metrics server",
+				"error", err.Error(),
+				"error_type", classifyError(err),
+			)
+			// Continue without metrics listener (non-fatal)
+		} else {
+			defer metricsCleanup()
+		}
+	}
+
+	// Sweep file_ref outputs now and periodically (age and quota)
+	stopOutputCleanup := startOutputCleanup(outputStoreFor(cfg), outputCleanupInterval)
+	defer stopOutputCleanup()
+
 	// Setup cleanup on exit
 	defer func() {
 		slog.Info("MCP server shutting down")
@@ -9,6 +9,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/shirou/gopsutil/v3/process"
+	"github.com/yaleh/meta-cc/internal/query"
 )
 
 var (
@@ -127,6 +128,78 @@ var (
 	)
 )
 
+// Shared jq expression cache metrics, read from the cache counters on scrape
+var (
+	jqCacheHits = prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "mcp_server_jq_cache_hits_total",
+			Help: "Compiled jq expressions served from the shared cache",
+		},
+		func() float64 { return float64(query.SharedJQCacheStats().Hits) },
+	)
+
+	jqCacheMisses = prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "mcp_server_jq_cache_misses_total",
+			Help: "jq expressions compiled because they were not cached",
+		},
+		func() float64 { return float64(query.SharedJQCacheStats().Misses) },
+	)
+
+	jqCacheEvictions = prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "mcp_server_jq_cache_evictions_total",
+			Help: "Least recently used jq expressions evicted from the shared cache",
+		},
+		func() float64 { return float64(query.SharedJQCacheStats().Evictions) },
+	)
+
+	jqCacheEntries = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "mcp_server_jq_cache_entries",
+			Help: "Compiled jq expressions currently held by the shared cache",
+		},
+		func() float64 { return float64(query.SharedJQCacheStats().Size) },
+	)
+)
+
+// Security metrics
+var (
+	pathPolicyViolations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcp_server_path_policy_violations_total",
+			Help: "File paths rejected because they are outside the allowed roots",
+		},
+		[]string{"tool_name"},
+	)
+
+	redactionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcp_server_redactions_total",
+			Help: "Secrets and personal data masked in tool responses",
+		},
+		[]string{"tool_name", "kind"},
+	)
+)
+
+// file_ref output storage metrics
+var (
+	outputReuses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcp_server_file_ref_reuses_total",
+			Help: "file_ref outputs served from an identical stored result set",
+		},
+		[]string{"tool_name"},
+	)
+
+	outputEvictions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mcp_server_file_ref_evictions_total",
+			Help: "Least recently used file_ref outputs evicted to stay within the quota",
+		},
+	)
+)
+
 // Atomic counters for saturation metrics (thread-safe)
 var (
 	requestQueueCounter   atomic.Int32
@@ -153,11 +226,28 @@ func init() {
 	prometheus.MustRegister(resourceErrors)
 	prometheus.MustRegister(timeoutErrors)
 
+	// Register jq cache metrics
+	prometheus.MustRegister(jqCacheHits)
+	prometheus.MustRegister(jqCacheMisses)
+	prometheus.MustRegister(jqCacheEvictions)
+	prometheus.MustRegister(jqCacheEntries)
+
+	// Register security metrics
+	prometheus.MustRegister(pathPolicyViolations)
+	prometheus.MustRegister(redactionsTotal)
+
+	// Register output storage metrics
+	prometheus.MustRegister(outputReuses)
+	prometheus.MustRegister(outputEvictions)
+
 	slog.Debug("Prometheus metrics registered",
-		"metrics_count", 15,
+		"metrics_count", 23,
 		"red_metrics", 5,
 		"use_metrics", 10,
-		"cardinality_estimate", 1043,
+		"cache_metrics", 4,
+		"security_metrics", 2,
+		"storage_metrics", 2,
+		"cardinality_estimate", 1091,
 	)
 }
 
@@ -329,6 +419,26 @@ func RecordConcurrentRequestDec() {
 	concurrentRequests.Set(float64(concurrentReqsCounter.Load()))
 }
 
+// RecordPathPolicyViolation records a file path rejected by the path policy
+func RecordPathPolicyViolation(toolName string) {
+	pathPolicyViolations.WithLabelValues(toolName).Inc()
+}
+
+// RecordRedactions records values of a kind masked in a tool response
+func RecordRedactions(toolName, kind string, count int) {
+	redactionsTotal.WithLabelValues(toolName, kind).Add(float64(count))
+}
+
+// RecordOutputReuse records a file_ref output reused for an identical result set
+func RecordOutputReuse(toolName string) {
+	outputReuses.WithLabelValues(toolName).Inc()
+}
+
+// RecordOutputEvictions records file_ref outputs evicted over the quota
+func RecordOutputEvictions(count int) {
+	outputEvictions.Add(float64(count))
+}
+
 // RecordResourceError records a resource-related error (USE Error metric)
 func RecordResourceError(resourceType string) {
 	resourceErrors.WithLabelValues(resourceType).Inc()
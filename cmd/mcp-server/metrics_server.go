@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics_server.go exposes the Prometheus registry from metrics.go
+// Two surfaces share the same data:
+//   - An opt-in HTTP listener (META_CC_METRICS_ADDR) serving /metrics, /healthz and pprof
+//   - The get_server_metrics MCP tool, for clients that cannot scrape
+
+// metricsShutdownTimeout bounds how long shutdown waits for in-flight scrapes
+const metricsShutdownTimeout = 2 * time.Second
+
+// StartMetricsServer starts the observability HTTP listener on addr.
+// The returned cleanup function stops the listener.
+func StartMetricsServer(addr string) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           newMetricsMux(prometheus.DefaultGatherer),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics server stopped",
+				"error", err.Error(),
+				"error_type", "network_error",
+			)
+		}
+	}()
+
+	slog.Info("metrics server started",
+		"addr", listener.Addr().String(),
+	)
+
+	cleanup := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Warn("metrics server shutdown failed",
+				"error", err.Error(),
+			)
+		}
+	}
+
+	return cleanup, nil
+}
+
+// newMetricsMux builds the handler tree for the observability listener
+func newMetricsMux(gatherer prometheus.Gatherer) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	})
+
+	// Register pprof explicitly; importing it for side effects would attach
+	// handlers to http.DefaultServeMux instead
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return mux
+}
+
+// MetricFamilySnapshot is the JSON form of a Prometheus metric family
+type MetricFamilySnapshot struct {
+	Name    string           `json:"name"`
+	Help    string           `json:"help"`
+	Type    string           `json:"type"`
+	Samples []MetricSnapshot `json:"samples"`
+}
+
+// MetricSnapshot is a single labelled sample within a metric family
+type MetricSnapshot struct {
+	Labels  map[string]string `json:"labels,omitempty"`
+	Value   *float64          `json:"value,omitempty"`
+	Count   *uint64           `json:"count,omitempty"`
+	Sum     *float64          `json:"sum,omitempty"`
+	Buckets map[string]uint64 `json:"buckets,omitempty"` // upper bound -> cumulative count
+}
+
+// snapshotMetrics gathers the registry into a JSON-friendly structure
+func snapshotMetrics(gatherer prometheus.Gatherer) ([]MetricFamilySnapshot, error) {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	snapshots := make([]MetricFamilySnapshot, 0, len(families))
+	for _, family := range families {
+		snapshot := MetricFamilySnapshot{
+			Name:    family.GetName(),
+			Help:    family.GetHelp(),
+			Type:    family.GetType().String(),
+			Samples: make([]MetricSnapshot, 0, len(family.GetMetric())),
+		}
+
+		for _, metric := range family.GetMetric() {
+			sample := MetricSnapshot{}
+			if pairs := metric.GetLabel(); len(pairs) > 0 {
+				sample.Labels = make(map[string]string, len(pairs))
+				for _, pair := range pairs {
+					sample.Labels[pair.GetName()] = pair.GetValue()
+				}
+			}
+
+			switch {
+			case metric.GetCounter() != nil:
+				value := metric.GetCounter().GetValue()
+				sample.Value = &value
+			case metric.GetGauge() != nil:
+				value := metric.GetGauge().GetValue()
+				sample.Value = &value
+			case metric.GetUntyped() != nil:
+				value := metric.GetUntyped().GetValue()
+				sample.Value = &value
+			case metric.GetHistogram() != nil:
+				histogram := metric.GetHistogram()
+				count := histogram.GetSampleCount()
+				sum := histogram.GetSampleSum()
+				sample.Count = &count
+				sample.Sum = &sum
+				sample.Buckets = make(map[string]uint64, len(histogram.GetBucket()))
+				for _, bucket := range histogram.GetBucket() {
+					bound := strconv.FormatFloat(bucket.GetUpperBound(), 'g', -1, 64)
+					sample.Buckets[bound] = bucket.GetCumulativeCount()
+				}
+			case metric.GetSummary() != nil:
+				count := metric.GetSummary().GetSampleCount()
+				sum := metric.GetSummary().GetSampleSum()
+				sample.Count = &count
+				sample.Sum = &sum
+			}
+
+			snapshot.Samples = append(snapshot.Samples, sample)
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// serverMetricsArgs are the arguments of get_server_metrics
+type serverMetricsArgs struct {
+	Prefix string `json:"prefix" default:"mcp_server_" desc:"Only include metric families with this name prefix (default: mcp_server_, empty for all)"`
+}
+
+// executeGetServerMetricsTool implements the get_server_metrics tool
+func executeGetServerMetricsTool(args serverMetricsArgs) (string, error) {
+	families, err := snapshotMetrics(prometheus.DefaultGatherer)
+	if err != nil {
+		return "", err
+	}
+
+	filtered := make([]MetricFamilySnapshot, 0, len(families))
+	for _, family := range families {
+		if strings.HasPrefix(family.Name, args.Prefix) {
+			filtered = append(filtered, family)
+		}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"timestamp": time.Now().Format(time.RFC3339),
+		"metrics":   filtered,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metrics: %w", err)
+	}
+
+	return string(data), nil
+}
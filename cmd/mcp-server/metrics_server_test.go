@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsMuxEndpoints(t *testing.T) {
+	RecordRequest("query_tools", "tools/call", "success")
+
+	server := httptest.NewServer(newMetricsMux(prometheus.DefaultGatherer))
+	defer server.Close()
+
+	tests := []struct {
+		path     string
+		contains string
+	}{
+		{"/metrics", "mcp_server_requests_total"},
+		{"/metrics", "mcp_server_jq_cache_hits_total"},
+		{"/healthz", `"status":"ok"`},
+		{"/debug/pprof/", "goroutine"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatalf("GET %s failed: %v", tt.path, err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s: expected 200, got %d", tt.path, resp.StatusCode)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), tt.contains) {
+				t.Errorf("GET %s: expected body to contain %q", tt.path, tt.contains)
+			}
+		})
+	}
+}
+
+func TestStartMetricsServer(t *testing.T) {
+	cleanup, err := StartMetricsServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("StartMetricsServer failed: %v", err)
+	}
+	cleanup()
+}
+
+func TestExecuteGetServerMetricsTool(t *testing.T) {
+	RecordRequest("query_tools", "tools/call", "success")
+	RecordRequestDuration("query_tools", "success", 0)
+
+	output, err := NewToolExecutor().ExecuteTool(context.Background(), nil, "get_server_metrics", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_server_metrics failed: %v", err)
+	}
+
+	var snapshot struct {
+		Metrics []MetricFamilySnapshot `json:"metrics"`
+	}
+	if err := json.Unmarshal([]byte(output), &snapshot); err != nil {
+		t.Fatalf("failed to parse snapshot: %v", err)
+	}
+
+	found := map[string]MetricFamilySnapshot{}
+	for _, family := range snapshot.Metrics {
+		if !strings.HasPrefix(family.Name, "mcp_server_") {
+			t.Errorf("default prefix should exclude %s", family.Name)
+		}
+		found[family.Name] = family
+	}
+
+	requests, ok := found["mcp_server_requests_total"]
+	if !ok || len(requests.Samples) == 0 || requests.Samples[0].Value == nil {
+		t.Fatalf("expected counter samples for mcp_server_requests_total, got %+v", requests)
+	}
+
+	duration, ok := found["mcp_server_request_duration_seconds"]
+	if !ok || len(duration.Samples) == 0 || duration.Samples[0].Count == nil || len(duration.Samples[0].Buckets) == 0 {
+		t.Errorf("expected histogram samples for mcp_server_request_duration_seconds, got %+v", duration)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// otlp_json_exporter.go implements a minimal OTLP/JSON span exporter
+// The payload is an ExportTraceServiceRequest encoded with the OTLP JSON mapping,
+// which both local collectors (Jaeger, otelcol on :4318) and the OTLP file
+// format accept. Encoding by hand keeps gRPC and protobuf out of the binary.
+
+// otlpHTTPTimeout bounds a single export POST
+const otlpHTTPTimeout = 10 * time.Second
+
+// otlpJSONExporter is an sdktrace.SpanExporter that writes OTLP/JSON payloads to a sink
+type otlpJSONExporter struct {
+	mu       sync.Mutex
+	send     func(ctx context.Context, payload []byte) error
+	close    func() error
+	shutdown bool
+}
+
+// newOTLPFileExporter appends one ExportTraceServiceRequest per line to path
+func newOTLPFileExporter(path string) (*otlpJSONExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file %s: %w", path, err)
+	}
+
+	return newOTLPWriterExporter(file, file.Close), nil
+}
+
+// newOTLPWriterExporter writes one ExportTraceServiceRequest per line to w
+func newOTLPWriterExporter(w io.Writer, closeFn func() error) *otlpJSONExporter {
+	return &otlpJSONExporter{
+		send: func(ctx context.Context, payload []byte) error {
+			_, err := w.Write(append(payload, '\n'))
+			return err
+		},
+		close: closeFn,
+	}
+}
+
+// newOTLPHTTPExporter POSTs JSON payloads to an OTLP/HTTP traces endpoint
+func newOTLPHTTPExporter(endpoint string) *otlpJSONExporter {
+	client := &http.Client{Timeout: otlpHTTPTimeout}
+
+	return &otlpJSONExporter{
+		send: func(ctx context.Context, payload []byte) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+			if err != nil {
+				return fmt.Errorf("failed to build OTLP request: %w", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := client.Do(req)
+			if err != nil {
+				return fmt.Errorf("failed to export spans to %s: %w", endpoint, err)
+			}
+			defer resp.Body.Close()
+			_, _ = io.Copy(io.Discard, resp.Body)
+
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				return fmt.Errorf("OTLP endpoint %s returned status %d", endpoint, resp.StatusCode)
+			}
+			return nil
+		},
+	}
+}
+
+// ExportSpans encodes spans and hands them to the sink
+func (e *otlpJSONExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(encodeOTLPTraces(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.shutdown {
+		return nil
+	}
+	return e.send(ctx, payload)
+}
+
+// Shutdown releases the sink; later exports are dropped
+func (e *otlpJSONExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.shutdown {
+		return nil
+	}
+	e.shutdown = true
+	if e.close != nil {
+		return e.close()
+	}
+	return nil
+}
+
+// OTLP JSON mapping types (subset of opentelemetry-proto trace/v1)
+
+type otlpTracesData struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"` // int64 is a string in OTLP JSON
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+// encodeOTLPTraces groups spans by resource and instrumentation scope
+func encodeOTLPTraces(spans []sdktrace.ReadOnlySpan) otlpTracesData {
+	type scopeKey struct{ name, version string }
+
+	var data otlpTracesData
+	resourceIndex := make(map[attribute.Distinct]int)
+	scopeIndex := make(map[attribute.Distinct]map[scopeKey]int)
+
+	for _, span := range spans {
+		res := span.Resource()
+		resKey := resourceKey(res)
+		ri, ok := resourceIndex[resKey]
+		if !ok {
+			ri = len(data.ResourceSpans)
+			resourceIndex[resKey] = ri
+			scopeIndex[resKey] = make(map[scopeKey]int)
+			data.ResourceSpans = append(data.ResourceSpans, otlpResourceSpans{
+				Resource: otlpResource{Attributes: encodeAttributes(resourceAttributes(res))},
+			})
+		}
+
+		scope := span.InstrumentationScope()
+		sk := scopeKey{scope.Name, scope.Version}
+		si, ok := scopeIndex[resKey][sk]
+		if !ok {
+			si = len(data.ResourceSpans[ri].ScopeSpans)
+			scopeIndex[resKey][sk] = si
+			data.ResourceSpans[ri].ScopeSpans = append(data.ResourceSpans[ri].ScopeSpans, otlpScopeSpans{
+				Scope: otlpScope{Name: scope.Name, Version: scope.Version},
+			})
+		}
+
+		scopeSpans := &data.ResourceSpans[ri].ScopeSpans[si]
+		scopeSpans.Spans = append(scopeSpans.Spans, encodeSpan(span))
+	}
+
+	return data
+}
+
+// encodeSpan converts a finished span to its OTLP JSON form
+func encodeSpan(span sdktrace.ReadOnlySpan) otlpSpan {
+	sc := span.SpanContext()
+	encoded := otlpSpan{
+		TraceID:           sc.TraceID().String(),
+		SpanID:            sc.SpanID().String(),
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()), // trace.SpanKind matches the OTLP enum values
+		StartTimeUnixNano: unixNanoString(span.StartTime()),
+		EndTimeUnixNano:   unixNanoString(span.EndTime()),
+		Attributes:        encodeAttributes(span.Attributes()),
+		Status:            encodeStatus(span.Status()),
+	}
+	if parent := span.Parent(); parent.HasSpanID() {
+		encoded.ParentSpanID = parent.SpanID().String()
+	}
+
+	for _, event := range span.Events() {
+		encoded.Events = append(encoded.Events, otlpEvent{
+			TimeUnixNano: unixNanoString(event.Time),
+			Name:         event.Name,
+			Attributes:   encodeAttributes(event.Attributes),
+		})
+	}
+
+	return encoded
+}
+
+// encodeStatus maps otel codes to OTLP status codes (Unset=0, Ok=1, Error=2)
+func encodeStatus(status sdktrace.Status) otlpStatus {
+	switch status.Code {
+	case codes.Ok:
+		return otlpStatus{Code: 1}
+	case codes.Error:
+		return otlpStatus{Code: 2, Message: status.Description}
+	default:
+		return otlpStatus{}
+	}
+}
+
+// encodeAttributes converts attribute key/values to OTLP JSON
+func encodeAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	encoded := make([]otlpKeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		encoded = append(encoded, otlpKeyValue{
+			Key:   string(kv.Key),
+			Value: encodeValue(kv.Value),
+		})
+	}
+	return encoded
+}
+
+// encodeValue converts a single attribute value to an OTLP AnyValue
+func encodeValue(v attribute.Value) otlpAnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return otlpAnyValue{BoolValue: &b}
+	case attribute.INT64:
+		i := strconv.FormatInt(v.AsInt64(), 10)
+		return otlpAnyValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return otlpAnyValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		values := make([]otlpAnyValue, 0)
+		for _, b := range v.AsBoolSlice() {
+			values = append(values, encodeValue(attribute.BoolValue(b)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.INT64SLICE:
+		values := make([]otlpAnyValue, 0)
+		for _, i := range v.AsInt64Slice() {
+			values = append(values, encodeValue(attribute.Int64Value(i)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.FLOAT64SLICE:
+		values := make([]otlpAnyValue, 0)
+		for _, f := range v.AsFloat64Slice() {
+			values = append(values, encodeValue(attribute.Float64Value(f)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.STRINGSLICE:
+		values := make([]otlpAnyValue, 0)
+		for _, s := range v.AsStringSlice() {
+			values = append(values, encodeValue(attribute.StringValue(s)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	default:
+		s := v.Emit()
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+// resourceKey returns a comparable identity for a (possibly nil) resource
+func resourceKey(res *resource.Resource) attribute.Distinct {
+	if res == nil {
+		return attribute.Distinct{}
+	}
+	return res.Equivalent()
+}
+
+// resourceAttributes returns the attributes of a (possibly nil) resource
+func resourceAttributes(res *resource.Resource) []attribute.KeyValue {
+	if res == nil {
+		return nil
+	}
+	return res.Attributes()
+}
+
+// unixNanoString formats a timestamp as OTLP JSON fixed64 (decimal string)
+func unixNanoString(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
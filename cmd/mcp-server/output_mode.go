@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/yaleh/meta-cc/internal/config"
+	"github.com/yaleh/meta-cc/pkg/output"
 )
 
 const (
@@ -11,14 +12,23 @@ const (
 	DefaultInlineThresholdBytes = 8 * 1024 // 8KB
 
 	// Output mode constants
-	OutputModeInline  = "inline"
-	OutputModeFileRef = "file_ref"
+	OutputModeInline     = "inline"
+	OutputModeFileRef    = "file_ref"
+	OutputModeSummarized = "summarized"
 )
 
 // OutputModeConfig holds configuration for output mode selection
 type OutputModeConfig struct {
 	// InlineThresholdBytes is the maximum size for inline mode (default: 8KB)
 	InlineThresholdBytes int
+	// MaxTokens is the token budget of the response; larger results are
+	// summarized (default: 0, no budget)
+	MaxTokens int
+}
+
+// isOutputMode reports whether mode names an output mode
+func isOutputMode(mode string) bool {
+	return mode == OutputModeInline || mode == OutputModeFileRef || mode == OutputModeSummarized
 }
 
 // DefaultOutputModeConfig returns the default configuration
@@ -95,13 +105,18 @@ func selectOutputMode(size int, explicitMode string) string {
 }
 
 // selectOutputModeWithConfig is the same as selectOutputMode but allows custom configuration.
-// This is useful for testing different thresholds or future configuration options.
+// With a token budget (MaxTokens), results estimated above the budget are
+// summarized rather than written to a file_ref the assistant may never read.
 func selectOutputModeWithConfig(size int, explicitMode string, config *OutputModeConfig) string {
 	// Check for explicit mode override
-	if explicitMode == OutputModeInline || explicitMode == OutputModeFileRef {
+	if isOutputMode(explicitMode) {
 		return explicitMode
 	}
 
+	if config.MaxTokens > 0 && output.EstimateTokens(size) > config.MaxTokens {
+		return OutputModeSummarized
+	}
+
 	// Auto-select based on size threshold
 	if size <= config.InlineThresholdBytes {
 		return OutputModeInline
@@ -115,31 +130,27 @@ func selectOutputModeWithConfig(size int, explicitMode string, config *OutputMod
 //
 // Parameters:
 //   - globalCfg: Centralized configuration (loaded at startup)
-//   - params: MCP tool parameters (may contain inline_threshold_bytes)
+//   - params: MCP tool parameters (may contain inline_threshold_bytes, max_tokens)
 //
 // Returns:
-//   - OutputModeConfig with resolved threshold
+//   - OutputModeConfig with resolved threshold and token budget
 //
 // Configuration Sources:
-//  1. Parameter: inline_threshold_bytes (highest priority - per-request override)
-//  2. Centralized Config: cfg.Output.InlineThreshold (from META_CC_INLINE_THRESHOLD)
-//  3. Default: 8192 bytes (8KB, applied in config.Load())
+//  1. Parameter: inline_threshold_bytes / max_tokens (highest priority - per-request override)
+//  2. Centralized Config: cfg.Output.InlineThreshold (META_CC_INLINE_THRESHOLD),
+//     cfg.Output.MaxTokens (META_CC_MAX_TOKENS)
+//  3. Default: 8192 bytes (8KB), no token budget (applied in config.Load())
 func getOutputModeConfig(globalCfg *config.Config, params map[string]interface{}) *OutputModeConfig {
 	config := DefaultOutputModeConfig()
 
-	// Check parameter first (highest priority - per-request override)
-	if thresholdParam, ok := params["inline_threshold_bytes"]; ok {
-		if threshold, ok := thresholdParam.(float64); ok {
-			config.InlineThresholdBytes = int(threshold)
-			return config
-		}
-		if threshold, ok := thresholdParam.(int); ok {
-			config.InlineThresholdBytes = threshold
-			return config
-		}
-	}
-
 	// Use centralized config (loaded from environment at startup)
 	config.InlineThresholdBytes = globalCfg.Output.InlineThreshold
+	config.MaxTokens = globalCfg.Output.MaxTokens
+
+	// Parameters override it per request
+	if _, ok := params["inline_threshold_bytes"]; ok {
+		config.InlineThresholdBytes = getIntParam(params, "inline_threshold_bytes", config.InlineThresholdBytes)
+	}
+	config.MaxTokens = getIntParam(params, "max_tokens", config.MaxTokens)
 	return config
 }
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/pkg/output"
+)
+
+// output_store.go keeps file_ref outputs in a private per-user directory
+// (0700 directory, 0600 files) instead of the shared temp dir. The directory
+// is capped by a size quota with least-recently-used eviction, swept on
+// startup and periodically, and identical result sets share one file.
+//
+// Every stored result set is identified by a result_id (the file name without
+// .jsonl) and may be given names (save_as), so query_result can refine it
+// later without rescanning sessions.
+//
+// Results larger than the chunk threshold are stored as a directory named by
+// the result_id, holding chunk files split along sessions or days and a
+// manifest (see output.SplitRecords). The directory counts as one output for
+// quota, eviction and cleanup.
+
+// outputCleanupInterval is how often a running server sweeps its outputs
+const outputCleanupInterval = 10 * time.Minute
+
+// resultNamesFile maps save_as names to result IDs inside the store directory
+const resultNamesFile = "names.json"
+
+// chunkManifestFile describes the chunks of a chunked result
+const chunkManifestFile = "manifest.json"
+
+var (
+	// resultIDPattern matches result IDs: <tool>-<content hash>
+	resultIDPattern = regexp.MustCompile(`^[a-z0-9_]+-[0-9a-f]{16}$`)
+	// resultNamePattern matches names given with save_as
+	resultNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]{0,63}$`)
+)
+
+// resultID returns the ID of the result stored at path (a JSONL file or the
+// manifest of a chunked result)
+func resultID(path string) string {
+	if filepath.Base(path) == chunkManifestFile {
+		return filepath.Base(filepath.Dir(path))
+	}
+	return strings.TrimSuffix(filepath.Base(path), ".jsonl")
+}
+
+// outputStore manages the file_ref outputs of one directory
+type outputStore struct {
+	mu         sync.Mutex
+	dir        string
+	quota      int64
+	maxAgeDays int
+	// chunkBytes is the size above which results are chunked (0: never)
+	chunkBytes int
+}
+
+// outputStores shares one store (and its lock) per directory
+var (
+	outputStoresMu sync.Mutex
+	outputStores   = make(map[string]*outputStore)
+)
+
+// outputStoreFor returns the store configured by cfg. A nil cfg or one whose
+// output settings were never loaded (QuotaMB is validated to be positive)
+// uses the environment and its defaults.
+func outputStoreFor(cfg *config.Config) *outputStore {
+	if cfg == nil || cfg.Output.QuotaMB <= 0 {
+		if loaded, err := config.Load(); err == nil {
+			cfg = loaded
+		} else {
+			cfg = &config.Config{Output: config.OutputConfig{QuotaMB: 256, MaxAgeDays: 7}}
+		}
+	}
+	dir := cfg.Output.OutputDir()
+
+	outputStoresMu.Lock()
+	defer outputStoresMu.Unlock()
+	s, ok := outputStores[dir]
+	if !ok {
+		s = &outputStore{dir: dir}
+		outputStores[dir] = s
+	}
+	s.mu.Lock()
+	s.quota = cfg.Output.QuotaBytes()
+	s.maxAgeDays = cfg.Output.MaxAgeDays
+	s.chunkBytes = cfg.Output.ChunkThreshold
+	s.mu.Unlock()
+	return s
+}
+
+// ensureDir creates the store directory, refusing symlinks and tightening
+// the permissions of a directory created by an older version
+func (s *outputStore) ensureDir() error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w", s.dir, mcerrors.ErrFileIO)
+	}
+	info, err := os.Lstat(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to stat output directory %s: %w", s.dir, mcerrors.ErrFileIO)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("output directory %s is a symlink or not a directory: %w", s.dir, mcerrors.ErrFileIO)
+	}
+	if info.Mode().Perm() != 0700 {
+		if err := os.Chmod(s.dir, 0700); err != nil {
+			return fmt.Errorf("failed to restrict output directory %s: %w", s.dir, mcerrors.ErrFileIO)
+		}
+	}
+	return nil
+}
+
+// Write stores records as JSONL and returns the file path. Identical
+// records written by the same tool reuse the existing file. Records larger
+// than the chunk threshold are chunked, and the path is their manifest.
+func (s *outputStore) Write(toolName string, data []interface{}) (string, error) {
+	content, err := encodeJSONL(data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	id := fmt.Sprintf("%s-%s", toolName, hex.EncodeToString(sum[:8]))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureDir(); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if s.chunkBytes > 0 && len(content) > s.chunkBytes {
+		dir := filepath.Join(s.dir, id)
+		path := filepath.Join(dir, chunkManifestFile)
+		if _, err := os.Stat(path); err == nil {
+			if err := os.Chtimes(dir, now, now); err == nil {
+				RecordOutputReuse(toolName)
+				return path, nil
+			}
+		}
+		if err := s.writeChunks(id, normalizeRecords(data)); err != nil {
+			return "", err
+		}
+		s.enforceQuota(dir)
+		return path, nil
+	}
+
+	path := filepath.Join(s.dir, id+".jsonl")
+	if info, err := os.Stat(path); err == nil && info.Size() == int64(len(content)) {
+		// Reuse: mark the file as recently used so eviction keeps it
+		if err := os.Chtimes(path, now, now); err == nil {
+			RecordOutputReuse(toolName)
+			return path, nil
+		}
+	}
+	if err := writeFileAtomic(path, content); err != nil {
+		return "", err
+	}
+	s.enforceQuota(path)
+	return path, nil
+}
+
+// writeChunks stores records as the chunk files and manifest of result id.
+// The directory is assembled under a temporary name and renamed into place,
+// so readers never see a partial result. Callers hold s.mu.
+func (s *outputStore) writeChunks(id string, records []interface{}) error {
+	dir := filepath.Join(s.dir, id)
+	tmp, err := os.MkdirTemp(s.dir, ".chunks-*")
+	if err != nil {
+		return fmt.Errorf("failed to create chunk directory in %s: %w", s.dir, mcerrors.ErrFileIO)
+	}
+	defer os.RemoveAll(tmp) // Left over only when the rename fails
+
+	chunks, groupBy := output.SplitRecords(records, s.chunkBytes)
+	manifest := output.ChunkManifest{
+		TotalRecords: len(records),
+		NumChunks:    len(chunks),
+		GroupedBy:    groupBy,
+		Chunks:       make([]output.ChunkMetadata, 0, len(chunks)),
+	}
+	for i, chunk := range chunks {
+		content, err := encodeJSONL(chunk)
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("chunk_%04d.jsonl", i+1)
+		if err := os.WriteFile(filepath.Join(tmp, name), content, 0600); err != nil {
+			return fmt.Errorf("failed to write chunk %s of %s: %w", name, id, mcerrors.ErrFileIO)
+		}
+
+		meta := output.DescribeRecordChunk(i, len(chunks), chunk, groupBy)
+		meta.File = filepath.Join(dir, name)
+		meta.SizeBytes = int64(len(content))
+		manifest.Chunks = append(manifest.Chunks, meta)
+		manifest.ChunkSize = max(manifest.ChunkSize, len(chunk))
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest of %s: %w", id, mcerrors.ErrParseError)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, chunkManifestFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write manifest of %s: %w", id, mcerrors.ErrFileIO)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		// Another server process stored the same result first
+		if _, statErr := os.Stat(filepath.Join(dir, chunkManifestFile)); statErr == nil {
+			return nil
+		}
+		return fmt.Errorf("failed to store chunks of %s: %w", id, mcerrors.ErrFileIO)
+	}
+	return nil
+}
+
+// readChunkManifest reads the manifest of a chunked result
+func readChunkManifest(path string) (*output.ChunkManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest output.ChunkManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid chunk manifest %s: %w", path, mcerrors.ErrParseError)
+	}
+	return &manifest, nil
+}
+
+// readNames returns the save_as names of the store. Callers hold s.mu.
+func (s *outputStore) readNames() (map[string]string, error) {
+	names := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(s.dir, resultNamesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return names, nil
+		}
+		return nil, fmt.Errorf("failed to read result names in %s: %w", s.dir, mcerrors.ErrFileIO)
+	}
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("invalid result names in %s: %w", s.dir, mcerrors.ErrParseError)
+	}
+	return names, nil
+}
+
+// Name gives the stored result id a name usable wherever a result_id is
+// accepted. Naming an existing name again points it at the new result.
+func (s *outputStore) Name(name, id string) error {
+	if !resultNamePattern.MatchString(name) || resultIDPattern.MatchString(name) {
+		return fmt.Errorf("invalid result name %q (letters, digits, '_', '.', '-'; starting with a letter): %w", name, mcerrors.ErrInvalidInput)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureDir(); err != nil {
+		return err
+	}
+	names, err := s.readNames()
+	if err != nil {
+		return err
+	}
+	names[name] = id
+	data, err := json.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode result names: %w", mcerrors.ErrParseError)
+	}
+	return writeFileAtomic(filepath.Join(s.dir, resultNamesFile), data)
+}
+
+// resolve returns the ID of ref, a result ID or a name. Callers hold s.mu.
+func (s *outputStore) resolve(ref string) (string, error) {
+	if resultIDPattern.MatchString(ref) {
+		return ref, nil
+	}
+	if resultNamePattern.MatchString(ref) {
+		names, err := s.readNames()
+		if err != nil {
+			return "", err
+		}
+		if id, ok := names[ref]; ok {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("unknown result %q (use the result_id of a query response or a save_as name): %w", ref, mcerrors.ErrNotFound)
+}
+
+// Load returns the records of a stored result, referenced by ID or name,
+// and its ID. Loading counts as a use for eviction.
+func (s *outputStore) Load(ref string) ([]interface{}, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.resolve(ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A JSONL file, or the chunks of a chunked result in order
+	path := filepath.Join(s.dir, id+".jsonl")
+	files := []string{path}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path = filepath.Join(s.dir, id)
+		manifest, err := readChunkManifest(filepath.Join(path, chunkManifestFile))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, "", fmt.Errorf("result %q has expired from the output store; rerun the query: %w", ref, mcerrors.ErrNotFound)
+			}
+			if errors.Is(err, mcerrors.ErrParseError) {
+				return nil, "", err
+			}
+			return nil, "", fmt.Errorf("failed to open result %s: %w", id, mcerrors.ErrFileIO)
+		}
+		files = files[:0]
+		for _, chunk := range manifest.Chunks {
+			files = append(files, filepath.Join(path, filepath.Base(chunk.File)))
+		}
+	}
+
+	records := make([]interface{}, 0)
+	for _, file := range files {
+		if records, err = appendJSONLFile(records, id, file); err != nil {
+			return nil, "", err
+		}
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return records, id, nil
+}
+
+// appendJSONLFile appends the records of a JSONL file of result id
+func appendJSONLFile(records []interface{}, id, path string) ([]interface{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("result %q has expired from the output store; rerun the query: %w", id, mcerrors.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to open result %s: %w", id, mcerrors.ErrFileIO)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var record interface{}
+		if err := decoder.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+			return nil, fmt.Errorf("invalid record in result %s: %w", id, mcerrors.ErrParseError)
+		}
+		records = append(records, record)
+	}
+}
+
+// storedOutput is a file or chunk directory of the store with its last use
+type storedOutput struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// list returns the outputs in the store, least recently used first
+func (s *outputStore) list() ([]storedOutput, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read output directory %s: %w", s.dir, mcerrors.ErrFileIO)
+	}
+	outputs := make([]storedOutput, 0, len(entries))
+	for _, entry := range entries {
+		chunked := entry.IsDir() && resultIDPattern.MatchString(entry.Name())
+		if !chunked && (entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jsonl")) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed concurrently
+		}
+		stored := storedOutput{
+			path:    filepath.Join(s.dir, entry.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		}
+		if chunked {
+			stored.size = dirSize(stored.path)
+		}
+		outputs = append(outputs, stored)
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].modTime.Before(outputs[j].modTime)
+	})
+	return outputs, nil
+}
+
+// dirSize returns the total size of the files in dir
+func dirSize(dir string) int64 {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	var size int64
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// enforceQuota evicts least recently used outputs until the store fits its
+// quota. keep (the output just written) is never evicted, even when it alone
+// exceeds the quota. Callers hold s.mu.
+func (s *outputStore) enforceQuota(keep string) ([]string, int64) {
+	outputs, err := s.list()
+	if err != nil {
+		return nil, 0
+	}
+	var total int64
+	for _, o := range outputs {
+		total += o.size
+	}
+
+	var evicted []string
+	var freed int64
+	for _, o := range outputs {
+		if total <= s.quota {
+			break
+		}
+		if o.path == keep {
+			continue
+		}
+		if err := os.RemoveAll(o.path); err != nil {
+			continue
+		}
+		total -= o.size
+		freed += o.size
+		evicted = append(evicted, o.path)
+	}
+	if len(evicted) > 0 {
+		RecordOutputEvictions(len(evicted))
+		slog.Info("evicted file_ref outputs over quota",
+			"dir", s.dir,
+			"evicted", len(evicted),
+			"freed_bytes", freed,
+			"quota_bytes", s.quota,
+		)
+	}
+	return evicted, freed
+}
+
+// Cleanup removes outputs not used for maxAge, then enforces the quota.
+// It returns the removed files and the bytes freed.
+func (s *outputStore) Cleanup(maxAge time.Duration) ([]string, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	outputs, err := s.list()
+	if err != nil {
+		return nil, 0, err
+	}
+	threshold := time.Now().Add(-maxAge)
+	removed := []string{}
+	var freed int64
+	for _, o := range outputs {
+		if !o.modTime.Before(threshold) {
+			break // Sorted by last use
+		}
+		if err := os.RemoveAll(o.path); err == nil {
+			removed = append(removed, o.path)
+			freed += o.size
+		}
+	}
+
+	evicted, evictedBytes := s.enforceQuota("")
+	return append(removed, evicted...), freed + evictedBytes, nil
+}
+
+// startOutputCleanup sweeps the store now and every interval until the
+// returned stop function is called
+func startOutputCleanup(s *outputStore, interval time.Duration) func() {
+	sweep := func() {
+		s.mu.Lock()
+		maxAgeDays := s.maxAgeDays
+		s.mu.Unlock()
+
+		removed, freed, err := cleanupOldFiles(s, maxAgeDays)
+		if err != nil {
+			slog.Warn("file_ref output cleanup failed",
+				"dir", s.dir,
+				"error", err.Error(),
+			)
+			return
+		}
+		if len(removed) > 0 {
+			slog.Info("removed old file_ref outputs",
+				"dir", s.dir,
+				"removed", len(removed),
+				"freed_bytes", freed,
+			)
+		}
+	}
+	sweep()
+
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sweep()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/pkg/output"
+)
+
+// newTestOutputStore returns a store in a fresh directory with the given quota
+func newTestOutputStore(t *testing.T, quota int64) *outputStore {
+	t.Helper()
+	return &outputStore{dir: filepath.Join(t.TempDir(), "outputs"), quota: quota, maxAgeDays: 7}
+}
+
+// records returns n records whose JSONL encoding is about size bytes each
+func records(n, size int, tag string) []interface{} {
+	data := make([]interface{}, n)
+	for i := range data {
+		data[i] = map[string]interface{}{"tag": tag, "i": i, "pad": strings.Repeat("x", size)}
+	}
+	return data
+}
+
+func TestOutputStorePermissions(t *testing.T) {
+	store := newTestOutputStore(t, 1<<20)
+	path, err := store.Write("query_tools", records(2, 10, "a"))
+	require.NoError(t, err)
+
+	require.Equal(t, store.dir, filepath.Dir(path))
+	require.True(t, strings.HasPrefix(filepath.Base(path), "query_tools-"))
+
+	info, err := os.Stat(store.dir)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// A directory left with wider permissions is tightened
+	require.NoError(t, os.Chmod(store.dir, 0755))
+	_, err = store.Write("query_tools", records(1, 10, "b"))
+	require.NoError(t, err)
+	info, err = os.Stat(store.dir)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), info.Mode().Perm())
+}
+
+func TestOutputStoreRefusesSymlink(t *testing.T) {
+	target := t.TempDir()
+	link := filepath.Join(t.TempDir(), "outputs")
+	require.NoError(t, os.Symlink(target, link))
+
+	store := &outputStore{dir: link, quota: 1 << 20}
+	_, err := store.Write("query_tools", records(1, 10, "a"))
+	require.Error(t, err)
+}
+
+func TestOutputStoreReusesIdenticalResults(t *testing.T) {
+	store := newTestOutputStore(t, 1<<20)
+	first, err := store.Write("query_tools", records(3, 10, "a"))
+	require.NoError(t, err)
+
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(first, old, old))
+
+	second, err := store.Write("query_tools", records(3, 10, "a"))
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+
+	// Reuse counts as a use for eviction
+	info, err := os.Stat(second)
+	require.NoError(t, err)
+	require.True(t, info.ModTime().After(old))
+
+	other, err := store.Write("query_tools", records(3, 10, "b"))
+	require.NoError(t, err)
+	require.NotEqual(t, first, other)
+	differentTool, err := store.Write("query_user_messages", records(3, 10, "a"))
+	require.NoError(t, err)
+	require.NotEqual(t, first, differentTool)
+
+	outputs, err := store.list()
+	require.NoError(t, err)
+	require.Len(t, outputs, 3)
+}
+
+func TestOutputStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	// Each output is about 1KB; the quota fits two of them
+	store := newTestOutputStore(t, 2500)
+	var paths []string
+	for i, tag := range []string{"a", "b"} {
+		path, err := store.Write("query_tools", records(1, 1000, tag))
+		require.NoError(t, err)
+		used := time.Now().Add(time.Duration(i-10) * time.Minute)
+		require.NoError(t, os.Chtimes(path, used, used))
+		paths = append(paths, path)
+	}
+
+	// Using "a" again makes "b" the least recently used
+	_, err := store.Write("query_tools", records(1, 1000, "a"))
+	require.NoError(t, err)
+	third, err := store.Write("query_tools", records(1, 1000, "c"))
+	require.NoError(t, err)
+
+	require.FileExists(t, paths[0])
+	require.NoFileExists(t, paths[1])
+	require.FileExists(t, third)
+
+	// An output larger than the quota is still kept while it is the newest
+	big, err := store.Write("query_tools", records(1, 5000, "big"))
+	require.NoError(t, err)
+	require.FileExists(t, big)
+	outputs, err := store.list()
+	require.NoError(t, err)
+	require.Len(t, outputs, 1)
+}
+
+func TestOutputStoreCleanup(t *testing.T) {
+	store := newTestOutputStore(t, 1<<20)
+	oldPath, err := store.Write("query_tools", records(1, 10, "old"))
+	require.NoError(t, err)
+	old := time.Now().Add(-8 * 24 * time.Hour)
+	require.NoError(t, os.Chtimes(oldPath, old, old))
+	recentPath, err := store.Write("query_tools", records(1, 10, "recent"))
+	require.NoError(t, err)
+
+	removed, freed, err := store.Cleanup(7 * 24 * time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, []string{oldPath}, removed)
+	require.Positive(t, freed)
+	require.NoFileExists(t, oldPath)
+	require.FileExists(t, recentPath)
+
+	// Cleaning a store that was never written is a no-op
+	empty := newTestOutputStore(t, 1<<20)
+	removed, _, err = empty.Cleanup(time.Hour)
+	require.NoError(t, err)
+	require.Empty(t, removed)
+}
+
+func TestStartOutputCleanupSweepsImmediately(t *testing.T) {
+	store := newTestOutputStore(t, 1<<20)
+	path, err := store.Write("query_tools", records(1, 10, "old"))
+	require.NoError(t, err)
+	old := time.Now().Add(-8 * 24 * time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+
+	stop := startOutputCleanup(store, time.Hour)
+	stop()
+	stop()
+	require.NoFileExists(t, path)
+}
+
+func TestOutputStoreForSharesStorePerDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outputs")
+	t.Setenv("META_CC_OUTPUT_DIR", dir)
+	t.Setenv("META_CC_OUTPUT_QUOTA_MB", "3")
+
+	store := outputStoreFor(nil)
+	require.Equal(t, dir, store.dir)
+	require.Equal(t, int64(3<<20), store.quota)
+	require.Same(t, store, outputStoreFor(nil))
+}
+
+// sessionRecords returns records of sessions s0..s(n-1), each with perSession
+// records about size bytes, one day apart
+func sessionRecords(n, perSession, size int) []interface{} {
+	var data []interface{}
+	for s := 0; s < n; s++ {
+		for i := 0; i < perSession; i++ {
+			data = append(data, map[string]interface{}{
+				"session_id": fmt.Sprintf("s%d", s),
+				"timestamp":  fmt.Sprintf("2025-10-%02dT10:%02d:00Z", s+1, i),
+				"pad":        strings.Repeat("x", size),
+			})
+		}
+	}
+	return data
+}
+
+func TestOutputStoreChunksLargeResults(t *testing.T) {
+	store := newTestOutputStore(t, 1<<20)
+	store.chunkBytes = 2500
+	data := sessionRecords(4, 10, 100)
+
+	path, err := store.Write("query_tools", data)
+	require.NoError(t, err)
+	require.Equal(t, chunkManifestFile, filepath.Base(path))
+	id := resultID(path)
+	require.True(t, resultIDPattern.MatchString(id), id)
+
+	manifest, err := readChunkManifest(path)
+	require.NoError(t, err)
+	require.Equal(t, output.ChunkBySession, manifest.GroupedBy)
+	require.Equal(t, 40, manifest.TotalRecords)
+	// Two sessions (about 1.4KB each) never fit one chunk: one per session
+	require.Len(t, manifest.Chunks, 4)
+	for i, chunk := range manifest.Chunks {
+		require.Equal(t, []string{fmt.Sprintf("s%d", i)}, chunk.Keys)
+		require.Equal(t, fmt.Sprintf("2025-10-%02dT10:00:00Z", i+1), chunk.From)
+		info, err := os.Stat(chunk.File)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		require.Equal(t, chunk.SizeBytes, info.Size())
+	}
+
+	// Loaded in order as one result
+	loaded, loadedID, err := store.Load(id)
+	require.NoError(t, err)
+	require.Equal(t, id, loadedID)
+	require.Equal(t, normalizeRecords(data), loaded)
+
+	// Identical results reuse the chunks
+	again, err := store.Write("query_tools", data)
+	require.NoError(t, err)
+	require.Equal(t, path, again)
+
+	// The chunk directory is one output for eviction
+	outputs, err := store.list()
+	require.NoError(t, err)
+	require.Len(t, outputs, 1)
+	require.Equal(t, filepath.Dir(path), outputs[0].path)
+	store.quota = 1
+	_, err = store.Write("query_tools", records(1, 10, "a"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Dir(path))
+	require.True(t, os.IsNotExist(err))
+	_, _, err = store.Load(id)
+	require.True(t, errors.Is(err, mcerrors.ErrNotFound), "unexpected error: %v", err)
+}
+
+func TestFileRefListsChunks(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Output.ChunkThreshold = 2500
+
+	response, err := adaptResponse(cfg, sessionRecords(3, 10, 100), map[string]interface{}{"output_mode": "file_ref"}, "query_tools")
+	require.NoError(t, err)
+	fileRef := response.(map[string]interface{})["file_ref"].(map[string]interface{})
+
+	chunks := fileRef["chunks"].([]output.ChunkMetadata)
+	require.Len(t, chunks, 3)
+	require.Equal(t, output.ChunkBySession, fileRef["grouped_by"])
+	require.Equal(t, 30, fileRef["line_count"])
+	var total int64
+	for _, chunk := range chunks {
+		total += chunk.SizeBytes
+	}
+	require.Equal(t, total, fileRef["size_bytes"])
+
+	// Below the threshold results stay one file
+	cfg.Output.ChunkThreshold = 1 << 20
+	response, err = adaptResponse(cfg, sessionRecords(3, 10, 100), map[string]interface{}{"output_mode": "file_ref"}, "query_tools")
+	require.NoError(t, err)
+	fileRef = response.(map[string]interface{})["file_ref"].(map[string]interface{})
+	require.NotContains(t, fileRef, "chunks")
+	require.True(t, strings.HasSuffix(fileRef["path"].(string), ".jsonl"))
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yaleh/meta-cc/pkg/output"
+)
+
+// output_summary.go builds summarized mode responses: when a result exceeds
+// the token budget, the response carries the most informative subset that
+// fits (small fields of the most relevant records) with value distributions
+// for every field, and reports exactly what was left out and how to fetch it.
+// When the distributions alone exceed the budget they are trimmed as well.
+
+// bulkyFieldBytes is the average value size above which a field is left out
+// of summarized records (e.g. tool output, message content)
+const bulkyFieldBytes = 200
+
+// summaryOrder describes how summarized records are chosen
+const summaryOrder = "errors first, then newest"
+
+// distributionTops are the top value counts tried, in order, when the
+// distributions do not fit the budget
+var distributionTops = []int{3, 1, 0}
+
+// distributionTrim reports how distributions were cut to fit the budget
+type distributionTrim struct {
+	// TopValues is how many top values each field kept
+	TopValues int `json:"top_values"`
+	// Fields are the fields whose distribution was left out, largest first
+	Fields []string `json:"fields,omitempty"`
+}
+
+// normalizeRecords converts records to their JSON form (maps, float64
+// numbers), so handler structs and decoded results are inspected alike
+func normalizeRecords(records []interface{}) []interface{} {
+	normalized := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		switch record.(type) {
+		case map[string]interface{}, string, float64, bool, nil:
+			normalized = append(normalized, record)
+			continue
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			continue
+		}
+		normalized = append(normalized, decoded)
+	}
+	return normalized
+}
+
+// rankByRelevance returns record indexes, most relevant first: errors, then
+// newest by timestamp, then the original order
+func rankByRelevance(records []interface{}) []int {
+	order := make([]int, len(records))
+	for i := range order {
+		order[i] = i
+	}
+	timestamp := func(i int) string {
+		if m, ok := records[i].(map[string]interface{}); ok {
+			ts, _ := m["timestamp"].(string)
+			return ts
+		}
+		return ""
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		errA, errB := output.IsErrorRecord(records[order[a]]), output.IsErrorRecord(records[order[b]])
+		if errA != errB {
+			return errA
+		}
+		return timestamp(order[a]) > timestamp(order[b])
+	})
+	return order
+}
+
+// buildSummarizedResponse fits a result into budgetBytes of response
+//
+// Parameters:
+//   - data: Records of the result (already redacted)
+//   - resultID, filePath: Where the full result is stored
+//   - budgetBytes: Size the response should stay within
+//
+// Records keep only fields averaging at most bulkyFieldBytes and are added in
+// relevance order while they fit. distributions covers every field of every
+// record, including the omitted ones, unless they leave no room for the most
+// relevant record: then fewer top values are listed per field, then the
+// largest fields are left out (see fitDistributions), and
+// omitted.distributions says so.
+func buildSummarizedResponse(data []interface{}, resultID, filePath string, budgetBytes int) map[string]interface{} {
+	records := normalizeRecords(data)
+	stats := collectFieldStats(records, 1)
+
+	var kept, omitted []string
+	for field, s := range stats {
+		if s.AvgBytes > bulkyFieldBytes {
+			omitted = append(omitted, field)
+		} else {
+			kept = append(kept, field)
+		}
+	}
+	sort.Strings(kept)
+	sort.Strings(omitted)
+
+	fullSize := output.EstimateRecordsSize(records).EstimatedBytes
+	response := map[string]interface{}{
+		"mode":             OutputModeSummarized,
+		"result_id":        resultID,
+		"total_records":    len(records),
+		"estimated_tokens": output.EstimateTokens(fullSize),
+		"order":            summaryOrder,
+		"distributions":    stats,
+	}
+	if len(kept) > 0 {
+		response["fields"] = kept
+	}
+
+	// Records fill what the rest of the response leaves of the budget; the
+	// counts filled in afterwards grow by a few digits at most
+	var trim *distributionTrim
+	setSelection := func(selected []interface{}) {
+		response["data"] = selected
+		response["returned_records"] = len(selected)
+		omittedParts := map[string]interface{}{
+			"records": len(records) - len(selected),
+			"fields":  append([]string{}, omitted...),
+		}
+		if trim != nil {
+			omittedParts["distributions"] = trim
+		}
+		response["omitted"] = omittedParts
+		response["fetch"] = summaryFetchHint(resultID, filePath, len(records), len(selected), omitted, trim)
+	}
+	skeletonBytes := func() int {
+		setSelection([]interface{}{})
+		skeleton, _ := json.Marshal(response)
+		return len(skeleton) + 32
+	}
+	candidates := records
+	if len(omitted) > 0 {
+		candidates = output.ProjectRecords(records, output.ProjectionConfig{Fields: kept})
+	}
+	ranked := rankByRelevance(records)
+
+	// Distributions give way to at least the most relevant record
+	reserved := 0
+	if len(ranked) > 0 && len(kept) > 0 {
+		if encoded, err := json.Marshal(candidates[ranked[0]]); err == nil {
+			reserved = len(encoded) + 1
+		}
+	}
+	if skeletonBytes()+reserved > budgetBytes {
+		trim = &distributionTrim{}
+		fitDistributions(response, stats, trim, func() bool { return skeletonBytes()+reserved <= budgetBytes })
+	}
+	remaining := budgetBytes - skeletonBytes()
+	selected := make([]interface{}, 0)
+	if len(kept) == 0 && len(omitted) > 0 {
+		// Every field is bulky: projected records would be empty
+		remaining = 0
+	}
+	for _, i := range ranked {
+		record := candidates[i]
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+		if len(encoded)+1 > remaining {
+			break
+		}
+		remaining -= len(encoded) + 1
+		selected = append(selected, record)
+	}
+
+	setSelection(selected)
+	return response
+}
+
+// fitDistributions trims the distributions of response until fits reports
+// the response fits: first to fewer top values per field, then without whole
+// fields, largest first. trim records what was cut; stats is not modified.
+func fitDistributions(response map[string]interface{}, stats map[string]*fieldStats, trim *distributionTrim, fits func() bool) {
+	trimmed := make(map[string]*fieldStats, len(stats))
+	for field, s := range stats {
+		c := *s
+		trimmed[field] = &c
+	}
+	response["distributions"] = trimmed
+
+	for _, top := range distributionTops {
+		trim.TopValues = top
+		for field, s := range trimmed {
+			s.Top = stats[field].Top[:min(top, len(stats[field].Top))]
+		}
+		if fits() {
+			return
+		}
+	}
+
+	sizes := make(map[string]int, len(trimmed))
+	fields := make([]string, 0, len(trimmed))
+	for field, s := range trimmed {
+		encoded, _ := json.Marshal(s)
+		sizes[field] = len(field) + len(encoded)
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if sizes[fields[i]] != sizes[fields[j]] {
+			return sizes[fields[i]] > sizes[fields[j]]
+		}
+		return fields[i] < fields[j]
+	})
+	for _, field := range fields {
+		delete(trimmed, field)
+		trim.Fields = append(trim.Fields, field)
+		if fits() {
+			return
+		}
+	}
+}
+
+// summaryFetchHint tells the assistant how to get what a summary left out
+func summaryFetchHint(resultID, filePath string, total, returned int, omitted []string, trim *distributionTrim) map[string]interface{} {
+	var hint strings.Builder
+	fmt.Fprintf(&hint, "Showing %d of %d records (%s)", returned, total, summaryOrder)
+	if len(omitted) > 0 {
+		fmt.Fprintf(&hint, " without fields %s", strings.Join(omitted, ", "))
+	}
+	if trim != nil {
+		fmt.Fprintf(&hint, "; distributions list %d top values per field", trim.TopValues)
+		if len(trim.Fields) > 0 {
+			fmt.Fprintf(&hint, " and leave out %s", strings.Join(trim.Fields, ", "))
+		}
+	}
+	fmt.Fprintf(&hint, ". Narrow the result with query_result({result_id: %q, jq_filter, sort_by, fields, offset, limit})", resultID)
+	fmt.Fprintf(&hint, " instead of reading %s.", filePath)
+
+	return map[string]interface{}{
+		"tool":      "query_result",
+		"arguments": map[string]interface{}{"result_id": resultID, "offset": 0, "limit": max(returned, 1)},
+		"file":      filePath,
+		"hint":      hint.String(),
+	}
+}
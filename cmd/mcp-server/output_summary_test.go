@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yaleh/meta-cc/internal/config"
+	"github.com/yaleh/meta-cc/internal/parser"
+)
+
+// bulkyToolCalls returns n tool calls with large outputs; every fifth fails
+func bulkyToolCalls(n int) []interface{} {
+	records := make([]interface{}, n)
+	for i := range records {
+		call := parser.ToolCall{
+			UUID:      fmt.Sprintf("u%03d", i),
+			ToolName:  []string{"Bash", "Read", "Edit"}[i%3],
+			Status:    "success",
+			Output:    strings.Repeat("output ", 100),
+			Timestamp: fmt.Sprintf("2025-10-02T10:%02d:00Z", i%60),
+		}
+		if i%5 == 0 {
+			call.Status = "error"
+			call.Error = "exit status 1"
+		}
+		records[i] = call
+	}
+	return records
+}
+
+func TestSelectOutputModeWithTokenBudget(t *testing.T) {
+	config := &OutputModeConfig{InlineThresholdBytes: 8192, MaxTokens: 1000}
+
+	require.Equal(t, OutputModeInline, selectOutputModeWithConfig(3000, "", config))
+	require.Equal(t, OutputModeSummarized, selectOutputModeWithConfig(4001, "", config))
+	require.Equal(t, OutputModeFileRef, selectOutputModeWithConfig(4001, OutputModeFileRef, config))
+	require.Equal(t, OutputModeSummarized, selectOutputModeWithConfig(10, OutputModeSummarized, config))
+
+	// Without a budget, size alone decides
+	config.MaxTokens = 0
+	require.Equal(t, OutputModeFileRef, selectOutputModeWithConfig(10000, "", config))
+}
+
+func TestGetOutputModeConfigMaxTokens(t *testing.T) {
+	cfg := &config.Config{Output: config.OutputConfig{InlineThreshold: 8192, MaxTokens: 2000}}
+
+	require.Equal(t, 2000, getOutputModeConfig(cfg, map[string]interface{}{}).MaxTokens)
+	require.Equal(t, 500, getOutputModeConfig(cfg, map[string]interface{}{"max_tokens": float64(500)}).MaxTokens)
+
+	modeConfig := getOutputModeConfig(cfg, map[string]interface{}{"inline_threshold_bytes": float64(1024)})
+	require.Equal(t, 1024, modeConfig.InlineThresholdBytes)
+	require.Equal(t, 2000, modeConfig.MaxTokens)
+}
+
+func TestSummarizedResponseFitsBudget(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	data := bulkyToolCalls(60)
+
+	result, err := adaptResponse(cfg, data, map[string]interface{}{"max_tokens": float64(1500)}, "query_tools")
+	require.NoError(t, err)
+	response := result.(map[string]interface{})
+
+	encoded, err := json.Marshal(response)
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(encoded), 1500*4)
+
+	require.Equal(t, OutputModeSummarized, response["mode"])
+	require.True(t, strings.HasPrefix(response["result_id"].(string), "query_tools-"))
+	require.Equal(t, 60, response["total_records"])
+	require.Greater(t, response["estimated_tokens"].(int), 1500)
+
+	// Bulky fields are left out of records but still described
+	omitted := response["omitted"].(map[string]interface{})
+	require.Equal(t, []string{"output"}, omitted["fields"])
+	stats := response["distributions"].(map[string]*fieldStats)
+	require.Contains(t, stats, "output")
+	require.Equal(t, 20, stats["tool_name"].Top[0].Count)
+	require.Equal(t, []valueCount{{Value: "success", Count: 48}, {Value: "error", Count: 12}}, stats["status"].Top)
+
+	// Errors come first, newest first
+	records := response["data"].([]interface{})
+	require.NotEmpty(t, records)
+	require.Equal(t, len(records), response["returned_records"])
+	require.Equal(t, 60-len(records), omitted["records"])
+	first := records[0].(map[string]interface{})
+	require.Equal(t, "error", first["status"])
+	require.Equal(t, "u055", first["uuid"])
+	require.NotContains(t, first, "output")
+
+	fetch := response["fetch"].(map[string]interface{})
+	require.Equal(t, "query_result", fetch["tool"])
+	require.Equal(t, response["result_id"], fetch["arguments"].(map[string]interface{})["result_id"])
+	require.Contains(t, fetch["hint"], "without fields output")
+
+	// The stored result is complete
+	stored, _, err := outputStoreFor(cfg).Load(response["result_id"].(string))
+	require.NoError(t, err)
+	require.Len(t, stored, 60)
+}
+
+func TestSummarizedResponseTrimsDistributionsOverBudget(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	// Many fields with long values: listing their distributions in full
+	// takes more than the whole budget
+	data := make([]interface{}, 60)
+	for i := range data {
+		record := map[string]interface{}{"uuid": fmt.Sprintf("u%03d", i)}
+		for f := 0; f < 30; f++ {
+			record[fmt.Sprintf("field_%02d", f)] = fmt.Sprintf("value %d of field %02d %s", i%5, f, strings.Repeat("x", 30))
+		}
+		data[i] = record
+	}
+	full, err := json.Marshal(collectFieldStats(normalizeRecords(data), 1))
+	require.NoError(t, err)
+	require.Greater(t, len(full), 1000*4)
+
+	result, err := adaptResponse(cfg, data, map[string]interface{}{"max_tokens": float64(1000)}, "query_tools")
+	require.NoError(t, err)
+	response := result.(map[string]interface{})
+	require.Equal(t, OutputModeSummarized, response["mode"])
+
+	encoded, err := json.Marshal(response)
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(encoded), 1000*4)
+
+	// The response says how the distributions were cut
+	trim := response["omitted"].(map[string]interface{})["distributions"].(*distributionTrim)
+	require.Less(t, trim.TopValues, topValuesPerField)
+	stats := response["distributions"].(map[string]*fieldStats)
+	require.Len(t, stats, 31-len(trim.Fields))
+	for _, field := range trim.Fields {
+		require.NotContains(t, stats, field)
+	}
+	for field, s := range stats {
+		require.LessOrEqual(t, len(s.Top), trim.TopValues, field)
+	}
+	require.Contains(t, response["fetch"].(map[string]interface{})["hint"], "top values per field")
+	// Whole fields gave way to the most relevant record
+	require.Equal(t, 0, trim.TopValues)
+	require.NotEmpty(t, trim.Fields)
+	require.Equal(t, 1, response["returned_records"])
+
+	// Within the budget, distributions are complete
+	result, err = adaptResponse(cfg, bulkyToolCalls(60), map[string]interface{}{"max_tokens": float64(1500)}, "query_tools")
+	require.NoError(t, err)
+	require.NotContains(t, result.(map[string]interface{})["omitted"], "distributions")
+}
+
+func TestSummarizedResponseUnderBudgetStaysInline(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	result, err := adaptResponse(cfg, bulkyToolCalls(2), map[string]interface{}{"max_tokens": float64(100000)}, "query_tools")
+	require.NoError(t, err)
+	require.Equal(t, OutputModeInline, result.(map[string]interface{})["mode"])
+}
+
+func TestCollectFieldStats(t *testing.T) {
+	records := make([]interface{}, 0)
+	for i := 0; i < 60; i++ {
+		records = append(records, map[string]interface{}{
+			"id":       fmt.Sprintf("id-%d", i),
+			"tokens":   float64(i),
+			"mixed":    []interface{}{"a", 1.0}[i%2],
+			"optional": nil,
+		})
+	}
+	stats := collectFieldStats(records, 1)
+
+	require.True(t, stats["id"].HighCardinality)
+	require.Empty(t, stats["id"].Top)
+	require.Equal(t, "number", stats["tokens"].Type)
+	require.Equal(t, 0.0, *stats["tokens"].Min)
+	require.Equal(t, 59.0, *stats["tokens"].Max)
+	require.Equal(t, 29.5, *stats["tokens"].Mean)
+	require.Equal(t, "mixed", stats["mixed"].Type)
+	require.Equal(t, 2, stats["mixed"].Distinct)
+	require.Equal(t, "null", stats["optional"].Type)
+	require.Equal(t, 60, stats["optional"].Nulls)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/locator"
+)
+
+// path_policy.go confines the tools that take file paths to session data.
+// Paths come from the assistant and therefore from whatever it read, so a
+// prompt-injected instruction must not turn them into a way to read ~/.ssh
+// or overwrite ~/.bashrc. Arguments tagged path:"read" or path:"write" are
+// resolved here for every tool before its handler runs.
+
+// Values of the path tag of tool arguments
+const (
+	pathRead  = "read"  // must resolve inside the allowed read roots
+	pathWrite = "write" // must resolve inside the exports directory
+)
+
+// newPathPolicy returns the policy for tool file arguments: the Claude
+// projects root, imported bundles, META_CC_ALLOWED_ROOTS and the server's own
+// file_ref outputs
+func newPathPolicy() *locator.PathPolicy {
+	var extraRoots []string
+	if cfg != nil {
+		extraRoots = cfg.Access.ExtraRootsSlice()
+	}
+	extraRoots = append(extraRoots, outputStoreFor(cfg).dir)
+	return locator.NewPathPolicy(locator.NewSessionLocator(), extraRoots)
+}
+
+// resolvePathArgs replaces the path arguments of a decoded argument struct
+// with their resolved form, rejecting the call if any is outside its roots
+func resolvePathArgs(ctx context.Context, toolName string, fields []argField, args reflect.Value) error {
+	for _, f := range fields {
+		if f.path == "" {
+			continue
+		}
+		v := args.FieldByIndex(f.index)
+		if v.Kind() == reflect.Slice {
+			files, ok := v.Interface().([]string)
+			if !ok || len(files) == 0 {
+				continue
+			}
+			resolved, err := resolveToolFiles(ctx, toolName, files)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(resolved))
+			continue
+		}
+
+		path := v.String()
+		if path == "" {
+			continue
+		}
+		var err error
+		if f.path == pathWrite {
+			path, err = resolveToolTarget(ctx, toolName, path)
+		} else {
+			var resolved []string
+			resolved, err = resolveToolFiles(ctx, toolName, []string{path})
+			if err == nil {
+				path = resolved[0]
+			}
+		}
+		if err != nil {
+			return err
+		}
+		v.SetString(path)
+	}
+	return nil
+}
+
+// resolveToolTarget resolves a file a tool was asked to write. Tools only
+// write into the exports directory: a bare file name is placed there, and any
+// other path must already point inside it. Violations are logged and counted.
+func resolveToolTarget(ctx context.Context, toolName, path string) (string, error) {
+	dir := exportDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create exports directory %s: %w", dir, mcerrors.ErrFileIO)
+	}
+	if !filepath.IsAbs(path) && filepath.Base(path) == path && path != "." && path != ".." {
+		path = filepath.Join(dir, path)
+	}
+
+	resolved, err := locator.NewRootsPolicy([]string{dir}).ResolveTarget(path)
+	if err != nil {
+		if errors.Is(err, mcerrors.ErrInvalidInput) {
+			recordPathViolation(ctx, toolName, path)
+		}
+		return "", err
+	}
+	return resolved, nil
+}
+
+// recordPathViolation logs and counts a path policy violation
+func recordPathViolation(ctx context.Context, toolName, path string) {
+	RecordPathPolicyViolation(toolName)
+	LoggerFromContext(ctx).Warn("path policy violation",
+		"tool_name", toolName,
+		"path", path,
+	)
+}
+
+// resolveToolFiles resolves the files a tool was asked to read, rejecting the
+// whole call with ErrInvalidInput if any is outside the allowed roots.
+// Violations are logged and counted.
+func resolveToolFiles(ctx context.Context, toolName string, files []string) ([]string, error) {
+	policy := newPathPolicy()
+	resolved := make([]string, 0, len(files))
+	for _, file := range files {
+		path, err := policy.Resolve(file)
+		if err != nil {
+			if errors.Is(err, mcerrors.ErrInvalidInput) {
+				recordPathViolation(ctx, toolName, file)
+			}
+			return nil, err
+		}
+		resolved = append(resolved, path)
+	}
+	return resolved, nil
+}
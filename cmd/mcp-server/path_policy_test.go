@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+)
+
+// pathPolicyViolationCount reads mcp_server_path_policy_violations_total for a tool
+func pathPolicyViolationCount(t *testing.T, toolName string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "mcp_server_path_policy_violations_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "tool_name" && label.GetValue() == toolName {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestFileToolsRejectPathsOutsideProjectsRoot(t *testing.T) {
+	root := setupProjectsRoot(t)
+	outside := filepath.Join(t.TempDir(), ".bashrc")
+	require.NoError(t, os.WriteFile(outside, []byte("{}\n"), 0600))
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	// Every argument taking a file path is checked; a new one must be tagged
+	var checked []string
+	for _, tool := range builtinTools.tools {
+		for _, f := range tool.fields {
+			if f.path == "" {
+				continue
+			}
+			checked = append(checked, tool.spec.Name+"."+f.name)
+
+			args := requiredArgs(tool.fields)
+			for _, path := range []string{outside, filepath.Join(root, "..", filepath.Base(filepath.Dir(outside)), ".bashrc")} {
+				args[f.name] = path
+				if f.typ.Kind() == reflect.Slice {
+					args[f.name] = []interface{}{filepath.Join(root, "-work-api", "s-api.jsonl"), path}
+				}
+				before := pathPolicyViolationCount(t, tool.spec.Name)
+				_, err := NewToolExecutor().ExecuteTool(context.Background(), cfg, tool.spec.Name, args)
+				require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "%s %s=%s: unexpected error %v", tool.spec.Name, f.name, path, err)
+				require.Equal(t, before+1, pathPolicyViolationCount(t, tool.spec.Name))
+			}
+		}
+	}
+	require.ElementsMatch(t, []string{
+		"inspect_session_files.files",
+		"execute_stage2_query.files",
+		"export_sessions.output",
+		"import_sessions.bundle",
+	}, checked)
+
+	data, err := os.ReadFile(outside)
+	require.NoError(t, err)
+	require.Equal(t, "{}\n", string(data), "files outside the exports directory are never written")
+
+	result, err := NewToolExecutor().ExecuteTool(context.Background(), cfg, "inspect_session_files", map[string]interface{}{
+		"files": []interface{}{filepath.Join(root, "-work-api", "s-api.jsonl")},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, result)
+}
+
+// requiredArgs returns placeholder values for the required arguments of a tool
+func requiredArgs(fields []argField) map[string]interface{} {
+	args := map[string]interface{}{}
+	for _, f := range fields {
+		if !f.required {
+			continue
+		}
+		switch f.typ.Kind() {
+		case reflect.Slice:
+			args[f.name] = []interface{}{"x"}
+		case reflect.String:
+			args[f.name] = "."
+		default:
+			args[f.name] = 1
+		}
+	}
+	return args
+}
+
+func TestStage2QueryReadsFileRefOutputs(t *testing.T) {
+	setupProjectsRoot(t)
+	path, err := outputStoreFor(nil).Write("query_tools", []interface{}{map[string]interface{}{"tool": "Bash"}})
+	require.NoError(t, err)
+	t.Cleanup(func() { os.Remove(path) })
+
+	result, err := handleExecuteStage2Query(context.Background(), stage2QueryArgs{Files: []string{path}, Filter: "."})
+	require.NoError(t, err)
+	require.Len(t, result.(map[string]interface{})["results"], 1)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yaleh/meta-cc/internal/query"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// progressReporterContextKey is the context key for storing the progress reporter
+type progressReporterContextKey struct{}
+
+// progressMinInterval throttles intermediate notifications; the final update is always sent
+const progressMinInterval = 100 * time.Millisecond
+
+// progressReporter emits MCP notifications/progress messages for a single
+// tools/call request that supplied a progressToken in its _meta params
+type progressReporter struct {
+	token interface{}
+
+	mu       sync.Mutex
+	last     time.Time
+	progress int
+	scan     query.ScanProgress
+	scanning bool
+	done     bool
+}
+
+// newProgressReporter returns a reporter for the given token, or nil if the
+// request did not ask for progress
+func newProgressReporter(params map[string]interface{}) *progressReporter {
+	meta, ok := params["_meta"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	token, ok := meta["progressToken"]
+	if !ok || token == nil {
+		return nil
+	}
+
+	switch token.(type) {
+	case string, float64:
+		return &progressReporter{token: token}
+	default:
+		// MCP only allows string or integer tokens
+		return nil
+	}
+}
+
+// withProgressReporter returns a context carrying the reporter
+func withProgressReporter(ctx context.Context, r *progressReporter) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressReporterContextKey{}, r)
+}
+
+// progressReporterFromContext returns the reporter stored in ctx, if any
+func progressReporterFromContext(ctx context.Context) *progressReporter {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(progressReporterContextKey{}).(*progressReporter)
+	return r
+}
+
+// scanProgressFunc returns the query.ProgressFunc for a request. It forwards
+// updates to the progress reporter (if the client asked for progress) and
+// records scan totals on the active span (if it is being sampled). It returns
+// nil when neither applies, so callers can pass the result straight through.
+func scanProgressFunc(ctx context.Context) query.ProgressFunc {
+	r := progressReporterFromContext(ctx)
+	span := trace.SpanFromContext(ctx)
+	recording := span.IsRecording()
+
+	if r == nil && !recording {
+		return nil
+	}
+
+	return func(p query.ScanProgress) {
+		if recording {
+			span.SetAttributes(
+				attribute.Int("scan.files_total", p.TotalFiles),
+				attribute.Int("scan.files_processed", p.FilesProcessed),
+				attribute.Int("scan.records_scanned", p.RecordsScanned),
+				attribute.Int64("scan.bytes_read", p.BytesRead),
+			)
+		}
+		r.report(p)
+	}
+}
+
+// start announces the scan totals before any file has been processed
+func (r *progressReporter) start(meta *directoryMetadata) {
+	if r == nil || meta == nil {
+		return
+	}
+	r.mu.Lock()
+	r.scanning = true
+	r.scan = query.ScanProgress{TotalFiles: meta.FileCount}
+	r.mu.Unlock()
+	r.send(0, meta.FileCount, fmt.Sprintf("scanning %d files (%d bytes)", meta.FileCount, meta.TotalSize), true)
+}
+
+// report forwards a scan update as a progress notification
+func (r *progressReporter) report(p query.ScanProgress) {
+	if r == nil {
+		return
+	}
+	final := p.TotalFiles > 0 && p.FilesProcessed >= p.TotalFiles
+	r.mu.Lock()
+	r.scanning = true
+	r.scan = p
+	r.done = r.done || final
+	r.mu.Unlock()
+	message := fmt.Sprintf("%d/%d files processed, %d records scanned", p.FilesProcessed, p.TotalFiles, p.RecordsScanned)
+	r.send(p.FilesProcessed, p.TotalFiles, message, final)
+}
+
+// finish sends the final update once the tool call returns. Scans that stop
+// before reaching every file (limit reached, cancellation, errors) never emit
+// a FilesProcessed == TotalFiles update, so the client would otherwise be left
+// waiting on a partial progress bar.
+func (r *progressReporter) finish() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	p, scanning, done := r.scan, r.scanning, r.done
+	r.done = true
+	r.mu.Unlock()
+	if !scanning || done {
+		return
+	}
+
+	total := p.TotalFiles
+	if total < p.FilesProcessed {
+		total = p.FilesProcessed
+	}
+	message := fmt.Sprintf("scan stopped after %d/%d files, %d records scanned", p.FilesProcessed, p.TotalFiles, p.RecordsScanned)
+	r.send(total, total, message, true)
+}
+
+// send writes a notification unless throttled. Progress values must increase
+// per the MCP spec, so repeated or decreasing values are dropped.
+func (r *progressReporter) send(progress, total int, message string, force bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if !r.last.IsZero() {
+		if progress <= r.progress {
+			return
+		}
+		if !force && now.Sub(r.last) < progressMinInterval {
+			return
+		}
+	}
+	r.last = now
+	r.progress = progress
+
+	params := map[string]interface{}{
+		"progressToken": r.token,
+		"progress":      progress,
+		"message":       message,
+	}
+	if total > 0 {
+		params["total"] = total
+	}
+	writeNotification("notifications/progress", params)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yaleh/meta-cc/internal/query"
+)
+
+func TestNewProgressReporter(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		want   bool
+	}{
+		{"no meta", map[string]interface{}{"name": "query_tools"}, false},
+		{"meta without token", map[string]interface{}{"_meta": map[string]interface{}{}}, false},
+		{"string token", map[string]interface{}{"_meta": map[string]interface{}{"progressToken": "abc"}}, true},
+		{"numeric token", map[string]interface{}{"_meta": map[string]interface{}{"progressToken": float64(7)}}, true},
+		{"invalid token", map[string]interface{}{"_meta": map[string]interface{}{"progressToken": true}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newProgressReporter(tt.params) != nil
+			if got != tt.want {
+				t.Errorf("newProgressReporter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressReporterEmitsNotifications(t *testing.T) {
+	var buf bytes.Buffer
+	origStdout := outputWriter
+	outputWriter = &buf
+	defer func() { outputWriter = origStdout }()
+
+	reporter := &progressReporter{token: "tok-1"}
+	reporter.start(&directoryMetadata{FileCount: 2, TotalSize: 100})
+	reporter.report(query.ScanProgress{FilesProcessed: 1, TotalFiles: 2, RecordsScanned: 5})
+	reporter.report(query.ScanProgress{FilesProcessed: 2, TotalFiles: 2, RecordsScanned: 9})
+	// Duplicate final update must not be re-sent
+	reporter.report(query.ScanProgress{FilesProcessed: 2, TotalFiles: 2, RecordsScanned: 9})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected at least start and final notifications, got %d", len(lines))
+	}
+
+	var first, last JSONRPCNotification
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("failed to parse notification: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatalf("failed to parse notification: %v", err)
+	}
+
+	if first.Method != "notifications/progress" {
+		t.Errorf("expected notifications/progress, got %s", first.Method)
+	}
+
+	params, ok := last.Params.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected params map, got %T", last.Params)
+	}
+	if params["progressToken"] != "tok-1" {
+		t.Errorf("expected progressToken tok-1, got %v", params["progressToken"])
+	}
+	if params["progress"] != float64(2) || params["total"] != float64(2) {
+		t.Errorf("expected progress 2/2, got %v/%v", params["progress"], params["total"])
+	}
+	if !strings.Contains(params["message"].(string), "9 records") {
+		t.Errorf("expected records in message, got %v", params["message"])
+	}
+}
+
+func TestQueryExecutorStreamFilesReportsProgress(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := []string{filepath.Join(tmpDir, "a.jsonl"), filepath.Join(tmpDir, "b.jsonl")}
+	for _, f := range files {
+		if err := os.WriteFile(f, []byte(`{"type":"user"}`+"\n"+`{"type":"assistant"}`+"\n"), 0644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+
+	executor := NewQueryExecutor(tmpDir)
+	code, err := executor.compileExpression(`select(.type == "user")`)
+	if err != nil {
+		t.Fatalf("compileExpression failed: %v", err)
+	}
+
+	var last query.ScanProgress
+	executor.Progress = func(p query.ScanProgress) { last = p }
+
+	results := executor.streamFiles(context.Background(), files, code, 0)
+	if len(results) != 2 {
+		t.Errorf("expected 2 results, got %d", len(results))
+	}
+	if last.FilesProcessed != 2 || last.TotalFiles != 2 || last.RecordsScanned != 4 {
+		t.Errorf("unexpected final progress: %+v", last)
+	}
+}
+
+func TestProgressReporterFinishesLimitedScan(t *testing.T) {
+	var buf bytes.Buffer
+	origStdout := outputWriter
+	outputWriter = &buf
+	defer func() { outputWriter = origStdout }()
+
+	tmpDir := t.TempDir()
+	files := []string{filepath.Join(tmpDir, "a.jsonl"), filepath.Join(tmpDir, "b.jsonl"), filepath.Join(tmpDir, "c.jsonl")}
+	for _, f := range files {
+		if err := os.WriteFile(f, []byte(`{"type":"user"}`+"\n"), 0644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+
+	executor := NewQueryExecutor(tmpDir)
+	code, err := executor.compileExpression(`.`)
+	if err != nil {
+		t.Fatalf("compileExpression failed: %v", err)
+	}
+
+	reporter := &progressReporter{token: "tok-limit"}
+	executor.Progress = reporter.report
+	reporter.start(&directoryMetadata{FileCount: len(files)})
+
+	results := executor.streamFiles(context.Background(), files, code, 1)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	reporter.finish()
+	// A second finish must not emit another notification
+	reporter.finish()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var last JSONRPCNotification
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatalf("failed to parse notification: %v", err)
+	}
+	params, ok := last.Params.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected params map, got %T", last.Params)
+	}
+	if params["progress"] != float64(len(files)) || params["total"] != float64(len(files)) {
+		t.Errorf("expected final progress %d/%d, got %v/%v", len(files), len(files), params["progress"], params["total"])
+	}
+
+	var finals int
+	for _, line := range lines {
+		if strings.Contains(line, fmt.Sprintf(`"progress":%d`, len(files))) {
+			finals++
+		}
+	}
+	if finals != 1 {
+		t.Errorf("expected exactly one final notification, got %d", finals)
+	}
+}
+
+func TestProgressReporterFinishWithoutScan(t *testing.T) {
+	var buf bytes.Buffer
+	origStdout := outputWriter
+	outputWriter = &buf
+	defer func() { outputWriter = origStdout }()
+
+	reporter := &progressReporter{token: "tok-idle"}
+	reporter.finish()
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no notification for a call that never scanned, got %q", buf.String())
+	}
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/yaleh/meta-cc/internal/query"
 )
 
 // TestCompileExpression tests jq expression compilation
@@ -74,108 +78,32 @@ func TestCompileExpression(t *testing.T) {
 	}
 }
 
-// TestExpressionCache tests LRU cache behavior
-func TestExpressionCache(t *testing.T) {
-	cache := &ExpressionCache{
-		entries: make(map[string]interface{}),
-		keys:    []string{},
-		maxSize: 3,
-	}
-
-	// Test cache miss
-	if code := cache.Get("expr1"); code != nil {
-		t.Error("expected cache miss, got hit")
-	}
-
-	// Test cache put and get
-	cache.Put("expr1", "code1")
-	if code := cache.Get("expr1"); code != "code1" {
-		t.Errorf("expected 'code1', got %v", code)
-	}
-
-	// Fill cache to max
-	cache.Put("expr2", "code2")
-	cache.Put("expr3", "code3")
-
-	if len(cache.entries) != 3 {
-		t.Errorf("expected cache size 3, got %d", len(cache.entries))
-	}
-
-	// Test LRU eviction
-	cache.Put("expr4", "code4")
-	if len(cache.entries) != 3 {
-		t.Errorf("expected cache size 3 after eviction, got %d", len(cache.entries))
-	}
-
-	// First entry should be evicted
-	if code := cache.Get("expr1"); code != nil {
-		t.Error("expected expr1 to be evicted")
-	}
-
-	// Newer entries should still exist
-	if code := cache.Get("expr2"); code != "code2" {
-		t.Errorf("expected 'code2', got %v", code)
-	}
-	if code := cache.Get("expr3"); code != "code3" {
-		t.Errorf("expected 'code3', got %v", code)
-	}
-	if code := cache.Get("expr4"); code != "code4" {
-		t.Errorf("expected 'code4', got %v", code)
-	}
-
-	// Test updating existing entry (should move to end)
-	cache.Put("expr2", "code2-updated")
-	if code := cache.Get("expr2"); code != "code2-updated" {
-		t.Errorf("expected 'code2-updated', got %v", code)
-	}
-
-	// Add one more - expr3 should be evicted (oldest after expr2 was moved)
-	cache.Put("expr5", "code5")
-	if code := cache.Get("expr3"); code != nil {
-		t.Error("expected expr3 to be evicted after LRU reordering")
-	}
-	if code := cache.Get("expr2"); code != "code2-updated" {
-		t.Error("expected expr2 to still exist after update")
-	}
-}
-
-// TestCacheHitRate tests cache effectiveness
+// TestCacheHitRate tests that executors share compiled expressions
 func TestCacheHitRate(t *testing.T) {
-	executor := NewQueryExecutor("")
-
 	expressions := []string{
-		"select(.type == \"user\")",
-		"select(.type == \"assistant\")",
-		"select(.type == \"user\")",      // repeat
-		"select(.type == \"assistant\")", // repeat
-		"select(.type == \"user\")",      // repeat
+		"select(.type == \"user\" and .cacheHitRate)",
+		"select(.type == \"assistant\" and .cacheHitRate)",
+		"select(.type == \"user\" and .cacheHitRate)",      // repeat
+		"select(.type == \"assistant\" and .cacheHitRate)", // repeat
+		"select(.type == \"user\" and .cacheHitRate)",      // repeat
 	}
 
-	hits := 0
-	misses := 0
-
+	before := query.SharedJQCacheStats()
 	for _, expr := range expressions {
-		if executor.cache.Get(expr) != nil {
-			hits++
-		} else {
-			misses++
+		// A new executor per query, as executeQuery creates one per request
+		if _, err := NewQueryExecutor("").compileExpression(expr); err != nil {
+			t.Fatal(err)
 		}
-		executor.compileExpression(expr) // compile and cache
 	}
+	after := query.SharedJQCacheStats()
 
 	// First 2 are misses, next 3 are hits
-	if hits != 3 {
+	if hits := after.Hits - before.Hits; hits != 3 {
 		t.Errorf("expected 3 cache hits, got %d", hits)
 	}
-	if misses != 2 {
+	if misses := after.Misses - before.Misses; misses != 2 {
 		t.Errorf("expected 2 cache misses, got %d", misses)
 	}
-
-	// Hit rate should be 60% (3/5)
-	hitRate := float64(hits) / float64(len(expressions))
-	if hitRate < 0.6 {
-		t.Errorf("expected hit rate >= 60%%, got %.2f%%", hitRate*100)
-	}
 }
 
 // TestBuildExpression tests expression building logic
@@ -449,3 +377,120 @@ func TestContextCancellation(t *testing.T) {
 		t.Errorf("expected few results due to cancellation, got %d", len(results))
 	}
 }
+
+func TestStreamFilesSkipsFilesWithoutMatchingTypes(t *testing.T) {
+	tmpDir := t.TempDir()
+	withSummary := filepath.Join(tmpDir, "a.jsonl")
+	withoutSummary := filepath.Join(tmpDir, "b.jsonl")
+	if err := os.WriteFile(withSummary, []byte(`{"type":"summary","summary":"s"}`+"\n"+`{"type":"user"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(withoutSummary, []byte(`{"type":"user"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	executor := NewQueryExecutor(tmpDir)
+	filter := `select(.type == "summary")`
+	executor.Prefilter = query.NewLinePrefilter(query.FilterRecordTypes(filter), nil)
+	code, err := executor.compileExpression(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records int
+	executor.Progress = func(p query.ScanProgress) { records = p.RecordsScanned }
+	// The first query indexes the files it reads, the second skips b.jsonl
+	for i := 0; i < 2; i++ {
+		results := executor.streamFiles(context.Background(), []string{withSummary, withoutSummary}, code, 0)
+		if len(results) != 1 {
+			t.Fatalf("expected 1 summary, got %d", len(results))
+		}
+		if records != 3 {
+			t.Errorf("skipped files still count their indexed records, got %d", records)
+		}
+	}
+
+	if _, _, skipped := executor.Prefilter.SkipIndexed(withoutSummary); !skipped {
+		t.Error("expected file without summaries to be skipped")
+	}
+	if _, _, skipped := executor.Prefilter.SkipIndexed(withSummary); skipped {
+		t.Error("expected file with summaries to be scanned")
+	}
+
+	// Files without a line holding every token are skipped as well
+	executor.Prefilter = query.NewLinePrefilter(nil, []string{`"tool_result"`, `"is_error"`})
+	if _, _, skipped := executor.Prefilter.SkipIndexed(withSummary); !skipped {
+		t.Error("expected file without tool errors to be skipped")
+	}
+}
+
+func TestStreamFilesMergesInFileOrderAndStopsAtLimit(t *testing.T) {
+	tmpDir := t.TempDir()
+	var files []string
+	for i := 0; i < 16; i++ {
+		file := filepath.Join(tmpDir, fmt.Sprintf("s%02d.jsonl", i))
+		// Earlier files are larger so parallel scans finish out of order
+		var data strings.Builder
+		for j := 0; j < (16-i)*50; j++ {
+			data.WriteString(`{"type":"assistant"}` + "\n")
+		}
+		fmt.Fprintf(&data, `{"type":"user","n":%d}`+"\n", i)
+		if err := os.WriteFile(file, []byte(data.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	executor := NewQueryExecutor(tmpDir)
+	executor.Workers = 4
+	code, err := executor.compileExpression(`select(.type == "user") | .n`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := executor.streamFiles(context.Background(), files, code, 0)
+	if fmt.Sprint(results) != "[0 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15]" {
+		t.Errorf("expected results in file order, got %v", results)
+	}
+
+	var filesProcessed int
+	executor.Progress = func(p query.ScanProgress) { filesProcessed = p.FilesProcessed }
+	results = executor.streamFiles(context.Background(), files, code, 2)
+	if fmt.Sprint(results) != "[0 1]" {
+		t.Errorf("expected the first 2 results, got %v", results)
+	}
+	if filesProcessed != 2 {
+		t.Errorf("expected scanning to stop after 2 files, got %d", filesProcessed)
+	}
+}
+
+func TestScanFileTagsSkippedLinesCWD(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "s1.jsonl")
+	content := `{"type":"summary","cwd":"/work/app"}
+{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Read"}]}}
+`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	executor := NewQueryExecutor(tmpDir)
+	executor.TagSources = true
+	filter := `select(.type == "assistant")`
+	executor.Prefilter = query.NewLinePrefilter(query.FilterRecordTypes(filter), []string{`"tool_use"`})
+	code, err := executor.compileExpression(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scan := executor.ScanFile(context.Background(), file, code, 0)
+	if scan.Err != nil {
+		t.Fatal(scan.Err)
+	}
+	if len(scan.Results) != 1 || scan.Records != 2 {
+		t.Fatalf("expected 1 result of 2 records, got %d of %d", len(scan.Results), scan.Records)
+	}
+	if got := scan.Results[0].(map[string]interface{})[query.ProjectPathField]; got != "/work/app" {
+		t.Errorf("expected cwd from the prefiltered line, got %v", got)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/locator"
+	"github.com/yaleh/meta-cc/internal/sessionio"
+)
+
+// scopeAll fans a query out across every project under the Claude projects
+// root (~/.claude/projects or META_CC_PROJECTS_ROOT)
+const scopeAll = "all"
+
+// querySource selects the session files a query reads
+type querySource struct {
+	scope    string   // session, project or all
+	projects []string // project paths, names or globs narrowing scope all
+}
+
+// source returns the query source selected by the call's scope and projects arguments
+func (c toolCall) source() querySource {
+	return querySource{scope: c.scope, projects: getStringSliceParam(c.args, "projects")}
+}
+
+// tagSources reports whether records must carry project_path and session_id
+func (s querySource) tagSources() bool {
+	return s.scope == scopeAll
+}
+
+// files returns the base directory and the session files to read, newest first
+func (s querySource) files() (string, []string, error) {
+	if len(s.projects) > 0 && s.scope != scopeAll {
+		return "", nil, fmt.Errorf("projects requires scope '%s' (got '%s'): %w", scopeAll, s.scope, mcerrors.ErrInvalidInput)
+	}
+
+	if s.scope != scopeAll {
+		baseDir, err := getQueryBaseDir(s.scope)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to get base directory: %w", err)
+		}
+		files, err := getJSONLFiles(baseDir)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to list JSONL files: %w", err)
+		}
+		return baseDir, files, nil
+	}
+
+	loc := locator.NewSessionLocator()
+	projects, err := loc.ListProjects(s.projects)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	if len(projects) == 0 {
+		return "", nil, fmt.Errorf("no projects with sessions found in %s matching %v: %w", loc.ProjectsRoot(), s.projects, mcerrors.ErrNotFound)
+	}
+
+	dirs := make([]string, 0, len(projects))
+	for _, p := range projects {
+		dirs = append(dirs, p.Path)
+	}
+	files, err := getJSONLFilesIn(dirs...)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list JSONL files: %w", err)
+	}
+	return loc.ProjectsRoot(), files, nil
+}
+
+// getJSONLFilesIn returns the session files of several directories (non-recursive)
+// Files are sorted by modification time (newest first) across all directories
+func getJSONLFilesIn(dirs ...string) ([]string, error) {
+	type fileInfo struct {
+		path    string
+		modTime int64
+	}
+	var fileInfos []fileInfo
+
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("failed to read directory: %w", err)
+		}
+		// Archived sessions (.jsonl.gz, .jsonl.zst) are read transparently
+		paths, err := sessionio.Glob(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory: %w", err)
+		}
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				// Skip files we can't stat
+				continue
+			}
+			fileInfos = append(fileInfos, fileInfo{
+				path:    path,
+				modTime: info.ModTime().Unix(),
+			})
+		}
+	}
+
+	sort.SliceStable(fileInfos, func(i, j int) bool {
+		return fileInfos[i].modTime > fileInfos[j].modTime
+	})
+
+	files := make([]string, 0, len(fileInfos))
+	for _, fi := range fileInfos {
+		files = append(files, fi.path)
+	}
+	return files, nil
+}
+
+// getStringSliceParam reads an array of strings from raw tool arguments
+func getStringSliceParam(args map[string]interface{}, key string) []string {
+	switch v := args[key].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/query"
+	"github.com/yaleh/meta-cc/internal/testutil"
+)
+
+// setupProjectsRoot creates two projects with one failing Bash call each
+// ("api" fails twice) and returns the projects root
+func setupProjectsRoot(t *testing.T) string {
+	t.Helper()
+
+	root := testutil.SetupProjectsRoot(t)
+
+	sessions := map[string]string{
+		"-work-api/s-api.jsonl": `{"type":"user","cwd":"/work/api","message":{"content":[{"type":"tool_result","tool_use_id":"a1","is_error":true,"content":"boom"}]}}
+{"type":"user","cwd":"/work/api","message":{"content":[{"type":"tool_result","tool_use_id":"a2","is_error":true,"content":"boom"}]}}
+`,
+		"-work-web/s-web.jsonl": `{"type":"user","cwd":"/work/web","message":{"content":[{"type":"tool_result","tool_use_id":"w1","is_error":true,"content":"boom"}]}}
+`,
+	}
+	for name, data := range sessions {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		testutil.WriteSession(t, path, data)
+	}
+	return root
+}
+
+func TestScopeAllTagsRecordsAcrossProjects(t *testing.T) {
+	setupProjectsRoot(t)
+	executor := NewToolExecutor()
+
+	results, err := executor.handleQueryToolErrors(context.Background(), nil, querySource{scope: scopeAll}, queryLimitArgs{})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	perProject := map[string]int{}
+	for _, r := range results {
+		record := r.(map[string]interface{})
+		perProject[record[query.ProjectPathField].(string)]++
+		require.NotEmpty(t, record[query.SessionIDField])
+	}
+	require.Equal(t, map[string]int{"/work/api": 2, "/work/web": 1}, perProject)
+
+	results, err = executor.handleQueryToolErrors(context.Background(), nil, querySource{scope: scopeAll, projects: []string{"web"}}, queryLimitArgs{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "s-web", results[0].(map[string]interface{})[query.SessionIDField])
+}
+
+func TestScopeAllValidation(t *testing.T) {
+	setupProjectsRoot(t)
+	executor := NewToolExecutor()
+
+	_, err := executor.handleQueryToolErrors(context.Background(), nil, querySource{scope: "project", projects: []string{"web"}}, queryLimitArgs{})
+	require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "unexpected error: %v", err)
+
+	_, err = executor.handleQueryToolErrors(context.Background(), nil, querySource{scope: scopeAll, projects: []string{"missing"}}, queryLimitArgs{})
+	require.True(t, errors.Is(err, mcerrors.ErrNotFound), "unexpected error: %v", err)
+}
+
+func TestStage1ToolsSupportScopeAll(t *testing.T) {
+	root := setupProjectsRoot(t)
+
+	dir, err := handleGetSessionDirectory(context.Background(), sessionDirectoryArgs{Scope: scopeAll})
+	require.NoError(t, err)
+	info := dir.(map[string]interface{})
+	require.Equal(t, filepath.Clean(root), info["directory"])
+	require.Equal(t, 2, info["project_count"])
+	require.Equal(t, 2, info["file_count"])
+
+	meta, err := handleGetSessionMetadata(context.Background(), sessionMetadataArgs{Scope: scopeAll, Projects: []string{"api"}})
+	require.NoError(t, err)
+	files := meta.(map[string]interface{})["files"].([]map[string]interface{})
+	require.Len(t, files, 1)
+	require.Equal(t, "-work-api", files[0]["project"])
+}
+
+func TestStage2QueryTagsFilesFromSeveralProjects(t *testing.T) {
+	root := setupProjectsRoot(t)
+	files := []string{
+		filepath.Join(root, "-work-api", "s-api.jsonl"),
+		filepath.Join(root, "-work-web", "s-web.jsonl"),
+	}
+
+	result, err := handleExecuteStage2Query(context.Background(), stage2QueryArgs{Files: files, Filter: ".", Transform: "{type}"})
+	require.NoError(t, err)
+	results := result.(map[string]interface{})["results"].([]interface{})
+	require.Len(t, results, 3)
+	require.Equal(t, "/work/web", results[2].(map[string]interface{})[query.ProjectPathField])
+
+	result, err = handleExecuteStage2Query(context.Background(), stage2QueryArgs{Files: files[:1], Filter: ".", Transform: "{type}"})
+	require.NoError(t, err)
+	results = result.(map[string]interface{})["results"].([]interface{})
+	require.NotContains(t, results[0], query.ProjectPathField, "single-project queries are not tagged")
+}
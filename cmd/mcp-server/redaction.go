@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/yaleh/meta-cc/internal/config"
+	"github.com/yaleh/meta-cc/internal/redact"
+)
+
+// redaction.go masks secrets and personal data in tool responses before they
+// are returned inline or written to file_ref outputs. Session tool outputs
+// routinely hold API keys from env dumps, .env contents and emails.
+
+// redactorCache keeps the redactor built for the current configuration so
+// user-defined patterns are compiled once
+var redactorCache struct {
+	mu       sync.Mutex
+	cfg      *config.Config
+	redactor *redact.Redactor
+}
+
+// responseRedactor returns the redactor configured by cfg, or nil when
+// redaction is disabled. Without configuration every detector runs.
+func responseRedactor(cfg *config.Config) *redact.Redactor {
+	redactorCache.mu.Lock()
+	defer redactorCache.mu.Unlock()
+	if redactorCache.redactor != nil && redactorCache.cfg == cfg {
+		return redactorCache.redactor
+	}
+
+	redactor := newResponseRedactor(cfg)
+	redactorCache.cfg = cfg
+	redactorCache.redactor = redactor
+	return redactor
+}
+
+func newResponseRedactor(cfg *config.Config) *redact.Redactor {
+	if cfg == nil {
+		return redact.New(redact.DefaultOptions())
+	}
+	if !cfg.Redaction.Enabled {
+		return nil
+	}
+	opts, err := redact.ParseOptions(cfg.Redaction.DetectorsSlice(), cfg.Redaction.PatternsSlice())
+	if err != nil {
+		// config.Load validates both lists; fail closed if it was bypassed
+		slog.Warn("invalid redaction configuration, using all detectors",
+			"error", err.Error(),
+		)
+		return redact.New(redact.DefaultOptions())
+	}
+	return redact.New(opts)
+}
+
+// redactionMetadata describes the redactions of a response
+func redactionMetadata(counts redact.Counts) map[string]interface{} {
+	return map[string]interface{}{
+		"total":   counts.Total(),
+		"by_kind": counts,
+	}
+}
+
+// recordRedactions counts the redactions of a tool response in metrics and logs
+func recordRedactions(toolName string, counts redact.Counts) {
+	if counts.Total() == 0 {
+		return
+	}
+	for _, kind := range counts.Kinds() {
+		RecordRedactions(toolName, kind, counts[kind])
+	}
+	slog.Debug("redacted tool response",
+		"tool_name", toolName,
+		"redactions", counts.Total(),
+	)
+}
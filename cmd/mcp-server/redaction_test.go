@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yaleh/meta-cc/internal/config"
+)
+
+// setupSecretSession creates a project whose only tool call failed with a
+// leaked API key and an email in its output, and returns the session path
+func setupSecretSession(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("META_CC_PROJECTS_ROOT", root)
+
+	path := filepath.Join(root, "-work-api", "s-secret.jsonl")
+	data := `{"type":"assistant","uuid":"a1","timestamp":"2025-10-02T10:00:00Z","cwd":"/work/api","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"env"}}]}}
+{"type":"user","uuid":"u1","timestamp":"2025-10-02T10:00:01Z","cwd":"/work/api","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":"ANTHROPIC_API_KEY=sk-ant-REDACTED OWNER=ops@corp.com"}]}}
+`
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	return path
+}
+
+func TestToolResponsesAreRedacted(t *testing.T) {
+	path := setupSecretSession(t)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	executor := NewToolExecutor()
+
+	// Records tools report redactions next to the data
+	output, err := executor.ExecuteTool(context.Background(), cfg, "query_tool_errors", map[string]interface{}{"scope": "all"})
+	require.NoError(t, err)
+	require.NotContains(t, output, "sk-ant-api03")
+	require.NotContains(t, output, "ops@corp.com")
+	require.Contains(t, output, "[REDACTED:api_key]")
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(output), &response))
+	redactions := response["redactions"].(map[string]interface{})
+	require.Equal(t, 2.0, redactions["total"])
+	require.Equal(t, map[string]interface{}{"api_key": 1.0, "email": 1.0}, redactions["by_kind"])
+
+	// JSON tools are redacted too
+	output, err = executor.ExecuteTool(context.Background(), cfg, "execute_stage2_query", map[string]interface{}{
+		"files":  []interface{}{path},
+		"filter": `select(.type == "user")`,
+	})
+	require.NoError(t, err)
+	require.NotContains(t, output, "sk-ant-api03")
+	require.Contains(t, output, `"redactions"`)
+}
+
+func TestTextResponsesAreRedacted(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	tool := defineTool(toolSpec{Name: "echo_text", Scope: scopeNone, Output: outputText},
+		func(ctx context.Context, call toolCall, args struct{}) (interface{}, error) {
+			return "Set ANTHROPIC_API_KEY=sk-ant-REDACTED before running", nil
+		})
+
+	output, err := NewToolExecutor().runTool(context.Background(), tool, toolCall{cfg: cfg, args: map[string]interface{}{}})
+	require.NoError(t, err)
+	require.Equal(t, "Set ANTHROPIC_API_KEY=[REDACTED:api_key] before running", output)
+}
+
+func TestRedactionCanBeDisabled(t *testing.T) {
+	setupSecretSession(t)
+	t.Setenv("META_CC_REDACTION", "false")
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	output, err := NewToolExecutor().ExecuteTool(context.Background(), cfg, "query_tool_errors", map[string]interface{}{"scope": "all"})
+	require.NoError(t, err)
+	require.True(t, strings.Contains(output, "sk-ant-api03"), "redaction disabled: %s", output)
+	require.NotContains(t, output, `"redactions"`)
+}
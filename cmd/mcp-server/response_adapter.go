@@ -3,9 +3,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/yaleh/meta-cc/internal/config"
 	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/pkg/output"
 )
 
 // adaptResponse adapts CLI output to hybrid mode format (inline or file_ref).
@@ -13,40 +16,120 @@ import (
 //
 // Parameters:
 //   - data: Parsed JSONL records from CLI
-//   - params: MCP tool parameters (including output_mode, inline_threshold_bytes)
+//   - params: MCP tool parameters (including output_mode, inline_threshold_bytes,
+//     fields, if_error_include, output_format)
 //   - toolName: Name of the MCP tool being executed
 //
 // Returns:
-//   - Inline mode: {"mode": "inline", "data": [...]}
-//   - File ref mode: {"mode": "file_ref", "file_ref": {...}}
+//   - Inline mode: {"mode": "inline", "result_id": "...", "data": [...]}
+//   - File ref mode: {"mode": "file_ref", "result_id": "...", "file_ref": {...}}, with
+//     "chunks" listing the chunk files of results over the chunk threshold
+//   - Summarized mode: {"mode": "summarized", "result_id": "...", "data": [...],
+//     "distributions": {...}, "omitted": {...}, "fetch": {...}} (see output_summary.go)
+//
+// fields projects the records before anything else, so the stored result is
+// the projected one. output_format renders the data of inline and summarized
+// responses ("format" names it); stored results stay JSONL.
+//
+// Every mode stores the records in the output store (reused when identical),
+// so query_result can refine them later by result_id or save_as name.
 func adaptResponse(cfg *config.Config, data []interface{}, params map[string]interface{}, toolName string) (interface{}, error) {
+	format := getStringParam(params, "output_format", output.FormatJSONLRecords)
+	if !slices.Contains(output.RecordFormats, format) {
+		return nil, fmt.Errorf("unknown output format '%s' (must be one of: %s): %w", format, strings.Join(output.RecordFormats, ", "), mcerrors.ErrInvalidInput)
+	}
+	projection := output.ProjectionConfig{
+		Fields:         getStringSliceParam(params, "fields"),
+		IfErrorInclude: getStringSliceParam(params, "if_error_include"),
+	}
+	if len(projection.Fields) > 0 || format != output.FormatJSONLRecords {
+		data = output.ProjectRecords(normalizeRecords(data), projection)
+	}
 
-	// Determine output mode (no truncation - rely on hybrid mode)
+	// Determine output mode (no truncation - rely on hybrid mode); formatted
+	// data is measured as rendered
 	size := calculateOutputSize(data)
+	formatted, err := formatRecords(data, projection.Fields, format)
+	if err != nil {
+		return nil, err
+	}
+	if format != output.FormatJSONLRecords {
+		size = formattedSize(formatted)
+	}
 	config := getOutputModeConfig(cfg, params)
 	mode := selectOutputModeWithConfig(size, getStringParam(params, "output_mode", ""), config)
+	if !isOutputMode(mode) {
+		return nil, fmt.Errorf("unknown output mode '%s' in adaptResponse: %w", mode, mcerrors.ErrInvalidInput)
+	}
 
-	// Build response based on mode
-	switch mode {
-	case OutputModeInline:
-		return buildInlineResponse(data), nil
-
-	case OutputModeFileRef:
-		// Create temp file
-		sessionHash := getSessionHash(cfg)
-		filePath := createTempFilePath(sessionHash, toolName)
+	store := outputStoreFor(cfg)
+	filePath, err := store.Write(toolName, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store result: %w", err)
+	}
+	id := resultID(filePath)
+	if name := getStringParam(params, "save_as", ""); name != "" {
+		if err := store.Name(name, id); err != nil {
+			return nil, err
+		}
+	}
 
-		// Write data to temp file
-		if err := writeJSONLFile(filePath, data); err != nil {
-			return nil, fmt.Errorf("failed to write temp file %s: %w", filePath, mcerrors.ErrFileIO)
+	// Build response based on mode
+	if mode == OutputModeSummarized {
+		budget := config.InlineThresholdBytes
+		if config.MaxTokens > 0 {
+			budget = config.MaxTokens * output.BytesPerToken
 		}
+		response := buildSummarizedResponse(data, id, filePath, budget)
+		if format != output.FormatJSONLRecords {
+			selected, _ := response["data"].([]interface{})
+			if response["data"], err = formatRecords(selected, projection.Fields, format); err != nil {
+				return nil, err
+			}
+			response["format"] = format
+		}
+		return response, nil
+	}
+	if mode == OutputModeInline {
+		response := buildInlineResponse(data)
+		response["result_id"] = id
+		if format != output.FormatJSONLRecords {
+			response["data"] = formatted
+			response["format"] = format
+		}
+		return response, nil
+	}
+	response, err := buildFileRefResponse(filePath, data)
+	if err != nil {
+		return nil, err
+	}
+	response["result_id"] = id
+	return response, nil
+}
 
-		// Build file reference response
-		return buildFileRefResponse(filePath, data)
+// formatRecords renders records in an output format, with the requested
+// fields as the leading columns
+func formatRecords(records []interface{}, fields []string, format string) (interface{}, error) {
+	if format == output.FormatJSONLRecords {
+		return records, nil
+	}
+	formatted, err := output.FormatRecords(records, output.RecordColumns(records, fields), format)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, mcerrors.ErrInvalidInput)
+	}
+	return formatted, nil
+}
 
-	default:
-		return nil, fmt.Errorf("unknown output mode '%s' in adaptResponse: %w", mode, mcerrors.ErrInvalidInput)
+// formattedSize returns the size of formatted data in a response
+func formattedSize(formatted interface{}) int {
+	if text, ok := formatted.(string); ok {
+		return len(text)
 	}
+	data, err := json.Marshal(formatted)
+	if err != nil {
+		return 0
+	}
+	return len(data)
 }
 
 // buildInlineResponse constructs inline mode response
@@ -73,6 +156,17 @@ func buildFileRefResponse(filePath string, data []interface{}) (map[string]inter
 		"fields":     fileRef.Fields,
 		"summary":    fileRef.Summary,
 	}
+	if len(fileRef.Chunks) > 0 {
+		listed := fileRef.Chunks
+		if len(listed) > maxListedChunks {
+			listed = listed[:maxListedChunks]
+			fileRefMap["chunks_omitted"] = len(fileRef.Chunks) - len(listed)
+		}
+		fileRefMap["chunks"] = listed
+		if fileRef.GroupedBy != "" {
+			fileRefMap["grouped_by"] = fileRef.GroupedBy
+		}
+	}
 
 	// Build response
 	response := map[string]interface{}{
@@ -91,29 +185,3 @@ func serializeResponse(response interface{}) (string, error) {
 	}
 	return string(jsonBytes), nil
 }
-
-// getSessionHash returns current session hash for temp file naming
-// Falls back to "unknown" if session info is not available
-func getSessionHash(cfg *config.Config) string {
-	// Use session hash from centralized config
-	sessionID := cfg.Session.SessionID
-	if sessionID != "" {
-		// Use first 8 chars of session ID as hash
-		if len(sessionID) > 8 {
-			return sessionID[:8]
-		}
-		return sessionID
-	}
-
-	// Try project hash
-	projectHash := cfg.Session.ProjectHash
-	if projectHash != "" {
-		if len(projectHash) > 8 {
-			return projectHash[:8]
-		}
-		return projectHash
-	}
-
-	// Fallback to "unknown"
-	return "unknown"
-}
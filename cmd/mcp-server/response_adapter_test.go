@@ -2,11 +2,15 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/pkg/output"
 )
 
 // TestAdaptInlineResponse tests inline mode formatting for small results
@@ -281,10 +285,8 @@ func TestBuildFileRefResponse(t *testing.T) {
 		map[string]interface{}{"tool": "Read", "status": "success"},
 	}
 
-	// Create temp file
-	filePath := createTempFilePath("test-session", "query_tools")
-
-	err := writeJSONLFile(filePath, data)
+	// Store output file
+	filePath, err := outputStoreFor(nil).Write("query_tools", data)
 	if err != nil {
 		t.Fatalf("failed to write temp file: %v", err)
 	}
@@ -523,3 +525,86 @@ func BenchmarkAdaptResponseFileRef(b *testing.B) {
 		}
 	}
 }
+
+// TestAdaptProjectionAndFormats tests fields, if_error_include and output_format
+func TestAdaptProjectionAndFormats(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load failed: %v", err)
+	}
+	fields := []interface{}{"uuid", "input.command"}
+
+	result, err := adaptResponse(cfg, bulkyToolCalls(2), map[string]interface{}{
+		"fields":           fields,
+		"if_error_include": []interface{}{"error"},
+	}, "query_tools")
+	if err != nil {
+		t.Fatalf("adaptResponse failed: %v", err)
+	}
+	data := result.(map[string]interface{})["data"].([]interface{})
+	want := []interface{}{
+		map[string]interface{}{"uuid": "u000", "error": "exit status 1"},
+		map[string]interface{}{"uuid": "u001"},
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("unexpected projection: %v", data)
+	}
+
+	result, err = adaptResponse(cfg, bulkyToolCalls(2), map[string]interface{}{
+		"fields":        []interface{}{"uuid", "status"},
+		"output_format": "csv",
+	}, "query_tools")
+	if err != nil {
+		t.Fatalf("adaptResponse failed: %v", err)
+	}
+	response := result.(map[string]interface{})
+	if response["format"] != "csv" || response["data"] != "uuid,status\nu000,error\nu001,success\n" {
+		t.Errorf("unexpected csv response: %v", response)
+	}
+
+	// The stored result stays JSONL of the projected records
+	stored, _, err := outputStoreFor(cfg).Load(response["result_id"].(string))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(stored[0], map[string]interface{}{"uuid": "u000", "status": "error"}) {
+		t.Errorf("unexpected stored record: %v", stored[0])
+	}
+
+	result, err = adaptResponse(cfg, bulkyToolCalls(2), map[string]interface{}{"output_format": "columnar"}, "query_tools")
+	if err != nil {
+		t.Fatalf("adaptResponse failed: %v", err)
+	}
+	columnar := result.(map[string]interface{})["data"].(output.ColumnarRecords)
+	if len(columnar.Rows) != 2 || !strings.Contains(strings.Join(columnar.Columns, ","), "tool_name") {
+		t.Errorf("unexpected columnar data: %v", columnar)
+	}
+
+	if _, err := adaptResponse(cfg, bulkyToolCalls(2), map[string]interface{}{"output_format": "xml"}, "query_tools"); !errors.Is(err, mcerrors.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for unknown format, got %v", err)
+	}
+}
+
+// TestAdaptSummarizedFormat tests output_format on summarized responses
+func TestAdaptSummarizedFormat(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load failed: %v", err)
+	}
+
+	result, err := adaptResponse(cfg, bulkyToolCalls(60), map[string]interface{}{
+		"max_tokens":    float64(1500),
+		"output_format": "markdown",
+	}, "query_tools")
+	if err != nil {
+		t.Fatalf("adaptResponse failed: %v", err)
+	}
+	response := result.(map[string]interface{})
+	table, ok := response["data"].(string)
+	if response["mode"] != OutputModeSummarized || !ok || !strings.HasPrefix(table, "| error |") {
+		t.Errorf("unexpected summarized markdown response: %v", response)
+	}
+	if strings.Count(table, "\n") != response["returned_records"].(int)+2 {
+		t.Errorf("expected a row per returned record:\n%s", table)
+	}
+}
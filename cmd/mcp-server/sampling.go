@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/query"
+)
+
+// sampling.go draws the random samples query tools return for sample,
+// stratify_by and seed (see query.Sampler), and carries the report of how a
+// sample was drawn to the response, next to the records.
+
+// samplingRecorderContextKey is the context key of a call's samplingRecorder
+type samplingRecorderContextKey struct{}
+
+// samplingRecorder holds the sample report of a tool call, set by the
+// handler that drew the sample
+type samplingRecorder struct {
+	report *query.SampleReport
+}
+
+// withSamplingRecorder returns a context whose handler can report a sample
+func withSamplingRecorder(ctx context.Context) (context.Context, *samplingRecorder) {
+	recorder := &samplingRecorder{}
+	return context.WithValue(ctx, samplingRecorderContextKey{}, recorder), recorder
+}
+
+// recordSampling stores report in the recorder of ctx, if any
+func recordSampling(ctx context.Context, report *query.SampleReport) {
+	if ctx == nil {
+		return
+	}
+	if recorder, ok := ctx.Value(samplingRecorderContextKey{}).(*samplingRecorder); ok {
+		recorder.report = report
+	}
+}
+
+// addSampling reports how the records of an adapted response were sampled
+func addSampling(response interface{}, report *query.SampleReport) {
+	if m, ok := response.(map[string]interface{}); ok && report != nil {
+		m["sampling"] = report
+	}
+}
+
+// sampleConfig returns the sample the arguments ask for, or nil without sample
+func (a queryLimitArgs) sampleConfig() (*query.SampleConfig, error) {
+	if a.Sample < 0 || a.Seed < 0 {
+		return nil, fmt.Errorf("sample and seed must not be negative: %w", mcerrors.ErrInvalidInput)
+	}
+	if a.Sample == 0 {
+		if a.StratifyBy != "" {
+			return nil, fmt.Errorf("stratify_by requires sample: %w", mcerrors.ErrInvalidInput)
+		}
+		return nil, nil
+	}
+	config := &query.SampleConfig{Size: a.Sample, StratifyBy: a.StratifyBy, Seed: uint64(a.Seed)}
+	// Reject an invalid stratify_by before any file is read
+	if _, err := query.NewSampler(*config); err != nil {
+		return nil, fmt.Errorf("%v: %w", err, mcerrors.ErrInvalidInput)
+	}
+	return config, nil
+}
+
+// newSampler returns the sampler the arguments ask for, or nil without sample
+func (a queryLimitArgs) newSampler() (*query.Sampler, error) {
+	config, err := a.sampleConfig()
+	if err != nil || config == nil {
+		return nil, err
+	}
+	return query.NewSampler(*config)
+}
+
+// finishSample returns the records drawn by sampler, at most limit of them,
+// and records its report for the response
+func finishSample(ctx context.Context, sampler *query.Sampler, limit int) []interface{} {
+	records := sampler.Records()
+	recordSampling(ctx, sampler.Report())
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records
+}
+
+// sampleRecords draws the sample the arguments ask for from records already
+// collected, at most limit of them; without sample records are returned as is
+func sampleRecords(ctx context.Context, records []interface{}, args queryLimitArgs, limit int) ([]interface{}, error) {
+	sampler, err := args.newSampler()
+	if err != nil || sampler == nil {
+		return records, err
+	}
+	for _, record := range records {
+		sampler.Add(record)
+	}
+	return finishSample(ctx, sampler, limit), nil
+}
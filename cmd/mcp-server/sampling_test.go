@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/query"
+)
+
+func TestQueryToolSampleReportsSampling(t *testing.T) {
+	setupProjectsRoot(t)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	output, err := NewToolExecutor().ExecuteTool(context.Background(), cfg, "query_tool_errors", map[string]interface{}{
+		"scope":       scopeAll,
+		"sample":      2,
+		"stratify_by": query.ProjectPathField,
+		"seed":        3,
+	})
+	require.NoError(t, err)
+
+	var response struct {
+		Data     []map[string]interface{} `json:"data"`
+		Sampling query.SampleReport       `json:"sampling"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(output), &response), output)
+
+	// The smaller project is not drowned out by the larger one
+	require.Len(t, response.Data, 2)
+	require.NotEqual(t, response.Data[0][query.ProjectPathField], response.Data[1][query.ProjectPathField])
+	require.Equal(t, query.SampleReport{
+		Method:     query.SampleStratified,
+		SampleSize: 2,
+		Seed:       3,
+		StratifyBy: query.ProjectPathField,
+		Population: 3,
+		Sampled:    2,
+		Strata: map[string]query.StratumCount{
+			"/work/api": {Population: 2, Sampled: 1},
+			"/work/web": {Population: 1, Sampled: 1},
+		},
+	}, response.Sampling)
+
+	// Without sample, responses carry no sampling report
+	output, err = NewToolExecutor().ExecuteTool(context.Background(), cfg, "query_tool_errors", map[string]interface{}{"scope": scopeAll})
+	require.NoError(t, err)
+	require.NotContains(t, output, `"sampling"`)
+}
+
+func TestSampleArgumentErrors(t *testing.T) {
+	executor := NewToolExecutor()
+	for _, args := range []queryLimitArgs{
+		{Sample: -1},
+		{StratifyBy: "tool_name"},
+		{Sample: 1, StratifyBy: "tool_name |"},
+	} {
+		_, err := executor.handleQueryToolErrors(context.Background(), nil, querySource{scope: scopeAll}, args)
+		require.True(t, errors.Is(err, mcerrors.ErrInvalidInput), "args %+v: unexpected error %v", args, err)
+	}
+}
+
+func TestStage2QuerySample(t *testing.T) {
+	root := setupProjectsRoot(t)
+	files := []string{
+		filepath.Join(root, "-work-api", "s-api.jsonl"),
+		filepath.Join(root, "-work-web", "s-web.jsonl"),
+	}
+
+	result, err := handleExecuteStage2Query(context.Background(), stage2QueryArgs{
+		Files:      files,
+		Filter:     ".",
+		Sample:     1,
+		StratifyBy: query.SessionIDField,
+	})
+	require.NoError(t, err)
+	response := result.(map[string]interface{})
+	require.Len(t, response["results"], 1)
+
+	sampling := response["sampling"].(*query.SampleReport)
+	require.Equal(t, 3, sampling.Population)
+	// With fewer sample slots than strata, the largest stratum is sampled
+	require.Equal(t, query.StratumCount{Population: 2, Sampled: 1}, sampling.Strata["s-api"])
+	require.Equal(t, query.StratumCount{Population: 1, Sampled: 0}, sampling.Strata["s-web"])
+}
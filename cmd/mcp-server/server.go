@@ -27,6 +27,13 @@ type JSONRPCResponse struct {
 	Error   *JSONRPCError `json:"error,omitempty"`
 }
 
+// JSONRPCNotification is a server-initiated message that expects no response
+type JSONRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 type JSONRPCError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -71,9 +78,21 @@ func handleRequest(req JSONRPCRequest) {
 	RecordConcurrentRequestInc()
 	defer RecordConcurrentRequestDec()
 
+	// Notifications never receive a response, not even an error
+	if isNotification(req) {
+		handleNotification(ctx, req)
+		return
+	}
+
 	switch req.Method {
 	case "initialize":
 		handleInitialize(ctx, req)
+	case "ping":
+		handlePing(ctx, req)
+	case "logging/setLevel":
+		handleLoggingSetLevel(ctx, req)
+	case "completion/complete":
+		handleCompletionComplete(ctx, req)
 	case "tools/list":
 		// Record tools/list request (no tool name)
 		RecordRequest("list", "tools/list", "success")
@@ -100,19 +119,20 @@ func handleRequest(req JSONRPCRequest) {
 
 func handleInitialize(ctx context.Context, req JSONRPCRequest) {
 	traceID := GetTraceID(ctx)
+
+	requested, _ := req.Params["protocolVersion"].(string)
+	negotiated := negotiateProtocolVersion(requested)
+
 	slog.Info("initialize request",
+		"requested_protocol_version", requested,
+		"protocol_version", negotiated,
 		"trace_id", traceID,
 	)
 
 	result := map[string]interface{}{
-		"protocolVersion": "2024-11-05",
-		"capabilities": map[string]interface{}{
-			"tools": map[string]bool{},
-		},
-		"serverInfo": map[string]string{
-			"name":    "meta-cc-mcp",
-			"version": "1.0.0",
-		},
+		"protocolVersion": negotiated,
+		"capabilities":    serverCapabilities(),
+		"serverInfo":      serverInfo(),
 	}
 	writeResponse(req.ID, result)
 }
@@ -164,6 +184,10 @@ func handleToolsCall(ctx context.Context, req JSONRPCRequest) {
 		defer span.End()
 	}
 
+	// Attach a progress reporter if the client supplied a progressToken
+	reporter := newProgressReporter(params)
+	ctx = withProgressReporter(ctx, reporter)
+
 	// Create request-scoped logger
 	logger, requestID := NewRequestLogger(toolName)
 
@@ -185,8 +209,9 @@ func handleToolsCall(ctx context.Context, req JSONRPCRequest) {
 	)
 
 	// Execute tool
-	output, err := executor.ExecuteTool(cfg, toolName, arguments)
+	output, err := executor.ExecuteTool(ctx, cfg, toolName, arguments)
 	elapsed := time.Since(start)
+	reporter.finish()
 
 	if err != nil {
 		errorType := classifyError(err)
@@ -289,3 +314,12 @@ func writeError(id interface{}, code int, message string) {
 	}
 	_ = json.NewEncoder(outputWriter).Encode(resp)
 }
+
+func writeNotification(method string, params interface{}) {
+	notification := JSONRPCNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	}
+	_ = json.NewEncoder(outputWriter).Encode(notification)
+}
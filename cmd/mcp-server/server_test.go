@@ -97,16 +97,22 @@ func TestHandleToolsList(t *testing.T) {
 		t.Fatalf("expected tools to be a slice, got %T", toolsInterface)
 	}
 
-	// Should have 17 tools after Phase 27 Stage 27.5
+	// Should have 25 tools
 	// Phase 25: 15 tools (1 query + 1 query_raw + 10 convenience + 3 utility)
 	// Phase 27 Stage 27.1: Removed query and query_raw (15 -> 13)
 	// Phase 27 Stage 27.2: Added get_session_directory (13 -> 14)
 	// Phase 27 Stage 27.3: Added inspect_session_files (14 -> 15)
 	// Phase 27 Stage 27.4: Added execute_stage2_query (15 -> 16)
 	// Phase 27 Stage 27.5: Added get_session_metadata (16 -> 17)
-	// Final: 17 tools (10 convenience + 3 utility + 4 two-stage)
-	if len(toolsSlice) != 17 {
-		t.Errorf("expected 17 tools after Phase 27 Stage 27.5, got %d", len(toolsSlice))
+	// Added get_server_metrics (17 -> 18)
+	// Added list_projects and list_sessions (18 -> 20)
+	// Added archive_sessions (20 -> 21)
+	// Added export_sessions and import_sessions (21 -> 23)
+	// Added query_security_audit (23 -> 24)
+	// Added query_result (24 -> 25)
+	// Final: 25 tools (11 convenience + 1 result + 7 utility + 4 two-stage + 2 catalog)
+	if len(toolsSlice) != 25 {
+		t.Errorf("expected 25 tools, got %d", len(toolsSlice))
 	}
 }
 
@@ -1,41 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"sync"
 	"time"
 
+	"github.com/yaleh/meta-cc/internal/config"
 	mcerrors "github.com/yaleh/meta-cc/internal/errors"
 )
 
-// TempFileManager manages temporary JSONL files with concurrency safety
-type TempFileManager struct {
-	mu sync.Mutex
-}
-
-var tempFileManager = &TempFileManager{}
+// legacyTempFilePrefix starts the name of file_ref outputs written to the
+// shared temp directory by earlier versions; they are still cleaned up
+const legacyTempFilePrefix = "meta-cc-mcp-"
 
-// createTempFilePath generates a unique temporary file path
-//
-// Pattern: /tmp/meta-cc-mcp-{session_hash}-{timestamp}-{query_type}.jsonl
-//
-// Parameters:
-//   - sessionHash: First 8 chars of session ID for grouping
-//   - queryType: Tool name (e.g., "query_tools", "get_stats")
-//
-// Returns:
-//   - Absolute path to temp file
-func createTempFilePath(sessionHash, queryType string) string {
-	// Use nanosecond timestamp for uniqueness
-	timestamp := time.Now().UnixNano()
-
-	filename := fmt.Sprintf("meta-cc-mcp-%s-%d-%s.jsonl",
-		sessionHash, timestamp, queryType)
-
-	return filepath.Join(os.TempDir(), filename)
+// encodeJSONL serializes records as JSON lines
+func encodeJSONL(data []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range data {
+		if err := encoder.Encode(record); err != nil {
+			return nil, fmt.Errorf("failed to encode record: %w", mcerrors.ErrParseError)
+		}
+	}
+	return buf.Bytes(), nil
 }
 
 // writeJSONLFile writes data to a JSONL file
@@ -48,34 +38,33 @@ func createTempFilePath(sessionHash, queryType string) string {
 //   - Error if file creation or write fails
 //
 // The function:
-//  1. Creates parent directories if needed
+//  1. Creates parent directories if needed (0700)
 //  2. Writes each record as a JSON line
-//  3. Uses atomic write (temp + rename) for safety
+//  3. Uses atomic write (temp + rename) for safety; the file is 0600
 func writeJSONLFile(path string, data []interface{}) error {
-	tempFileManager.mu.Lock()
-	defer tempFileManager.mu.Unlock()
-
-	// Ensure parent directory exists
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dir, mcerrors.ErrFileIO)
+	content, err := encodeJSONL(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), mcerrors.ErrFileIO)
 	}
+	return writeFileAtomic(path, content)
+}
 
-	// Create temp file for atomic write
-	tmpPath := path + ".tmp"
-	file, err := os.Create(tmpPath)
+// writeFileAtomic writes content to a randomly named 0600 file next to path
+// and renames it into place, so readers never see partial files
+func writeFileAtomic(path string, content []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
 	if err != nil {
-		return fmt.Errorf("failed to create temp file %s: %w", tmpPath, mcerrors.ErrFileIO)
+		return fmt.Errorf("failed to create temp file for %s: %w", path, mcerrors.ErrFileIO)
 	}
+	tmpPath := file.Name()
 
-	// Write JSONL data
-	encoder := json.NewEncoder(file)
-	for _, record := range data {
-		if err := encoder.Encode(record); err != nil {
-			file.Close()
-			os.Remove(tmpPath)
-			return fmt.Errorf("failed to encode record to %s: %w", tmpPath, mcerrors.ErrParseError)
-		}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write file %s: %w", tmpPath, mcerrors.ErrFileIO)
 	}
 
 	// Sync to disk
@@ -100,26 +89,16 @@ func writeJSONLFile(path string, data []interface{}) error {
 	return nil
 }
 
-// cleanupOldFiles removes temporary files older than maxAgeDays
-//
-// Parameters:
-//   - maxAgeDays: Maximum age in days (files older than this are removed)
-//
-// Returns:
-//   - []string: List of removed file paths
-//   - int64: Total bytes freed
-//   - error: If scan fails
-//
-// The function scans /tmp for meta-cc-mcp-*.jsonl files and removes
-// files with modification time older than the threshold.
-func cleanupOldFiles(maxAgeDays int) ([]string, int64, error) {
-	pattern := filepath.Join(os.TempDir(), "meta-cc-mcp-*.jsonl")
+// cleanupLegacyTempFiles removes file_ref outputs older than maxAge that
+// earlier versions left in the shared temp directory
+func cleanupLegacyTempFiles(maxAge time.Duration) ([]string, int64, error) {
+	pattern := filepath.Join(os.TempDir(), legacyTempFilePrefix+"*.jsonl")
 	files, err := filepath.Glob(pattern)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to glob files with pattern %s: %w", pattern, mcerrors.ErrFileIO)
 	}
 
-	threshold := time.Now().Add(-time.Duration(maxAgeDays) * 24 * time.Hour)
+	threshold := time.Now().Add(-maxAge)
 	removed := []string{}
 	var freedBytes int64
 
@@ -142,11 +121,43 @@ func cleanupOldFiles(maxAgeDays int) ([]string, int64, error) {
 	return removed, freedBytes, nil
 }
 
-// executeCleanupTool handles the cleanup_temp_files MCP tool
-func executeCleanupTool(args map[string]interface{}) (string, error) {
-	maxAgeDays := getIntParam(args, "max_age_days", 7)
+// cleanupOldFiles removes file_ref outputs older than maxAgeDays
+//
+// Parameters:
+//   - store: Output store to clean up
+//   - maxAgeDays: Maximum age in days (files older than this are removed)
+//
+// Returns:
+//   - []string: List of removed file paths
+//   - int64: Total bytes freed
+//   - error: If scan fails
+//
+// The function removes outputs not used within the threshold from the
+// store, evicts outputs over the quota, and removes old outputs of earlier
+// versions from the shared temp directory.
+func cleanupOldFiles(store *outputStore, maxAgeDays int) ([]string, int64, error) {
+	maxAge := time.Duration(maxAgeDays) * 24 * time.Hour
+	removed, freedBytes, err := store.Cleanup(maxAge)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	legacy, legacyBytes, err := cleanupLegacyTempFiles(maxAge)
+	if err != nil {
+		return nil, 0, err
+	}
+	return append(removed, legacy...), freedBytes + legacyBytes, nil
+}
 
-	removed, freedBytes, err := cleanupOldFiles(maxAgeDays)
+// cleanupArgs are the arguments of cleanup_temp_files
+type cleanupArgs struct {
+	MaxAgeDays int `json:"max_age_days" default:"7" desc:"Max file age in days (default: 7)"`
+}
+
+// executeCleanupTool handles the cleanup_temp_files MCP tool
+func executeCleanupTool(cfg *config.Config, args cleanupArgs) (string, error) {
+	store := outputStoreFor(cfg)
+	removed, freedBytes, err := cleanupOldFiles(store, args.MaxAgeDays)
 	if err != nil {
 		return "", err
 	}
@@ -155,6 +166,7 @@ func executeCleanupTool(args map[string]interface{}) (string, error) {
 		"removed_count": len(removed),
 		"freed_bytes":   freedBytes,
 		"files":         removed,
+		"output_dir":    store.dir,
 	}
 
 	jsonBytes, err := json.MarshalIndent(result, "", "  ")
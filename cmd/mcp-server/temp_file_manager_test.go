@@ -4,44 +4,11 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"testing"
 	"time"
 )
 
-func TestCreateTempFilePath(t *testing.T) {
-	sessionHash := "abc12345"
-	queryType := "query_tools"
-
-	path := createTempFilePath(sessionHash, queryType)
-
-	// Check pattern: {TempDir}/meta-cc-mcp-{session_hash}-{timestamp}-{query_type}.jsonl
-	expectedPrefix := filepath.Join(os.TempDir(), "meta-cc-mcp-")
-	if !strings.HasPrefix(path, expectedPrefix) {
-		t.Errorf("expected path to start with %s, got %s", expectedPrefix, path)
-	}
-	if !strings.HasSuffix(path, ".jsonl") {
-		t.Errorf("expected path to end with .jsonl, got %s", path)
-	}
-	if !strings.Contains(path, sessionHash) {
-		t.Errorf("expected path to contain session hash %s, got %s", sessionHash, path)
-	}
-	if !strings.Contains(path, queryType) {
-		t.Errorf("expected path to contain query type %s, got %s", queryType, path)
-	}
-
-	// Check uniqueness - two calls should produce different paths
-	// Add small delay on Windows where nanosecond precision may be lower
-	if runtime.GOOS == "windows" {
-		time.Sleep(time.Millisecond)
-	}
-	path2 := createTempFilePath(sessionHash, queryType)
-	if path == path2 {
-		t.Errorf("expected unique paths, got same: %s", path)
-	}
-}
-
 func TestWriteJSONLFile(t *testing.T) {
 	data := []interface{}{
 		map[string]interface{}{"id": 1, "name": "test1"},
@@ -80,40 +47,52 @@ func TestWriteJSONLFile(t *testing.T) {
 }
 
 func TestCleanupOldFiles(t *testing.T) {
-	// Create temp files with different ages
+	store := &outputStore{dir: t.TempDir(), quota: 1 << 20, maxAgeDays: 7}
 	now := time.Now()
 
-	// Old file (8 days ago)
-	oldPath := filepath.Join(os.TempDir(), "meta-cc-mcp-old-123-test.jsonl")
-	if err := os.WriteFile(oldPath, []byte("test"), 0644); err != nil {
+	// Old output (8 days ago)
+	oldPath := filepath.Join(store.dir, "query_tools-old.jsonl")
+	if err := os.WriteFile(oldPath, []byte("test"), 0600); err != nil {
 		t.Fatalf("Failed to create old test file: %v", err)
 	}
 	oldTime := now.Add(-8 * 24 * time.Hour)
 	if err := os.Chtimes(oldPath, oldTime, oldTime); err != nil {
 		t.Fatalf("Failed to set old file times: %v", err)
 	}
-	defer os.Remove(oldPath)
 
-	// Recent file (5 days ago)
-	recentPath := filepath.Join(os.TempDir(), "meta-cc-mcp-recent-456-test.jsonl")
-	if err := os.WriteFile(recentPath, []byte("test"), 0644); err != nil {
+	// Recent output (5 days ago)
+	recentPath := filepath.Join(store.dir, "query_tools-recent.jsonl")
+	if err := os.WriteFile(recentPath, []byte("test"), 0600); err != nil {
 		t.Fatalf("Failed to create recent test file: %v", err)
 	}
 	recentTime := now.Add(-5 * 24 * time.Hour)
 	if err := os.Chtimes(recentPath, recentTime, recentTime); err != nil {
 		t.Fatalf("Failed to set recent file times: %v", err)
 	}
-	defer os.Remove(recentPath)
+
+	// Old output of an earlier version in the shared temp dir
+	legacy, err := os.CreateTemp("", legacyTempFilePrefix+"*-test.jsonl")
+	if err != nil {
+		t.Fatalf("Failed to create legacy test file: %v", err)
+	}
+	legacy.Close()
+	legacyPath := legacy.Name()
+	defer os.Remove(legacyPath)
+	if err := os.Chtimes(legacyPath, oldTime, oldTime); err != nil {
+		t.Fatalf("Failed to set legacy file times: %v", err)
+	}
 
 	// Cleanup files older than 7 days
-	removed, freedBytes, err := cleanupOldFiles(7)
+	removed, freedBytes, err := cleanupOldFiles(store, 7)
 	if err != nil {
 		t.Fatalf("cleanupOldFiles failed: %v", err)
 	}
 
-	// Verify old file was removed
-	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
-		t.Errorf("expected old file to be removed, but it still exists")
+	// Verify old files were removed
+	for _, path := range []string{oldPath, legacyPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, but it still exists", path)
+		}
 	}
 
 	// Verify recent file still exists
@@ -122,8 +101,8 @@ func TestCleanupOldFiles(t *testing.T) {
 	}
 
 	// Verify removed count
-	if len(removed) < 1 {
-		t.Errorf("expected at least 1 removed file, got %d", len(removed))
+	if len(removed) < 2 {
+		t.Errorf("expected at least 2 removed files, got %d", len(removed))
 	}
 
 	// Verify freed bytes is positive
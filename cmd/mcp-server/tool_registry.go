@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/redact"
+)
+
+// tool_registry.go implements the declarative tool registry
+//
+// Each tool is declared once with defineTool: a typed argument struct, a
+// description, its scope behavior and a handler. The input schema is generated
+// from the struct and arguments are decoded and validated before the handler
+// runs, so the advertised schema and the handler cannot disagree.
+//
+// Argument struct fields use these tags:
+//   - json:"name"        parameter name (required; "-" skips the field)
+//   - desc:"..."         parameter description
+//   - enum:"a,b"         allowed values for string parameters
+//   - default:"v"        value applied when the parameter is omitted
+//   - mcp:"required"     parameter must be present (and non-empty for strings)
+//   - mcp:"hidden"       accepted but not advertised (test overrides)
+//
+// Embedded structs are flattened, as with encoding/json.
+
+// scopeNone is the scope of tools that do not read session history
+const scopeNone = "none"
+
+// toolOutput selects how a handler result becomes the tool response text
+type toolOutput int
+
+const (
+	// outputText handlers return the response text as a string
+	outputText toolOutput = iota
+	// outputJSON handler results are marshaled to a JSON object
+	outputJSON
+	// outputRecords handlers return []interface{} for the hybrid output pipeline
+	outputRecords
+)
+
+// toolSpec declares everything about a tool except its argument type and handler
+type toolSpec struct {
+	Name        string
+	Description string
+	// Scope is the default value of the scope parameter, or scopeNone
+	Scope string
+	// Output selects how handler results are rendered
+	Output toolOutput
+	// StandardParams merges StandardToolParameters into the schema
+	StandardParams bool
+	// Params overrides generated properties (e.g. jq_filter with output schema docs)
+	Params map[string]Property
+}
+
+// toolCall carries request-scoped inputs shared by every handler
+type toolCall struct {
+	executor *ToolExecutor
+	cfg      *config.Config
+	scope    string
+	// args are the raw arguments, used by the output pipeline (inline threshold, format)
+	args map[string]interface{}
+}
+
+// registeredTool is a tool declaration bound to its typed handler
+type registeredTool struct {
+	spec   toolSpec
+	schema ToolSchema
+	fields []argField
+	run    func(ctx context.Context, call toolCall) (interface{}, error)
+}
+
+// toolRegistry holds tool declarations in advertisement order
+type toolRegistry struct {
+	tools []registeredTool
+	index map[string]int
+}
+
+// newToolRegistry builds a registry, panicking on duplicate names
+func newToolRegistry(tools ...registeredTool) *toolRegistry {
+	r := &toolRegistry{index: make(map[string]int, len(tools))}
+	for _, tool := range tools {
+		if _, exists := r.index[tool.spec.Name]; exists {
+			panic(fmt.Sprintf("tool %s registered twice", tool.spec.Name))
+		}
+		r.index[tool.spec.Name] = len(r.tools)
+		r.tools = append(r.tools, tool)
+	}
+	return r
+}
+
+// lookup returns the tool registered under name
+func (r *toolRegistry) lookup(name string) (registeredTool, bool) {
+	i, ok := r.index[name]
+	if !ok {
+		return registeredTool{}, false
+	}
+	return r.tools[i], true
+}
+
+// definitions returns the tools/list entries in registration order
+func (r *toolRegistry) definitions() []Tool {
+	tools := make([]Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		tools = append(tools, Tool{
+			Name:        tool.spec.Name,
+			Description: tool.spec.Description,
+			InputSchema: tool.schema,
+		})
+	}
+	return tools
+}
+
+// defineTool binds a spec to a handler taking typed arguments A
+func defineTool[A any](spec toolSpec, handler func(ctx context.Context, call toolCall, args A) (interface{}, error)) registeredTool {
+	fields := argFields(reflect.TypeOf((*A)(nil)).Elem())
+
+	properties, required := schemaProperties(fields)
+	if spec.StandardParams {
+		properties = MergeParameters(properties)
+	}
+	for name, prop := range spec.Params {
+		properties[name] = prop
+	}
+
+	schema := ToolSchema{Type: "object", Properties: properties}
+	if len(required) > 0 {
+		schema.Required = required
+	}
+
+	return registeredTool{
+		spec:   spec,
+		schema: schema,
+		fields: fields,
+		run: func(ctx context.Context, call toolCall) (interface{}, error) {
+			if spec.StandardParams {
+				var standard standardArgs
+				if err := decodeArgs(spec.Name, standardArgFields, call.args, &standard); err != nil {
+					return nil, err
+				}
+			}
+
+			var args A
+			if err := decodeArgs(spec.Name, fields, call.args, &args); err != nil {
+				return nil, err
+			}
+			if err := resolvePathArgs(ctx, spec.Name, fields, reflect.ValueOf(&args).Elem()); err != nil {
+				return nil, err
+			}
+			return handler(ctx, call, args)
+		},
+	}
+}
+
+// toolScope resolves the scope a call runs in (used for metrics and logging)
+func toolScope(spec toolSpec, args map[string]interface{}) string {
+	if spec.Scope == scopeNone {
+		return scopeNone
+	}
+	return getStringParam(args, "scope", spec.Scope)
+}
+
+// ExecuteTool validates arguments, runs the registered handler and renders its result
+// The context carries request-scoped state such as the progress reporter
+func (e *ToolExecutor) ExecuteTool(ctx context.Context, cfg *config.Config, toolName string, args map[string]interface{}) (string, error) {
+	start := time.Now()
+
+	tool, ok := builtinTools.lookup(toolName)
+	if !ok {
+		recordToolFailure(toolName, getStringParam(args, "scope", "project"), start, "validation_error")
+		return "", fmt.Errorf("unknown tool %s in executor: %w", toolName, mcerrors.ErrUnknownTool)
+	}
+
+	scope := toolScope(tool.spec, args)
+	call := toolCall{executor: e, cfg: cfg, scope: scope, args: args}
+
+	output, err := e.runTool(ctx, tool, call)
+	if err != nil {
+		errorType := classifyError(err)
+		slog.Error("tool execution failed",
+			"tool_name", toolName,
+			"error", err.Error(),
+			"error_type", errorType,
+		)
+		recordToolFailure(toolName, scope, start, errorType)
+		return "", err
+	}
+
+	slog.Debug("tool execution pipeline completed successfully",
+		"tool_name", toolName,
+		"output_length", len(output),
+	)
+
+	recordToolSuccess(toolName, scope, start)
+	return output, nil
+}
+
+// runTool invokes the handler and converts its result according to the tool's output kind
+func (e *ToolExecutor) runTool(ctx context.Context, tool registeredTool, call toolCall) (string, error) {
+	ctx, sampling := withSamplingRecorder(ctx)
+	result, err := tool.run(ctx, call)
+	if err != nil {
+		return "", err
+	}
+
+	// Redact session data before it leaves the server, inline or as a file_ref
+	redactor := responseRedactor(call.cfg)
+	counts := redact.Counts{}
+	defer recordRedactions(tool.spec.Name, counts)
+
+	switch tool.spec.Output {
+	case outputText:
+		// Text tools serve capability documents, which may quote session data
+		text, ok := result.(string)
+		if !ok {
+			return "", fmt.Errorf("tool %s returned %T, expected text", tool.spec.Name, result)
+		}
+		if redactor.Enabled() {
+			text = redactor.String(text, counts)
+		}
+		return text, nil
+
+	case outputJSON:
+		if redactor.Enabled() {
+			result = redactor.Value(result, counts)
+			if response, ok := result.(map[string]interface{}); ok && counts.Total() > 0 {
+				response["redactions"] = redactionMetadata(counts)
+			}
+		}
+		jsonData, err := json.Marshal(result)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal result: %w", err)
+		}
+		return string(jsonData), nil
+
+	default:
+		records, ok := result.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("tool %s returned %T, expected records", tool.spec.Name, result)
+		}
+		if redactor.Enabled() {
+			records = redactor.Records(records, counts)
+		}
+		pipeline := newToolPipelineConfig(call.args)
+		pipeline.redactions = counts
+		pipeline.sampling = sampling.report
+		return e.buildResponse(call.cfg, records, call.args, tool.spec.Name, pipeline)
+	}
+}
+
+// argField describes one parameter of an argument struct
+type argField struct {
+	name     string
+	index    []int
+	typ      reflect.Type
+	desc     string
+	enum     []string
+	def      string
+	required bool
+	hidden   bool
+	// path is "read" or "write" for file paths checked by the path policy
+	// before the handler runs (see path_policy.go)
+	path string
+}
+
+// argFields lists the parameters declared by an argument struct
+func argFields(t reflect.Type) []argField {
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("tool arguments must be a struct, got %s", t))
+	}
+
+	var fields []argField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for _, embedded := range argFields(f.Type) {
+				embedded.index = append([]int{i}, embedded.index...)
+				fields = append(fields, embedded)
+			}
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+
+		field := argField{
+			name:  name,
+			index: []int{i},
+			typ:   f.Type,
+			desc:  f.Tag.Get("desc"),
+			def:   f.Tag.Get("default"),
+		}
+		if enum := f.Tag.Get("enum"); enum != "" {
+			field.enum = strings.Split(enum, ",")
+		}
+		for _, opt := range strings.Split(f.Tag.Get("mcp"), ",") {
+			switch opt {
+			case "required":
+				field.required = true
+			case "hidden":
+				field.hidden = true
+			}
+		}
+
+		if field.path = f.Tag.Get("path"); field.path != "" {
+			if field.path != pathRead && field.path != pathWrite {
+				panic(fmt.Sprintf("invalid path tag for %s: %q", name, field.path))
+			}
+			if field.typ.Kind() != reflect.String && (field.typ.Kind() != reflect.Slice || field.typ.Elem().Kind() != reflect.String) {
+				panic(fmt.Sprintf("path argument %s must be a string or an array of strings", name))
+			}
+		}
+
+		// Fail at startup rather than on the first call
+		schemaType(field.typ)
+		if field.def != "" {
+			if err := setDefault(reflect.New(field.typ).Elem(), field.def); err != nil {
+				panic(fmt.Sprintf("invalid default for %s: %v", name, err))
+			}
+		}
+
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// schemaProperties converts argument fields to JSON Schema properties
+func schemaProperties(fields []argField) (map[string]Property, []string) {
+	properties := make(map[string]Property, len(fields))
+	var required []string
+	for _, f := range fields {
+		if f.hidden {
+			continue
+		}
+		prop := Property{
+			Type:        schemaType(f.typ),
+			Description: f.desc,
+			Enum:        f.enum,
+		}
+		if f.typ.Kind() == reflect.Slice {
+			prop.Items = &Property{Type: schemaType(f.typ.Elem())}
+		}
+		properties[f.name] = prop
+		if f.required {
+			required = append(required, f.name)
+		}
+	}
+	return properties, required
+}
+
+// schemaType maps a Go field type to a JSON Schema type
+func schemaType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		schemaType(t.Elem())
+		return "array"
+	default:
+		panic(fmt.Sprintf("unsupported tool argument type %s", t))
+	}
+}
+
+// setDefault parses a default tag value into v
+func setDefault(v reflect.Value, def string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(def)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(def, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(def, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("defaults are not supported for %s", v.Type())
+	}
+	return nil
+}
+
+// decodeArgs applies defaults, decodes raw arguments into out and validates
+// required and enum constraints
+func decodeArgs(toolName string, fields []argField, raw map[string]interface{}, out interface{}) error {
+	v := reflect.ValueOf(out).Elem()
+
+	for _, f := range fields {
+		value, present := raw[f.name]
+		if f.required && (!present || value == nil || value == "") {
+			return fmt.Errorf("missing required parameter '%s' for %s tool: %w", f.name, toolName, mcerrors.ErrMissingParameter)
+		}
+		if f.def != "" && (!present || value == nil) {
+			if err := setDefault(v.FieldByIndex(f.index), f.def); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("invalid arguments for %s tool: %v: %w", toolName, err, mcerrors.ErrInvalidInput)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("invalid %s: expected %s, got %s: %w", typeErr.Field, typeName(typeErr.Type), typeErr.Value, mcerrors.ErrInvalidInput)
+		}
+		return fmt.Errorf("invalid arguments for %s tool: %v: %w", toolName, err, mcerrors.ErrInvalidInput)
+	}
+
+	for _, f := range fields {
+		if len(f.enum) == 0 {
+			continue
+		}
+		value := v.FieldByIndex(f.index).String()
+		if value == "" || slices.Contains(f.enum, value) {
+			continue
+		}
+		return fmt.Errorf("invalid %s: %s (must be one of: %s): %w", f.name, value, strings.Join(f.enum, ", "), mcerrors.ErrInvalidInput)
+	}
+
+	return nil
+}
+
+// typeName describes a Go type in JSON terms for error messages
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Slice:
+		return "array of " + schemaType(t.Elem())
+	default:
+		return schemaType(t)
+	}
+}
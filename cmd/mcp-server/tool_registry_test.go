@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+)
+
+type registryTestEmbedded struct {
+	Limit int `json:"limit" desc:"Max results"`
+}
+
+type registryTestArgs struct {
+	Name    string   `json:"name" mcp:"required" desc:"Name"`
+	Mode    string   `json:"mode" enum:"fast,slow" default:"fast" desc:"Mode"`
+	Files   []string `json:"files" desc:"Files"`
+	Verbose bool     `json:"verbose" desc:"Verbose output"`
+	Secret  string   `json:"_secret" mcp:"hidden"`
+	Ignored string   `json:"-"`
+	registryTestEmbedded
+}
+
+func TestDefineToolGeneratesSchema(t *testing.T) {
+	tool := defineTool(toolSpec{Name: "test_tool", Scope: scopeNone, Output: outputJSON},
+		func(ctx context.Context, call toolCall, args registryTestArgs) (interface{}, error) {
+			return args, nil
+		})
+
+	props := tool.schema.Properties
+	expected := map[string]string{
+		"name":    "string",
+		"mode":    "string",
+		"files":   "array",
+		"verbose": "boolean",
+		"limit":   "number",
+	}
+	require.Len(t, props, len(expected))
+	for name, typ := range expected {
+		require.Equal(t, typ, props[name].Type, "type of %s", name)
+	}
+
+	require.Equal(t, []string{"fast", "slow"}, props["mode"].Enum)
+	require.Equal(t, "string", props["files"].Items.Type)
+	require.Equal(t, []string{"name"}, tool.schema.Required)
+	require.NotContains(t, props, "_secret", "hidden parameters must not be advertised")
+}
+
+func TestDefineToolMergesStandardParameters(t *testing.T) {
+	override := Property{Type: "string", Description: "custom"}
+	tool := defineTool(toolSpec{
+		Name:           "test_tool",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+		Params:         map[string]Property{"jq_filter": override},
+	}, func(ctx context.Context, call toolCall, args registryTestEmbedded) (interface{}, error) {
+		return []interface{}{}, nil
+	})
+
+	for name := range StandardToolParameters() {
+		require.Contains(t, tool.schema.Properties, name)
+	}
+	require.Equal(t, override, tool.schema.Properties["jq_filter"])
+	require.Equal(t, []string{"project", "session", "all"}, tool.schema.Properties["scope"].Enum)
+}
+
+func TestDecodeArgs(t *testing.T) {
+	fields := argFields(reflect.TypeOf(registryTestArgs{}))
+
+	t.Run("defaults and values", func(t *testing.T) {
+		var args registryTestArgs
+		err := decodeArgs("test_tool", fields, map[string]interface{}{
+			"name":    "x",
+			"files":   []interface{}{"a.jsonl", "b.jsonl"},
+			"limit":   float64(5),
+			"_secret": "s",
+			"extra":   "ignored",
+		}, &args)
+		require.NoError(t, err)
+		require.Equal(t, "x", args.Name)
+		require.Equal(t, "fast", args.Mode)
+		require.Equal(t, []string{"a.jsonl", "b.jsonl"}, args.Files)
+		require.Equal(t, 5, args.Limit)
+		require.Equal(t, "s", args.Secret)
+	})
+
+	t.Run("explicit value overrides default", func(t *testing.T) {
+		var args registryTestArgs
+		require.NoError(t, decodeArgs("test_tool", fields, map[string]interface{}{"name": "x", "mode": "slow"}, &args))
+		require.Equal(t, "slow", args.Mode)
+	})
+
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		wantErr error
+		message string
+	}{
+		{"missing required", map[string]interface{}{}, mcerrors.ErrMissingParameter, "missing required parameter 'name' for test_tool tool"},
+		{"empty required string", map[string]interface{}{"name": ""}, mcerrors.ErrMissingParameter, "'name'"},
+		{"wrong type", map[string]interface{}{"name": "x", "limit": "ten"}, mcerrors.ErrInvalidInput, "invalid limit: expected integer, got string"},
+		{"fractional integer", map[string]interface{}{"name": "x", "limit": 1.5}, mcerrors.ErrInvalidInput, "invalid limit"},
+		{"wrong element type", map[string]interface{}{"name": "x", "files": []interface{}{1}}, mcerrors.ErrInvalidInput, "invalid files"},
+		{"enum violation", map[string]interface{}{"name": "x", "mode": "medium"}, mcerrors.ErrInvalidInput, "invalid mode: medium (must be one of: fast, slow)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args registryTestArgs
+			err := decodeArgs("test_tool", fields, tt.args, &args)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, tt.wantErr), "unexpected error class: %v", err)
+			require.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
+
+func TestRegisteredToolValidatesBeforeHandler(t *testing.T) {
+	called := false
+	tool := defineTool(toolSpec{Name: "test_tool", Scope: "project", Output: outputRecords, StandardParams: true},
+		func(ctx context.Context, call toolCall, args registryTestArgs) (interface{}, error) {
+			called = true
+			return []interface{}{}, nil
+		})
+
+	invalid := []map[string]interface{}{
+		{},
+		{"name": "x", "scope": "everywhere"},
+		{"name": "x", "stats_only": "yes"},
+	}
+	for _, args := range invalid {
+		_, err := tool.run(context.Background(), toolCall{args: args})
+		require.Error(t, err, "args %v", args)
+	}
+	require.False(t, called, "handler must not run with invalid arguments")
+
+	_, err := tool.run(context.Background(), toolCall{args: map[string]interface{}{"name": "x"}})
+	require.NoError(t, err)
+	require.True(t, called)
+}
+
+func TestBuiltinToolsDeclareScopeConsistently(t *testing.T) {
+	for _, tool := range builtinTools.tools {
+		spec := tool.spec
+		if i := strings.Index(spec.Description, "Default scope: "); i >= 0 {
+			declared := strings.TrimSuffix(spec.Description[i+len("Default scope: "):], ".")
+			require.Equal(t, declared, spec.Scope, "tool %s description and scope disagree", spec.Name)
+		}
+		if spec.Scope != scopeNone {
+			require.Contains(t, tool.schema.Properties, "scope", "scoped tool %s must accept scope", spec.Name)
+		}
+	}
+}
+
+func TestQueryToolsFiltersByToolAndStatus(t *testing.T) {
+	testData := `{"type":"assistant","timestamp":"2025-01-01T10:00:00Z","message":{"content":[{"type":"tool_use","name":"Bash","id":"t1","input":{}}]}}
+{"type":"user","timestamp":"2025-01-01T10:00:01Z","message":{"content":[{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":"exit 1"}]}}
+{"type":"assistant","timestamp":"2025-01-01T10:00:02Z","message":{"content":[{"type":"tool_use","name":"Read","id":"t2","input":{}}]}}
+{"type":"user","timestamp":"2025-01-01T10:00:03Z","message":{"content":[{"type":"tool_result","tool_use_id":"t2","content":"ok"}]}}
+{"type":"assistant","timestamp":"2025-01-01T10:00:04Z","message":{"content":[{"type":"tool_use","name":"Bash","id":"t3","input":{}}]}}
+{"type":"user","timestamp":"2025-01-01T10:00:05Z","message":{"content":[{"type":"tool_result","tool_use_id":"t3","content":"ok"}]}}
+`
+	projectPath := setupTestSessionDir(t, testData)
+	originalWd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(projectPath))
+	defer func() { require.NoError(t, os.Chdir(originalWd)) }()
+
+	executor := NewToolExecutor()
+	ids := func(results []interface{}) []string {
+		var out []string
+		for _, r := range results {
+			block := r.(map[string]interface{})["message"].(map[string]interface{})["content"].([]interface{})[0]
+			out = append(out, block.(map[string]interface{})["id"].(string))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name string
+		args queryToolsArgs
+		want []string
+	}{
+		{"all", queryToolsArgs{}, []string{"t1", "t2", "t3"}},
+		{"by tool", queryToolsArgs{Tool: "Bash"}, []string{"t1", "t3"}},
+		{"errors", queryToolsArgs{Status: "error"}, []string{"t1"}},
+		{"successful Bash", queryToolsArgs{Tool: "Bash", Status: "success"}, []string{"t3"}},
+		{"limit after status", queryToolsArgs{Status: "success", queryLimitArgs: queryLimitArgs{Limit: 1}}, []string{"t2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := executor.handleQueryTools(context.Background(), nil, querySource{scope: "project"}, tt.args)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, ids(results))
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 )
@@ -18,34 +20,29 @@ import (
 //   - Good: "Query tool calls with filters. Default scope: project."
 //   - Bad:  "Query tool call history across project with filters (tool name, status). Default project-level scope reveals cross-session usage patterns and trends."
 
+// standardArgs are the output pipeline parameters shared by every query tool.
+// Tools receive them through toolCall.args; they are decoded here only to validate them.
+type standardArgs struct {
+	Scope                string   `json:"scope" enum:"project,session,all" desc:"Query scope: 'project' (default), 'session' or 'all' (every project; records gain project_path and session_id)"`
+	Projects             []string `json:"projects" desc:"With scope 'all': only these projects (paths, names or globs, e.g. '/home/me/work/*' or 'meta-cc')"`
+	JQFilter             string   `json:"jq_filter" desc:"jq expression for filtering. Defaults to '.[]' when omitted. IMPORTANT: Do NOT wrap in quotes - use raw jq expression like: .[] | {field: .field}"`
+	StatsOnly            bool     `json:"stats_only" desc:"Return only statistics (default: false)"`
+	StatsFirst           bool     `json:"stats_first" desc:"Return stats first, then details (default: false)"`
+	InlineThresholdBytes int      `json:"inline_threshold_bytes" desc:"Threshold for inline vs file_ref mode in bytes (default: 8192). Can also set META_CC_INLINE_THRESHOLD env var"`
+	MaxTokens            int      `json:"max_tokens" desc:"Token budget of the response. Larger results are summarized: small fields of the most relevant records, value distributions, and what was omitted. Can also set META_CC_MAX_TOKENS env var"`
+	Fields               []string `json:"fields" desc:"Keep only these fields of each record; dotted paths reach nested fields (e.g. 'input.command')"`
+	IfErrorInclude       []string `json:"if_error_include" desc:"With fields: also keep these fields for error records (e.g. 'error', 'output')"`
+	OutputFormat         string   `json:"output_format" enum:"jsonl,tsv,csv,markdown,columnar" desc:"Format of inline data: jsonl (records), tsv, csv, markdown (table) or columnar ({columns, rows}) (default: jsonl)"`
+	SaveAs               string   `json:"save_as" desc:"Name the result set for query_result (diff, intersect); responses always carry a result_id"`
+}
+
+// standardArgFields caches the parsed standardArgs declaration
+var standardArgFields = argFields(reflect.TypeOf(standardArgs{}))
+
 // StandardToolParameters returns the standard set of parameters for all MCP tools
 func StandardToolParameters() map[string]Property {
-	return map[string]Property{
-		"scope": {
-			Type:        "string",
-			Description: "Query scope: 'project' (default) or 'session'",
-		},
-		"jq_filter": {
-			Type:        "string",
-			Description: "jq expression for filtering. Defaults to '.[]' when omitted. IMPORTANT: Do NOT wrap in quotes - use raw jq expression like: .[] | {field: .field}",
-		},
-		"stats_only": {
-			Type:        "boolean",
-			Description: "Return only statistics (default: false)",
-		},
-		"stats_first": {
-			Type:        "boolean",
-			Description: "Return stats first, then details (default: false)",
-		},
-		"inline_threshold_bytes": {
-			Type:        "number",
-			Description: "Threshold for inline vs file_ref mode in bytes (default: 8192). Can also set META_CC_INLINE_THRESHOLD env var",
-		},
-		"output_format": {
-			Type:        "string",
-			Description: "Output format: jsonl or tsv (default: jsonl)",
-		},
-	}
+	properties, _ := schemaProperties(standardArgFields)
+	return properties
 }
 
 // jqFilterWithSchema creates a jq_filter property with output schema documentation
@@ -86,110 +83,89 @@ func MergeParameters(specific map[string]Property) map[string]Property {
 	return result
 }
 
-// buildToolSchema creates a ToolSchema with merged parameters
-func buildToolSchema(properties map[string]Property, required ...string) ToolSchema {
-	schema := ToolSchema{
-		Type:       "object",
-		Properties: MergeParameters(properties),
-	}
-	if len(required) > 0 {
-		schema.Required = required
-	}
-	return schema
-}
-
-// buildTool creates a Tool with the given name, description, and schema
-func buildTool(name, description string, properties map[string]Property, required ...string) Tool {
-	return Tool{
-		Name:        name,
-		Description: description,
-		InputSchema: buildToolSchema(properties, required...),
-	}
-}
-
-func getToolDefinitions() []Tool {
-	return []Tool{
-		// Phase 27 Stage 27.1: query and query_raw tools removed
-		// Use the 10 shortcut query tools instead
-
-		// Layer 1: Convenience Tools (10 high-frequency queries)
-		// Note: query_user_messages and query_tools already exist above
-		buildTool("query_tool_errors", "Query tool execution errors. Default scope: project.", map[string]Property{
-			"limit": {
-				Type:        "number",
-				Description: "Max results (no limit by default, rely on hybrid output mode)",
-			},
-		}),
-		buildTool("query_token_usage", "Query assistant messages with token usage stats. Default scope: project.", map[string]Property{
-			"limit": {
-				Type:        "number",
-				Description: "Max results (no limit by default, rely on hybrid output mode)",
-			},
-		}),
-		buildTool("query_conversation_flow", "Query user and assistant conversation flow. Default scope: project.", map[string]Property{
-			"limit": {
-				Type:        "number",
-				Description: "Max results (no limit by default, rely on hybrid output mode)",
-			},
-			"transform": {
-				Type:        "string",
-				Description: "Optional jq transform for parent-child relationships",
-			},
-		}),
-		buildTool("query_system_errors", "Query system API errors. Default scope: project.", map[string]Property{
-			"limit": {
-				Type:        "number",
-				Description: "Max results (no limit by default, rely on hybrid output mode)",
-			},
-		}),
-		buildTool("query_file_snapshots", "Query file history snapshots. Default scope: project.", map[string]Property{
-			"limit": {
-				Type:        "number",
-				Description: "Max results (no limit by default, rely on hybrid output mode)",
-			},
-		}),
-		buildTool("query_timestamps", "Query all entries with timestamps. Default scope: project.", map[string]Property{
-			"limit": {
-				Type:        "number",
-				Description: "Max results (no limit by default, rely on hybrid output mode)",
-			},
-		}),
-		buildTool("query_summaries", "Query session summaries. Default scope: project.", map[string]Property{
-			"keyword": {
-				Type:        "string",
-				Description: "Keyword to search in summary (case-insensitive)",
-			},
-			"limit": {
-				Type:        "number",
-				Description: "Max results (no limit by default, rely on hybrid output mode)",
-			},
-		}),
-		buildTool("query_tool_blocks", "Query tool use or tool result blocks. Default scope: project.", map[string]Property{
-			"block_type": {
-				Type:        "string",
-				Description: "Block type: 'tool_use' or 'tool_result' (required)",
-			},
-			"limit": {
-				Type:        "number",
-				Description: "Max results (no limit by default, rely on hybrid output mode)",
-			},
-		}, "block_type"),
-
-		buildTool("query_tools", "Query assistant's internal tool calls. Large output, not for user analysis. Default scope: project.", map[string]Property{
-			// Tier 2: Filtering
-			"tool": {
-				Type:        "string",
-				Description: "Filter by tool name",
-			},
-			"status": {
-				Type:        "string",
-				Description: "Filter by status (error/success)",
-			},
-			// Tier 4: Output Control
-			"limit": {
-				Type:        "number",
-				Description: "Max results (no limit by default, rely on hybrid output mode)",
-			},
+// builtinTools declares every MCP tool in tools/list order
+// Phase 27 Stage 27.1: query and query_raw tools removed; use the shortcut query tools instead
+var builtinTools = newToolRegistry(
+	// Layer 1: Convenience Tools (10 high-frequency queries)
+	defineTool(toolSpec{
+		Name:           "query_tool_errors",
+		Description:    "Query tool execution errors. Default scope: project.",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args queryLimitArgs) (interface{}, error) {
+		return call.executor.handleQueryToolErrors(ctx, call.cfg, call.source(), args)
+	}),
+	defineTool(toolSpec{
+		Name:           "query_token_usage",
+		Description:    "Query assistant messages with token usage stats. Default scope: project.",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args queryLimitArgs) (interface{}, error) {
+		return call.executor.handleQueryTokenUsage(ctx, call.cfg, call.source(), args)
+	}),
+	defineTool(toolSpec{
+		Name:           "query_conversation_flow",
+		Description:    "Query user and assistant conversation flow. Default scope: project.",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args queryLimitArgs) (interface{}, error) {
+		return call.executor.handleQueryConversationFlow(ctx, call.cfg, call.source(), args)
+	}),
+	defineTool(toolSpec{
+		Name:           "query_system_errors",
+		Description:    "Query system API errors. Default scope: project.",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args queryLimitArgs) (interface{}, error) {
+		return call.executor.handleQuerySystemErrors(ctx, call.cfg, call.source(), args)
+	}),
+	defineTool(toolSpec{
+		Name:           "query_file_snapshots",
+		Description:    "Query file history snapshots. Default scope: project.",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args queryLimitArgs) (interface{}, error) {
+		return call.executor.handleQueryFileSnapshots(ctx, call.cfg, call.source(), args)
+	}),
+	defineTool(toolSpec{
+		Name:           "query_timestamps",
+		Description:    "Query all entries with timestamps. Default scope: project.",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args queryLimitArgs) (interface{}, error) {
+		return call.executor.handleQueryTimestamps(ctx, call.cfg, call.source(), args)
+	}),
+	defineTool(toolSpec{
+		Name:           "query_summaries",
+		Description:    "Query session summaries. Default scope: project.",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args querySummariesArgs) (interface{}, error) {
+		return call.executor.handleQuerySummaries(ctx, call.cfg, call.source(), args)
+	}),
+	defineTool(toolSpec{
+		Name:           "query_tool_blocks",
+		Description:    "Query tool use or tool result blocks. Default scope: project.",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args queryToolBlocksArgs) (interface{}, error) {
+		return call.executor.handleQueryToolBlocks(ctx, call.cfg, call.source(), args)
+	}),
+	defineTool(toolSpec{
+		Name:           "query_tools",
+		Description:    "Query assistant's internal tool calls. Large output, not for user analysis. Default scope: project.",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+		Params: map[string]Property{
 			// Override jq_filter with schema (snake_case fields)
 			"jq_filter": jqFilterWithSchema(map[string]string{
 				"tool_name": "string - Tool identifier (e.g., \"Bash\", \"Read\", \"mcp__meta-cc__query_tools\")",
@@ -200,132 +176,181 @@ func getToolDefinitions() []Tool {
 				"output":    "object - Tool output/result",
 				"uuid":      "string - Unique call identifier",
 			}, ".[] | select(.tool_name == \"Bash\" and .status == \"error\")"),
-		}),
-		buildTool("query_user_messages", "Search user messages with regex. May contain large outputs. Default scope: project.", map[string]Property{
-			// Tier 1: Required
-			"pattern": {
-				Type:        "string",
-				Description: "Regex pattern to match (required)",
-			},
-			// Tier 3: Range
-			"max_message_length": {
-				Type:        "number",
-				Description: "Max chars per message content (default: 0 = no truncation, rely on hybrid mode for large results)",
-			},
-			// Tier 4: Output Control
-			"limit": {
-				Type:        "number",
-				Description: "Max results (no limit by default, rely on hybrid output mode)",
-			},
-			"content_summary": {
-				Type:        "boolean",
-				Description: "Return only turn/timestamp/preview (100 chars), skip full content. Use hybrid mode instead for better information preservation.",
-			},
+		},
+	}, func(ctx context.Context, call toolCall, args queryToolsArgs) (interface{}, error) {
+		return call.executor.handleQueryTools(ctx, call.cfg, call.source(), args)
+	}),
+	defineTool(toolSpec{
+		Name:           "query_user_messages",
+		Description:    "Search user messages with regex. May contain large outputs. Default scope: project.",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+		Params: map[string]Property{
 			// Override jq_filter with schema
 			"jq_filter": jqFilterWithSchema(map[string]string{
 				"turn":      "number - Turn sequence number",
 				"timestamp": "string - ISO8601 timestamp",
 				"content":   "string - User message content",
 			}, ".[] | select(.content | test(\"error|bug\"; \"i\"))"),
-		}, "pattern"),
-		{
-			Name:        "cleanup_temp_files",
-			Description: "Remove old temporary MCP files. Default scope: none.",
-			InputSchema: ToolSchema{
-				Type: "object",
-				Properties: map[string]Property{
-					"max_age_days": {
-						Type:        "number",
-						Description: "Max file age in days (default: 7)",
-					},
-				},
-			},
-		},
-		{
-			Name:        "list_capabilities",
-			Description: "List all available capabilities from configured sources. Returns compact capability index.",
-			InputSchema: ToolSchema{
-				Type:       "object",
-				Properties: map[string]Property{
-					// No public parameters
-					// Hidden test parameters (_sources, _disable_cache) are not exposed in schema
-				},
-			},
 		},
-		{
-			Name:        "get_capability",
-			Description: "Retrieve complete capability content by name from configured sources.",
-			InputSchema: ToolSchema{
-				Type: "object",
-				Properties: map[string]Property{
-					"name": {
-						Type:        "string",
-						Description: "Name of the capability to retrieve (without .md extension)",
-					},
-					// Hidden test parameters (_sources) are not exposed in schema
-				},
-				Required: []string{"name"},
-			},
-		},
-		buildTool("get_session_directory", "Get session directory metadata. Default scope: project.", map[string]Property{
-			"scope": {
-				Type:        "string",
-				Description: "Query scope: 'session' (current session only) or 'project' (all sessions)",
-			},
-		}, "scope"),
-		buildTool("inspect_session_files", "Inspect session files for metadata (record types, time ranges, size).", map[string]Property{
-			"files": {
-				Type:        "array",
-				Description: "Array of absolute file paths to inspect",
-				Items: &Property{
-					Type: "string",
-				},
-			},
-			"include_samples": {
-				Type:        "boolean",
-				Description: "If true, include 1-2 sample records per type (default: false)",
-			},
-		}, "files"),
-		{
-			Name:        "execute_stage2_query",
-			Description: "Execute Stage 2 query on selected files with filtering, sorting, and limits.",
-			InputSchema: ToolSchema{
-				Type: "object",
-				Properties: map[string]Property{
-					"files": {
-						Type:        "array",
-						Description: "Array of absolute file paths to query (from Stage 1 inspection)",
-						Items: &Property{
-							Type: "string",
-						},
-					},
-					"filter": {
-						Type:        "string",
-						Description: "jq filter expression (e.g., 'select(.type == \"user\")'). Required.",
-					},
-					"sort": {
-						Type:        "string",
-						Description: "jq sort expression (e.g., 'sort_by(.timestamp)'). Optional.",
-					},
-					"transform": {
-						Type:        "string",
-						Description: "jq transform expression (e.g., '{type, timestamp}'). Optional.",
-					},
-					"limit": {
-						Type:        "number",
-						Description: "Maximum number of results to return. Optional (default: no limit).",
-					},
-				},
-				Required: []string{"files", "filter"},
-			},
+	}, func(ctx context.Context, call toolCall, args queryUserMessagesArgs) (interface{}, error) {
+		return call.executor.handleQueryUserMessages(ctx, call.cfg, call.source(), args)
+	}),
+	defineTool(toolSpec{
+		Name:           "query_security_audit",
+		Description:    "Audit risky commands and sensitive file writes by rule. Default scope: project.",
+		Scope:          "project",
+		Output:         outputRecords,
+		StandardParams: true,
+		Params: map[string]Property{
+			"jq_filter": jqFilterWithSchema(map[string]string{
+				"rule_id":     "string - Matched rule (e.g., \"git-force-push\")",
+				"category":    "string - Rule category (e.g., \"destructive\", \"credential_access\")",
+				"severity":    "string - low, medium, high or critical",
+				"description": "string - Rule description",
+				"tool_name":   "string - Tool that ran the command or wrote the file",
+				"command":     "string - Shell command (command rules)",
+				"file_path":   "string - Target file (path rules)",
+				"status":      "string - Execution status (\"success\" or \"error\")",
+				"session_id":  "string - Session identifier",
+				"timestamp":   "string - ISO8601 timestamp",
+				"uuid":        "string - Entry containing the tool call",
+				"user_prompt": "string - User prompt preceding the call",
+			}, ".[] | select(.severity == \"critical\")"),
 		},
-		buildTool("get_session_metadata", "Get session metadata including JSONL schema, file info, and query templates. Default scope: project.", map[string]Property{
-			"scope": {
-				Type:        "string",
-				Description: "Query scope: 'project' (default) or 'session'",
-			},
-		}),
-	}
+	}, func(ctx context.Context, call toolCall, args querySecurityAuditArgs) (interface{}, error) {
+		return call.executor.handleQuerySecurityAudit(ctx, call.cfg, call.source(), args)
+	}),
+
+	// Follow-up queries on stored results
+	defineTool(toolSpec{
+		Name:           "query_result",
+		Description:    "Refine a stored result by result_id: jq, sort, sample, page, diff. Default scope: none.",
+		Scope:          scopeNone,
+		Output:         outputRecords,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args queryResultArgs) (interface{}, error) {
+		return handleQueryResult(ctx, call.cfg, args)
+	}),
+
+	// Utility tools
+	defineTool(toolSpec{
+		Name:        "cleanup_temp_files",
+		Description: "Remove old temporary MCP files. Default scope: none.",
+		Scope:       scopeNone,
+		Output:      outputText,
+	}, func(ctx context.Context, call toolCall, args cleanupArgs) (interface{}, error) {
+		return executeCleanupTool(call.cfg, args)
+	}),
+	defineTool(toolSpec{
+		Name:        "list_capabilities",
+		Description: "List all available capabilities from configured sources. Returns compact capability index.",
+		Scope:       scopeNone,
+		Output:      outputText,
+	}, func(ctx context.Context, call toolCall, args listCapabilitiesArgs) (interface{}, error) {
+		return executeListCapabilitiesTool(call.cfg, args)
+	}),
+	defineTool(toolSpec{
+		Name:        "get_capability",
+		Description: "Retrieve complete capability content by name from configured sources.",
+		Scope:       scopeNone,
+		Output:      outputText,
+	}, func(ctx context.Context, call toolCall, args getCapabilityArgs) (interface{}, error) {
+		return executeGetCapabilityTool(call.cfg, args)
+	}),
+	defineTool(toolSpec{
+		Name:        "get_server_metrics",
+		Description: "Get a JSON snapshot of MCP server metrics (requests, durations, resources). Default scope: none.",
+		Scope:       scopeNone,
+		Output:      outputText,
+	}, func(ctx context.Context, call toolCall, args serverMetricsArgs) (interface{}, error) {
+		return executeGetServerMetricsTool(args)
+	}),
+	defineTool(toolSpec{
+		Name:        "archive_sessions",
+		Description: "Gzip sessions older than N days in place with a manifest. Default scope: none.",
+		Scope:       scopeNone,
+		Output:      outputJSON,
+	}, func(ctx context.Context, call toolCall, args archiveSessionsArgs) (interface{}, error) {
+		return handleArchiveSessions(ctx, args)
+	}),
+	defineTool(toolSpec{
+		Name:        "export_sessions",
+		Description: "Pack sessions into a tar.gz bundle with a manifest for another machine. Default scope: none.",
+		Scope:       scopeNone,
+		Output:      outputJSON,
+	}, func(ctx context.Context, call toolCall, args exportSessionsArgs) (interface{}, error) {
+		return handleExportSessions(ctx, args)
+	}),
+	defineTool(toolSpec{
+		Name:        "import_sessions",
+		Description: "Import a session bundle as a read-only root searched by every tool. Default scope: none.",
+		Scope:       scopeNone,
+		Output:      outputJSON,
+	}, func(ctx context.Context, call toolCall, args importSessionsArgs) (interface{}, error) {
+		return handleImportSessions(ctx, call.cfg, args)
+	}),
+
+	// Two-stage query tools
+	defineTool(toolSpec{
+		Name:           "get_session_directory",
+		Description:    "Get session directory metadata. Default scope: project.",
+		Scope:          "project",
+		Output:         outputJSON,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args sessionDirectoryArgs) (interface{}, error) {
+		return handleGetSessionDirectory(ctx, args)
+	}),
+	defineTool(toolSpec{
+		Name:           "inspect_session_files",
+		Description:    "Inspect session files for metadata (record types, time ranges, size).",
+		Scope:          scopeNone,
+		Output:         outputJSON,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args inspectSessionFilesArgs) (interface{}, error) {
+		return handleInspectSessionFiles(ctx, args)
+	}),
+	defineTool(toolSpec{
+		Name:        "execute_stage2_query",
+		Description: "Execute Stage 2 query on selected files with filtering, sorting, and limits.",
+		Scope:       scopeNone,
+		Output:      outputJSON,
+	}, func(ctx context.Context, call toolCall, args stage2QueryArgs) (interface{}, error) {
+		return handleExecuteStage2Query(ctx, args)
+	}),
+	defineTool(toolSpec{
+		Name:           "get_session_metadata",
+		Description:    "Get session metadata including JSONL schema, file info, and query templates. Default scope: project.",
+		Scope:          "project",
+		Output:         outputJSON,
+		StandardParams: true,
+	}, func(ctx context.Context, call toolCall, args sessionMetadataArgs) (interface{}, error) {
+		return handleGetSessionMetadata(ctx, args)
+	}),
+
+	// Catalog tools
+	defineTool(toolSpec{
+		Name:        "list_projects",
+		Description: "List projects with sessions: original path, session count, size and last activity.",
+		Scope:       scopeNone,
+		Output:      outputJSON,
+	}, func(ctx context.Context, call toolCall, args listProjectsArgs) (interface{}, error) {
+		return handleListProjects(ctx, args)
+	}),
+	defineTool(toolSpec{
+		Name:        "list_sessions",
+		Description: "List sessions with first prompt, title, time range, turns, tokens, branches and versions.",
+		Scope:       scopeNone,
+		Output:      outputJSON,
+	}, func(ctx context.Context, call toolCall, args listSessionsArgs) (interface{}, error) {
+		return handleListSessions(ctx, args)
+	}),
+)
+
+// getToolDefinitions returns the tools/list entries for all registered tools
+func getToolDefinitions() []Tool {
+	return builtinTools.definitions()
 }
 
 type Tool struct {
@@ -344,4 +369,5 @@ type Property struct {
 	Type        string    `json:"type"`
 	Description string    `json:"description"`
 	Items       *Property `json:"items,omitempty"` // For array types
+	Enum        []string  `json:"enum,omitempty"`
 }
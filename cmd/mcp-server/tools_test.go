@@ -106,8 +106,10 @@ func TestAllToolsHaveStandardParameters(t *testing.T) {
 			}
 
 			// Skip utility tools and Stage 1/2 tools that don't follow query tool patterns
-			if tool.Name == "cleanup_temp_files" || tool.Name == "list_capabilities" || tool.Name == "get_capability" ||
-				tool.Name == "get_session_directory" || tool.Name == "inspect_session_files" || tool.Name == "execute_stage2_query" {
+			if tool.Name == "cleanup_temp_files" || tool.Name == "list_capabilities" || tool.Name == "get_capability" || tool.Name == "get_server_metrics" ||
+				tool.Name == "get_session_directory" || tool.Name == "inspect_session_files" || tool.Name == "execute_stage2_query" ||
+				tool.Name == "list_projects" || tool.Name == "list_sessions" || tool.Name == "archive_sessions" ||
+				tool.Name == "export_sessions" || tool.Name == "import_sessions" {
 				t.Logf("Skipping utility/two-stage tool: %s", tool.Name)
 				return
 			}
@@ -164,7 +166,8 @@ func TestToolDescriptionConsistency(t *testing.T) {
 
 		// Skip utility tools and two-stage tools (no scope param) that don't follow "Default scope:" pattern
 		if tool.Name == "cleanup_temp_files" || tool.Name == "list_capabilities" || tool.Name == "get_capability" ||
-			tool.Name == "get_session_directory" || tool.Name == "inspect_session_files" || tool.Name == "execute_stage2_query" {
+			tool.Name == "get_session_directory" || tool.Name == "inspect_session_files" || tool.Name == "execute_stage2_query" ||
+			tool.Name == "list_projects" || tool.Name == "list_sessions" {
 			continue
 		}
 
@@ -353,12 +356,18 @@ func TestToolCountIncreasedTo14(t *testing.T) {
 	// Phase 27 Stage 27.3: Added inspect_session_files (14 -> 15)
 	// Phase 27 Stage 27.4: Added execute_stage2_query (15 -> 16)
 	// Phase 27 Stage 27.5: Added get_session_metadata (16 -> 17)
-	// New target: 17 tools (10 convenience + 3 utility + 4 two-stage)
-	expectedCount := 17
+	// Added get_server_metrics (17 -> 18)
+	// Added list_projects and list_sessions (18 -> 20)
+	// Added archive_sessions (20 -> 21)
+	// Added export_sessions and import_sessions (21 -> 23)
+	// Added query_security_audit (23 -> 24)
+	// Added query_result (24 -> 25)
+	// New target: 25 tools (11 convenience + 1 result + 7 utility + 4 two-stage + 2 catalog)
+	expectedCount := 25
 	actualCount := len(tools)
 
 	if actualCount != expectedCount {
-		t.Errorf("expected %d tools after Phase 27 Stage 27.5, got %d", expectedCount, actualCount)
+		t.Errorf("expected %d tools, got %d", expectedCount, actualCount)
 
 		// List all tool names for debugging
 		t.Log("Current tools:")
@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/yaleh/meta-cc/internal/version"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/sdk/resource"
@@ -15,13 +20,36 @@ import (
 
 var tracer trace.Tracer
 
+// Tracing is configured with the standard OpenTelemetry environment variables:
+//
+//	OTEL_TRACES_EXPORTER   none (default), console|stdout, otlp, otlp_file
+//	OTEL_TRACES_SAMPLER    always_on, always_off, traceidratio,
+//	                       parentbased_always_on (default), parentbased_always_off,
+//	                       parentbased_traceidratio
+//	OTEL_TRACES_SAMPLER_ARG  ratio for the traceidratio samplers (default: 1.0)
+//	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT / OTEL_EXPORTER_OTLP_ENDPOINT
+//	                       OTLP/HTTP collector (default: http://localhost:4318/v1/traces)
+//	META_CC_TRACES_FILE    output path for otlp_file (default: $TMPDIR/meta-cc-traces.jsonl)
+//
+// The otlp exporters send JSON-encoded payloads, which Jaeger and the
+// OpenTelemetry Collector accept on their OTLP/HTTP port.
+
+// defaultOTLPEndpoint is the OTLP/HTTP port of a local collector
+const defaultOTLPEndpoint = "http://localhost:4318"
+
 // InitTracing initializes OpenTelemetry distributed tracing
 func InitTracing() (func(), error) {
-	// Create stdout exporter for testing/development
-	exporter, err := stdouttrace.New(
-		stdouttrace.WithPrettyPrint(),
-		stdouttrace.WithWriter(os.Stderr), // Write traces to stderr to avoid mixing with JSON-RPC
-	)
+	exporterName := strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER")))
+	if exporterName == "" {
+		exporterName = "none"
+	}
+
+	exporter, target, err := newTraceExporter(exporterName)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler, err := newTraceSampler(os.Getenv("OTEL_TRACES_SAMPLER"), os.Getenv("OTEL_TRACES_SAMPLER_ARG"))
 	if err != nil {
 		return nil, err
 	}
@@ -30,29 +58,23 @@ func InitTracing() (func(), error) {
 	res, err := resource.New(context.Background(),
 		resource.WithAttributes(
 			semconv.ServiceName("meta-cc-mcp"),
-			semconv.ServiceVersion("1.0.0"),
+			semconv.ServiceVersion(version.String()),
 		),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	// Configure sampler (AlwaysOn for development, configurable for production)
-	sampler := sdktrace.AlwaysSample()
-	if samplingRatio := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); samplingRatio != "" {
-		// TraceIDRatioBased sampler can be configured via environment variable
-		// Example: OTEL_TRACES_SAMPLER_ARG=0.1 for 10% sampling
-		slog.Debug("trace sampling configured",
-			"sampling_ratio", samplingRatio,
-		)
-	}
-
 	// Create trace provider
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
+	// Without an exporter spans are still created so trace IDs appear in logs
+	opts := []sdktrace.TracerProviderOption{
 		sdktrace.WithResource(res),
 		sdktrace.WithSampler(sampler),
-	)
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
 
 	// Register as global trace provider
 	otel.SetTracerProvider(tp)
@@ -61,7 +83,9 @@ func InitTracing() (func(), error) {
 	tracer = tp.Tracer("meta-cc-mcp")
 
 	slog.Info("distributed tracing initialized",
-		"exporter", "stdout",
+		"exporter", exporterName,
+		"target", target,
+		"sampler", sampler.Description(),
 		"service_name", "meta-cc-mcp",
 	)
 
@@ -77,6 +101,83 @@ func InitTracing() (func(), error) {
 	return cleanup, nil
 }
 
+// newTraceExporter builds the exporter selected by OTEL_TRACES_EXPORTER.
+// It returns a nil exporter for "none" and a human-readable target for logging.
+func newTraceExporter(name string) (sdktrace.SpanExporter, string, error) {
+	switch name {
+	case "none":
+		return nil, "", nil
+
+	case "console", "stdout":
+		// Write traces to stderr to avoid mixing with JSON-RPC on stdout
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+		if err != nil {
+			return nil, "", err
+		}
+		return exporter, "stderr", nil
+
+	case "otlp":
+		endpoint := otlpTracesEndpoint()
+		return newOTLPHTTPExporter(endpoint), endpoint, nil
+
+	case "otlp_file":
+		path := os.Getenv("META_CC_TRACES_FILE")
+		if path == "" {
+			path = filepath.Join(os.TempDir(), "meta-cc-traces.jsonl")
+		}
+		exporter, err := newOTLPFileExporter(path)
+		if err != nil {
+			return nil, "", err
+		}
+		return exporter, path, nil
+
+	default:
+		return nil, "", fmt.Errorf("unsupported OTEL_TRACES_EXPORTER: %s (must be one of: none, console, stdout, otlp, otlp_file)", name)
+	}
+}
+
+// otlpTracesEndpoint resolves the OTLP/HTTP traces URL from the standard variables.
+// The signal-specific variable is used as-is; the generic one gets /v1/traces appended.
+func otlpTracesEndpoint() string {
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if base == "" {
+		base = defaultOTLPEndpoint
+	}
+	return strings.TrimRight(base, "/") + "/v1/traces"
+}
+
+// newTraceSampler builds the sampler selected by OTEL_TRACES_SAMPLER and its argument
+func newTraceSampler(name, arg string) (sdktrace.Sampler, error) {
+	ratio := 1.0
+	if arg != "" {
+		parsed, err := strconv.ParseFloat(arg, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return nil, fmt.Errorf("invalid OTEL_TRACES_SAMPLER_ARG: %s (must be a ratio between 0 and 1)", arg)
+		}
+		ratio = parsed
+	}
+
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio":
+		return sdktrace.TraceIDRatioBased(ratio), nil
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)), nil
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_SAMPLER: %s", name)
+	}
+}
+
 // GetTracer returns the global tracer instance
 func GetTracer() trace.Tracer {
 	return tracer
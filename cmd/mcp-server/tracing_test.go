@@ -1,7 +1,18 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // TestInitTracing tests the InitTracing function
@@ -12,3 +23,166 @@ func TestInitTracing(t *testing.T) {
 	}
 	defer cleanup()
 }
+
+// TestInitTracingRejectsUnknownExporter verifies invalid OTEL_TRACES_EXPORTER values fail fast
+func TestInitTracingRejectsUnknownExporter(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+
+	if _, err := InitTracing(); err == nil {
+		t.Fatal("expected error for unsupported exporter")
+	}
+}
+
+func TestNewTraceSampler(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		want    string
+		wantErr bool
+	}{
+		{"", "", "ParentBased{root:AlwaysOnSampler", false},
+		{"always_on", "", "AlwaysOnSampler", false},
+		{"always_off", "", "AlwaysOffSampler", false},
+		{"traceidratio", "0.25", "TraceIDRatioBased{0.25}", false},
+		{"parentbased_traceidratio", "0.5", "ParentBased{root:TraceIDRatioBased{0.5}", false},
+		{"traceidratio", "1.5", "", true},
+		{"traceidratio", "abc", "", true},
+		{"jaeger_remote", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.arg, func(t *testing.T) {
+			sampler, err := newTraceSampler(tt.name, tt.arg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got sampler %s", sampler.Description())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.HasPrefix(sampler.Description(), tt.want) {
+				t.Errorf("expected sampler %s, got %s", tt.want, sampler.Description())
+			}
+		})
+	}
+}
+
+func TestOTLPTracesEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if got := otlpTracesEndpoint(); got != "http://localhost:4318/v1/traces" {
+		t.Errorf("unexpected default endpoint: %s", got)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://127.0.0.1:9999/")
+	if got := otlpTracesEndpoint(); got != "http://127.0.0.1:9999/v1/traces" {
+		t.Errorf("unexpected base endpoint: %s", got)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://collector/custom")
+	if got := otlpTracesEndpoint(); got != "http://collector/custom" {
+		t.Errorf("signal-specific endpoint should win, got %s", got)
+	}
+}
+
+// recordSpans runs fn against a provider wired to exporter and flushes it
+func recordSpans(t *testing.T, exporter sdktrace.SpanExporter, fn func(ctx context.Context, tp *sdktrace.TracerProvider)) {
+	t.Helper()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	fn(context.Background(), tp)
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+}
+
+func TestOTLPFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	exporter, err := newOTLPFileExporter(path)
+	if err != nil {
+		t.Fatalf("newOTLPFileExporter failed: %v", err)
+	}
+
+	recordSpans(t, exporter, func(ctx context.Context, tp *sdktrace.TracerProvider) {
+		ctx, parent := tp.Tracer("meta-cc-mcp").Start(ctx, "tool.execute")
+		_, child := tp.Tracer("meta-cc-mcp").Start(ctx, "scan")
+		child.SetAttributes(
+			attribute.Int64("scan.bytes_read", 4096),
+			attribute.StringSlice("scan.files", []string{"a.jsonl", "b.jsonl"}),
+		)
+		child.End()
+		parent.End()
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read trace file: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one payload per export (2), got %d", len(lines))
+	}
+
+	var payload otlpTracesData
+	if err := json.Unmarshal([]byte(lines[0]), &payload); err != nil {
+		t.Fatalf("invalid OTLP JSON: %v", err)
+	}
+
+	span := payload.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if span.Name != "scan" || len(span.TraceID) != 32 || len(span.SpanID) != 16 || span.ParentSpanID == "" {
+		t.Errorf("unexpected span encoding: %+v", span)
+	}
+
+	attrs := map[string]otlpAnyValue{}
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	if v := attrs["scan.bytes_read"]; v.IntValue == nil || *v.IntValue != "4096" {
+		t.Errorf("expected intValue \"4096\", got %+v", v)
+	}
+	if v := attrs["scan.files"]; v.ArrayValue == nil || len(v.ArrayValue.Values) != 2 {
+		t.Errorf("expected 2-element arrayValue, got %+v", v)
+	}
+}
+
+func TestOTLPHTTPExporter(t *testing.T) {
+	var body []byte
+	var contentType string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	recordSpans(t, newOTLPHTTPExporter(collector.URL+"/v1/traces"), func(ctx context.Context, tp *sdktrace.TracerProvider) {
+		_, span := tp.Tracer("meta-cc-mcp").Start(ctx, "jsonrpc.request")
+		span.End()
+	})
+
+	if contentType != "application/json" {
+		t.Errorf("expected application/json, got %s", contentType)
+	}
+	if !strings.Contains(string(body), `"name":"jsonrpc.request"`) {
+		t.Errorf("expected span in payload, got %s", body)
+	}
+}
+
+func TestOTLPHTTPExporterReportsFailure(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	exporter := newOTLPHTTPExporter(collector.URL)
+	tp := sdktrace.NewTracerProvider()
+	_, span := tp.Tracer("test").Start(context.Background(), "s")
+	span.End()
+
+	stub := []sdktrace.ReadOnlySpan{span.(sdktrace.ReadOnlySpan)}
+	if err := exporter.ExportSpans(context.Background(), stub); err == nil {
+		t.Error("expected error for non-2xx collector response")
+	}
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yaleh/meta-cc/internal/analyzer"
+	"github.com/yaleh/meta-cc/internal/bundle"
+	"github.com/yaleh/meta-cc/internal/config"
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/locator"
+	"github.com/yaleh/meta-cc/internal/parser"
+	"github.com/yaleh/meta-cc/internal/query"
+	"github.com/yaleh/meta-cc/internal/scrub"
+	"github.com/yaleh/meta-cc/internal/sessionio"
+	"github.com/yaleh/meta-cc/pkg/pipeline"
+)
+
+// parseFlags parses command flags and validates the shared ones
+func (env *cmdEnv) parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateFormat(env.global.format); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return nil
+}
+
+// loadSession loads entries and tool calls for the selected scope
+func (env *cmdEnv) loadSession() (*pipeline.SessionPipeline, []parser.ToolCall, error) {
+	opts, err := env.global.pipelineOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	pipe := pipeline.NewSessionPipeline(opts)
+	if err := pipe.Load(pipeline.LoadOptions{AutoDetect: true, Indexed: true}); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", query.ErrSessionLoad, err)
+	}
+	return pipe, pipe.ExtractToolCalls(), nil
+}
+
+// sessionFiles resolves the session files of the selected scope, newest
+// first like the query tools of the MCP server
+func (env *cmdEnv) sessionFiles() ([]string, error) {
+	opts, err := env.global.pipelineOptions()
+	if err != nil {
+		return nil, err
+	}
+	loc := locator.NewSessionLocator()
+	if opts.ProjectPath != "" && opts.SessionID == "" && !opts.SessionOnly {
+		paths, err := loc.AllSessionsFromProject(opts.ProjectPath)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", query.ErrSessionLoad, err)
+		}
+		sort.SliceStable(paths, func(i, j int) bool { return modTime(paths[i]).After(modTime(paths[j])) })
+		return paths, nil
+	}
+	path, err := loc.Locate(locator.LocateOptions{
+		SessionID:   opts.SessionID,
+		ProjectPath: opts.ProjectPath,
+		SessionOnly: opts.SessionOnly,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", query.ErrSessionLoad, err)
+	}
+	return []string{path}, nil
+}
+
+// scanRecords runs a jq filter over files with the record scanner of the MCP
+// query tools and returns the matching session records
+func scanRecords(files []string, filter string, tokens []string, limit int) ([]interface{}, error) {
+	code, err := query.CompileJQ(filter)
+	if err != nil {
+		return nil, err
+	}
+	scanner := query.RecordScanner{Prefilter: query.NewLinePrefilter(query.FilterRecordTypes(filter), tokens)}
+	return scanner.Run(context.Background(), files, code, limit), nil
+}
+
+// recordField returns a string field of a raw session record
+func recordField(record interface{}, key string) string {
+	r, _ := record.(map[string]interface{})
+	s, _ := r[key].(string)
+	return s
+}
+
+// toolCallsResult renders assistant records with one row per record
+func toolCallsResult(records []interface{}) result {
+	rows := make([][]string, 0, len(records))
+	for _, r := range records {
+		var names []string
+		for _, block := range query.ToolUseBlocks(r) {
+			if name, ok := block["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+		rows = append(rows, []string{recordField(r, "timestamp"), strings.Join(names, ","), recordField(r, "uuid")})
+	}
+	return result{
+		records: records,
+		headers: []string{"TIMESTAMP", "TOOL", "UUID"},
+		rows:    rows,
+	}
+}
+
+// toolErrorsResult renders user records with one row per failed tool_result
+func toolErrorsResult(records []interface{}) result {
+	rows := make([][]string, 0, len(records))
+	for _, r := range records {
+		for _, block := range query.ToolResultBlocks(r) {
+			if block["is_error"] != true {
+				continue
+			}
+			id, _ := block["tool_use_id"].(string)
+			rows = append(rows, []string{recordField(r, "timestamp"), id, recordField(r, "uuid"), toolResultText(block["content"])})
+		}
+	}
+	return result{
+		records: records,
+		headers: []string{"TIMESTAMP", "TOOL_USE_ID", "UUID", "ERROR"},
+		rows:    rows,
+	}
+}
+
+// toolResultText flattens tool_result content, a string or a list of text blocks
+func toolResultText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var parts []string
+		for _, raw := range c {
+			if block, ok := raw.(map[string]interface{}); ok {
+				if text, ok := block["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
+
+// runQueryTools mirrors the query_tools MCP tool and prints the same records
+func runQueryTools(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("query tools")
+	tool := fs.String("tool", "", "Filter by tool name")
+	status := fs.String("status", "", "Filter by status: error or success")
+	limit := fs.Int("limit", 0, "Maximum number of results (0 = no limit)")
+	if err := env.parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *status != "" && *status != "error" && *status != "success" {
+		return fmt.Errorf("%w: invalid status: %s (must be one of: error, success)", errUsage, *status)
+	}
+
+	files, err := env.sessionFiles()
+	if err != nil {
+		return err
+	}
+	filter := query.ToolCallsFilter(*tool)
+	if *status == "" {
+		calls, err := scanRecords(files, filter, query.ToolCallTokens, *limit)
+		if err != nil {
+			return err
+		}
+		return render(env.stdout, env.global.format, toolCallsResult(calls))
+	}
+
+	// Status is recorded on the tool_result in the following user message,
+	// so collect failed tool_use IDs first and filter calls against them
+	errorIDs, err := scanRecords(files, query.ToolErrorIDsFilter, query.ToolErrorTokens, 0)
+	if err != nil {
+		return err
+	}
+	calls, err := scanRecords(files, filter, query.ToolCallTokens, 0)
+	if err != nil {
+		return err
+	}
+	calls = query.FilterToolCallsByStatus(calls, *tool, *status == "error", query.FailedToolUseIDs(errorIDs), *limit)
+	return render(env.stdout, env.global.format, toolCallsResult(calls))
+}
+
+// runQueryErrors mirrors the query_tool_errors MCP tool: it lists the user
+// records holding a failed tool_result. With --patterns it groups errors seen
+// 3 or more times by signature instead.
+func runQueryErrors(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("query errors")
+	limit := fs.Int("limit", 0, "Maximum number of results (0 = no limit)")
+	patterns := fs.Bool("patterns", false, "Group errors seen 3 or more times into patterns")
+	if err := env.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if !*patterns {
+		files, err := env.sessionFiles()
+		if err != nil {
+			return err
+		}
+		records, err := scanRecords(files, query.ToolErrorsFilter, query.ToolErrorTokens, *limit)
+		if err != nil {
+			return err
+		}
+		return render(env.stdout, env.global.format, toolErrorsResult(records))
+	}
+
+	pipe, calls, err := env.loadSession()
+	if err != nil {
+		return err
+	}
+	found := analyzer.DetectErrorPatterns(pipe.Skeletons(), calls)
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Occurrences > found[j].Occurrences })
+	if *limit > 0 && len(found) > *limit {
+		found = found[:*limit]
+	}
+
+	rows := make([][]string, 0, len(found))
+	for _, p := range found {
+		rows = append(rows, []string{strconv.Itoa(p.Occurrences), p.ToolName, p.FirstSeen, p.LastSeen, p.ErrorText})
+	}
+	return render(env.stdout, env.global.format, result{
+		records: found,
+		headers: []string{"COUNT", "TOOL", "FIRST_SEEN", "LAST_SEEN", "ERROR"},
+		rows:    rows,
+	})
+}
+
+// statsRecord is the machine-readable form of analyzer.SessionStats
+type statsRecord struct {
+	TurnCount          int            `json:"turn_count"`
+	UserTurnCount      int            `json:"user_turn_count"`
+	AssistantTurnCount int            `json:"assistant_turn_count"`
+	ToolCallCount      int            `json:"tool_call_count"`
+	ErrorCount         int            `json:"error_count"`
+	ErrorRate          float64        `json:"error_rate"`
+	DurationSeconds    int64          `json:"duration_seconds"`
+	ToolFrequency      map[string]int `json:"tool_frequency"`
+}
+
+// runStats summarizes turns, tool usage and errors of the selected sessions
+func runStats(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("stats")
+	if err := env.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	pipe, calls, err := env.loadSession()
+	if err != nil {
+		return err
+	}
+	s := query.BuildSessionStats(pipe.Skeletons(), calls)
+	record := statsRecord{
+		TurnCount:          s.TurnCount,
+		UserTurnCount:      s.UserTurnCount,
+		AssistantTurnCount: s.AssistantTurnCount,
+		ToolCallCount:      s.ToolCallCount,
+		ErrorCount:         s.ErrorCount,
+		ErrorRate:          s.ErrorRate,
+		DurationSeconds:    s.DurationSeconds,
+		ToolFrequency:      s.ToolFrequency,
+	}
+
+	rows := [][]string{
+		{"turns", strconv.Itoa(s.TurnCount)},
+		{"user_turns", strconv.Itoa(s.UserTurnCount)},
+		{"assistant_turns", strconv.Itoa(s.AssistantTurnCount)},
+		{"tool_calls", strconv.Itoa(s.ToolCallCount)},
+		{"errors", strconv.Itoa(s.ErrorCount)},
+		{"error_rate", fmt.Sprintf("%.1f%%", s.ErrorRate)},
+		{"duration", (time.Duration(s.DurationSeconds) * time.Second).String()},
+	}
+	for _, t := range s.TopTools {
+		rows = append(rows, []string{"tool:" + t.Name, strconv.Itoa(t.Count)})
+	}
+	return render(env.stdout, env.global.format, result{
+		records: record,
+		headers: []string{"METRIC", "VALUE"},
+		rows:    rows,
+	})
+}
+
+// sessionRecord describes one session file
+type sessionRecord struct {
+	SessionID string `json:"session_id"`
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	Modified  string `json:"modified"`
+}
+
+// runSessionsList lists session files of the project, newest first
+func runSessionsList(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("sessions list")
+	if err := env.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	projectPath := env.global.project
+	if projectPath == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current directory: %w", err)
+		}
+		projectPath = cwd
+	}
+
+	paths, err := locator.NewSessionLocator().AllSessionsFromProject(projectPath)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, mcerrors.ErrNotFound)
+	}
+
+	records := make([]sessionRecord, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, mcerrors.ErrFileIO)
+		}
+		records = append(records, sessionRecord{
+			SessionID: sessionio.SessionID(path),
+			Path:      path,
+			SizeBytes: info.Size(),
+			Modified:  info.ModTime().UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Modified > records[j].Modified })
+
+	rows := make([][]string, 0, len(records))
+	for _, r := range records {
+		rows = append(rows, []string{r.SessionID, r.Modified, strconv.FormatInt(r.SizeBytes, 10)})
+	}
+	return render(env.stdout, env.global.format, result{
+		records: records,
+		headers: []string{"SESSION", "MODIFIED", "SIZE"},
+		rows:    rows,
+	})
+}
+
+// runAnalyzeSequences finds repeated tool call sequences
+func runAnalyzeSequences(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("analyze sequences")
+	minOccurrences := fs.Int("min-occurrences", 3, "Minimum occurrences for a sequence to be reported")
+	pattern := fs.String("pattern", "", "Only report this sequence (e.g. \"Read -> Edit\")")
+	includeBuiltin := fs.Bool("include-builtin", false, "Include built-in tools such as Read and Bash")
+	if err := env.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	pipe, _, err := env.loadSession()
+	if err != nil {
+		return err
+	}
+	seq, err := query.BuildToolSequenceQuery(pipe.Skeletons(), *minOccurrences, *pattern, *includeBuiltin)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(seq.Sequences))
+	for _, s := range seq.Sequences {
+		rows = append(rows, []string{strconv.Itoa(s.Count), strconv.Itoa(s.TimeSpanMin), s.Pattern})
+	}
+	return render(env.stdout, env.global.format, result{
+		records: seq.Sequences,
+		headers: []string{"COUNT", "SPAN_MIN", "PATTERN"},
+		rows:    rows,
+	})
+}
+
+// runSessionsExport mirrors the export_sessions MCP tool: it bundles the
+// session selected by --session, or the project's sessions newest first
+func runSessionsExport(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("sessions export")
+	output := fs.String("output", "", "Bundle path (default: meta-cc-bundle-<time>.tar.gz in the current directory)")
+	name := fs.String("name", "", "Label stored in the bundle manifest")
+	limit := fs.Int("limit", 0, "Export only the newest N sessions (0 = all)")
+	if err := env.parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *limit < 0 {
+		return fmt.Errorf("%w: limit must be non-negative (got %d)", errUsage, *limit)
+	}
+
+	loc := locator.NewSessionLocator()
+	var paths []string
+	if env.global.session != "" {
+		path, err := loc.FromSessionID(env.global.session)
+		if err != nil {
+			return fmt.Errorf("%v: %w", err, mcerrors.ErrNotFound)
+		}
+		paths = []string{path}
+	} else {
+		projectPath := env.global.project
+		if projectPath == "" {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current directory: %w", err)
+			}
+			projectPath = cwd
+		}
+		var err error
+		if paths, err = loc.AllSessionsFromProject(projectPath); err != nil {
+			return fmt.Errorf("%v: %w", err, mcerrors.ErrNotFound)
+		}
+		sort.SliceStable(paths, func(i, j int) bool { return modTime(paths[i]).After(modTime(paths[j])) })
+		if *limit > 0 && len(paths) > *limit {
+			paths = paths[:*limit]
+		}
+	}
+
+	path := *output
+	if path == "" {
+		path = bundle.DefaultFileName(time.Now())
+	}
+	manifest, err := bundle.ExportFile(path, *name, paths)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stderr, "Exported %d sessions to %s\n", len(manifest.Sessions), path)
+	return renderBundleSessions(env, manifest.Sessions)
+}
+
+// runSessionsImport mirrors the import_sessions MCP tool
+func runSessionsImport(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("sessions import")
+	if err := env.parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: expected one bundle path", errUsage)
+	}
+	dir := locator.BundlesDir()
+	if dir == "" {
+		return fmt.Errorf("bundles directory not configured (set META_CC_BUNDLES_DIR): %w", mcerrors.ErrInvalidInput)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	imported, err := bundle.Import(fs.Arg(0), dir, cfg.Bundle.MaxImportBytes())
+	if err != nil {
+		return err
+	}
+	if imported.Existing {
+		fmt.Fprintf(env.stderr, "Bundle already imported into %s\n", imported.Dir)
+	} else {
+		fmt.Fprintf(env.stderr, "Imported %d sessions into %s\n", len(imported.Manifest.Sessions), imported.Dir)
+	}
+	return renderBundleSessions(env, imported.Manifest.Sessions)
+}
+
+// runSessionsScrub anonymizes the session file given as argument, or the one
+// selected by --session, and writes it as plain JSONL
+func runSessionsScrub(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("sessions scrub")
+	output := fs.String("output", "", "Write the scrubbed session to this file (default: stdout)")
+	root := fs.String("root", "", "Project root whose paths are rewritten (default: the session's working directory)")
+	hashPaths := fs.Bool("hash-paths", false, "Also hash file and directory names under the project root")
+	maxOutput := fs.Int("max-output", 0, "Truncate tool outputs longer than N bytes (0 = keep)")
+	salt := fs.String("salt", "", "Secret mixed into replacement hashes")
+	var words []string
+	fs.Func("word", "Extra term to replace, such as a user or company name (repeatable)", func(v string) error {
+		words = append(words, v)
+		return nil
+	})
+	if err := env.parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *maxOutput < 0 {
+		return fmt.Errorf("%w: max-output must be non-negative (got %d)", errUsage, *maxOutput)
+	}
+
+	var path string
+	switch {
+	case fs.NArg() == 1:
+		path = fs.Arg(0)
+	case fs.NArg() == 0 && env.global.session != "":
+		var err error
+		if path, err = locator.NewSessionLocator().FromSessionID(env.global.session); err != nil {
+			return fmt.Errorf("%v: %w", err, mcerrors.ErrNotFound)
+		}
+	default:
+		return fmt.Errorf("%w: expected one session file or --session", errUsage)
+	}
+
+	opts := scrub.Options{ProjectRoot: *root, HashPaths: *hashPaths, Words: words, MaxToolOutput: *maxOutput, Salt: *salt}
+	if opts.ProjectRoot == "" {
+		header, err := query.ScanSessionHeader(path)
+		if err != nil {
+			return fmt.Errorf("failed to read session %s: %w", path, mcerrors.ErrFileIO)
+		}
+		opts.ProjectRoot = header.ProjectPath
+	}
+
+	in, err := sessionio.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open session %s: %w", path, mcerrors.ErrFileIO)
+	}
+	defer in.Close()
+
+	out := env.stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *output, mcerrors.ErrFileIO)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	s := scrub.New(opts)
+	if err := s.Session(in, out); err != nil {
+		return fmt.Errorf("failed to scrub %s: %w", path, mcerrors.ErrFileIO)
+	}
+	r := s.Report()
+	fmt.Fprintf(env.stderr, "Scrubbed %d lines: %d secrets, %d emails, %d paths, %d words, %d outputs truncated\n",
+		r.Lines, r.Secrets, r.Emails, r.Paths, r.Words, r.Truncated)
+	return nil
+}
+
+// renderBundleSessions lists the sessions of a bundle manifest
+func renderBundleSessions(env *cmdEnv, sessions []bundle.Session) error {
+	rows := make([][]string, 0, len(sessions))
+	for _, s := range sessions {
+		project := s.ProjectPath
+		if project == "" {
+			project = s.Project
+		}
+		rows = append(rows, []string{s.SessionID, project, s.StartTime, s.EndTime, strconv.FormatInt(s.SizeBytes, 10)})
+	}
+	return render(env.stdout, env.global.format, result{
+		records: sessions,
+		headers: []string{"SESSION", "PROJECT", "START", "END", "SIZE"},
+		rows:    rows,
+	})
+}
+
+// modTime returns a file's modification time, or the zero time
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
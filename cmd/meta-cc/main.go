@@ -0,0 +1,168 @@
+// Command meta-cc analyzes Claude Code session history from the terminal.
+//
+// It exposes the same analyses as the MCP server's query tools, built on
+// pkg/pipeline and internal/query, with human-friendly table output and
+// machine-readable jsonl/tsv output for shell scripts:
+//
+//	meta-cc [global flags] <command> [subcommand] [flags]
+//
+// Global flags may also be given after the command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/yaleh/meta-cc/internal/version"
+	"github.com/yaleh/meta-cc/pkg/pipeline"
+)
+
+// errUsage marks errors caused by invalid command-line usage (exit code 2)
+var errUsage = errors.New("usage error")
+
+// globalFlags holds flags accepted by every command
+type globalFlags struct {
+	session     string
+	project     string
+	sessionOnly bool
+	format      string
+}
+
+// register binds the global flags to fs, keeping values already parsed so
+// they can appear both before and after the command name
+func (g *globalFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.session, "session", g.session, "Analyze the session with this ID")
+	fs.StringVar(&g.project, "project", g.project, "Analyze all sessions of this project path (default: current directory)")
+	fs.BoolVar(&g.sessionOnly, "session-only", g.sessionOnly, "Analyze only the newest session of the project")
+	fs.StringVar(&g.format, "format", g.format, "Output format: table, jsonl or tsv")
+}
+
+// pipelineOptions converts the flags to pipeline options. Like the MCP server,
+// the default scope is every session of the current project.
+func (g *globalFlags) pipelineOptions() (pipeline.GlobalOptions, error) {
+	opts := pipeline.GlobalOptions{
+		SessionID:   g.session,
+		ProjectPath: g.project,
+		SessionOnly: g.sessionOnly,
+	}
+	if opts.ProjectPath == "" && opts.SessionID == "" && !opts.SessionOnly {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return opts, fmt.Errorf("failed to get current directory: %w", err)
+		}
+		opts.ProjectPath = cwd
+	}
+	return opts, nil
+}
+
+// command is a leaf command such as "query tools"
+type command struct {
+	name    string
+	summary string
+	run     func(env *cmdEnv, args []string) error
+}
+
+// commands lists every leaf command in help order
+var commands = []command{
+	{"query tools", "List assistant records with tool calls, optionally filtered by tool and status", runQueryTools},
+	{"query errors", "List failed tool results, or group them into error patterns with --patterns", runQueryErrors},
+	{"stats", "Summarize turns, tool usage and error rate", runStats},
+	{"sessions list", "List session files of the project", runSessionsList},
+	{"sessions export", "Pack the project's sessions into a tar.gz bundle", runSessionsExport},
+	{"sessions import", "Import a session bundle as a read-only session root", runSessionsImport},
+	{"sessions scrub", "Anonymize a session file for bug reports and fixtures", runSessionsScrub},
+	{"analyze sequences", "Find repeated tool call sequences", runAnalyzeSequences},
+}
+
+// cmdEnv carries state shared by commands
+type cmdEnv struct {
+	global *globalFlags
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// newFlagSet returns a flag set for a command with the global flags registered
+func (env *cmdEnv) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("meta-cc "+name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	env.global.register(fs)
+	return fs
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the CLI and returns the process exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	env := &cmdEnv{global: &globalFlags{format: formatTable}, stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("meta-cc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	env.global.register(fs)
+	showVersion := fs.Bool("version", false, "Print version and exit")
+	fs.Usage = func() { printUsage(stderr, fs) }
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *showVersion {
+		fmt.Fprintf(stdout, "meta-cc %s\n", version.String())
+		return 0
+	}
+
+	cmd, rest, ok := findCommand(fs.Args())
+	if !ok {
+		if fs.NArg() > 0 {
+			fmt.Fprintf(stderr, "meta-cc: unknown command %q\n\n", strings.Join(fs.Args(), " "))
+		}
+		printUsage(stderr, fs)
+		return 2
+	}
+
+	if err := cmd.run(env, rest); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(stderr, "meta-cc %s: %v\n", cmd.name, err)
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// findCommand matches the longest command name at the start of args
+func findCommand(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) < len(words) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+// printUsage writes top-level help
+func printUsage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintln(w, "Usage: meta-cc [global flags] <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-18s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Global flags:")
+	fs.PrintDefaults()
+}
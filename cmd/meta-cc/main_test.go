@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaleh/meta-cc/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithTempIndex(m))
+}
+
+const testSession = `{"type":"user","uuid":"u1","timestamp":"2025-01-01T10:00:00Z","message":{"role":"user","content":"fix the build"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T10:00:01Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go build"}}]}}
+{"type":"user","uuid":"u2","timestamp":"2025-01-01T10:00:02Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":"exit status 1"}]}}
+{"type":"assistant","uuid":"a2","timestamp":"2025-01-01T10:00:03Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"Read","input":{"file_path":"main.go"}}]}}
+{"type":"user","uuid":"u3","timestamp":"2025-01-01T10:00:04Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":"package main"}]}}
+`
+
+// setupProject creates a projects root containing one session for a temp
+// project directory and returns the project path
+func setupProject(t *testing.T) string {
+	t.Helper()
+
+	projectPath, _ := testutil.SetupProject(t, testutil.SetupProjectsRoot(t), map[string]string{"session-1": testSession})
+	return projectPath
+}
+
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestQueryToolsTable(t *testing.T) {
+	project := setupProject(t)
+
+	code, out, errOut := runCLI(t, "--project", project, "query", "tools")
+	require.Equal(t, 0, code, errOut)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "TIMESTAMP"))
+	assert.Contains(t, lines[1], "Bash")
+	assert.Contains(t, lines[2], "Read")
+}
+
+func TestQueryToolsFiltersAndFormats(t *testing.T) {
+	project := setupProject(t)
+
+	// Global flags are accepted after the command name too
+	code, out, errOut := runCLI(t, "query", "tools", "--project", project, "--status", "error", "--format", "jsonl")
+	require.Equal(t, 0, code, errOut)
+
+	// Records are the raw session records the query_tools MCP tool returns
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(out)), &record))
+	assert.Equal(t, "a1", record["uuid"])
+	assert.Equal(t, "assistant", record["type"])
+
+	code, out, errOut = runCLI(t, "--format", "tsv", "--project", project, "query", "tools", "--tool", "Read")
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, []string{"TIMESTAMP\tTOOL\tUUID", "2025-01-01T10:00:03Z\tRead\ta2"},
+		strings.Split(strings.TrimSuffix(out, "\n"), "\n"))
+
+	code, out, errOut = runCLI(t, "--format", "tsv", "--project", project, "query", "tools", "--status", "success", "--limit", "1")
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, out, "\tRead\ta2")
+	assert.NotContains(t, out, "Bash")
+}
+
+func TestQueryErrors(t *testing.T) {
+	project := setupProject(t)
+
+	code, out, errOut := runCLI(t, "--project", project, "--format", "tsv", "query", "errors")
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, []string{"TIMESTAMP\tTOOL_USE_ID\tUUID\tERROR", "2025-01-01T10:00:02Z\tt1\tu2\texit status 1"},
+		strings.Split(strings.TrimSuffix(out, "\n"), "\n"))
+
+	// The records match those of the query_tool_errors MCP tool
+	code, out, errOut = runCLI(t, "--project", project, "--format", "jsonl", "query", "errors")
+	require.Equal(t, 0, code, errOut)
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(out)), &record))
+	assert.Equal(t, "u2", record["uuid"])
+}
+
+func TestStats(t *testing.T) {
+	project := setupProject(t)
+
+	code, out, errOut := runCLI(t, "--project", project, "--format", "jsonl", "stats")
+	require.Equal(t, 0, code, errOut)
+
+	var stats statsRecord
+	require.NoError(t, json.Unmarshal([]byte(out), &stats))
+	assert.Equal(t, 2, stats.ToolCallCount)
+	assert.Equal(t, 1, stats.ErrorCount)
+	assert.Equal(t, 1, stats.ToolFrequency["Read"])
+}
+
+func TestSessionsList(t *testing.T) {
+	project := setupProject(t)
+
+	code, out, errOut := runCLI(t, "--project", project, "sessions", "list")
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, out, "session-1")
+	assert.Contains(t, out, "SIZE")
+}
+
+func TestSessionsExportImport(t *testing.T) {
+	project := setupProject(t)
+	bundlePath := filepath.Join(t.TempDir(), "b.tar.gz")
+
+	code, out, errOut := runCLI(t, "--project", project, "sessions", "export", "--output", bundlePath)
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, out, "session-1")
+	assert.Contains(t, errOut, "Exported 1 sessions")
+
+	// Once imported, the session is found even without the local copy
+	require.NoError(t, os.RemoveAll(os.Getenv("META_CC_PROJECTS_ROOT")))
+	code, _, errOut = runCLI(t, "sessions", "import", bundlePath)
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, errOut, "Imported 1 sessions")
+
+	code, out, errOut = runCLI(t, "--session", "session-1", "query", "errors")
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, out, "exit status 1")
+
+	code, _, errOut = runCLI(t, "sessions", "import", bundlePath)
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, errOut, "already imported")
+
+	code, _, _ = runCLI(t, "sessions", "import")
+	assert.Equal(t, 2, code)
+}
+
+func TestSessionsScrub(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	session := strings.Replace(testSession, `"fix the build"`, `"fix the build, mail bob@corp.com"`, 1)
+	require.NoError(t, os.WriteFile(path, []byte(session), 0644))
+	output := filepath.Join(t.TempDir(), "scrubbed.jsonl")
+
+	code, _, errOut := runCLI(t, "sessions", "scrub", "--word", "build", "--output", output, path)
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, errOut, "Scrubbed 5 lines: 0 secrets, 1 emails")
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "bob@corp.com")
+	assert.NotContains(t, string(data), "build")
+	assert.Contains(t, string(data), `"tool_use_id":"t1"`)
+
+	code, _, _ = runCLI(t, "sessions", "scrub")
+	assert.Equal(t, 2, code)
+}
+
+func TestAnalyzeSequences(t *testing.T) {
+	project := setupProject(t)
+
+	code, out, errOut := runCLI(t, "--project", project, "analyze", "sequences", "--min-occurrences", "1", "--include-builtin")
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, out, "Bash → Read")
+}
+
+func TestUsageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no command", nil, "Usage: meta-cc"},
+		{"unknown command", []string{"frobnicate"}, `unknown command "frobnicate"`},
+		{"invalid format", []string{"--format", "xml", "stats"}, "invalid format: xml"},
+		{"invalid status", []string{"query", "tools", "--status", "broken"}, "invalid status: broken"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, errOut := runCLI(t, tt.args...)
+			assert.Equal(t, 2, code)
+			assert.Contains(t, errOut, tt.want)
+		})
+	}
+}
+
+func TestMissingSessionFails(t *testing.T) {
+	t.Setenv("META_CC_PROJECTS_ROOT", t.TempDir())
+
+	code, _, errOut := runCLI(t, "--project", t.TempDir(), "stats")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "meta-cc stats:")
+}
+
+func TestVersion(t *testing.T) {
+	code, out, _ := runCLI(t, "--version")
+	require.Equal(t, 0, code)
+	assert.True(t, strings.HasPrefix(out, "meta-cc "))
+}
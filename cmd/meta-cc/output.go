@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/pkg/output"
+)
+
+// Output formats accepted by --format
+const (
+	formatTable = "table"
+	formatJSONL = "jsonl"
+	formatTSV   = "tsv"
+)
+
+// result is a command's output: the raw records for jsonl and a
+// header/rows view for table and tsv
+type result struct {
+	records interface{}
+	headers []string
+	rows    [][]string
+}
+
+// validateFormat rejects unknown --format values before any work is done
+func validateFormat(format string) error {
+	switch format {
+	case formatTable, formatJSONL, formatTSV:
+		return nil
+	default:
+		return fmt.Errorf("invalid format: %s (must be one of: table, jsonl, tsv): %w", format, mcerrors.ErrInvalidInput)
+	}
+}
+
+// render writes res to w in the requested format
+func render(w io.Writer, format string, res result) error {
+	switch format {
+	case formatJSONL:
+		out, err := output.FormatJSONL(res.records)
+		if err != nil {
+			return err
+		}
+		if !strings.HasSuffix(out, "\n") {
+			out += "\n"
+		}
+		_, err = io.WriteString(w, out)
+		return err
+	case formatTSV:
+		var b strings.Builder
+		writeDelimited(&b, res.headers, "\t", sanitizeTSV)
+		for _, row := range res.rows {
+			writeDelimited(&b, row, "\t", sanitizeTSV)
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		writeDelimited(tw, res.headers, "\t", sanitizeCell)
+		for _, row := range res.rows {
+			writeDelimited(tw, row, "\t", sanitizeCell)
+		}
+		return tw.Flush()
+	}
+}
+
+// writeDelimited writes one line of cleaned fields
+func writeDelimited(w io.Writer, fields []string, sep string, clean func(string) string) {
+	cleaned := make([]string, len(fields))
+	for i, f := range fields {
+		cleaned[i] = clean(f)
+	}
+	fmt.Fprintln(w, strings.Join(cleaned, sep))
+}
+
+// maxCellWidth caps table cells so long error texts do not wrap the terminal
+const maxCellWidth = 60
+
+// sanitizeTSV removes characters that would break the TSV structure
+func sanitizeTSV(s string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", "").Replace(s)
+}
+
+// sanitizeCell flattens and truncates a value for table display
+func sanitizeCell(s string) string {
+	s = strings.Join(strings.Fields(sanitizeTSV(s)), " ")
+	if r := []rune(s); len(r) > maxCellWidth {
+		s = string(r[:maxCellWidth-3]) + "..."
+	}
+	return s
+}
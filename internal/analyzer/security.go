@@ -0,0 +1,342 @@
+package analyzer
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/parser"
+)
+
+// defaultSecurityRules is the built-in rule file of the security audit
+//
+//go:embed security_rules.yaml
+var defaultSecurityRules []byte
+
+// Severities ordered from least to most severe
+var Severities = []string{"low", "medium", "high", "critical"}
+
+// SeverityRank returns the position of severity in Severities, or -1
+func SeverityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// Default tools checked by rules that do not list any
+var (
+	commandTools = []string{"Bash"}
+	pathTools    = []string{"Write", "Edit", "MultiEdit", "NotebookEdit"}
+)
+
+// SecurityRule classifies tool calls by their shell command or target path
+type SecurityRule struct {
+	ID          string   `yaml:"id" json:"id"`
+	Category    string   `yaml:"category" json:"category"`
+	Severity    string   `yaml:"severity" json:"severity"`
+	Description string   `yaml:"description" json:"description"`
+	Tools       []string `yaml:"tools,omitempty" json:"tools,omitempty"`
+	// Command matches the command input of the tools (default: Bash)
+	Command string `yaml:"command,omitempty" json:"command,omitempty"`
+	// Path matches the file path input of the tools (default: Write, Edit, MultiEdit, NotebookEdit)
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+	// Disabled turns off a built-in rule of the same ID
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	command *regexp.Regexp
+	path    *regexp.Regexp
+	tools   map[string]bool
+}
+
+// compile validates the rule and compiles its expressions
+func (r *SecurityRule) compile() error {
+	if r.ID == "" {
+		return fmt.Errorf("security rule without id: %w", mcerrors.ErrInvalidInput)
+	}
+	if SeverityRank(r.Severity) < 0 {
+		return fmt.Errorf("security rule %s: invalid severity %q (must be one of: %v): %w", r.ID, r.Severity, Severities, mcerrors.ErrInvalidInput)
+	}
+	if r.Category == "" {
+		return fmt.Errorf("security rule %s: missing category: %w", r.ID, mcerrors.ErrInvalidInput)
+	}
+	if (r.Command == "") == (r.Path == "") {
+		return fmt.Errorf("security rule %s: exactly one of command and path is required: %w", r.ID, mcerrors.ErrInvalidInput)
+	}
+
+	var err error
+	tools := r.Tools
+	if r.Command != "" {
+		if r.command, err = regexp.Compile(r.Command); err != nil {
+			return fmt.Errorf("security rule %s: invalid command pattern: %v: %w", r.ID, err, mcerrors.ErrInvalidInput)
+		}
+		if len(tools) == 0 {
+			tools = commandTools
+		}
+	} else {
+		if r.path, err = regexp.Compile(r.Path); err != nil {
+			return fmt.Errorf("security rule %s: invalid path pattern: %v: %w", r.ID, err, mcerrors.ErrInvalidInput)
+		}
+		if len(tools) == 0 {
+			tools = pathTools
+		}
+	}
+	r.tools = make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		r.tools[tool] = true
+	}
+	return nil
+}
+
+// match returns the command or path of a tool call matched by the rule
+func (r *SecurityRule) match(toolName string, input map[string]interface{}) (command, path string, ok bool) {
+	if !r.tools[toolName] {
+		return "", "", false
+	}
+	if r.command != nil {
+		command, _ = input["command"].(string)
+		return command, "", command != "" && r.command.MatchString(command)
+	}
+	path = toolPath(input)
+	return "", path, path != "" && r.path.MatchString(path)
+}
+
+// toolPath returns the file a tool call reads or writes
+func toolPath(input map[string]interface{}) string {
+	for _, key := range []string{"file_path", "notebook_path", "path"} {
+		if path, ok := input[key].(string); ok && path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// SecurityRules is an ordered, compiled rule set
+type SecurityRules struct {
+	rules []*SecurityRule
+}
+
+// Rules returns the enabled rules in evaluation order
+func (s *SecurityRules) Rules() []SecurityRule {
+	rules := make([]SecurityRule, 0, len(s.rules))
+	for _, r := range s.rules {
+		rules = append(rules, *r)
+	}
+	return rules
+}
+
+// securityRuleFile is the YAML layout of rule files
+type securityRuleFile struct {
+	Rules []SecurityRule `yaml:"rules"`
+}
+
+// parseSecurityRules decodes a rule file, rejecting unknown fields
+func parseSecurityRules(data []byte) ([]SecurityRule, error) {
+	var file securityRuleFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid security rule file: %v: %w", err, mcerrors.ErrInvalidInput)
+	}
+	return file.Rules, nil
+}
+
+// DefaultSecurityRules returns the built-in rules
+func DefaultSecurityRules() *SecurityRules {
+	rules, err := mergeSecurityRules(nil)
+	if err != nil {
+		// The embedded file is covered by tests
+		panic(err)
+	}
+	return rules
+}
+
+// LoadSecurityRules returns the built-in rules merged with the local rule
+// file at path: new IDs are appended, existing IDs are replaced and
+// disabled rules are removed. An empty path returns the built-in rules.
+func LoadSecurityRules(path string) (*SecurityRules, error) {
+	if path == "" {
+		return DefaultSecurityRules(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("security rule file %s: %w", path, mcerrors.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to read security rule file %s: %w", path, mcerrors.ErrFileIO)
+	}
+	local, err := parseSecurityRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return mergeSecurityRules(local)
+}
+
+// mergeSecurityRules applies local rules on top of the built-in ones
+func mergeSecurityRules(local []SecurityRule) (*SecurityRules, error) {
+	builtin, err := parseSecurityRules(defaultSecurityRules)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(builtin))
+	for i, r := range builtin {
+		index[r.ID] = i
+	}
+	for _, r := range local {
+		if i, exists := index[r.ID]; exists {
+			builtin[i] = r
+			continue
+		}
+		index[r.ID] = len(builtin)
+		builtin = append(builtin, r)
+	}
+
+	set := &SecurityRules{}
+	for i := range builtin {
+		r := builtin[i]
+		if r.Disabled {
+			continue
+		}
+		if err := r.compile(); err != nil {
+			return nil, err
+		}
+		set.rules = append(set.rules, &r)
+	}
+	return set, nil
+}
+
+// SecurityFinding is a tool call matched by a security rule
+type SecurityFinding struct {
+	RuleID      string `json:"rule_id"`
+	Category    string `json:"category"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	ToolName    string `json:"tool_name"`
+	Command     string `json:"command,omitempty"`
+	FilePath    string `json:"file_path,omitempty"`
+	Status      string `json:"status"`
+	ProjectPath string `json:"project_path,omitempty"`
+	SessionID   string `json:"session_id"`
+	Timestamp   string `json:"timestamp"`
+	UUID        string `json:"uuid"`
+	UserPrompt  string `json:"user_prompt,omitempty"`
+}
+
+// maxPromptLength caps the user prompt reported with each finding
+const maxPromptLength = 300
+
+// AuditSecurity classifies the tool calls of a session with rules. Each
+// finding carries the user prompt that preceded the call; a call matched by
+// several rules yields one finding per rule.
+func AuditSecurity(entries []parser.SessionEntry, rules *SecurityRules) []SecurityFinding {
+	auditor := NewSecurityAuditor(rules)
+	for _, entry := range entries {
+		auditor.Add(entry)
+	}
+	return auditor.Findings()
+}
+
+// SecurityAuditor is AuditSecurity for sessions read one entry at a time, so
+// a scan need not hold every entry of a file
+type SecurityAuditor struct {
+	rules    *SecurityRules
+	failed   map[string]bool // tool_use IDs whose result was an error
+	prompt   string          // latest user prompt
+	findings []SecurityFinding
+	useIDs   []string // tool_use ID of each finding, for its status
+}
+
+// NewSecurityAuditor returns an auditor classifying tool calls with rules
+func NewSecurityAuditor(rules *SecurityRules) *SecurityAuditor {
+	return &SecurityAuditor{rules: rules, failed: make(map[string]bool)}
+}
+
+// Add audits the next entry of the session
+func (a *SecurityAuditor) Add(entry parser.SessionEntry) {
+	if entry.Message == nil {
+		return
+	}
+	for _, block := range entry.Message.Content {
+		if block.Type == "tool_result" && block.ToolResult != nil {
+			r := block.ToolResult
+			a.failed[r.ToolUseID] = r.IsError || r.Status == "error"
+		}
+	}
+	if entry.Type == "user" {
+		if text := promptText(entry.Message.Content); text != "" {
+			a.prompt = text
+		}
+		return
+	}
+	for _, block := range entry.Message.Content {
+		if block.Type != "tool_use" || block.ToolUse == nil {
+			continue
+		}
+		use := block.ToolUse
+		for _, rule := range a.rules.rules {
+			command, path, ok := rule.match(use.Name, use.Input)
+			if !ok {
+				continue
+			}
+			a.findings = append(a.findings, SecurityFinding{
+				RuleID:      rule.ID,
+				Category:    rule.Category,
+				Severity:    rule.Severity,
+				Description: rule.Description,
+				ToolName:    use.Name,
+				Command:     command,
+				FilePath:    path,
+				SessionID:   entry.SessionID,
+				Timestamp:   entry.Timestamp,
+				UUID:        entry.UUID,
+				UserPrompt:  a.prompt,
+			})
+			a.useIDs = append(a.useIDs, use.ID)
+		}
+	}
+}
+
+// Findings returns the findings of the entries added so far. A call's status
+// is only known once its result has been added.
+func (a *SecurityAuditor) Findings() []SecurityFinding {
+	findings := make([]SecurityFinding, len(a.findings))
+	for i, f := range a.findings {
+		f.Status = "success"
+		if a.failed[a.useIDs[i]] {
+			f.Status = "error"
+		}
+		findings[i] = f
+	}
+	return findings
+}
+
+// promptText returns the text a user typed, ignoring tool results
+func promptText(blocks []parser.ContentBlock) string {
+	var parts []string
+	for _, block := range blocks {
+		if block.Type == "text" && strings.TrimSpace(block.Text) != "" {
+			parts = append(parts, strings.TrimSpace(block.Text))
+		}
+	}
+	text := strings.Join(parts, "\n")
+	if len(text) > maxPromptLength {
+		cut := maxPromptLength
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + "..."
+	}
+	return text
+}
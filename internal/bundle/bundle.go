@@ -0,0 +1,243 @@
+// Package bundle packs session files into portable tar.gz bundles and imports
+// them as read-only session roots.
+//
+// A bundle holds a manifest.json entry followed by the session files, stored
+// as <project directory>/<session file> exactly as they are on disk (archived
+// sessions stay compressed). Importing a bundle extracts it into its own
+// directory with the same layout as the Claude Code projects root, so the
+// session locator can search it next to the local projects.
+package bundle
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/query"
+	"github.com/yaleh/meta-cc/internal/sessionio"
+	"github.com/yaleh/meta-cc/internal/version"
+)
+
+// ManifestName is the manifest entry of a bundle and of an imported bundle directory
+const ManifestName = "manifest.json"
+
+// manifestVersion is bumped whenever Manifest changes incompatibly
+const manifestVersion = 1
+
+// maxManifestSize bounds the manifest read from an untrusted bundle
+const maxManifestSize = 16 << 20
+
+// Session describes one session file in a bundle
+type Session struct {
+	SessionID   string   `json:"session_id"`
+	Project     string   `json:"project"` // project directory name (path hash)
+	ProjectPath string   `json:"project_path,omitempty"`
+	File        string   `json:"file"` // entry name inside the bundle
+	SizeBytes   int64    `json:"size_bytes"`
+	SHA256      string   `json:"sha256"`
+	StartTime   string   `json:"start_time,omitempty"`
+	EndTime     string   `json:"end_time,omitempty"`
+	Versions    []string `json:"versions,omitempty"`
+	GitBranches []string `json:"git_branches,omitempty"`
+}
+
+// Manifest describes a bundle
+type Manifest struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"` // meta-cc version
+	StartTime string    `json:"start_time,omitempty"`
+	EndTime   string    `json:"end_time,omitempty"`
+	Sessions  []Session `json:"sessions"`
+}
+
+// DefaultFileName names a bundle created at t
+func DefaultFileName(t time.Time) string {
+	return "meta-cc-bundle-" + t.UTC().Format("20060102-150405") + ".tar.gz"
+}
+
+// ExportFile writes a bundle of files to path, replacing it atomically
+func ExportFile(path, name string, files []string) (*Manifest, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".bundle-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle: %w", mcerrors.ErrFileIO)
+	}
+	defer os.Remove(tmp.Name())
+
+	manifest, err := Export(tmp, name, files)
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write bundle: %w", mcerrors.ErrFileIO)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to write bundle %s: %w", path, mcerrors.ErrFileIO)
+	}
+	return manifest, nil
+}
+
+// Export writes a bundle of session files to w and returns its manifest.
+// Sessions still being written are bundled as they were when Export started.
+func Export(w io.Writer, name string, files []string) (*Manifest, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no sessions to export: %w", mcerrors.ErrInvalidInput)
+	}
+
+	manifest := &Manifest{
+		Version:   manifestVersion,
+		Name:      name,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		CreatedBy: "meta-cc " + version.String(),
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	for _, path := range files {
+		session, err := describe(path)
+		if err != nil {
+			return nil, err
+		}
+		if seen[session.File] {
+			continue
+		}
+		seen[session.File] = true
+		manifest.add(session)
+		paths = append(paths, path)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewWriter(w)
+	zw := gzip.NewWriter(buffered)
+	tw := tar.NewWriter(zw)
+	if err := writeEntry(tw, ManifestName, bytes.NewReader(data), int64(len(data)), manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+	for i, path := range paths {
+		if err := copySession(tw, path, manifest.Sessions[i]); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range []io.Closer{tw, zw} {
+		if err := c.Close(); err != nil {
+			return nil, fmt.Errorf("failed to write bundle: %w", mcerrors.ErrFileIO)
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", mcerrors.ErrFileIO)
+	}
+	return manifest, nil
+}
+
+// add appends a session and widens the manifest's time range
+func (m *Manifest) add(s Session) {
+	m.Sessions = append(m.Sessions, s)
+	if s.StartTime != "" && (m.StartTime == "" || s.StartTime < m.StartTime) {
+		m.StartTime = s.StartTime
+	}
+	if s.EndTime > m.EndTime {
+		m.EndTime = s.EndTime
+	}
+}
+
+// describe reads the manifest entry of a session file
+func describe(path string) (Session, error) {
+	if !sessionio.IsSessionFile(path) {
+		return Session{}, fmt.Errorf("not a session file: %s: %w", path, mcerrors.ErrInvalidInput)
+	}
+	header, err := query.ScanSessionHeader(path)
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to read session %s: %w", path, mcerrors.ErrFileIO)
+	}
+	size, sum, err := checksum(path)
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to read session %s: %w", path, mcerrors.ErrFileIO)
+	}
+
+	project := filepath.Base(filepath.Dir(path))
+	return Session{
+		SessionID:   header.SessionID,
+		Project:     project,
+		ProjectPath: header.ProjectPath,
+		File:        project + "/" + filepath.Base(path),
+		SizeBytes:   size,
+		SHA256:      sum,
+		StartTime:   header.StartTime,
+		EndTime:     header.EndTime,
+		Versions:    header.Versions,
+		GitBranches: header.GitBranches,
+	}, nil
+}
+
+// checksum returns the size and SHA-256 of a file
+func checksum(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copySession writes the bundled prefix of a session file, checking that it
+// did not change since it was described
+func copySession(tw *tar.Writer, path string, s Session) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read session %s: %w", path, mcerrors.ErrFileIO)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read session %s: %w", path, mcerrors.ErrFileIO)
+	}
+
+	h := sha256.New()
+	r := io.TeeReader(io.LimitReader(f, s.SizeBytes), h)
+	if err := writeEntry(tw, s.File, r, s.SizeBytes, info.ModTime()); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != s.SHA256 {
+		return fmt.Errorf("session %s changed while exporting: %w", path, mcerrors.ErrFileIO)
+	}
+	return nil
+}
+
+// writeEntry writes one regular file entry
+func writeEntry(tw *tar.Writer, name string, r io.Reader, size int64, modTime time.Time) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write bundle entry %s: %w", name, mcerrors.ErrFileIO)
+	}
+	if _, err := io.CopyN(tw, r, size); err != nil {
+		return fmt.Errorf("failed to write bundle entry %s: %w", name, mcerrors.ErrFileIO)
+	}
+	return nil
+}
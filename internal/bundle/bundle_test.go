@@ -0,0 +1,195 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/sessionio"
+)
+
+const sessionData = `{"type":"user","cwd":"/work/app","gitBranch":"main","version":"1.0.0","timestamp":"2025-10-02T10:00:00Z","message":{"role":"user","content":"Fix the build"}}
+{"type":"assistant","timestamp":"2025-10-02T10:05:00Z","message":{"id":"m1","role":"assistant","content":[]}}
+`
+
+const otherData = `{"type":"user","cwd":"/work/app","version":"1.1.0","timestamp":"2025-10-03T09:00:00Z","message":{"role":"user","content":"Add tests"}}
+`
+
+// testImportLimit is the total session size tests allow a bundle to import
+const testImportLimit = 1 << 20
+
+// setupSessions writes two sessions of one project, the older one archived,
+// and returns their paths
+func setupSessions(t *testing.T) []string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "-work-app")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	old := filepath.Join(dir, "s-old.jsonl")
+	if err := os.WriteFile(old, []byte(sessionData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldTime := time.Now().Add(-72 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(old, oldTime, oldTime); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessionio.ArchiveFile(old); err != nil {
+		t.Fatal(err)
+	}
+
+	recent := filepath.Join(dir, "s-new.jsonl")
+	if err := os.WriteFile(recent, []byte(otherData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return []string{recent, old + ".gz"}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	files := setupSessions(t)
+	bundlePath := filepath.Join(t.TempDir(), "b.tar.gz")
+
+	manifest, err := ExportFile(bundlePath, "failing build", append(files, files[0]))
+	if err != nil {
+		t.Fatalf("ExportFile failed: %v", err)
+	}
+	if len(manifest.Sessions) != 2 {
+		t.Fatalf("expected duplicates to be dropped, got %d sessions", len(manifest.Sessions))
+	}
+	s := manifest.Sessions[1]
+	if s.SessionID != "s-old" || s.Project != "-work-app" || s.File != "-work-app/s-old.jsonl.gz" || s.ProjectPath != "/work/app" {
+		t.Errorf("unexpected session entry: %+v", s)
+	}
+	if s.StartTime != "2025-10-02T10:00:00Z" || len(s.Versions) != 1 || s.Versions[0] != "1.0.0" || len(s.SHA256) != 64 {
+		t.Errorf("unexpected session metadata: %+v", s)
+	}
+	if manifest.Name != "failing build" || manifest.StartTime != "2025-10-02T10:00:00Z" || manifest.EndTime != "2025-10-03T09:00:00Z" {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+
+	bundlesDir := t.TempDir()
+	imported, err := Import(bundlePath, bundlesDir, testImportLimit)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if imported.Existing || len(imported.ID) != 12 {
+		t.Errorf("unexpected import: %+v", imported)
+	}
+	for _, src := range files {
+		dst := filepath.Join(imported.Dir, "-work-app", filepath.Base(src))
+		want, _ := os.ReadFile(src)
+		got, err := os.ReadFile(dst)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s differs from %s: %v", dst, src, err)
+		}
+		srcInfo, _ := os.Stat(src)
+		dstInfo, err := os.Stat(dst)
+		if err != nil || !dstInfo.ModTime().Equal(srcInfo.ModTime()) || dstInfo.Mode().Perm()&0222 != 0 {
+			t.Errorf("%s: want read-only copy with mtime %v, got %v", dst, srcInfo.ModTime(), dstInfo)
+		}
+	}
+
+	// Importing the same bundle again is a no-op
+	again, err := Import(bundlePath, bundlesDir, testImportLimit)
+	if err != nil || !again.Existing || again.Dir != imported.Dir {
+		t.Errorf("re-import: got %+v, %v", again, err)
+	}
+
+	bundles, err := ListImported(bundlesDir)
+	if err != nil || len(bundles) != 1 || bundles[0].ID != imported.ID || bundles[0].Manifest.Name != "failing build" {
+		t.Errorf("ListImported: got %+v, %v", bundles, err)
+	}
+}
+
+func TestExportRejectsNonSessionFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Export(&bytes.Buffer{}, "", []string{path}); !errors.Is(err, mcerrors.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+	if _, err := Export(&bytes.Buffer{}, "", nil); !errors.Is(err, mcerrors.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for no files, got %v", err)
+	}
+}
+
+// writeBundle writes a bundle with the given manifest and entries
+func writeBundle(t *testing.T, manifest Manifest, entries map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, content []byte) {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(content)), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(ManifestName, data)
+	for name, content := range entries {
+		write(name, []byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "bad.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportRejectsInvalidBundles(t *testing.T) {
+	checksum := "0000000000000000000000000000000000000000000000000000000000000000"
+	session := func(project, file string) Manifest {
+		return Manifest{Version: manifestVersion, Sessions: []Session{{
+			SessionID: "s", Project: project, File: file, SizeBytes: 2, SHA256: checksum,
+		}}}
+	}
+	oversized := session("-p", "-p/s.jsonl")
+	oversized.Sessions[0].SizeBytes = testImportLimit + 1
+
+	tests := map[string]string{
+		"checksum mismatch": writeBundle(t, session("-p", "-p/s.jsonl"), map[string]string{"-p/s.jsonl": "{}"}),
+		"path traversal":    writeBundle(t, session("..", "../s.jsonl"), map[string]string{"../s.jsonl": "{}"}),
+		"not a session":     writeBundle(t, session("-p", "-p/run.sh"), map[string]string{"-p/run.sh": "{}"}),
+		"missing entry":     writeBundle(t, session("-p", "-p/s.jsonl"), nil),
+		"unlisted entry":    writeBundle(t, session("-p", "-p/s.jsonl"), map[string]string{"-p/other.jsonl": "{}"}),
+		"no sessions":       writeBundle(t, Manifest{Version: manifestVersion}, nil),
+		"over size limit":   writeBundle(t, oversized, map[string]string{"-p/s.jsonl": "{}"}),
+	}
+	for name, path := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if _, err := Import(path, dir, testImportLimit); !errors.Is(err, mcerrors.ErrInvalidInput) {
+				t.Errorf("expected ErrInvalidInput, got %v", err)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("failed import left %d entries behind", len(entries))
+			}
+		})
+	}
+
+	if _, err := Import(filepath.Join(t.TempDir(), "missing.tar.gz"), t.TempDir(), testImportLimit); !errors.Is(err, mcerrors.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
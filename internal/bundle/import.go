@@ -0,0 +1,246 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/sessionio"
+)
+
+// Imported is a bundle extracted into a bundles directory
+type Imported struct {
+	ID       string    `json:"id"`
+	Dir      string    `json:"dir"`
+	Existing bool      `json:"existing,omitempty"` // imported earlier; nothing was extracted
+	Manifest *Manifest `json:"manifest"`
+}
+
+// Import extracts the bundle at path into its own directory under dir, named
+// after a digest of its manifest, and returns it. Every session is checked
+// against the manifest before the directory appears. Bundles whose sessions
+// total more than maxBytes are rejected before anything is extracted.
+// Importing the same bundle again returns the existing import.
+func Import(path, dir string, maxBytes int64) (*Imported, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("bundle %s: %w", path, mcerrors.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to open bundle %s: %w", path, mcerrors.ErrFileIO)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, invalid("not a gzip file")
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != ManifestName || header.Size > maxManifestSize {
+		return nil, invalid("first entry must be " + ManifestName)
+	}
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, invalid("truncated manifest")
+	}
+	manifest, err := parseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	imported := &Imported{ID: hex.EncodeToString(sum[:6]), Manifest: manifest}
+	imported.Dir = filepath.Join(dir, imported.ID)
+	if _, err := os.Stat(filepath.Join(imported.Dir, ManifestName)); err == nil {
+		imported.Existing = true
+		return imported, nil
+	}
+
+	// Entry sizes must match the manifest (see extract), so its total bounds
+	// what is written
+	var total int64
+	for _, s := range manifest.Sessions {
+		total += s.SizeBytes
+	}
+	if total > maxBytes {
+		return nil, fmt.Errorf("bundle %s holds %d bytes of sessions, over the import limit of %d bytes: %w",
+			path, total, maxBytes, mcerrors.ErrInvalidInput)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create bundles directory %s: %w", dir, mcerrors.ErrFileIO)
+	}
+	// Extract next to the final directory so that locators never see a partial import
+	tmp, err := os.MkdirTemp(dir, ".import-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundles directory %s: %w", dir, mcerrors.ErrFileIO)
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := extract(tr, manifest, tmp); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, ManifestName), data, 0444); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", mcerrors.ErrFileIO)
+	}
+	if err := os.Rename(tmp, imported.Dir); err != nil {
+		return nil, fmt.Errorf("failed to import bundle into %s: %w", imported.Dir, mcerrors.ErrFileIO)
+	}
+	return imported, nil
+}
+
+// ListImported returns the bundles imported into dir, oldest first
+func ListImported(dir string) ([]Imported, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundles directory %s: %w", dir, mcerrors.ErrFileIO)
+	}
+
+	var bundles []Imported
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		bundleDir := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(filepath.Join(bundleDir, ManifestName))
+		if err != nil {
+			continue
+		}
+		manifest, err := parseManifest(data)
+		if err != nil {
+			continue
+		}
+		bundles = append(bundles, Imported{ID: entry.Name(), Dir: bundleDir, Manifest: manifest})
+	}
+	sort.SliceStable(bundles, func(i, j int) bool {
+		return bundles[i].Manifest.CreatedAt.Before(bundles[j].Manifest.CreatedAt)
+	})
+	return bundles, nil
+}
+
+// parseManifest decodes and validates a bundle manifest. Entry names come
+// from another machine and must not escape the import directory.
+func parseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, invalid("malformed manifest")
+	}
+	if m.Version != manifestVersion {
+		return nil, invalid(fmt.Sprintf("unsupported manifest version %d", m.Version))
+	}
+	if len(m.Sessions) == 0 {
+		return nil, invalid("manifest lists no sessions")
+	}
+
+	seen := make(map[string]bool, len(m.Sessions))
+	for _, s := range m.Sessions {
+		project, file, ok := strings.Cut(s.File, "/")
+		if !ok || project != s.Project || !safeName(project) || !safeName(file) || !sessionio.IsSessionFile(file) {
+			return nil, invalid(fmt.Sprintf("bad session entry %q", s.File))
+		}
+		if seen[s.File] {
+			return nil, invalid(fmt.Sprintf("duplicate session entry %q", s.File))
+		}
+		if s.SizeBytes < 0 || len(s.SHA256) != sha256.Size*2 {
+			return nil, invalid(fmt.Sprintf("bad size or checksum for %q", s.File))
+		}
+		seen[s.File] = true
+	}
+	return &m, nil
+}
+
+// safeName reports whether name is a single, non-hidden path element
+func safeName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\:`) && filepath.Base(name) == name
+}
+
+// extract writes the session entries listed by the manifest into dir
+func extract(tr *tar.Reader, manifest *Manifest, dir string) error {
+	pending := make(map[string]Session, len(manifest.Sessions))
+	for _, s := range manifest.Sessions {
+		pending[s.File] = s
+	}
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return invalid("truncated archive")
+		}
+		s, ok := pending[header.Name]
+		if !ok || header.Typeflag != tar.TypeReg {
+			return invalid(fmt.Sprintf("unexpected entry %q", header.Name))
+		}
+		if header.Size != s.SizeBytes {
+			return invalid(fmt.Sprintf("size mismatch for %q", header.Name))
+		}
+		if err := extractSession(tr, s, header, dir); err != nil {
+			return err
+		}
+		delete(pending, header.Name)
+	}
+
+	if len(pending) > 0 {
+		missing := make([]string, 0, len(pending))
+		for name := range pending {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return invalid(fmt.Sprintf("missing entries %v", missing))
+	}
+	return nil
+}
+
+// extractSession writes one session file read-only, verifying its checksum
+func extractSession(r io.Reader, s Session, header *tar.Header, dir string) error {
+	projectDir := filepath.Join(dir, s.Project)
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", projectDir, mcerrors.ErrFileIO)
+	}
+	path := filepath.Join(dir, filepath.FromSlash(s.File))
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0444)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, mcerrors.ErrFileIO)
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), io.LimitReader(r, s.SizeBytes))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return invalid(fmt.Sprintf("truncated entry %q", s.File))
+	}
+	if hex.EncodeToString(h.Sum(nil)) != s.SHA256 {
+		return invalid(fmt.Sprintf("checksum mismatch for %q", s.File))
+	}
+	// Keep the original modification time: locators pick the newest session by it
+	if err := os.Chtimes(path, header.ModTime, header.ModTime); err != nil {
+		return fmt.Errorf("failed to set times of %s: %w", path, mcerrors.ErrFileIO)
+	}
+	return nil
+}
+
+// invalid reports a malformed bundle
+func invalid(reason string) error {
+	return fmt.Errorf("invalid session bundle: %s: %w", reason, mcerrors.ErrInvalidInput)
+}
@@ -7,7 +7,10 @@ package config
 import (
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -25,6 +28,21 @@ type Config struct {
 
 	// Session holds session information from Claude Code
 	Session SessionConfig
+
+	// Metrics holds the optional observability HTTP listener configuration
+	Metrics MetricsConfig
+
+	// Bundle holds session bundle import configuration
+	Bundle BundleConfig
+
+	// Access holds the file access policy of tools that take file paths
+	Access AccessConfig
+
+	// Redaction holds the secret and PII redaction applied to tool responses
+	Redaction RedactionConfig
+
+	// Security holds the rules of the security audit
+	Security SecurityConfig
 }
 
 // LogConfig holds logging-related configuration.
@@ -55,6 +73,46 @@ type OutputConfig struct {
 	// InlineThreshold sets the byte threshold for switching to file_ref mode.
 	// Default: 8192 (8KB)
 	InlineThreshold int
+
+	// MaxTokens is the token budget of a response; larger results are
+	// summarized instead of returned inline or as file_ref.
+	// Default: 0 (no budget)
+	MaxTokens int
+
+	// ChunkThreshold is the size in bytes above which file_ref outputs are
+	// split into chunk files with a manifest; each chunk stays near it.
+	// Default: 1048576 (1MB); 0 disables chunking
+	ChunkThreshold int
+
+	// Dir is the private directory file_ref outputs are written to.
+	// Default: "" (meta-cc/outputs in the user cache dir, see OutputDir)
+	Dir string
+
+	// QuotaMB caps the size of Dir; least recently used outputs are evicted.
+	// Default: 256
+	QuotaMB int
+
+	// MaxAgeDays is the age after which outputs are removed automatically.
+	// Default: 7
+	MaxAgeDays int
+}
+
+// OutputDir returns the directory for file_ref outputs: Dir when set,
+// otherwise meta-cc/outputs in the user cache dir (e.g. ~/.cache/meta-cc/outputs),
+// falling back to a per-user directory in the system temp dir.
+func (c OutputConfig) OutputDir() string {
+	if c.Dir != "" {
+		return filepath.Clean(c.Dir)
+	}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(cacheDir, "meta-cc", "outputs")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("meta-cc-outputs-%d", os.Getuid()))
+}
+
+// QuotaBytes returns QuotaMB in bytes.
+func (c OutputConfig) QuotaBytes() int64 {
+	return int64(c.QuotaMB) << 20
 }
 
 // CapabilityConfig holds capability source configuration.
@@ -64,6 +122,82 @@ type CapabilityConfig struct {
 	Sources string
 }
 
+// MetricsConfig holds observability listener configuration.
+type MetricsConfig struct {
+	// Addr is the host:port for the /metrics, /healthz and pprof listener.
+	// Only loopback hosts are accepted so profiling data never leaves the machine.
+	// Default: "" (listener disabled)
+	Addr string
+}
+
+// Enabled reports whether the metrics listener should be started.
+func (c MetricsConfig) Enabled() bool {
+	return c.Addr != ""
+}
+
+// BundleConfig holds session bundle import configuration.
+type BundleConfig struct {
+	// MaxImportMB caps the total size of the sessions one bundle may
+	// extract; larger bundles are rejected before anything is written.
+	// Default: 1024
+	MaxImportMB int
+}
+
+// MaxImportBytes returns MaxImportMB in bytes.
+func (c BundleConfig) MaxImportBytes() int64 {
+	return int64(c.MaxImportMB) << 20
+}
+
+// AccessConfig holds the file access policy of tools that take file paths.
+type AccessConfig struct {
+	// ExtraRoots lists directories, separated by the OS path list separator,
+	// that tools may read besides the Claude projects root and imported bundles.
+	// Default: "" (none)
+	ExtraRoots string
+}
+
+// ExtraRootsSlice returns the extra roots as a slice of paths.
+// Returns empty slice if no roots are configured.
+func (c AccessConfig) ExtraRootsSlice() []string {
+	if c.ExtraRoots == "" {
+		return nil
+	}
+	return filepath.SplitList(c.ExtraRoots)
+}
+
+// RedactionConfig holds the redaction applied to every tool response.
+type RedactionConfig struct {
+	// Enabled turns redaction on or off.
+	// Default: true
+	Enabled bool
+
+	// Detectors lists the built-in detectors to run, comma-separated.
+	// Valid values: secrets, entropy, email
+	// Default: "secrets,entropy,email"
+	Detectors string
+
+	// Patterns holds user-defined regular expressions, one per line.
+	// Default: "" (none)
+	Patterns string
+}
+
+// DetectorsSlice returns the enabled detector names.
+func (c RedactionConfig) DetectorsSlice() []string {
+	return splitList(c.Detectors, ",")
+}
+
+// PatternsSlice returns the user-defined patterns.
+func (c RedactionConfig) PatternsSlice() []string {
+	return splitList(c.Patterns, "\n")
+}
+
+// SecurityConfig holds the rules of the security audit.
+type SecurityConfig struct {
+	// RulesFile is a local YAML rule file merged over the built-in rules.
+	// Default: "" (built-in rules only)
+	RulesFile string
+}
+
 // SessionConfig holds session information from Claude Code.
 // Note: Session information is no longer loaded from environment variables.
 // This structure is retained for future use and backward compatibility.
@@ -87,6 +221,11 @@ func Load() (*Config, error) {
 		Output:     loadOutputConfig(),
 		Capability: loadCapabilityConfig(),
 		Session:    loadSessionConfig(),
+		Metrics:    loadMetricsConfig(),
+		Bundle:     loadBundleConfig(),
+		Access:     loadAccessConfig(),
+		Redaction:  loadRedactionConfig(),
+		Security:   loadSecurityConfig(),
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -117,6 +256,78 @@ func (c *Config) Validate() error {
 			c.Output.InlineThreshold)
 	}
 
+	if c.Output.MaxTokens < 0 {
+		return fmt.Errorf("META_CC_MAX_TOKENS must not be negative, got: %d", c.Output.MaxTokens)
+	}
+
+	if c.Output.ChunkThreshold < 0 {
+		return fmt.Errorf("META_CC_CHUNK_THRESHOLD must not be negative, got: %d", c.Output.ChunkThreshold)
+	}
+
+	// Validate output storage
+	if c.Output.Dir != "" && !filepath.IsAbs(c.Output.Dir) {
+		return fmt.Errorf("invalid META_CC_OUTPUT_DIR: %s is not an absolute path", c.Output.Dir)
+	}
+	if c.Output.QuotaMB <= 0 {
+		return fmt.Errorf("META_CC_OUTPUT_QUOTA_MB must be positive, got: %d", c.Output.QuotaMB)
+	}
+	if c.Output.MaxAgeDays <= 0 {
+		return fmt.Errorf("META_CC_OUTPUT_MAX_AGE_DAYS must be positive, got: %d", c.Output.MaxAgeDays)
+	}
+
+	// Validate bundle import limit (must be positive)
+	if c.Bundle.MaxImportMB <= 0 {
+		return fmt.Errorf("META_CC_BUNDLE_MAX_IMPORT_MB must be positive, got: %d",
+			c.Bundle.MaxImportMB)
+	}
+
+	// Validate extra roots (absolute paths only: relative ones depend on the cwd)
+	for _, root := range c.Access.ExtraRootsSlice() {
+		if !filepath.IsAbs(root) {
+			return fmt.Errorf("invalid META_CC_ALLOWED_ROOTS: %s is not an absolute path", root)
+		}
+	}
+
+	// Validate redaction detectors and patterns
+	validDetectors := []string{"secrets", "entropy", "email"}
+	for _, name := range c.Redaction.DetectorsSlice() {
+		if !contains(validDetectors, name) {
+			return fmt.Errorf("invalid META_CC_REDACTION_DETECTORS: %s (must be one of: %v)",
+				name, validDetectors)
+		}
+	}
+	for _, expr := range c.Redaction.PatternsSlice() {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid META_CC_REDACTION_PATTERNS: %w", err)
+		}
+	}
+
+	// Validate metrics listener address (loopback only)
+	if c.Metrics.Enabled() {
+		if err := validateLoopbackAddr(c.Metrics.Addr); err != nil {
+			return fmt.Errorf("invalid META_CC_METRICS_ADDR: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateLoopbackAddr checks that addr is a host:port bound to a loopback interface.
+func validateLoopbackAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if port == "" {
+		return fmt.Errorf("%s: missing port", addr)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%s: host must be localhost or a loopback address", addr)
+	}
 	return nil
 }
 
@@ -146,6 +357,11 @@ func loadOutputConfig() OutputConfig {
 	return OutputConfig{
 		Mode:            getEnvOrDefault("META_CC_OUTPUT_MODE", "auto"),
 		InlineThreshold: getEnvInt("META_CC_INLINE_THRESHOLD", 8192),
+		MaxTokens:       getEnvInt("META_CC_MAX_TOKENS", 0),
+		ChunkThreshold:  getEnvInt("META_CC_CHUNK_THRESHOLD", 1<<20),
+		Dir:             os.Getenv("META_CC_OUTPUT_DIR"),
+		QuotaMB:         getEnvInt("META_CC_OUTPUT_QUOTA_MB", 256),
+		MaxAgeDays:      getEnvInt("META_CC_OUTPUT_MAX_AGE_DAYS", 7),
 	}
 }
 
@@ -156,6 +372,43 @@ func loadCapabilityConfig() CapabilityConfig {
 	}
 }
 
+// loadMetricsConfig loads metrics listener configuration from environment.
+func loadMetricsConfig() MetricsConfig {
+	return MetricsConfig{
+		Addr: os.Getenv("META_CC_METRICS_ADDR"),
+	}
+}
+
+// loadBundleConfig loads bundle configuration from environment.
+func loadBundleConfig() BundleConfig {
+	return BundleConfig{
+		MaxImportMB: getEnvInt("META_CC_BUNDLE_MAX_IMPORT_MB", 1024),
+	}
+}
+
+// loadAccessConfig loads the file access policy from environment.
+func loadAccessConfig() AccessConfig {
+	return AccessConfig{
+		ExtraRoots: os.Getenv("META_CC_ALLOWED_ROOTS"),
+	}
+}
+
+// loadRedactionConfig loads redaction configuration from environment.
+func loadRedactionConfig() RedactionConfig {
+	return RedactionConfig{
+		Enabled:   getEnvBool("META_CC_REDACTION", true),
+		Detectors: getEnvOrDefault("META_CC_REDACTION_DETECTORS", "secrets,entropy,email"),
+		Patterns:  os.Getenv("META_CC_REDACTION_PATTERNS"),
+	}
+}
+
+// loadSecurityConfig loads security audit configuration from environment.
+func loadSecurityConfig() SecurityConfig {
+	return SecurityConfig{
+		RulesFile: os.Getenv("META_CC_SECURITY_RULES"),
+	}
+}
+
 // loadSessionConfig loads session configuration from environment.
 // Note: Environment variables are no longer used. This returns an empty config.
 func loadSessionConfig() SessionConfig {
@@ -206,6 +459,17 @@ func parseLogLevel(levelStr string) slog.Level {
 	}
 }
 
+// splitList splits s on sep, trimming items and dropping empty ones.
+func splitList(s, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
@@ -3,6 +3,7 @@ package config
 import (
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -68,6 +69,10 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.Output.InlineThreshold != 8192 {
 		t.Errorf("default inline threshold should be 8192, got %d", cfg.Output.InlineThreshold)
 	}
+
+	if cfg.Bundle.MaxImportBytes() != 1024<<20 {
+		t.Errorf("default bundle import limit should be 1024MB, got %d bytes", cfg.Bundle.MaxImportBytes())
+	}
 }
 
 func TestValidateInvalidLogFormat(t *testing.T) {
@@ -112,6 +117,203 @@ func TestValidateInvalidInlineThreshold(t *testing.T) {
 	}
 }
 
+func TestValidateInvalidBundleMaxImport(t *testing.T) {
+	clearTestEnv(t)
+	os.Setenv("META_CC_BUNDLE_MAX_IMPORT_MB", "0")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected validation error for invalid bundle import limit")
+	}
+
+	if !strings.Contains(err.Error(), "META_CC_BUNDLE_MAX_IMPORT_MB must be positive") {
+		t.Errorf("error should mention positive import limit: %v", err)
+	}
+}
+
+func TestMetricsAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{"", false},
+		{"127.0.0.1:9464", false},
+		{"localhost:9464", false},
+		{"[::1]:9464", false},
+		{"0.0.0.0:9464", true},
+		{":9464", true},
+		{"192.168.1.10:9464", true},
+		{"127.0.0.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			clearTestEnv(t)
+			os.Setenv("META_CC_METRICS_ADDR", tt.addr)
+			defer os.Unsetenv("META_CC_METRICS_ADDR")
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "invalid META_CC_METRICS_ADDR") {
+					t.Errorf("expected metrics addr error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Metrics.Enabled() != (tt.addr != "") {
+				t.Errorf("Enabled() = %v for addr %q", cfg.Metrics.Enabled(), tt.addr)
+			}
+		})
+	}
+}
+
+func TestAllowedRoots(t *testing.T) {
+	clearTestEnv(t)
+	defer os.Unsetenv("META_CC_ALLOWED_ROOTS")
+
+	roots := []string{"/srv/sessions", "/mnt/archive"}
+	os.Setenv("META_CC_ALLOWED_ROOTS", strings.Join(roots, string(filepath.ListSeparator)))
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Access.ExtraRootsSlice(); len(got) != 2 || got[0] != roots[0] || got[1] != roots[1] {
+		t.Errorf("ExtraRootsSlice() = %v, want %v", got, roots)
+	}
+
+	os.Setenv("META_CC_ALLOWED_ROOTS", "relative/dir")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "invalid META_CC_ALLOWED_ROOTS") {
+		t.Errorf("expected allowed roots error, got %v", err)
+	}
+}
+
+func TestOutputStorageConfig(t *testing.T) {
+	clearTestEnv(t)
+	defer clearTestEnv(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Output.QuotaBytes() != 256<<20 || cfg.Output.MaxAgeDays != 7 {
+		t.Errorf("unexpected default output storage: %+v", cfg.Output)
+	}
+	if dir := cfg.Output.OutputDir(); !filepath.IsAbs(dir) {
+		t.Errorf("OutputDir() = %s, want an absolute path", dir)
+	}
+
+	os.Setenv("META_CC_OUTPUT_DIR", "/srv/meta-cc/outputs/")
+	os.Setenv("META_CC_OUTPUT_QUOTA_MB", "16")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Output.OutputDir() != "/srv/meta-cc/outputs" || cfg.Output.QuotaBytes() != 16<<20 {
+		t.Errorf("unexpected output storage: %+v", cfg.Output)
+	}
+
+	for env, value := range map[string]string{
+		"META_CC_OUTPUT_DIR":          "outputs",
+		"META_CC_OUTPUT_QUOTA_MB":     "0",
+		"META_CC_OUTPUT_MAX_AGE_DAYS": "-1",
+	} {
+		clearTestEnv(t)
+		os.Setenv(env, value)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), env) {
+			t.Errorf("%s=%q: expected error, got %v", env, value, err)
+		}
+	}
+}
+
+func TestMaxTokensConfig(t *testing.T) {
+	clearTestEnv(t)
+	defer clearTestEnv(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Output.MaxTokens != 0 {
+		t.Errorf("expected no token budget by default, got %d", cfg.Output.MaxTokens)
+	}
+
+	os.Setenv("META_CC_MAX_TOKENS", "4000")
+	if cfg, err = Load(); err != nil || cfg.Output.MaxTokens != 4000 {
+		t.Errorf("expected MaxTokens 4000, got %+v (%v)", cfg, err)
+	}
+
+	os.Setenv("META_CC_MAX_TOKENS", "-1")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "META_CC_MAX_TOKENS") {
+		t.Errorf("expected META_CC_MAX_TOKENS error, got %v", err)
+	}
+}
+
+func TestChunkThresholdConfig(t *testing.T) {
+	clearTestEnv(t)
+	defer clearTestEnv(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Output.ChunkThreshold != 1<<20 {
+		t.Errorf("expected 1MB chunk threshold by default, got %d", cfg.Output.ChunkThreshold)
+	}
+
+	os.Setenv("META_CC_CHUNK_THRESHOLD", "0")
+	if cfg, err = Load(); err != nil || cfg.Output.ChunkThreshold != 0 {
+		t.Errorf("expected chunking disabled, got %+v (%v)", cfg, err)
+	}
+
+	os.Setenv("META_CC_CHUNK_THRESHOLD", "-1")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "META_CC_CHUNK_THRESHOLD") {
+		t.Errorf("expected META_CC_CHUNK_THRESHOLD error, got %v", err)
+	}
+}
+
+func TestRedactionConfig(t *testing.T) {
+	clearTestEnv(t)
+	defer clearTestEnv(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Redaction.Enabled || len(cfg.Redaction.DetectorsSlice()) != 3 || len(cfg.Redaction.PatternsSlice()) != 0 {
+		t.Errorf("unexpected default redaction config: %+v", cfg.Redaction)
+	}
+
+	os.Setenv("META_CC_REDACTION", "false")
+	os.Setenv("META_CC_REDACTION_DETECTORS", "secrets, email")
+	os.Setenv("META_CC_REDACTION_PATTERNS", "ACME-[0-9]+\ninternal\\.corp")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Redaction.Enabled {
+		t.Error("META_CC_REDACTION=false must disable redaction")
+	}
+	if got := cfg.Redaction.DetectorsSlice(); len(got) != 2 || got[1] != "email" {
+		t.Errorf("DetectorsSlice() = %v", got)
+	}
+	if got := cfg.Redaction.PatternsSlice(); len(got) != 2 || got[1] != `internal\.corp` {
+		t.Errorf("PatternsSlice() = %v", got)
+	}
+
+	for env, value := range map[string]string{
+		"META_CC_REDACTION_DETECTORS": "phone",
+		"META_CC_REDACTION_PATTERNS":  "(",
+	} {
+		clearTestEnv(t)
+		os.Setenv(env, value)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "invalid "+env) {
+			t.Errorf("%s=%q: expected error, got %v", env, value, err)
+		}
+	}
+}
+
 func TestLogLevelParsing(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -285,7 +487,19 @@ func clearTestEnv(t *testing.T) {
 		"META_CC_LOGGING_ENABLED",
 		"META_CC_OUTPUT_MODE",
 		"META_CC_INLINE_THRESHOLD",
+		"META_CC_MAX_TOKENS",
+		"META_CC_CHUNK_THRESHOLD",
+		"META_CC_OUTPUT_DIR",
+		"META_CC_OUTPUT_QUOTA_MB",
+		"META_CC_OUTPUT_MAX_AGE_DAYS",
 		"META_CC_CAPABILITY_SOURCES",
+		"META_CC_METRICS_ADDR",
+		"META_CC_BUNDLE_MAX_IMPORT_MB",
+		"META_CC_ALLOWED_ROOTS",
+		"META_CC_REDACTION",
+		"META_CC_REDACTION_DETECTORS",
+		"META_CC_REDACTION_PATTERNS",
+		"META_CC_SECURITY_RULES",
 		"LOG_LEVEL", // Deprecated fallback
 		"CC_SESSION_ID",
 		"CC_PROJECT_HASH",
@@ -0,0 +1,778 @@
+// Package index caches what meta-cc extracts from session files so that old
+// sessions, which never change, are decoded only once.
+//
+// Each session file gets one cache entry under the user cache directory,
+// keyed by its path and validated against its size and modification time.
+// When the active session grows, only the appended lines are decoded. Query
+// scans that read a whole file anyway build its entry from the same read (see
+// Recorder). Entries not used for MaxEntryAge, such as those of deleted
+// sessions, are pruned, as are the least recently used ones once the
+// directory grows past MaxDirBytes.
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yaleh/meta-cc/internal/parser"
+	"github.com/yaleh/meta-cc/internal/sessionio"
+)
+
+// EnvDir overrides the index directory. The value "off" disables the
+// on-disk index; indexes are then kept in process memory only.
+const EnvDir = "META_CC_INDEX_DIR"
+
+// formatVersion is bumped whenever FileIndex changes incompatibly
+const formatVersion = 2
+
+// Bounds of the decoded indexes an Index keeps in memory. The size of an
+// index is approximated by the bytes it was decoded from (see memoSize).
+const (
+	maxMemoFiles = 256
+	maxMemoBytes = 64 << 20
+	// memoRecordOverhead approximates the per-skeleton and per-call memory
+	// beyond the decoded bytes
+	memoRecordOverhead = 256
+)
+
+// tailGuardSize is how many indexed bytes are compared before appending, to
+// detect files that were rewritten rather than grown
+const tailGuardSize = 64
+
+// Limits of the stored entries, enforced at most every pruneInterval
+const (
+	MaxEntryAge   = 30 * 24 * time.Hour
+	MaxDirBytes   = 512 << 20
+	pruneInterval = time.Hour
+	// touchInterval is how stale an entry's modification time, which records
+	// its last use, may get before a load refreshes it
+	touchInterval = time.Hour
+)
+
+// CountedTokens are the literal texts whose lines FileIndex.Tokens counts.
+// Queries that only match lines containing one of them skip files the index
+// shows have none (see Lacks).
+var CountedTokens = []string{
+	`"tool_use"`,
+	`"tool_result"`,
+	`"is_error"`,
+	`"usage"`,
+	`"api_error"`,
+	`"messageId"`,
+	`"timestamp"`,
+}
+
+func init() {
+	// Tool inputs and usage maps hold arbitrary JSON values
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// FileIndex is the cached extraction of one session file
+type FileIndex struct {
+	Version int
+	Path    string
+	Size    int64 // file size when last indexed
+	ModTime int64 // file modification time (UnixNano) when last indexed
+	Offset  int64 // bytes decoded so far; appended data starts here
+	Tail    []byte
+
+	Lines       int            // lines read, including blank lines
+	Records     int            // non-blank lines
+	RecordTypes map[string]int // records per "type" field
+	Tokens      map[string]int // lines containing each of CountedTokens
+	StartTime   time.Time      // earliest RFC3339 timestamp
+	EndTime     time.Time      // latest RFC3339 timestamp
+	CWD         string         // first working directory recorded
+	ParseError  string         // first line that failed to decode, if any
+
+	// Skeletons are the user and assistant entries reduced to their metadata
+	// and the IDs and names of their tool_use blocks. They are not complete
+	// entries: text, thinking, tool inputs and tool results are dropped
+	// (inputs and results are kept in ToolCalls), so they only serve analyses
+	// of turns, timing and tool sequences.
+	Skeletons []parser.SessionEntry
+	// ToolCalls pairs each tool_use with its result, in file order
+	ToolCalls []parser.ToolCall
+
+	// callIndex maps tool_use IDs to positions in ToolCalls, so results
+	// appended later can be paired. It is not stored; see pairing.
+	callIndex map[string]int
+}
+
+// HasTimeRange reports whether any record carried a timestamp
+func (fi *FileIndex) HasTimeRange() bool {
+	return !fi.StartTime.IsZero()
+}
+
+// Lacks reports whether no line of the file contains token. It is false for
+// tokens not in CountedTokens, which are not counted.
+func (fi *FileIndex) Lacks(token string) bool {
+	return slices.Contains(CountedTokens, token) && fi.Tokens[token] == 0
+}
+
+// Err returns the decoding failure of the file in the form parser.ParseEntries
+// reports it, or nil
+func (fi *FileIndex) Err() error {
+	if fi.ParseError == "" {
+		return nil
+	}
+	return errors.New(fi.ParseError)
+}
+
+// Index stores FileIndex entries in a directory and keeps the most recently
+// used ones decoded in memory
+type Index struct {
+	dir string // empty: in-memory only
+
+	mu        sync.Mutex
+	memo      map[string]*memoEntry
+	memoBytes int64
+	tick      uint64
+	pruned    time.Time // last Prune of the directory
+}
+
+// memoEntry is a decoded index with its size and last use, for eviction
+type memoEntry struct {
+	fi   *FileIndex
+	size int64
+	used uint64
+}
+
+// shared holds the Default index of each directory, so its memory cache
+// lives as long as the process
+var (
+	sharedMu sync.Mutex
+	shared   = make(map[string]*Index)
+)
+
+// New returns an index stored in dir. An empty dir disables persistence.
+func New(dir string) *Index {
+	return &Index{dir: dir, memo: make(map[string]*memoEntry)}
+}
+
+// Default returns the process-wide index in META_CC_INDEX_DIR, or in the
+// meta-cc directory of the user cache dir (e.g. ~/.cache/meta-cc/index)
+func Default() *Index {
+	dir := defaultDir()
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	ix, ok := shared[dir]
+	if !ok {
+		ix = New(dir)
+		shared[dir] = ix
+	}
+	return ix
+}
+
+// defaultDir resolves the directory Default stores indexes in
+func defaultDir() string {
+	if dir, ok := os.LookupEnv(EnvDir); ok {
+		if dir == "off" {
+			return ""
+		}
+		return dir
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDir, "meta-cc", "index")
+}
+
+// Dir returns the directory entries are stored in ("" when in-memory only)
+func (ix *Index) Dir() string {
+	return ix.dir
+}
+
+// File returns the index of a session file, decoding only what changed
+// since it was last indexed. Failing to persist the index is not an error.
+// Returned indexes are shared between callers and must not be modified.
+func (ix *Index) File(path string) (*FileIndex, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat session file: %w", err)
+	}
+
+	fi := ix.recall(abs)
+	memoized := fi != nil
+	if !memoized {
+		fi = ix.load(abs)
+	}
+	if fi != nil && fi.Size == info.Size() && fi.ModTime == info.ModTime().UnixNano() {
+		ix.remember(fi)
+		return fi, nil
+	}
+
+	if sessionio.IsCompressed(abs) {
+		// Archived sessions are not appended to; any change means a rewrite
+		fi, err = ix.rebuild(abs)
+	} else {
+		fi, err = ix.extend(abs, fi, info.Size(), memoized)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fi.Size = info.Size()
+	fi.ModTime = info.ModTime().UnixNano()
+
+	ix.save(fi)
+	ix.remember(fi)
+	return fi, nil
+}
+
+// Lookup returns the index of a session file when a memoized or stored one
+// is up to date, without reading the file
+func (ix *Index) Lookup(path string) (*FileIndex, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, false
+	}
+	fi := ix.recall(abs)
+	if fi == nil {
+		fi = ix.load(abs)
+	}
+	if fi == nil || fi.Size != info.Size() || fi.ModTime != info.ModTime().UnixNano() {
+		return nil, false
+	}
+	ix.remember(fi)
+	return fi, true
+}
+
+// Recorder builds the index of a session file from the lines a caller reads
+// anyway, so the first scan of a file also indexes it
+type Recorder struct {
+	ix   *Index
+	fi   *FileIndex
+	info os.FileInfo
+}
+
+// Record returns a Recorder for a session file, or nil when its index is up
+// to date or the file cannot be read. A nil Recorder ignores every call.
+func (ix *Index) Record(path string) *Recorder {
+	if _, ok := ix.Lookup(path); ok {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil
+	}
+	return &Recorder{ix: ix, fi: newFileIndex(abs), info: info}
+}
+
+// Line indexes a non-blank line, trimmed of surrounding space; lineNum counts
+// every line of the file from 1. Lines must be passed in file order.
+func (r *Recorder) Line(lineNum int, line []byte) {
+	if r == nil {
+		return
+	}
+	r.fi.Lines = lineNum
+	r.fi.addLine(line)
+}
+
+// Done stores the index after every line was passed to Line. bytesRead is
+// the size of the data read, decompressed for archived sessions. Files that
+// changed during the scan, or whose last line may still be written, are left
+// for File to index.
+func (r *Recorder) Done(bytesRead int64) {
+	if r == nil {
+		return
+	}
+	info, err := os.Stat(r.fi.Path)
+	if err != nil || info.Size() != r.info.Size() || !info.ModTime().Equal(r.info.ModTime()) {
+		return
+	}
+	if !sessionio.IsCompressed(r.fi.Path) {
+		if bytesRead != info.Size() {
+			return
+		}
+		tail, err := readTail(r.fi.Path, info.Size())
+		if err != nil || len(tail) > 0 && tail[len(tail)-1] != '\n' {
+			return
+		}
+		r.fi.Tail = tail
+	}
+	r.fi.Offset = bytesRead
+	r.fi.Size = info.Size()
+	r.fi.ModTime = info.ModTime().UnixNano()
+
+	r.ix.save(r.fi)
+	r.ix.remember(r.fi)
+}
+
+// readTail returns the last tailGuardSize bytes of a plain file of size bytes
+func readTail(path string, size int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	tail := make([]byte, min(size, tailGuardSize))
+	if _, err := file.ReadAt(tail, size-int64(len(tail))); err != nil {
+		return nil, err
+	}
+	return tail, nil
+}
+
+// extend decodes what was appended to a plain session file since fi was
+// built, starting over when the file was rewritten
+func (ix *Index) extend(path string, fi *FileIndex, size int64, memoized bool) (*FileIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session file: %w", err)
+	}
+	defer file.Close()
+
+	switch {
+	case fi == nil || size < fi.Offset || !fi.tailMatches(file):
+		fi = newFileIndex(path)
+	case memoized:
+		// Other callers may still be reading the memoized index
+		fi = fi.clone()
+	}
+	if _, err := file.Seek(fi.Offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error reading session file: %w", err)
+	}
+	if err := fi.update(file); err != nil {
+		return nil, fmt.Errorf("error reading session file: %w", err)
+	}
+	return fi, nil
+}
+
+// rebuild decodes an archived session file from the start
+func (ix *Index) rebuild(path string) (*FileIndex, error) {
+	file, err := sessionio.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session file: %w", err)
+	}
+	defer file.Close()
+
+	fi := newFileIndex(path)
+	if err := fi.update(file); err != nil {
+		return nil, fmt.Errorf("error reading session file: %w", err)
+	}
+	return fi, nil
+}
+
+// recall returns the memoized index of a file, which may be out of date
+func (ix *Index) recall(path string) *FileIndex {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if entry, ok := ix.memo[path]; ok {
+		return entry.fi
+	}
+	return nil
+}
+
+// remember memoizes an index, evicting the least recently used ones while
+// the memo holds more than maxMemoFiles indexes or maxMemoBytes. Indexes
+// larger than maxMemoBytes are not memoized.
+func (ix *Index) remember(fi *FileIndex) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.tick++
+	if entry, ok := ix.memo[fi.Path]; ok {
+		ix.memoBytes -= entry.size
+		delete(ix.memo, fi.Path)
+	}
+	size := memoSize(fi)
+	if size > maxMemoBytes {
+		return
+	}
+	for len(ix.memo) > 0 && (len(ix.memo) >= maxMemoFiles || ix.memoBytes+size > maxMemoBytes) {
+		oldest := ""
+		for path, entry := range ix.memo {
+			if oldest == "" || entry.used < ix.memo[oldest].used {
+				oldest = path
+			}
+		}
+		ix.memoBytes -= ix.memo[oldest].size
+		delete(ix.memo, oldest)
+	}
+	ix.memo[fi.Path] = &memoEntry{fi: fi, size: size, used: ix.tick}
+	ix.memoBytes += size
+}
+
+// memoSize approximates the memory held by an index. Tool outputs dominate
+// it and are copied from the decoded data, so the bytes decoded bound it up
+// to per-record overhead.
+func memoSize(fi *FileIndex) int64 {
+	return fi.Offset + int64(len(fi.Skeletons)+len(fi.ToolCalls))*memoRecordOverhead
+}
+
+// Files indexes several session files in order
+func (ix *Index) Files(paths []string) ([]*FileIndex, error) {
+	indexes := make([]*FileIndex, 0, len(paths))
+	for _, path := range paths {
+		fi, err := ix.File(path)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, fi)
+	}
+	return indexes, nil
+}
+
+// entryPath returns where the index of a session file is stored
+func (ix *Index) entryPath(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(ix.dir, hex.EncodeToString(sum[:16])+".gob")
+}
+
+// load reads a stored index, returning nil when it is missing or unusable
+func (ix *Index) load(path string) *FileIndex {
+	if ix.dir == "" {
+		return nil
+	}
+	f, err := os.Open(ix.entryPath(path))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var fi FileIndex
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&fi); err != nil {
+		return nil
+	}
+	if fi.Version != formatVersion || fi.Path != path {
+		return nil
+	}
+	if fi.RecordTypes == nil {
+		fi.RecordTypes = make(map[string]int)
+	}
+	if fi.Tokens == nil {
+		fi.Tokens = make(map[string]int)
+	}
+	// The modification time of an entry records its last use for Prune
+	if info, err := f.Stat(); err == nil && time.Since(info.ModTime()) > touchInterval {
+		now := time.Now()
+		os.Chtimes(f.Name(), now, now)
+	}
+	return &fi
+}
+
+// save stores an index atomically so concurrent readers never see a partial entry
+func (ix *Index) save(fi *FileIndex) {
+	if ix.dir == "" {
+		return
+	}
+	if err := os.MkdirAll(ix.dir, 0700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(ix.dir, ".entry-*")
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(tmp)
+	err = gob.NewEncoder(w).Encode(fi)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), ix.entryPath(fi.Path))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	ix.maybePrune()
+}
+
+// maybePrune prunes the directory unless it was pruned within pruneInterval
+func (ix *Index) maybePrune() {
+	ix.mu.Lock()
+	due := time.Since(ix.pruned) >= pruneInterval
+	if due {
+		ix.pruned = time.Now()
+	}
+	ix.mu.Unlock()
+	if due {
+		ix.Prune(MaxEntryAge, MaxDirBytes)
+	}
+}
+
+// storedEntry is a file in the index directory
+type storedEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// Prune removes stored entries not used for maxAge, which drops those of
+// deleted or rotated sessions, then the least recently used entries until
+// the directory holds at most maxBytes. Temporary files left by interrupted
+// saves are removed with the old entries. It returns the number of files
+// removed and the bytes freed.
+func (ix *Index) Prune(maxAge time.Duration, maxBytes int64) (int, int64, error) {
+	if ix.dir == "" {
+		return 0, 0, nil
+	}
+	dirEntries, err := os.ReadDir(ix.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, fmt.Errorf("failed to read index directory: %w", err)
+	}
+	var stored []storedEntry
+	var total int64
+	for _, entry := range dirEntries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasSuffix(name, ".gob") && !strings.HasPrefix(name, ".entry-") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed concurrently
+		}
+		stored = append(stored, storedEntry{path: filepath.Join(ix.dir, name), size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].modTime.Before(stored[j].modTime) })
+
+	threshold := time.Now().Add(-maxAge)
+	removed := 0
+	var freed int64
+	for _, entry := range stored {
+		if !entry.modTime.Before(threshold) && total <= maxBytes {
+			break // Sorted by last use
+		}
+		if err := os.Remove(entry.path); err != nil {
+			continue
+		}
+		removed++
+		freed += entry.size
+		total -= entry.size
+	}
+	return removed, freed, nil
+}
+
+// newFileIndex returns an empty index for a session file
+func newFileIndex(path string) *FileIndex {
+	return &FileIndex{
+		Version:     formatVersion,
+		Path:        path,
+		RecordTypes: make(map[string]int),
+		Tokens:      make(map[string]int),
+		callIndex:   make(map[string]int),
+	}
+}
+
+// pairing returns callIndex, rebuilding it from the tool_use IDs kept in
+// Skeletons after a load. Calls were appended in first-seen ID order.
+func (fi *FileIndex) pairing() map[string]int {
+	if fi.callIndex != nil {
+		return fi.callIndex
+	}
+	fi.callIndex = make(map[string]int, len(fi.ToolCalls))
+	for _, entry := range fi.Skeletons {
+		if entry.Message == nil {
+			continue
+		}
+		for _, block := range entry.Message.Content {
+			if block.ToolUse == nil {
+				continue
+			}
+			if _, seen := fi.callIndex[block.ToolUse.ID]; !seen {
+				fi.callIndex[block.ToolUse.ID] = len(fi.callIndex)
+			}
+		}
+	}
+	return fi.callIndex
+}
+
+// clone copies an index so it can be updated without affecting readers
+func (fi *FileIndex) clone() *FileIndex {
+	c := *fi
+	c.Tail = slices.Clone(fi.Tail)
+	c.RecordTypes = maps.Clone(fi.RecordTypes)
+	c.Tokens = maps.Clone(fi.Tokens)
+	c.Skeletons = slices.Clone(fi.Skeletons)
+	c.ToolCalls = slices.Clone(fi.ToolCalls)
+	c.callIndex = maps.Clone(fi.callIndex)
+	return &c
+}
+
+// tailMatches reports whether the bytes before Offset are unchanged
+func (fi *FileIndex) tailMatches(file *os.File) bool {
+	if len(fi.Tail) == 0 {
+		return fi.Offset == 0
+	}
+	buf := make([]byte, len(fi.Tail))
+	if _, err := file.ReadAt(buf, fi.Offset-int64(len(buf))); err != nil {
+		return false
+	}
+	return bytes.Equal(buf, fi.Tail)
+}
+
+// update decodes the lines read from r, which is positioned at Offset. A
+// final line without a newline that is not valid JSON yet is left for the
+// next update, since the session may still be writing it.
+func (fi *FileIndex) update(r io.Reader) error {
+	reader := bufio.NewReaderSize(r, 256*1024)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(line) == 0 {
+			return nil
+		}
+		complete := line[len(line)-1] == '\n'
+		if !complete && !json.Valid(bytes.TrimSpace(line)) {
+			return nil
+		}
+
+		fi.Lines++
+		fi.addLine(bytes.TrimSpace(line))
+		fi.advance(line)
+
+		if !complete {
+			return nil
+		}
+	}
+}
+
+// advance moves Offset past a decoded line and remembers its last bytes
+func (fi *FileIndex) advance(line []byte) {
+	fi.Offset += int64(len(line))
+	if len(line) >= tailGuardSize {
+		fi.Tail = append([]byte(nil), line[len(line)-tailGuardSize:]...)
+		return
+	}
+	tail := append(fi.Tail, line...)
+	fi.Tail = append([]byte(nil), tail[max(0, len(tail)-tailGuardSize):]...)
+}
+
+// recordHeader holds the fields every record is indexed by
+type recordHeader struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	CWD       string `json:"cwd"`
+}
+
+// addLine indexes one line. Invalid lines are counted and remembered as the
+// parse error, matching parser.ParseEntries which rejects the whole file.
+func (fi *FileIndex) addLine(line []byte) {
+	if len(line) == 0 {
+		return
+	}
+	fi.Records++
+	for _, token := range CountedTokens {
+		if bytes.Contains(line, []byte(token)) {
+			fi.Tokens[token]++
+		}
+	}
+
+	var header recordHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		fi.fail(err)
+		return
+	}
+	if header.Type != "" {
+		fi.RecordTypes[header.Type]++
+	}
+	if t, err := time.Parse(time.RFC3339, header.Timestamp); err == nil {
+		if fi.StartTime.IsZero() || t.Before(fi.StartTime) {
+			fi.StartTime = t
+		}
+		if fi.EndTime.IsZero() || t.After(fi.EndTime) {
+			fi.EndTime = t
+		}
+	}
+	if fi.CWD == "" {
+		fi.CWD = header.CWD
+	}
+
+	if header.Type != "user" && header.Type != "assistant" {
+		return
+	}
+	var entry parser.SessionEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		fi.fail(err)
+		return
+	}
+	fi.addEntry(entry)
+}
+
+// fail records the first decoding error
+func (fi *FileIndex) fail(err error) {
+	if fi.ParseError == "" {
+		fi.ParseError = fmt.Sprintf("failed to parse line %d: %v", fi.Lines, err)
+	}
+}
+
+// addEntry pairs tool calls the way parser.ExtractToolCalls does (a repeated
+// ID replaces the earlier use or result) and keeps the entry's skeleton
+func (fi *FileIndex) addEntry(entry parser.SessionEntry) {
+	if entry.Message != nil {
+		calls := fi.pairing()
+		var kept []parser.ContentBlock
+		for _, block := range entry.Message.Content {
+			switch {
+			case block.Type == "tool_use" && block.ToolUse != nil:
+				call := parser.ToolCall{
+					UUID:      entry.UUID,
+					ToolName:  block.ToolUse.Name,
+					Input:     block.ToolUse.Input,
+					Timestamp: entry.Timestamp,
+				}
+				if i, ok := calls[block.ToolUse.ID]; ok {
+					// Keep an already paired result
+					call.Output, call.Status, call.Error = fi.ToolCalls[i].Output, fi.ToolCalls[i].Status, fi.ToolCalls[i].Error
+					fi.ToolCalls[i] = call
+				} else {
+					calls[block.ToolUse.ID] = len(fi.ToolCalls)
+					fi.ToolCalls = append(fi.ToolCalls, call)
+				}
+				// Inputs live in ToolCalls; entries keep the tool name only
+				kept = append(kept, parser.ContentBlock{
+					Type:    block.Type,
+					ToolUse: &parser.ToolUse{ID: block.ToolUse.ID, Name: block.ToolUse.Name},
+				})
+			case block.Type == "tool_result" && block.ToolResult != nil:
+				if i, ok := calls[block.ToolResult.ToolUseID]; ok {
+					fi.ToolCalls[i].Output = block.ToolResult.Content
+					fi.ToolCalls[i].Status = block.ToolResult.Status
+					fi.ToolCalls[i].Error = block.ToolResult.Error
+				}
+			}
+		}
+		msg := *entry.Message
+		msg.Content = kept
+		entry.Message = &msg
+	}
+	fi.Skeletons = append(fi.Skeletons, entry)
+}
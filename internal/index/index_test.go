@@ -0,0 +1,471 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yaleh/meta-cc/internal/parser"
+	"github.com/yaleh/meta-cc/internal/sessionio"
+	"github.com/yaleh/meta-cc/internal/testutil"
+)
+
+const sessionHead = `{"type":"summary","summary":"Fix bug"}
+{"type":"user","uuid":"u1","cwd":"/work/app","timestamp":"2025-10-02T10:00:00Z","message":{"role":"user","content":"Fix the bug"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-10-02T10:00:05Z","message":{"id":"m1","role":"assistant","content":[{"type":"text","text":"Looking"},{"type":"tool_use","id":"t1","name":"Read","input":{"file_path":"/work/app/main.go","offset":null}},{"type":"tool_use","id":"t2","name":"Bash","input":{"command":"go test","args":["-v"]}}],"usage":{"input_tokens":10,"server_tool_use":{"web_search_requests":0}}}}
+{"type":"user","uuid":"u2","timestamp":"2025-10-02T10:00:06Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"package main"}]}}
+`
+
+const sessionTail = `{"type":"user","uuid":"u3","timestamp":"2025-10-02T10:01:00Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","is_error":true,"content":"FAIL"}]}}
+{"type":"file-history-snapshot","messageId":"x"}
+`
+
+func appendSession(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sortedCalls(calls []parser.ToolCall) []parser.ToolCall {
+	sorted := append([]parser.ToolCall(nil), calls...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ToolName < sorted[j].ToolName })
+	return sorted
+}
+
+func TestFileMatchesParser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	testutil.WriteSession(t, path, sessionHead+sessionTail)
+
+	fi, err := New(t.TempDir()).File(path)
+	if err != nil {
+		t.Fatalf("File failed: %v", err)
+	}
+
+	entries, err := parser.NewSessionParser(path).ParseEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fi.Skeletons) != len(entries) {
+		t.Fatalf("expected %d entries, got %d", len(entries), len(fi.Skeletons))
+	}
+
+	want := sortedCalls(parser.ExtractToolCalls(entries))
+	got := sortedCalls(fi.ToolCalls)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d tool calls, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].ToolName != want[i].ToolName || got[i].UUID != want[i].UUID || got[i].Output != want[i].Output ||
+			got[i].Error != want[i].Error || got[i].Status != want[i].Status || got[i].Timestamp != want[i].Timestamp {
+			t.Errorf("tool call %d differs: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if fi.Records != 6 || fi.RecordTypes["user"] != 3 || fi.RecordTypes["summary"] != 1 || fi.RecordTypes["file-history-snapshot"] != 1 {
+		t.Errorf("unexpected record counts: %d %v", fi.Records, fi.RecordTypes)
+	}
+	if fi.CWD != "/work/app" || fi.StartTime.Format(time.RFC3339) != "2025-10-02T10:00:00Z" || fi.EndTime.Format(time.RFC3339) != "2025-10-02T10:01:00Z" {
+		t.Errorf("unexpected metadata: cwd=%s start=%v end=%v", fi.CWD, fi.StartTime, fi.EndTime)
+	}
+
+	// Message content is reduced to tool_use blocks
+	for _, entry := range fi.Skeletons {
+		for _, block := range entry.Message.Content {
+			if block.Type != "tool_use" {
+				t.Errorf("unexpected %s block kept in entry %s", block.Type, entry.UUID)
+			}
+		}
+	}
+}
+
+func TestFileIsReadFromCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	testutil.WriteSession(t, path, sessionHead)
+	ix := New(t.TempDir())
+
+	first, err := ix.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same size and mtime but different content: only a cache hit returns the old calls
+	testutil.WriteSession(t, path, strings.Replace(sessionHead, `"name":"Read"`, `"name":"Grep"`, 1))
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := ix.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.ToolCalls[0].ToolName != "Read" || len(cached.ToolCalls) != len(first.ToolCalls) {
+		t.Errorf("expected cached index, got %+v", cached.ToolCalls)
+	}
+	// A new Index (e.g. the next process) reads the stored entry
+	stored, err := New(ix.Dir()).File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ToolCalls[0].ToolName != "Read" {
+		t.Errorf("expected stored index, got %+v", stored.ToolCalls)
+	}
+	if stored.ToolCalls[0].Input["offset"] != nil || stored.ToolCalls[1].Input["args"].([]interface{})[0] != "-v" {
+		t.Errorf("tool inputs did not survive the cache: %+v", stored.ToolCalls)
+	}
+}
+
+func TestFileIndexesAppendedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	testutil.WriteSession(t, path, sessionHead)
+	ix := New(t.TempDir())
+
+	before, err := ix.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.ToolCalls[1].Error != "" {
+		t.Fatalf("t2 should not have a result yet: %+v", before.ToolCalls[1])
+	}
+	offset := before.Offset
+
+	// An unfinished final line is left for later
+	appendSession(t, path, sessionTail+`{"type":"user","uuid":"u4"`)
+	after, err := ix.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Offset != offset+int64(len(sessionTail)) {
+		t.Errorf("expected offset %d, got %d", offset+int64(len(sessionTail)), after.Offset)
+	}
+	if after.ToolCalls[1].Error != "FAIL" {
+		t.Errorf("expected appended result paired with earlier tool_use, got %+v", after.ToolCalls[1])
+	}
+	if after.ParseError != "" {
+		t.Errorf("unfinished line must not be a parse error: %s", after.ParseError)
+	}
+
+	appendSession(t, path, `,"timestamp":"2025-10-02T11:00:00Z","message":{"role":"user","content":"next"}}`+"\n")
+	done, err := ix.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done.Skeletons) != 5 || done.EndTime.Format(time.RFC3339) != "2025-10-02T11:00:00Z" {
+		t.Errorf("expected completed line indexed, got %d entries ending %v", len(done.Skeletons), done.EndTime)
+	}
+}
+
+func TestFileRebuildsRewrittenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	testutil.WriteSession(t, path, sessionHead)
+	ix := New(t.TempDir())
+	if _, err := ix.File(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Longer file whose indexed prefix changed
+	testutil.WriteSession(t, path, strings.Replace(sessionHead, "package main", "package test", 1)+sessionTail)
+	fi, err := ix.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.ToolCalls[0].Output != "package test" {
+		t.Errorf("expected rebuilt index, got %+v", fi.ToolCalls[0])
+	}
+	if fi.Records != 6 {
+		t.Errorf("expected 6 records after rebuild, got %d", fi.Records)
+	}
+}
+
+func TestFileRecordsParseError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	testutil.WriteSession(t, path, sessionHead+"\nnot json\n")
+
+	fi, err := New("").File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Err() == nil || !strings.Contains(fi.Err().Error(), "failed to parse line 6") {
+		t.Errorf("expected parse error on line 6, got %v", fi.Err())
+	}
+	if fi.Records != 5 {
+		t.Errorf("invalid lines still count as records, got %d", fi.Records)
+	}
+
+	if _, err := New("").File(path + ".missing"); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestDefaultHonorsEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(EnvDir, dir)
+	if Default().Dir() != dir {
+		t.Errorf("expected %s, got %s", dir, Default().Dir())
+	}
+
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	testutil.WriteSession(t, path, sessionHead)
+	if _, err := Default().File(path); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := filepath.Glob(filepath.Join(dir, "*.gob"))
+	if len(stored) != 1 {
+		t.Errorf("expected one stored entry, got %v", stored)
+	}
+
+	t.Setenv(EnvDir, "off")
+	if Default().Dir() != "" {
+		t.Errorf("expected in-memory index, got %s", Default().Dir())
+	}
+}
+
+func TestFileMemoizesAndNeverMutatesReturnedIndexes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	testutil.WriteSession(t, path, sessionHead)
+	ix := New("")
+
+	first, err := ix.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ix.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != again {
+		t.Error("expected unchanged file to be served from memory")
+	}
+
+	appendSession(t, path, sessionTail)
+	grown, err := ix.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grown == first || len(first.Skeletons) != 3 || first.ToolCalls[1].Error != "" {
+		t.Errorf("earlier index was modified by the update: %d entries, %+v", len(first.Skeletons), first.ToolCalls[1])
+	}
+	if len(grown.Skeletons) != 4 || grown.ToolCalls[1].Error != "FAIL" {
+		t.Errorf("expected grown index, got %d entries, %+v", len(grown.Skeletons), grown.ToolCalls[1])
+	}
+}
+
+func TestDefaultIsShared(t *testing.T) {
+	t.Setenv(EnvDir, t.TempDir())
+	if Default() != Default() {
+		t.Error("expected one Default index per directory")
+	}
+}
+
+func TestFileReadsArchivedSession(t *testing.T) {
+	dir := t.TempDir()
+	plainPath := filepath.Join(dir, "s.jsonl")
+	testutil.WriteSession(t, plainPath, sessionHead+sessionTail)
+	archived, err := sessionio.ArchiveFile(plainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, archived.Archive)
+	ix := New(t.TempDir())
+
+	fi, err := ix.File(path)
+	if err != nil {
+		t.Fatalf("File failed: %v", err)
+	}
+	if fi.Records != 6 || len(fi.ToolCalls) != 2 || fi.CWD != "/work/app" {
+		t.Errorf("unexpected index: records=%d calls=%d cwd=%s", fi.Records, len(fi.ToolCalls), fi.CWD)
+	}
+
+	// A replaced archive is indexed from scratch, never appended to
+	testutil.WriteSession(t, plainPath, sessionHead)
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessionio.ArchiveFile(plainPath); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	fi, err = ix.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Records != 4 {
+		t.Errorf("expected 4 records after rewrite, got %d", fi.Records)
+	}
+}
+
+// recordLines passes the lines of data to r the way a line scan reads them
+func recordLines(r *Recorder, data string) {
+	for i, line := range strings.Split(data, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			r.Line(i+1, []byte(trimmed))
+		}
+	}
+}
+
+func TestRecorderBuildsIndexFromScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	testutil.WriteSession(t, path, sessionHead+sessionTail)
+	ix := New(t.TempDir())
+
+	if _, ok := ix.Lookup(path); ok {
+		t.Fatal("expected no index before the scan")
+	}
+	r := ix.Record(path)
+	if r == nil {
+		t.Fatal("expected a recorder for an unindexed file")
+	}
+	recordLines(r, sessionHead+sessionTail)
+	r.Done(int64(len(sessionHead + sessionTail)))
+
+	// The next process finds the entry the scan stored
+	recorded, ok := New(ix.Dir()).Lookup(path)
+	if !ok {
+		t.Fatal("expected the scan to store an index")
+	}
+	built, err := New("").File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded.Records != built.Records || recorded.Offset != built.Offset || string(recorded.Tail) != string(built.Tail) ||
+		len(recorded.Skeletons) != len(built.Skeletons) || len(recorded.ToolCalls) != len(built.ToolCalls) {
+		t.Errorf("recorded index differs from a built one: %+v", recorded)
+	}
+	if recorded.Tokens[`"is_error"`] != 1 || recorded.Tokens[`"tool_use"`] != 1 || !recorded.Lacks(`"api_error"`) {
+		t.Errorf("unexpected token counts: %v", recorded.Tokens)
+	}
+	if recorded.Lacks(`"not counted"`) {
+		t.Error("tokens that are not counted are never lacking")
+	}
+	if ix.Record(path) != nil {
+		t.Error("expected no recorder for an up-to-date file")
+	}
+
+	// An appended scan extends the recorded index like a built one
+	appendSession(t, path, `{"type":"summary","summary":"more"}`+"\n")
+	fi, err := ix.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Records != 7 || fi.RecordTypes["summary"] != 2 {
+		t.Errorf("expected the appended line indexed, got %d records %v", fi.Records, fi.RecordTypes)
+	}
+}
+
+func TestRecorderSkipsIncompleteScans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	unfinished := sessionHead + `{"type":"user","uuid":"u4"`
+	testutil.WriteSession(t, path, unfinished)
+	ix := New(t.TempDir())
+
+	// The last line may still be written
+	r := ix.Record(path)
+	recordLines(r, unfinished)
+	r.Done(int64(len(unfinished)))
+	if _, ok := ix.Lookup(path); ok {
+		t.Error("expected a file with an unfinished line not to be stored")
+	}
+
+	// Part of the file was read
+	testutil.WriteSession(t, path, sessionHead)
+	r = ix.Record(path)
+	recordLines(r, sessionHead)
+	r.Done(int64(len(sessionHead)) - 1)
+	if _, ok := ix.Lookup(path); ok {
+		t.Error("expected a partial scan not to be stored")
+	}
+
+	// A nil recorder ignores every call
+	var none *Recorder
+	none.Line(1, []byte(`{}`))
+	none.Done(0)
+}
+
+func TestMemoIsBoundedByBytes(t *testing.T) {
+	ix := New("")
+	half := newFileIndex("/a.jsonl")
+	half.Offset = maxMemoBytes/2 + 1
+	ix.remember(half)
+	other := newFileIndex("/b.jsonl")
+	other.Offset = maxMemoBytes/2 + 1
+	ix.remember(other)
+
+	if ix.recall("/a.jsonl") != nil || ix.recall("/b.jsonl") != other {
+		t.Error("expected the least recently used index to be evicted")
+	}
+	if ix.memoBytes > maxMemoBytes {
+		t.Errorf("memo holds %d bytes, limit %d", ix.memoBytes, maxMemoBytes)
+	}
+
+	huge := newFileIndex("/huge.jsonl")
+	huge.Offset = maxMemoBytes + 1
+	ix.remember(huge)
+	if ix.recall("/huge.jsonl") != nil || ix.recall("/b.jsonl") != other {
+		t.Error("expected an index larger than the limit to be left out of the memo")
+	}
+}
+
+func TestPruneRemovesOldAndLeastRecentlyUsedEntries(t *testing.T) {
+	ix := New(t.TempDir())
+	var paths []string
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(t.TempDir(), "s.jsonl")
+		testutil.WriteSession(t, path, sessionHead)
+		if _, err := ix.File(path); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	entries, _ := filepath.Glob(filepath.Join(ix.Dir(), "*.gob"))
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 stored entries, got %v", entries)
+	}
+	// The first session was last used long ago, the second a little later
+	for i, age := range []time.Duration{60 * 24 * time.Hour, 2 * time.Hour} {
+		used := time.Now().Add(-age)
+		if err := os.Chtimes(ix.entryPath(paths[i]), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := os.Stat(ix.entryPath(paths[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, _, err := ix.Prune(MaxEntryAge, MaxDirBytes)
+	if err != nil || removed != 1 {
+		t.Fatalf("expected the old entry removed, got %d (%v)", removed, err)
+	}
+	if _, err := os.Stat(ix.entryPath(paths[0])); !os.IsNotExist(err) {
+		t.Error("expected the old entry to be gone")
+	}
+
+	// Over the size limit, the least recently used entry goes first
+	removed, freed, err := ix.Prune(MaxEntryAge, info.Size())
+	if err != nil || removed != 1 || freed == 0 {
+		t.Fatalf("expected one entry evicted, got %d (%v)", removed, err)
+	}
+	if _, err := os.Stat(ix.entryPath(paths[1])); !os.IsNotExist(err) {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if _, ok := New(ix.Dir()).Lookup(paths[2]); !ok {
+		t.Error("expected the recently used entry to be kept")
+	}
+}
@@ -5,38 +5,49 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/yaleh/meta-cc/internal/sessionio"
 )
 
 // FromSessionID 通过会话 ID 查找会话文件
-// 遍历 ~/.claude/projects/*/，查找匹配的 {session-id}.jsonl
+// 遍历 ~/.claude/projects/*/ 及导入的会话包，查找匹配的 {session-id}.jsonl
 // 如果找到多个（跨项目同名会话），返回最新的
 func (l *SessionLocator) FromSessionID(sessionID string) (string, error) {
 	projectsRoot := l.projectsRoot
-	if projectsRoot == "" {
+	if projectsRoot == "" && len(l.bundleRoots) == 0 {
 		return "", fmt.Errorf("Claude Code projects directory not configured")
 	}
 
-	if _, err := os.Stat(projectsRoot); os.IsNotExist(err) {
+	if _, err := os.Stat(projectsRoot); os.IsNotExist(err) && len(l.bundleRoots) == 0 {
 		return "", fmt.Errorf("Claude Code projects directory not found: %s", projectsRoot)
 	}
 
-	// 遍历所有项目目录
-	projectDirs, err := os.ReadDir(projectsRoot)
-	if err != nil {
-		return "", fmt.Errorf("failed to read projects directory: %w", err)
-	}
-
 	var candidates []string
-	sessionFilename := sessionID + ".jsonl"
 
-	for _, projectDir := range projectDirs {
-		if !projectDir.IsDir() {
+	for _, root := range l.roots() {
+		// 遍历所有项目目录
+		projectDirs, err := os.ReadDir(root)
+		if err != nil {
+			// 导入的会话包或缺失的 projects 根目录不影响其他根目录
+			if root == projectsRoot && !os.IsNotExist(err) {
+				return "", fmt.Errorf("failed to read projects directory: %w", err)
+			}
 			continue
 		}
 
-		sessionPath := filepath.Join(projectsRoot, projectDir.Name(), sessionFilename)
-		if _, err := os.Stat(sessionPath); err == nil {
-			candidates = append(candidates, sessionPath)
+		for _, projectDir := range projectDirs {
+			if !projectDir.IsDir() {
+				continue
+			}
+
+			// 依次尝试未归档与归档的会话文件
+			for _, ext := range []string{sessionio.PlainExt, sessionio.GzipExt, sessionio.ZstdExt} {
+				sessionPath := filepath.Join(root, projectDir.Name(), sessionID+ext)
+				if _, err := os.Stat(sessionPath); err == nil {
+					candidates = append(candidates, sessionPath)
+					break
+				}
+			}
 		}
 	}
 
@@ -50,36 +61,12 @@ func (l *SessionLocator) FromSessionID(sessionID string) (string, error) {
 
 // FromProjectPath 通过项目路径查找最新会话
 // 1. 将项目路径转换为哈希（/ → -）
-// 2. 定位 ~/.claude/projects/{hash}/
-// 3. 返回该目录下最新的 .jsonl 文件
+// 2. 定位 ~/.claude/projects/{hash}/ 及导入会话包中的同名目录
+// 3. 返回这些目录下最新的会话文件
 func (l *SessionLocator) FromProjectPath(projectPath string) (string, error) {
-	// 解析相对路径为绝对路径（如 "." -> "/home/yale/work/meta-cc"）
-	absPath, err := filepath.Abs(projectPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve project path: %w", err)
-	}
-
-	// 计算项目哈希 (pathToHash now handles symlink resolution)
-	projectHash := pathToHash(absPath)
-
-	projectsRoot := l.projectsRoot
-	if projectsRoot == "" {
-		return "", fmt.Errorf("Claude Code projects directory not configured")
-	}
-
-	sessionDir := filepath.Join(projectsRoot, projectHash)
-	if _, err := os.Stat(sessionDir); os.IsNotExist(err) {
-		return "", fmt.Errorf("no sessions found for project: %s (hash: %s)", projectPath, projectHash)
-	}
-
-	// 查找所有 .jsonl 文件
-	sessions, err := filepath.Glob(filepath.Join(sessionDir, "*.jsonl"))
+	sessions, err := l.projectSessions(projectPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to search session files: %w", err)
-	}
-
-	if len(sessions) == 0 {
-		return "", fmt.Errorf("no session files found in: %s", sessionDir)
+		return "", err
 	}
 
 	// 返回最新的会话文件
@@ -88,9 +75,14 @@ func (l *SessionLocator) FromProjectPath(projectPath string) (string, error) {
 
 // AllSessionsFromProject 通过项目路径查找所有会话文件
 // 1. 将项目路径转换为哈希（/ → -）
-// 2. 定位 ~/.claude/projects/{hash}/
-// 3. 返回该目录下所有 .jsonl 文件的路径
+// 2. 定位 ~/.claude/projects/{hash}/ 及导入会话包中的同名目录
+// 3. 返回这些目录下所有会话文件的路径
 func (l *SessionLocator) AllSessionsFromProject(projectPath string) ([]string, error) {
+	return l.projectSessions(projectPath)
+}
+
+// projectSessions 返回项目在所有会话根目录下的会话文件
+func (l *SessionLocator) projectSessions(projectPath string) ([]string, error) {
 	// 解析相对路径为绝对路径（如 "." -> "/home/yale/work/meta-cc"）
 	absPath, err := filepath.Abs(projectPath)
 	if err != nil {
@@ -101,26 +93,32 @@ func (l *SessionLocator) AllSessionsFromProject(projectPath string) ([]string, e
 	projectHash := pathToHash(absPath)
 
 	projectsRoot := l.projectsRoot
-	if projectsRoot == "" {
+	if projectsRoot == "" && len(l.bundleRoots) == 0 {
 		return nil, fmt.Errorf("Claude Code projects directory not configured")
 	}
 
-	sessionDir := filepath.Join(projectsRoot, projectHash)
-	if _, err := os.Stat(sessionDir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("no sessions found for project: %s (hash: %s)", projectPath, projectHash)
-	}
+	var sessions, sessionDirs []string
+	for _, root := range l.roots() {
+		sessionDir := filepath.Join(root, projectHash)
+		if _, err := os.Stat(sessionDir); os.IsNotExist(err) {
+			continue
+		}
+		sessionDirs = append(sessionDirs, sessionDir)
 
-	// 查找所有 .jsonl 文件
-	sessions, err := filepath.Glob(filepath.Join(sessionDir, "*.jsonl"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to search session files: %w", err)
+		// 查找所有会话文件（含归档的 .jsonl.gz / .jsonl.zst）
+		files, err := sessionio.Glob(sessionDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search session files: %w", err)
+		}
+		sessions = append(sessions, files...)
 	}
 
+	if len(sessionDirs) == 0 {
+		return nil, fmt.Errorf("no sessions found for project: %s (hash: %s)", projectPath, projectHash)
+	}
 	if len(sessions) == 0 {
-		return nil, fmt.Errorf("no session files found in: %s", sessionDir)
+		return nil, fmt.Errorf("no session files found in: %s", strings.Join(sessionDirs, ", "))
 	}
-
-	// 返回所有会话文件
 	return sessions, nil
 }
 
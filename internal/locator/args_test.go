@@ -8,20 +8,9 @@ import (
 	"github.com/yaleh/meta-cc/internal/testutil"
 )
 
-func setupProjectsRoot(t *testing.T) string {
-	t.Helper()
-	dir, err := os.MkdirTemp("", "meta-cc-projects-*")
-	if err != nil {
-		t.Fatalf("failed to create temp projects dir: %v", err)
-	}
-	t.Cleanup(func() { _ = os.RemoveAll(dir) })
-	t.Setenv(projectsRootEnv, dir)
-	return dir
-}
-
 func TestFromSessionID_Success(t *testing.T) {
 	// 准备测试环境
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 	projectHash := "-test-project-session-id"
 	sessionID := "abc123-def456"
 
@@ -57,7 +46,7 @@ func TestFromSessionID_NotFound(t *testing.T) {
 
 func TestFromSessionID_MultipleProjects(t *testing.T) {
 	// 准备：在多个项目目录中创建同名会话文件
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 	sessionID := "shared-session-id"
 
 	// 项目1（旧）
@@ -100,7 +89,7 @@ func TestFromSessionID_MultipleProjects(t *testing.T) {
 
 func TestFromProjectPath_Success(t *testing.T) {
 	// 准备测试环境
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 	// Use temp dir for cross-platform compatibility
 	tempDir, err := os.MkdirTemp("", "testproject")
 	if err != nil {
@@ -155,7 +144,7 @@ func TestFromProjectPath_NoSessions(t *testing.T) {
 
 func TestFromProjectPath_RelativePath(t *testing.T) {
 	// Test that relative paths like "." are resolved to absolute paths
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 
 	// Get current working directory
 	cwd, err := os.Getwd()
@@ -203,7 +192,7 @@ func TestFromProjectPath_RelativePath(t *testing.T) {
 
 func TestAllSessionsFromProject_Success(t *testing.T) {
 	// Test that AllSessionsFromProject returns all session files for a project
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 	// Use temp dir for cross-platform compatibility
 	tempDir, err := os.MkdirTemp("", "testproject")
 	if err != nil {
@@ -276,7 +265,7 @@ func TestAllSessionsFromProject_NoSessions(t *testing.T) {
 
 func TestAllSessionsFromProject_RelativePath(t *testing.T) {
 	// Test that relative paths are resolved to absolute paths
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 	cwd, _ := os.Getwd()
 
 	projectHash := pathToHash(cwd)
@@ -319,3 +308,36 @@ func TestAllSessionsFromProject_RelativePath(t *testing.T) {
 		t.Errorf("Expected 2 sessions, got %d", len(sessionsFromRelative))
 	}
 }
+
+func TestFromSessionID_Archived(t *testing.T) {
+	// 归档后的会话（.jsonl.gz / .jsonl.zst）同样可以按 ID 查找
+	projectsRoot := testutil.SetupProjectsRoot(t)
+	sessionDir := filepath.Join(projectsRoot, "-test-project-archived")
+	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		t.Fatalf("failed to create session dir: %v", err)
+	}
+	for _, name := range []string{"old.jsonl.gz", "older.jsonl.zst", "new.jsonl"} {
+		if err := os.WriteFile(filepath.Join(sessionDir, name), nil, 0644); err != nil {
+			t.Fatalf("failed to write session file: %v", err)
+		}
+	}
+
+	locator := NewSessionLocator()
+	for id, name := range map[string]string{"old": "old.jsonl.gz", "older": "older.jsonl.zst", "new": "new.jsonl"} {
+		path, err := locator.FromSessionID(id)
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got: %v", id, err)
+		}
+		if path != filepath.Join(sessionDir, name) {
+			t.Errorf("Expected %s, got %s", name, path)
+		}
+	}
+
+	sessions, err := locator.AllSessionsFromProject("/test/project/archived")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(sessions) != 3 {
+		t.Errorf("Expected 3 sessions, got %d: %v", len(sessions), sessions)
+	}
+}
@@ -4,15 +4,21 @@ package locator
 import (
 	"os"
 	"path/filepath"
+	"sort"
 )
 
 const projectsRootEnv = "META_CC_PROJECTS_ROOT"
 
+// bundlesDirEnv 覆盖导入会话包的存放目录
+const bundlesDirEnv = "META_CC_BUNDLES_DIR"
+
 type SessionLocator struct {
 	projectsRoot string
+	bundleRoots  []string // 已导入的会话包（只读），目录布局与 projects 根目录相同
 }
 
 // NewSessionLocator 创建 SessionLocator 实例
+// 除 projects 根目录外，还会搜索 BundlesDir 下所有已导入的会话包
 func NewSessionLocator() *SessionLocator {
 	root := os.Getenv(projectsRootEnv)
 	if root == "" {
@@ -26,5 +32,61 @@ func NewSessionLocator() *SessionLocator {
 
 	return &SessionLocator{
 		projectsRoot: root,
+		bundleRoots:  listBundleRoots(BundlesDir()),
+	}
+}
+
+// NewSessionLocatorWithRoot 使用指定的 projects 根目录创建 SessionLocator（不搜索导入的会话包）
+func NewSessionLocatorWithRoot(root string) *SessionLocator {
+	return &SessionLocator{
+		projectsRoot: filepath.Clean(root),
+	}
+}
+
+// BundlesDir 返回导入会话包的存放目录，每个子目录是一个只读的会话根目录
+// 默认为用户配置目录下的 meta-cc/bundles，可通过 META_CC_BUNDLES_DIR 覆盖
+func BundlesDir() string {
+	if dir := os.Getenv(bundlesDirEnv); dir != "" {
+		return filepath.Clean(dir)
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "meta-cc", "bundles")
+}
+
+// BundleRoots 返回搜索的已导入会话包目录
+func (l *SessionLocator) BundleRoots() []string {
+	return l.bundleRoots
+}
+
+// listBundleRoots 列出 dir 下的会话包目录（按名称排序），忽略以 . 开头的临时目录
+func listBundleRoots(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var roots []string
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name()[0] == '.' {
+			continue
+		}
+		roots = append(roots, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// roots 返回依次搜索的会话根目录：projects 根目录在前，导入的会话包在后
+func (l *SessionLocator) roots() []string {
+	roots := make([]string, 0, 1+len(l.bundleRoots))
+	if l.projectsRoot != "" {
+		roots = append(roots, l.projectsRoot)
 	}
+	return append(roots, l.bundleRoots...)
 }
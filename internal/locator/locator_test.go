@@ -4,11 +4,13 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/yaleh/meta-cc/internal/testutil"
 )
 
 func TestLocate_WithSessionID(t *testing.T) {
 	// 准备测试环境
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 	projectHash := "-test-locate-session"
 	sessionID := "test-session-123"
 
@@ -50,7 +52,7 @@ func TestLocate_SessionIDNotFound(t *testing.T) {
 
 func TestLocate_WithProjectPath(t *testing.T) {
 	// 准备测试环境
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 	// Use temp dir for cross-platform compatibility
 	tempDir, err := os.MkdirTemp("", "testproject")
 	if err != nil {
@@ -100,7 +102,7 @@ func TestLocate_ProjectPathNotFound(t *testing.T) {
 func TestLocate_DefaultCWD(t *testing.T) {
 	// 准备测试环境：使用当前工作目录
 	cwd, _ := os.Getwd()
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 	projectHash := pathToHash(cwd)
 
 	sessionDir := filepath.Join(projectsRoot, projectHash)
@@ -141,7 +143,7 @@ func TestLocate_SessionOnlyMode(t *testing.T) {
 func TestLocate_WithEnvVars(t *testing.T) {
 	// Test that environment variables are no longer used
 	// 准备测试环境
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 	sessionID := "env-session-123"
 	projectHash := "-test-env-project"
 
@@ -178,7 +180,7 @@ func TestLocate_EnvVarsIgnoredInProjectMode(t *testing.T) {
 	defer os.Unsetenv("CC_SESSION_ID")
 
 	// 准备项目路径的会话
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 	// Use temp dir for cross-platform compatibility
 	tempDir, err := os.MkdirTemp("", "testproject")
 	if err != nil {
@@ -218,7 +220,7 @@ func TestLocate_EnvVarsIgnoredInProjectMode(t *testing.T) {
 
 func TestLocate_SessionIDPriority(t *testing.T) {
 	// 准备多个选项，验证 SessionID 优先级最高
-	projectsRoot := setupProjectsRoot(t)
+	projectsRoot := testutil.SetupProjectsRoot(t)
 	sessionID := "priority-test-session"
 	projectHash := "-test-priority"
 
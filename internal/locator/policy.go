@@ -0,0 +1,117 @@
+package locator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+)
+
+// PathPolicy 限定工具可以读取的文件：会话根目录（projects 根目录与导入的会话包）
+// 以及额外配置的根目录（例如 MCP 服务器的 file_ref 输出目录）
+//
+// 调用方传入的路径可能来自被注入的提示词，因此符号链接解析后再检查，
+// 防止借助链接读取 ~/.ssh、/etc 等目录
+type PathPolicy struct {
+	roots []string
+}
+
+// NewPathPolicy 创建 PathPolicy，允许 loc 搜索的会话根目录和 extraRoots
+func NewPathPolicy(loc *SessionLocator, extraRoots []string) *PathPolicy {
+	return NewRootsPolicy(append(loc.roots(), extraRoots...))
+}
+
+// NewRootsPolicy 创建只允许 roots 的 PathPolicy（例如只允许写入导出目录）
+func NewRootsPolicy(roots []string) *PathPolicy {
+	p := &PathPolicy{}
+	for _, root := range roots {
+		if root == "" || !filepath.IsAbs(root) {
+			continue
+		}
+		root = filepath.Clean(root)
+		p.roots = append(p.roots, root)
+		// 同时记录解析后的路径（例如 macOS 上 /var → /private/var）
+		if resolved, err := filepath.EvalSymlinks(root); err == nil && resolved != root {
+			p.roots = append(p.roots, resolved)
+		}
+	}
+	return p
+}
+
+// Resolve 解析 path 的符号链接并检查其是否允许读取，返回解析后的路径
+// 不允许的路径返回 ErrInvalidInput，允许范围内不存在的文件返回 ErrNotFound
+func (p *PathPolicy) Resolve(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("path %s is not absolute: %w", path, mcerrors.ErrInvalidInput)
+	}
+	path = filepath.Clean(path)
+	// 先做字面检查，避免对允许范围外的路径泄露其是否存在
+	if !p.allowed(path) {
+		return "", p.denied(path)
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("file %s: %w", path, mcerrors.ErrNotFound)
+		}
+		return "", fmt.Errorf("failed to resolve %s: %w", path, mcerrors.ErrFileIO)
+	}
+	if !p.allowed(resolved) {
+		return "", p.denied(path)
+	}
+	return resolved, nil
+}
+
+// ResolveTarget 检查将要写入的 path 是否位于允许范围内，返回父目录解析符号链接后的路径
+// 目标文件可以不存在，但父目录必须存在；目标是符号链接时拒绝，避免借助链接覆盖范围外的文件
+func (p *PathPolicy) ResolveTarget(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("path %s is not absolute: %w", path, mcerrors.ErrInvalidInput)
+	}
+	path = filepath.Clean(path)
+	if !p.allowed(path) {
+		return "", p.denied(path)
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("directory of %s: %w", path, mcerrors.ErrNotFound)
+		}
+		return "", fmt.Errorf("failed to resolve %s: %w", path, mcerrors.ErrFileIO)
+	}
+	resolved := filepath.Join(dir, filepath.Base(path))
+	if !p.allowed(resolved) {
+		return "", p.denied(path)
+	}
+	if info, err := os.Lstat(resolved); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", p.denied(path)
+	}
+	return resolved, nil
+}
+
+// allowed 判断（已清理的绝对）路径是否位于允许范围内
+func (p *PathPolicy) allowed(path string) bool {
+	for _, root := range p.roots {
+		if within(root, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PathPolicy) denied(path string) error {
+	return fmt.Errorf("path %s is outside the Claude projects root and allowed roots: %w", path, mcerrors.ErrInvalidInput)
+}
+
+// within 判断 path 是否位于 root 之下
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
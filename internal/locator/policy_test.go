@@ -0,0 +1,128 @@
+package locator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/testutil"
+)
+
+// tempFile 在系统临时目录中创建以 pattern 命名的文件
+func tempFile(t *testing.T, pattern string) string {
+	t.Helper()
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	return f.Name()
+}
+
+func TestPathPolicy(t *testing.T) {
+	projectsRoot := testutil.SetupProjectsRoot(t)
+	session := filepath.Join(projectsRoot, "-work-app", "s1.jsonl")
+	if err := os.MkdirAll(filepath.Dir(session), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(session, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outside := t.TempDir()
+	secret := filepath.Join(outside, "id_rsa")
+	if err := os.WriteFile(secret, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// 位于 projects 根目录内、指向外部文件的符号链接
+	link := filepath.Join(projectsRoot, "-work-app", "link.jsonl")
+	if err := os.Symlink(secret, link); err != nil {
+		t.Fatal(err)
+	}
+
+	extra := t.TempDir()
+	extraFile := filepath.Join(extra, "s2.jsonl")
+	if err := os.WriteFile(extraFile, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := NewPathPolicy(NewSessionLocator(), []string{extra})
+
+	tests := []struct {
+		name string
+		path string
+		want error
+	}{
+		{"session", session, nil},
+		{"extra root", extraFile, nil},
+		{"temp file", tempFile(t, "meta-cc-mcp-*.jsonl"), mcerrors.ErrInvalidInput},
+		{"outside", secret, mcerrors.ErrInvalidInput},
+		{"traversal", filepath.Join(projectsRoot, "..", filepath.Base(outside), "id_rsa"), mcerrors.ErrInvalidInput},
+		{"symlink escape", link, mcerrors.ErrInvalidInput},
+		{"relative", "s1.jsonl", mcerrors.ErrInvalidInput},
+		{"missing", filepath.Join(projectsRoot, "-work-app", "missing.jsonl"), mcerrors.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := policy.Resolve(tt.path)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Resolve(%s) failed: %v", tt.path, err)
+				}
+				if !filepath.IsAbs(resolved) {
+					t.Errorf("Resolve(%s) = %s, want an absolute path", tt.path, resolved)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Resolve(%s) error = %v, want %v", tt.path, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathPolicyResolveTarget(t *testing.T) {
+	exports := t.TempDir()
+	outside := t.TempDir()
+	bashrc := filepath.Join(outside, ".bashrc")
+	if err := os.WriteFile(bashrc, []byte("export PATH"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 导出目录内指向外部文件与外部目录的符号链接
+	if err := os.Symlink(bashrc, filepath.Join(exports, "link.tar.gz")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(exports, "dir")); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := NewRootsPolicy([]string{exports})
+
+	tests := []struct {
+		name string
+		path string
+		want error
+	}{
+		{"new file", filepath.Join(exports, "a.tar.gz"), nil},
+		{"outside", bashrc, mcerrors.ErrInvalidInput},
+		{"dot-dot", filepath.Join(exports, "..", filepath.Base(outside), ".bashrc"), mcerrors.ErrInvalidInput},
+		{"file symlink", filepath.Join(exports, "link.tar.gz"), mcerrors.ErrInvalidInput},
+		{"directory symlink", filepath.Join(exports, "dir", ".bashrc"), mcerrors.ErrInvalidInput},
+		{"missing directory", filepath.Join(exports, "missing", "a.tar.gz"), mcerrors.ErrNotFound},
+		{"relative", "a.tar.gz", mcerrors.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := policy.ResolveTarget(tt.path)
+			if tt.want == nil && err != nil {
+				t.Fatalf("ResolveTarget(%s) failed: %v", tt.path, err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("ResolveTarget(%s) = %v, want %v", tt.path, err, tt.want)
+			}
+		})
+	}
+}
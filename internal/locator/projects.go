@@ -0,0 +1,111 @@
+package locator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yaleh/meta-cc/internal/sessionio"
+)
+
+// ProjectDir 表示会话根目录下的一个项目会话目录
+type ProjectDir struct {
+	Name   string // 目录名（项目路径哈希，如 -home-yale-work-app）
+	Path   string // 目录绝对路径
+	Bundle string // 所属的导入会话包目录（只读）；本地项目为空
+}
+
+// ProjectsRoot 返回 Claude Code projects 根目录
+func (l *SessionLocator) ProjectsRoot() string {
+	return l.projectsRoot
+}
+
+// ListProjects 列出 projects 根目录及导入会话包中包含会话文件的项目目录（按名称排序，本地项目在前）
+// patterns 为空时返回全部项目，否则仅返回匹配任一模式的项目，模式规则见 MatchProject
+func (l *SessionLocator) ListProjects(patterns []string) ([]ProjectDir, error) {
+	projectsRoot := l.projectsRoot
+	if projectsRoot == "" {
+		return nil, fmt.Errorf("Claude Code projects directory not configured")
+	}
+
+	var projects []ProjectDir
+	for _, root := range l.roots() {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			if root == projectsRoot {
+				return nil, fmt.Errorf("failed to read projects directory: %w", err)
+			}
+			continue
+		}
+
+		bundle := ""
+		if root != projectsRoot {
+			bundle = root
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			if len(patterns) > 0 && !matchesAny(patterns, entry.Name()) {
+				continue
+			}
+
+			dir := filepath.Join(root, entry.Name())
+			sessions, err := sessionio.Glob(dir)
+			if err != nil || len(sessions) == 0 {
+				continue
+			}
+			projects = append(projects, ProjectDir{Name: entry.Name(), Path: dir, Bundle: bundle})
+		}
+	}
+
+	sort.SliceStable(projects, func(i, j int) bool {
+		if projects[i].Name != projects[j].Name {
+			return projects[i].Name < projects[j].Name
+		}
+		return projects[i].Bundle < projects[j].Bundle
+	})
+	return projects, nil
+}
+
+// matchesAny 判断项目目录名是否匹配任一模式
+func matchesAny(patterns []string, dirName string) bool {
+	for _, pattern := range patterns {
+		if MatchProject(pattern, dirName) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchProject 判断项目目录名（路径哈希）是否匹配模式，模式支持 filepath.Match 通配符：
+//  1. 含路径分隔符的模式视为项目路径（如 /home/yale/work/* 或 ./app），转换为哈希后匹配
+//  2. 其他模式匹配目录名本身，或匹配项目路径的末尾部分（如 meta-cc 匹配 -home-yale-work-meta-cc）
+func MatchProject(pattern, dirName string) bool {
+	if pattern == "" {
+		return false
+	}
+
+	if strings.ContainsAny(pattern, `/\`) {
+		if !filepath.IsAbs(pattern) {
+			if abs, err := filepath.Abs(pattern); err == nil {
+				pattern = abs
+			}
+		}
+		// 通配符路径无法解析符号链接，直接按字符替换
+		hashed := strings.NewReplacer(`\`, "-", "/", "-", ":", "-").Replace(pattern)
+		if !strings.ContainsAny(pattern, "*?[") {
+			hashed = pathToHash(pattern)
+		}
+		ok, _ := filepath.Match(hashed, dirName)
+		return ok
+	}
+
+	if ok, _ := filepath.Match(pattern, dirName); ok {
+		return true
+	}
+	ok, _ := filepath.Match("*-"+pattern, dirName)
+	return ok
+}
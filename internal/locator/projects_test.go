@@ -0,0 +1,114 @@
+package locator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yaleh/meta-cc/internal/testutil"
+)
+
+func TestMatchProject(t *testing.T) {
+	tests := []struct {
+		pattern string
+		dirName string
+		want    bool
+	}{
+		{"-home-yale-work-app", "-home-yale-work-app", true},
+		{"/home/yale/work/app", "-home-yale-work-app", true},
+		{"/home/yale/work/*", "-home-yale-work-app", true},
+		{"/home/yale/other/*", "-home-yale-work-app", false},
+		{"app", "-home-yale-work-app", true},
+		{"meta-cc", "-home-yale-work-meta-cc", true},
+		{"meta-*", "-home-yale-work-meta-cc", true},
+		{"cc-app", "-home-yale-work-app", false},
+		{"", "-home-yale-work-app", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchProject(tt.pattern, tt.dirName); got != tt.want {
+			t.Errorf("MatchProject(%q, %q) = %v, want %v", tt.pattern, tt.dirName, got, tt.want)
+		}
+	}
+}
+
+func TestListProjects(t *testing.T) {
+	projectsRoot := testutil.SetupProjectsRoot(t)
+	for _, dir := range []string{"-work-api", "-work-web", "-work-empty"} {
+		if err := os.MkdirAll(filepath.Join(projectsRoot, dir), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+	for _, dir := range []string{"-work-api", "-work-web"} {
+		if err := os.WriteFile(filepath.Join(projectsRoot, dir, "s.jsonl"), []byte("{}\n"), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	loc := NewSessionLocator()
+	projects, err := loc.ListProjects(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(projects) != 2 || projects[0].Name != "-work-api" || projects[1].Name != "-work-web" {
+		t.Errorf("Expected projects with sessions only, got %+v", projects)
+	}
+
+	projects, err = loc.ListProjects([]string{"web"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(projects) != 1 || projects[0].Path != filepath.Join(projectsRoot, "-work-web") {
+		t.Errorf("Expected only -work-web, got %+v", projects)
+	}
+}
+
+func TestBundleRootsAreSearched(t *testing.T) {
+	projectsRoot := testutil.SetupProjectsRoot(t)
+	bundlesDir := t.TempDir()
+	t.Setenv(bundlesDirEnv, bundlesDir)
+
+	// 本地项目与导入的会话包中各有一个会话
+	files := map[string]string{
+		filepath.Join(projectsRoot, "-work-api", "local.jsonl"):          "{}\n",
+		filepath.Join(bundlesDir, "b1", "-work-api", "shared.jsonl"):     "{}\n",
+		filepath.Join(bundlesDir, "b1", "-home-bob-app", "bob.jsonl.gz"): "",
+		filepath.Join(bundlesDir, ".import-1", "-work-tmp", "t.jsonl"):   "{}\n",
+	}
+	for path, data := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	loc := NewSessionLocator()
+	if roots := loc.BundleRoots(); len(roots) != 1 || roots[0] != filepath.Join(bundlesDir, "b1") {
+		t.Fatalf("Expected only the b1 bundle root, got %v", roots)
+	}
+
+	projects, err := loc.ListProjects(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(projects) != 3 || projects[0].Name != "-home-bob-app" || projects[1].Bundle != "" || projects[2].Bundle == "" {
+		t.Errorf("Expected local and bundled projects, got %+v", projects)
+	}
+
+	path, err := loc.FromSessionID("bob")
+	if err != nil || path != filepath.Join(bundlesDir, "b1", "-home-bob-app", "bob.jsonl.gz") {
+		t.Errorf("Expected bundled session, got %s, %v", path, err)
+	}
+
+	sessions, err := loc.AllSessionsFromProject("/work/api")
+	if err != nil || len(sessions) != 2 {
+		t.Errorf("Expected local and bundled sessions of /work/api, got %v, %v", sessions, err)
+	}
+
+	// 显式指定根目录时不搜索会话包
+	if _, err := NewSessionLocatorWithRoot(projectsRoot).FromSessionID("bob"); err == nil {
+		t.Error("Expected bundles to be ignored by NewSessionLocatorWithRoot")
+	}
+}
@@ -4,8 +4,9 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
+
+	"github.com/yaleh/meta-cc/internal/sessionio"
 )
 
 // SessionParser 负责解析 Claude Code 会话文件
@@ -28,7 +29,8 @@ func NewSessionParser(filePath string) *SessionParser {
 //   - 仅返回消息类型（type == "user" 或 "assistant"）
 //   - 过滤掉 file-history-snapshot 等非消息类型
 func (p *SessionParser) ParseEntries() ([]SessionEntry, error) {
-	file, err := os.Open(p.filePath)
+	// 归档会话（.jsonl.gz / .jsonl.zst）透明解压
+	file, err := sessionio.Open(p.filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open session file: %w", err)
 	}
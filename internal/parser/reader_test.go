@@ -256,3 +256,24 @@ func TestParseEntriesFromContent_FilterNonMessageTypes(t *testing.T) {
 		}
 	}
 }
+
+func TestParseSession_ArchivedFile(t *testing.T) {
+	// 归档会话（zstd）与原始文件解析结果一致
+	plain, err := NewSessionParser(testutil.FixtureDir() + "/session-with-errors.jsonl").ParseEntries()
+	if err != nil {
+		t.Fatalf("Failed to parse plain session: %v", err)
+	}
+	archived, err := NewSessionParser(testutil.FixtureDir() + "/session-with-errors.jsonl.zst").ParseEntries()
+	if err != nil {
+		t.Fatalf("Failed to parse archived session: %v", err)
+	}
+
+	if len(archived) == 0 || len(archived) != len(plain) {
+		t.Fatalf("Expected %d entries, got %d", len(plain), len(archived))
+	}
+	for i := range plain {
+		if archived[i].UUID != plain[i].UUID {
+			t.Errorf("Entry %d: expected UUID %s, got %s", i, plain[i].UUID, archived[i].UUID)
+		}
+	}
+}
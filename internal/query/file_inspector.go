@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"os"
 	"time"
+
+	"github.com/yaleh/meta-cc/internal/index"
+	"github.com/yaleh/meta-cc/internal/sessionio"
 )
 
 // RecordSample represents a sample record from a session file
@@ -40,6 +43,11 @@ type InspectionResult struct {
 
 // InspectFiles inspects one or more session files and returns metadata
 func InspectFiles(files []string, includeSamples bool) (*InspectionResult, error) {
+	return InspectFilesWithProgress(files, includeSamples, nil)
+}
+
+// InspectFilesWithProgress is InspectFiles with a per-file progress callback
+func InspectFilesWithProgress(files []string, includeSamples bool, progress ProgressFunc) (*InspectionResult, error) {
 	result := &InspectionResult{
 		Files: make([]FileMetadata, 0, len(files)),
 		Summary: InspectionSummary{
@@ -56,13 +64,28 @@ func InspectFiles(files []string, includeSamples bool) (*InspectionResult, error
 		result.Files = append(result.Files, *metadata)
 		result.Summary.TotalSizeBytes += metadata.SizeBytes
 		result.Summary.TotalRecords += metadata.LineCount
+		progress.report(ScanProgress{
+			FilesProcessed: len(result.Files),
+			TotalFiles:     len(files),
+			RecordsScanned: result.Summary.TotalRecords,
+			BytesRead:      result.Summary.TotalSizeBytes,
+		})
 	}
 
 	return result, nil
 }
 
-// inspectFile inspects a single session file and returns its metadata
+// inspectFile inspects a single session file and returns its metadata.
+// Without samples the counts come from the session index.
 func inspectFile(path string, includeSamples bool) (*FileMetadata, error) {
+	if !includeSamples {
+		fi, err := index.Default().File(path)
+		if err != nil {
+			return nil, err
+		}
+		return indexedFileMetadata(path, fi), nil
+	}
+
 	// Get file info
 	fileInfo, err := os.Stat(path)
 	if err != nil {
@@ -76,7 +99,7 @@ func inspectFile(path string, includeSamples bool) (*FileMetadata, error) {
 	}
 
 	// Open and read file
-	file, err := os.Open(path)
+	file, err := sessionio.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
@@ -141,6 +164,24 @@ func inspectFile(path string, includeSamples bool) (*FileMetadata, error) {
 	return metadata, nil
 }
 
+// indexedFileMetadata builds file metadata from a session index
+func indexedFileMetadata(path string, fi *index.FileIndex) *FileMetadata {
+	metadata := &FileMetadata{
+		Path:        path,
+		SizeBytes:   fi.Size,
+		LineCount:   fi.Records,
+		RecordTypes: make(map[string]int, len(fi.RecordTypes)),
+	}
+	for recordType, count := range fi.RecordTypes {
+		metadata.RecordTypes[recordType] = count
+	}
+	if fi.HasTimeRange() {
+		metadata.TimeRange.Start = fi.StartTime.Format(time.RFC3339)
+		metadata.TimeRange.End = fi.EndTime.Format(time.RFC3339)
+	}
+	return metadata
+}
+
 // parseRecordType extracts the record type from a JSONL line
 func parseRecordType(line string) string {
 	var record map[string]interface{}
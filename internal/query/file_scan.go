@@ -0,0 +1,153 @@
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/yaleh/meta-cc/internal/sessionio"
+)
+
+// maxScanWorkers caps the number of files scanned concurrently; scans are
+// mostly I/O and JSON decoding, so more workers than this rarely helps
+const maxScanWorkers = 8
+
+// scanReadBufferSize is the read buffer of ForEachLine; longer lines are
+// assembled across reads, so it bounds nothing but the I/O size
+const scanReadBufferSize = 64 * 1024
+
+// FileScan is the outcome of scanning a single file
+type FileScan struct {
+	Results []interface{}
+	Records int   // non-blank lines read
+	Bytes   int64 // bytes read, including newlines
+	Err     error
+}
+
+// ScanWorkers returns the default number of files scanned concurrently
+func ScanWorkers() int {
+	return min(runtime.GOMAXPROCS(0), maxScanWorkers)
+}
+
+// ScanFilesOrdered scans files on a bounded pool of workers and hands each
+// outcome to emit in the order of files, regardless of which scan finishes
+// first. Workers run at most a few files ahead of emit, so memory stays
+// bounded by the results of those files. When emit returns false, or ctx is
+// done, scans in flight are cancelled and no further files are started.
+func ScanFilesOrdered(ctx context.Context, files []string, workers int, scan func(ctx context.Context, path string) FileScan, emit func(i int, r FileScan) bool) {
+	if len(files) == 0 {
+		return
+	}
+	if workers <= 0 {
+		workers = ScanWorkers()
+	}
+	workers = min(workers, len(files))
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	// Each file gets its own buffered slot so workers never block on emit;
+	// window tokens limit how far dispatch runs ahead of emit
+	slots := make([]chan FileScan, len(files))
+	for i := range slots {
+		slots[i] = make(chan FileScan, 1)
+	}
+	window := make(chan struct{}, 2*workers)
+	jobs := make(chan int)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for i := range files {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				slots[i] <- scan(ctx, files[i])
+			}
+		}()
+	}
+
+	for i := range files {
+		var r FileScan
+		select {
+		case r = <-slots[i]:
+		case <-ctx.Done():
+			return
+		}
+		<-window
+		if !emit(i, r) {
+			return
+		}
+	}
+}
+
+// ForEachLine calls fn with every non-blank line of a JSONL file and its
+// 1-based line number, reading one line at a time so memory is bounded by the
+// longest line. The line is only valid during the call. Scanning stops when fn returns false or ctx is done.
+// It returns the number of non-blank lines and bytes read.
+func ForEachLine(ctx context.Context, path string, fn func(lineNum int, line []byte) bool) (records int, bytesRead int64, err error) {
+	file, err := sessionio.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, scanReadBufferSize)
+	var long []byte
+	lineNum := 0
+	for {
+		if ctx.Err() != nil {
+			return records, bytesRead, nil
+		}
+
+		line, readErr := reader.ReadSlice('\n')
+		if errors.Is(readErr, bufio.ErrBufferFull) {
+			// Assemble lines longer than the read buffer
+			long = append(long[:0], line...)
+			for errors.Is(readErr, bufio.ErrBufferFull) {
+				line, readErr = reader.ReadSlice('\n')
+				long = append(long, line...)
+			}
+			line = long
+		}
+		if readErr != nil && readErr != io.EOF {
+			return records, bytesRead, readErr
+		}
+
+		lineNum++
+		bytesRead += int64(len(line))
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			records++
+			if !fn(lineNum, trimmed) {
+				return records, bytesRead, nil
+			}
+		}
+		if readErr == io.EOF {
+			return records, bytesRead, nil
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScanFilesOrderedEmitsInFileOrder(t *testing.T) {
+	files := make([]string, 20)
+	for i := range files {
+		files[i] = fmt.Sprintf("f%02d", i)
+	}
+
+	// Earlier files take longer, so scans finish out of order
+	scan := func(ctx context.Context, path string) FileScan {
+		var n int
+		fmt.Sscanf(path, "f%d", &n)
+		time.Sleep(time.Duration(len(files)-n) * time.Millisecond)
+		return FileScan{Results: []interface{}{path}, Records: 1}
+	}
+
+	var got []string
+	ScanFilesOrdered(context.Background(), files, 4, scan, func(i int, r FileScan) bool {
+		if r.Results[0] != files[i] {
+			t.Errorf("emit %d got results of %v", i, r.Results[0])
+		}
+		got = append(got, r.Results[0].(string))
+		return true
+	})
+	if strings.Join(got, ",") != strings.Join(files, ",") {
+		t.Errorf("expected file order, got %v", got)
+	}
+}
+
+func TestScanFilesOrderedStopsEarly(t *testing.T) {
+	files := make([]string, 100)
+	for i := range files {
+		files[i] = fmt.Sprintf("f%d", i)
+	}
+
+	var started atomic.Int32
+	scan := func(ctx context.Context, path string) FileScan {
+		started.Add(1)
+		return FileScan{}
+	}
+
+	emitted := 0
+	ScanFilesOrdered(context.Background(), files, 2, scan, func(i int, r FileScan) bool {
+		emitted++
+		return i < 2
+	})
+	if emitted != 3 {
+		t.Errorf("expected emit to stop after 3 files, got %d", emitted)
+	}
+	// Dispatch runs at most 2*workers files ahead of emit
+	if n := started.Load(); n > 3+4 {
+		t.Errorf("expected scanning to stop near the emitted files, %d of %d started", n, len(files))
+	}
+}
+
+func TestScanFilesOrderedHonorsCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	emitted := 0
+	ScanFilesOrdered(ctx, []string{"a", "b", "c"}, 0, func(ctx context.Context, path string) FileScan {
+		return FileScan{}
+	}, func(i int, r FileScan) bool {
+		emitted++
+		return true
+	})
+	if emitted > 0 {
+		t.Errorf("expected no files emitted after cancellation, got %d", emitted)
+	}
+}
+
+func TestForEachLine(t *testing.T) {
+	long := `{"text":"` + strings.Repeat("x", 3*scanReadBufferSize) + `"}`
+	data := `{"a":1}` + "\n\n  \n" + long + "\n" + `{"b":2}`
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []int
+	var lengths []int
+	records, size, err := ForEachLine(context.Background(), path, func(lineNum int, line []byte) bool {
+		lines = append(lines, lineNum)
+		lengths = append(lengths, len(line))
+		return true
+	})
+	if err != nil {
+		t.Fatalf("ForEachLine failed: %v", err)
+	}
+	if records != 3 || size != int64(len(data)) {
+		t.Errorf("expected 3 records and %d bytes, got %d and %d", len(data), records, size)
+	}
+	if fmt.Sprint(lines) != "[1 4 5]" {
+		t.Errorf("unexpected line numbers %v", lines)
+	}
+	if lengths[1] != len(long) {
+		t.Errorf("expected long line of %d bytes, got %d", len(long), lengths[1])
+	}
+
+	// Stops when fn returns false
+	records, _, _ = ForEachLine(context.Background(), path, func(int, []byte) bool { return false })
+	if records != 1 {
+		t.Errorf("expected scan to stop after the first record, got %d", records)
+	}
+
+	if _, _, err := ForEachLine(context.Background(), path+".missing", func(int, []byte) bool { return true }); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -13,7 +13,7 @@ import (
 // ApplyJQFilter applies a jq expression to JSONL data.
 func ApplyJQFilter(jsonlData string, jqExpr string) (string, error) {
 	normalizedExpr := defaultJQExpression(jqExpr)
-	query, err := parseJQExpression(normalizedExpr)
+	code, err := compileJQExpression(normalizedExpr)
 	if err != nil {
 		return "", err
 	}
@@ -23,7 +23,7 @@ func ApplyJQFilter(jsonlData string, jqExpr string) (string, error) {
 		return "", err
 	}
 
-	results, err := runJQQuery(query, records)
+	results, err := runJQQuery(code, records)
 	if err != nil {
 		return "", err
 	}
@@ -77,15 +77,16 @@ func defaultJQExpression(expr string) string {
 	return expr
 }
 
-func parseJQExpression(expr string) (*gojq.Query, error) {
-	query, err := gojq.Parse(expr)
+// compileJQExpression compiles expr through the shared jq cache
+func compileJQExpression(expr string) (*gojq.Code, error) {
+	code, err := CompileJQ(expr)
 	if err != nil {
 		if isLikelyQuoted(expr) {
 			return nil, fmt.Errorf("jq filter error: '%s' appears to be quoted. Remove outer quotes: use '.[] | {field: .field}' not \"%s\"", expr, expr)
 		}
 		return nil, fmt.Errorf("invalid jq expression '%s': %w", expr, mcerrors.ErrParseError)
 	}
-	return query, nil
+	return code, nil
 }
 
 func isLikelyQuoted(expr string) bool {
@@ -115,9 +116,9 @@ func parseJSONLRecords(jsonlData string) ([]interface{}, error) {
 	return records, nil
 }
 
-func runJQQuery(query *gojq.Query, data []interface{}) ([]interface{}, error) {
+func runJQQuery(code *gojq.Code, data []interface{}) ([]interface{}, error) {
 	var results []interface{}
-	iter := query.Run(data)
+	iter := code.Run(data)
 
 	for {
 		value, ok := iter.Next()
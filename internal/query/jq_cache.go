@@ -0,0 +1,129 @@
+package query
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/itchyny/gojq"
+)
+
+// DefaultJQCacheSize is the number of compiled expressions the shared cache keeps
+const DefaultJQCacheSize = 256
+
+// sharedJQCache is the process-wide cache used by CompileJQ
+var sharedJQCache = NewJQCache(DefaultJQCacheSize)
+
+// JQCacheStats counts the lookups of a JQCache
+type JQCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+// JQCache is an LRU cache of compiled jq expressions. Compiled code is safe
+// to run concurrently, so one cache can serve every query in the process.
+type JQCache struct {
+	mu      sync.Mutex
+	maxSize int
+	order   *list.List               // most recently used first
+	entries map[string]*list.Element // values are *jqCacheEntry
+	stats   JQCacheStats
+}
+
+// jqCacheEntry is an element of JQCache.order
+type jqCacheEntry struct {
+	expr string
+	code *gojq.Code
+}
+
+// NewJQCache creates a cache holding at most maxSize expressions
+func NewJQCache(maxSize int) *JQCache {
+	return &JQCache{
+		maxSize: max(maxSize, 1),
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// CompileJQ compiles a jq expression through the process-wide cache. An empty
+// expression is the identity filter.
+func CompileJQ(expr string) (*gojq.Code, error) {
+	return sharedJQCache.Compile(expr)
+}
+
+// SharedJQCacheStats returns the counters of the process-wide cache
+func SharedJQCacheStats() JQCacheStats {
+	return sharedJQCache.Stats()
+}
+
+// Compile returns the compiled code of expr, compiling and caching it on a
+// miss. Expressions that fail to parse or compile are not cached.
+func (c *JQCache) Compile(expr string) (*gojq.Code, error) {
+	if strings.TrimSpace(expr) == "" {
+		expr = "."
+	}
+	if code := c.Get(expr); code != nil {
+		return code, nil
+	}
+
+	parsed, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jq expression '%s': %w", expr, err)
+	}
+	code, err := gojq.Compile(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile jq expression '%s': %w", expr, err)
+	}
+
+	c.Put(expr, code)
+	return code, nil
+}
+
+// Get returns the cached code of expr, or nil on a miss
+func (c *JQCache) Get(expr string) *gojq.Code {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[expr]
+	if !ok {
+		c.stats.Misses++
+		return nil
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(elem)
+	return elem.Value.(*jqCacheEntry).code
+}
+
+// Put stores compiled code, evicting the least recently used expression when
+// the cache is full
+func (c *JQCache) Put(expr string, code *gojq.Code) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[expr]; ok {
+		elem.Value.(*jqCacheEntry).code = code
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	if c.order.Len() >= c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*jqCacheEntry).expr)
+		c.stats.Evictions++
+	}
+	c.entries[expr] = c.order.PushFront(&jqCacheEntry{expr: expr, code: code})
+}
+
+// Stats returns the cache counters and current size
+func (c *JQCache) Stats() JQCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+	return stats
+}
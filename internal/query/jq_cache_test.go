@@ -0,0 +1,112 @@
+package query
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJQCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewJQCache(3)
+
+	compile := func(expr string) {
+		t.Helper()
+		if _, err := cache.Compile(expr); err != nil {
+			t.Fatalf("Compile(%q) failed: %v", expr, err)
+		}
+	}
+
+	// Test cache miss
+	if cache.Get(".a") != nil {
+		t.Error("expected cache miss, got hit")
+	}
+
+	compile(".a")
+	compile(".b")
+	compile(".c")
+	if cache.Get(".a") == nil {
+		t.Fatal("expected .a to be cached")
+	}
+
+	// .a was used most recently, so .b is evicted
+	compile(".d")
+	if cache.Get(".b") != nil {
+		t.Error("expected .b to be evicted")
+	}
+	for _, expr := range []string{".a", ".c", ".d"} {
+		if cache.Get(expr) == nil {
+			t.Errorf("expected %s to be cached", expr)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Size != 3 || stats.Evictions != 1 {
+		t.Errorf("expected size 3 with 1 eviction, got %+v", stats)
+	}
+}
+
+func TestJQCacheCompile(t *testing.T) {
+	cache := NewJQCache(10)
+
+	first, err := cache.Compile(`select(.type == "user")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cache.Compile(`select(.type == "user")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("expected the cached code on the second compile")
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("expected 1 hit and 1 miss, got %+v", stats)
+	}
+
+	// Empty means identity
+	identity, err := cache.Compile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity != cache.Get(".") {
+		t.Error("expected empty expression cached as identity")
+	}
+
+	// Failures are not cached
+	if _, err := cache.Compile("select(("); err == nil || !strings.Contains(err.Error(), "invalid jq expression") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+	if _, err := cache.Compile("undefined_fn"); err == nil || !strings.Contains(err.Error(), "failed to compile") {
+		t.Errorf("expected compile error, got %v", err)
+	}
+	if cache.Stats().Size != 2 {
+		t.Errorf("expected failed expressions not to be cached, got %+v", cache.Stats())
+	}
+}
+
+func TestJQCacheConcurrentUse(t *testing.T) {
+	cache := NewJQCache(4)
+	exprs := []string{".a", ".b", ".c", ".d", ".e", ".f"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				code, err := cache.Compile(exprs[(i+j)%len(exprs)])
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				iter := code.Run(map[string]interface{}{"a": 1})
+				iter.Next()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := cache.Stats(); stats.Size > 4 || stats.Hits+stats.Misses != 800 {
+		t.Errorf("unexpected stats after concurrent use: %+v", stats)
+	}
+}
@@ -135,8 +135,8 @@ func TestGenerateStats_EmptyData(t *testing.T) {
 	}
 }
 
-func TestParseJQExpressionQuotedError(t *testing.T) {
-	_, err := parseJQExpression(`'.[]'`)
+func TestCompileJQExpressionQuotedError(t *testing.T) {
+	_, err := compileJQExpression(`'.[]'`)
 	if err == nil {
 		t.Fatal("expected quoted expression to return error")
 	}
@@ -0,0 +1,12 @@
+package query
+
+import (
+	"os"
+	"testing"
+
+	"github.com/yaleh/meta-cc/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithTempIndex(m))
+}
@@ -0,0 +1,165 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/yaleh/meta-cc/internal/index"
+)
+
+// prefilter.go lets scans avoid work a query cannot need: lines a query
+// cannot match are not decoded (LinePrefilter), and files the session index
+// shows hold no such line are not read at all (SkipIndexed). Files that are
+// not indexed yet are indexed by the scan that reads them (ForEachIndexedLine).
+
+// typeClausePattern matches `.type == "a"` optionally followed by `or .type == "b"` clauses
+var typeClausePattern = regexp.MustCompile(`^\s*\.type\s*==\s*"[^"]+"(\s+or\s+\.type\s*==\s*"[^"]+")*`)
+
+// orPattern finds `or` operators that could widen a type clause
+var orPattern = regexp.MustCompile(`\bor\b`)
+
+// typeValuePattern extracts the record types of a type clause
+var typeValuePattern = regexp.MustCompile(`\.type\s*==\s*"([^"]+)"`)
+
+// FilterRecordTypes returns the record types a jq filter can match when its
+// first stage selects on .type, e.g. `select(.type == "user" and ...) | ...`.
+// It returns nil whenever the filter might match other records.
+func FilterRecordTypes(filter string) []string {
+	filter = strings.TrimSpace(filter)
+	if !strings.HasPrefix(filter, "select(") {
+		return nil
+	}
+
+	// Find the parenthesis closing select( and require a pipe or the end after it
+	depth, end, inString := 0, -1, false
+	for i := len("select"); i < len(filter) && end < 0; i++ {
+		switch c := filter[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return nil
+	}
+	if after := strings.TrimSpace(filter[end+1:]); after != "" && !strings.HasPrefix(after, "|") {
+		return nil
+	}
+
+	body := filter[len("select("):end]
+	clause := typeClausePattern.FindString(body)
+	if clause == "" {
+		return nil
+	}
+	// Anything after the type clause must narrow it: `and ...` without any `or`
+	if rest := strings.TrimSpace(body[len(clause):]); rest != "" {
+		if !strings.HasPrefix(rest, "and ") && !strings.HasPrefix(rest, "and(") || orPattern.MatchString(rest) {
+			return nil
+		}
+	}
+
+	var types []string
+	for _, m := range typeValuePattern.FindAllStringSubmatch(clause, -1) {
+		types = append(types, m[1])
+	}
+	return types
+}
+
+// LinePrefilter is a cheap byte-level test passed by every line a query can
+// match. It relies on session records spelling keys and ASCII values without
+// escapes, which is how Claude Code writes them.
+type LinePrefilter struct {
+	anyOf [][]byte // a line must contain one of these, if any are set
+	allOf [][]byte // and every one of these
+
+	recordTypes []string // the record types and tokens the filter was built from
+	tokens      []string
+}
+
+// NewLinePrefilter builds a prefilter from the record types a query can match
+// and literal tokens every matching line contains
+func NewLinePrefilter(recordTypes []string, tokens []string) LinePrefilter {
+	p := LinePrefilter{recordTypes: recordTypes, tokens: tokens}
+	for _, t := range recordTypes {
+		p.anyOf = append(p.anyOf, []byte(`"`+t+`"`))
+	}
+	for _, t := range tokens {
+		p.allOf = append(p.allOf, []byte(t))
+	}
+	return p
+}
+
+// Match reports whether a line may match the query
+func (p LinePrefilter) Match(line []byte) bool {
+	for _, token := range p.allOf {
+		if !bytes.Contains(line, token) {
+			return false
+		}
+	}
+	if len(p.anyOf) == 0 {
+		return true
+	}
+	for _, token := range p.anyOf {
+		if bytes.Contains(line, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipIndexed reports whether the up-to-date session index of path shows the
+// file holds no line p can match, along with the file's indexed totals as
+// ForEachLine would count them. Files not indexed yet, or with lines that do
+// not decode, are never skipped.
+func (p LinePrefilter) SkipIndexed(path string) (records int, bytesRead int64, skip bool) {
+	if len(p.recordTypes) == 0 && len(p.tokens) == 0 {
+		return 0, 0, false
+	}
+	fi, ok := index.Default().Lookup(path)
+	if !ok || fi.Err() != nil {
+		return 0, 0, false
+	}
+	skip = len(p.recordTypes) > 0
+	for _, t := range p.recordTypes {
+		if fi.RecordTypes[t] > 0 {
+			skip = false
+		}
+	}
+	for _, token := range p.tokens {
+		if fi.Lacks(token) {
+			skip = true
+		}
+	}
+	if !skip {
+		return 0, 0, false
+	}
+	return fi.Records, fi.Offset, true
+}
+
+// ForEachIndexedLine is ForEachLine that also builds the session index of
+// path from the lines it reads when the index is not up to date, so the first
+// query of a file reads it once. A scan that stops early indexes nothing.
+func ForEachIndexedLine(ctx context.Context, path string, fn func(lineNum int, line []byte) bool) (records int, bytesRead int64, err error) {
+	recorder := index.Default().Record(path)
+	stopped := false
+	records, bytesRead, err = ForEachLine(ctx, path, func(lineNum int, line []byte) bool {
+		recorder.Line(lineNum, line)
+		stopped = !fn(lineNum, line)
+		return !stopped
+	})
+	if err == nil && !stopped && ctx.Err() == nil {
+		recorder.Done(bytesRead)
+	}
+	return records, bytesRead, err
+}
@@ -0,0 +1,139 @@
+package query
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yaleh/meta-cc/internal/index"
+)
+
+func TestFilterRecordTypes(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`select(.type == "user")`, []string{"user"}},
+		{`select(.type == "user" or .type == "assistant")`, []string{"user", "assistant"}},
+		{`select(.type == "user" and (.message.content | type == "array")) | .message`, []string{"user"}},
+		{`select(.type == "file-history-snapshot" and has("messageId"))`, []string{"file-history-snapshot"}},
+		{`select(.type == "user" and .a or .b)`, nil},
+		{`select(.type == "user" or .isMeta)`, nil},
+		{`select(.type == "user"), .summary`, nil},
+		{`select(.timestamp != null)`, nil},
+		{`.[] | select(.type == "user")`, nil},
+		{`select(.type == "user" and (.text | test("\\)\"(")))`, []string{"user"}},
+		{`select(.type == "a\"b")`, nil},
+		{`select(.type == "user"`, nil},
+	}
+	for _, tt := range tests {
+		got := FilterRecordTypes(tt.filter)
+		if len(got) != len(tt.want) {
+			t.Errorf("FilterRecordTypes(%q) = %v, want %v", tt.filter, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("FilterRecordTypes(%q) = %v, want %v", tt.filter, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestLinePrefilter(t *testing.T) {
+	toolUse := []byte(`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Bash"}]}}`)
+	text := []byte(`{"type":"assistant","message":{"content":[{"type":"text","text":"hi"}]}}`)
+	user := []byte(`{"type": "user","message":{"content":"tool_use"}}`)
+
+	tests := []struct {
+		name   string
+		filter LinePrefilter
+		line   []byte
+		want   bool
+	}{
+		{"empty passes everything", NewLinePrefilter(nil, nil), text, true},
+		{"token present", NewLinePrefilter(nil, []string{`"tool_use"`}), toolUse, true},
+		{"token missing", NewLinePrefilter(nil, []string{`"tool_use"`}), text, false},
+		{"one of the record types", NewLinePrefilter([]string{"user", "system"}, nil), user, true},
+		{"none of the record types", NewLinePrefilter([]string{"user", "system"}, nil), toolUse, false},
+		{"type and token", NewLinePrefilter([]string{"assistant"}, []string{`"tool_use"`}), user, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(tt.line); got != tt.want {
+			t.Errorf("%s: match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestForEachIndexedLineIndexesFirstScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	data := testUser1 + "\n" + testAsst1 + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A scan that stops early indexes nothing
+	if _, _, err := ForEachIndexedLine(context.Background(), path, func(int, []byte) bool { return false }); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := index.Default().Lookup(path); ok {
+		t.Fatal("expected no index after a partial scan")
+	}
+
+	records, size, err := ForEachIndexedLine(context.Background(), path, func(int, []byte) bool { return true })
+	if err != nil || records != 2 || size != int64(len(data)) {
+		t.Fatalf("unexpected scan: %d records, %d bytes, %v", records, size, err)
+	}
+	fi, ok := index.Default().Lookup(path)
+	if !ok || fi.Records != 2 || fi.RecordTypes["assistant"] != 1 {
+		t.Fatalf("expected the scan to index the file, got %+v", fi)
+	}
+
+	// The index now lets queries that cannot match skip the file
+	records, size, skip := NewLinePrefilter([]string{"summary"}, nil).SkipIndexed(path)
+	if !skip || records != 2 || size != int64(len(data)) {
+		t.Errorf("expected the file skipped with its totals, got %v %d %d", skip, records, size)
+	}
+	if _, _, skip := NewLinePrefilter(nil, []string{`"timestamp"`}).SkipIndexed(path); skip {
+		t.Error("expected a file with timestamps to be scanned")
+	}
+	if _, _, skip := NewLinePrefilter([]string{"user"}, []string{`"tool_result"`}).SkipIndexed(path); !skip {
+		t.Error("expected a file without tool results to be skipped")
+	}
+}
+
+func TestExecuteStage2QuerySkipsIndexedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	users := filepath.Join(tempDir, "users.jsonl")
+	assistants := filepath.Join(tempDir, "assistants.jsonl")
+	if err := os.WriteFile(users, []byte(testUser1+"\n"+testUser2+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Invalid lines are reported even though the filter cannot match them
+	if err := os.WriteFile(assistants, []byte(testAsst1+"\nnot json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	query := &Stage2Query{Files: []string{users}, Filter: `select(.type == "assistant")`}
+	for i := 0; i < 2; i++ {
+		// The first run indexes the file, the second skips it
+		result, err := ExecuteStage2Query(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Results) != 0 || result.Metadata.TotalRecordsScanned != 2 {
+			t.Errorf("run %d: expected no results of 2 records, got %d of %d", i, len(result.Results), result.Metadata.TotalRecordsScanned)
+		}
+	}
+	if _, ok := index.Default().Lookup(users); !ok {
+		t.Error("expected the scan to index the file")
+	}
+
+	query = &Stage2Query{Files: []string{assistants}, Filter: `select(.type == "user")`}
+	for i := 0; i < 2; i++ {
+		if _, err := ExecuteStage2Query(query); err == nil {
+			t.Errorf("run %d: expected invalid JSON to be reported", i)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package query
+
+// ScanProgress describes how far a multi-file scan has advanced
+type ScanProgress struct {
+	FilesProcessed int   // Files fully scanned so far
+	TotalFiles     int   // Files scheduled for this scan
+	RecordsScanned int   // JSONL records read across all processed files
+	BytesRead      int64 // Bytes read across all processed files
+}
+
+// ProgressFunc receives progress updates from multi-file scans.
+// It is called once after each file completes, so implementations should be cheap.
+type ProgressFunc func(ScanProgress)
+
+// report invokes fn if it is non-nil
+func (fn ProgressFunc) report(p ScanProgress) {
+	if fn != nil {
+		fn(p)
+	}
+}
@@ -0,0 +1,156 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/itchyny/gojq"
+)
+
+// RecordScanner runs a compiled jq expression over every record of a set of
+// JSONL session files. It backs the query tools of the MCP server and the
+// query commands of the CLI, so both return the same records.
+type RecordScanner struct {
+	Progress   ProgressFunc // optional per-file progress callback
+	TagSources bool         // tag object results with project_path and session_id
+	// Prefilter holds the record types and tokens of every line the query can
+	// match; other lines are not decoded, and files the session index shows
+	// have no such line are not read
+	Prefilter LinePrefilter
+	Workers   int // files scanned concurrently (0 = ScanWorkers())
+	// Sampler, if set, is offered every result instead of Run returning them
+	Sampler *Sampler
+}
+
+// Run scans files in parallel and merges their results in the given order
+// (newest first for session lists), stopping as soon as limit results are
+// collected (limit 0 reads every file). Files that fail to read are skipped.
+func (s *RecordScanner) Run(ctx context.Context, files []string, code *gojq.Code, limit int) []interface{} {
+	var results []interface{}
+	recordsScanned := 0
+	var bytesRead int64
+
+	scan := func(ctx context.Context, file string) FileScan {
+		if records, size, skipped := s.Prefilter.SkipIndexed(file); skipped {
+			return FileScan{Records: records, Bytes: size}
+		}
+		return s.ScanFile(ctx, file, code, limit)
+	}
+	ScanFilesOrdered(ctx, files, s.Workers, scan, func(i int, r FileScan) bool {
+		recordsScanned += r.Records
+		bytesRead += r.Bytes
+		if s.Progress != nil {
+			s.Progress(ScanProgress{
+				FilesProcessed: i + 1,
+				TotalFiles:     len(files),
+				RecordsScanned: recordsScanned,
+				BytesRead:      bytesRead,
+			})
+		}
+		if r.Err != nil {
+			// Skip files that fail but continue processing other files
+			return true
+		}
+
+		if s.Sampler != nil {
+			for _, result := range r.Results {
+				s.Sampler.Add(result)
+			}
+			return true
+		}
+		results = append(results, r.Results...)
+		if limit > 0 && len(results) >= limit {
+			results = results[:limit]
+			return false
+		}
+		return true
+	})
+
+	return results
+}
+
+// ScanFile runs code over a single JSONL file one line at a time. Invalid
+// lines and jq errors are skipped. A file never contributes more than limit
+// results, so the scan stops once it has that many (limit 0 reads the whole
+// file).
+func (s *RecordScanner) ScanFile(ctx context.Context, path string, code *gojq.Code, limit int) FileScan {
+	var results []interface{}
+	cwd := ""
+	full := func() bool { return limit > 0 && len(results) >= limit }
+
+	// Reading the whole file also indexes it, for SkipIndexed on later queries
+	records, size, err := ForEachIndexedLine(ctx, path, func(_ int, line []byte) bool {
+		if cwd == "" && s.TagSources {
+			cwd = lineCWD(line)
+		}
+		// Lines the query cannot match are skipped without decoding
+		if !s.Prefilter.Match(line) {
+			return true
+		}
+
+		var entry interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return true
+		}
+
+		iter := code.Run(entry)
+		for !full() {
+			value, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if _, ok := value.(error); ok {
+				continue
+			}
+			results = append(results, value)
+		}
+		return !full()
+	})
+	scan := FileScan{Records: records, Bytes: size}
+	if err != nil {
+		scan.Results = s.tagResults(path, cwd, results)
+		scan.Err = fmt.Errorf("error reading file %s: %w", path, err)
+		return scan
+	}
+	// A scan that stopped early may not have reached the first cwd
+	if cwd == "" && s.TagSources && full() {
+		cwd = ReadSessionCWD(path)
+	}
+
+	scan.Results = s.tagResults(path, cwd, results)
+	return scan
+}
+
+// cwdToken marks lines that may carry a "cwd" field
+var cwdToken = []byte(`"cwd"`)
+
+// lineCWD returns the "cwd" field of a raw JSONL line, if any
+func lineCWD(line []byte) string {
+	if !bytes.Contains(line, cwdToken) {
+		return ""
+	}
+	var record struct {
+		CWD string `json:"cwd"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil {
+		return ""
+	}
+	return record.CWD
+}
+
+// tagResults adds project_path and session_id to results of cross-project queries
+func (s *RecordScanner) tagResults(path, cwd string, results []interface{}) []interface{} {
+	if !s.TagSources {
+		return results
+	}
+	tag := NewSourceTag(path, nil)
+	if cwd != "" {
+		tag.ProjectPath = cwd
+	}
+	for i, r := range results {
+		results[i] = tag.Apply(r)
+	}
+	return results
+}
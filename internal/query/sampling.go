@@ -0,0 +1,273 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// Sampling methods reported in SampleReport
+const (
+	SampleReservoir  = "reservoir"
+	SampleStratified = "stratified"
+)
+
+// noStratum is the stratum of records for which stratify_by yields nothing
+const noStratum = "(none)"
+
+// maxReportedStrata caps the strata listed in a SampleReport; the largest
+// strata are kept and the rest are counted in StrataOmitted
+const maxReportedStrata = 50
+
+// SampleConfig configures a Sampler
+type SampleConfig struct {
+	Size       int    // Records to keep
+	StratifyBy string // Optional field path or jq expression giving the stratum of a record
+	Seed       uint64 // The same seed and input always give the same sample
+}
+
+// SampleReport describes how a sample was drawn, so it can be judged and
+// reproduced
+type SampleReport struct {
+	Method        string                  `json:"method"`
+	SampleSize    int                     `json:"sample_size"`
+	Seed          uint64                  `json:"seed"`
+	StratifyBy    string                  `json:"stratify_by,omitempty"`
+	Population    int                     `json:"population"`
+	Sampled       int                     `json:"sampled"`
+	Strata        map[string]StratumCount `json:"strata,omitempty"`
+	StrataOmitted int                     `json:"strata_omitted,omitempty"`
+}
+
+// StratumCount is the population and sample size of one stratum
+type StratumCount struct {
+	Population int `json:"population"`
+	Sampled    int `json:"sampled"`
+}
+
+// Sampler draws a uniform random sample from a stream of records of unknown
+// length, keeping at most Size records per stratum in memory (reservoir
+// sampling, Algorithm R). With StratifyBy, the sample is allocated across
+// strata in proportion to their populations, at least one record per stratum
+// while the sample has room. The sample keeps the order records were added in.
+type Sampler struct {
+	config SampleConfig
+	key    *gojq.Code
+	rng    *rand.Rand
+	added  int
+	strata map[string]*stratum
+	sample []interface{}
+	report *SampleReport
+}
+
+// stratum is the population count and reservoir of one stratum
+type stratum struct {
+	population int
+	reservoir  []sampledRecord
+	quota      int
+}
+
+// sampledRecord is a record in a reservoir with its position in the input
+type sampledRecord struct {
+	index  int
+	record interface{}
+}
+
+// NewSampler returns a sampler for config. StratifyBy is a jq expression
+// whose first output is the stratum (e.g. '.message.content[0].name'); a
+// bare field path such as 'tool_name' or 'input.command' is accepted too.
+func NewSampler(config SampleConfig) (*Sampler, error) {
+	if config.Size <= 0 {
+		return nil, fmt.Errorf("sample size must be positive (got %d)", config.Size)
+	}
+	s := &Sampler{
+		config: config,
+		rng:    rand.New(rand.NewPCG(config.Seed, 0)),
+		strata: make(map[string]*stratum),
+	}
+	if expr := strings.TrimSpace(config.StratifyBy); expr != "" {
+		if !strings.HasPrefix(expr, ".") {
+			expr = "." + expr
+		}
+		code, err := CompileJQ(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stratify_by %q: %w", config.StratifyBy, err)
+		}
+		s.key = code
+	}
+	return s, nil
+}
+
+// Add offers a record to the sample
+func (s *Sampler) Add(record interface{}) {
+	key := s.stratumOf(record)
+	st, ok := s.strata[key]
+	if !ok {
+		st = &stratum{}
+		s.strata[key] = st
+	}
+	st.population++
+	item := sampledRecord{index: s.added, record: record}
+	s.added++
+
+	if len(st.reservoir) < s.config.Size {
+		st.reservoir = append(st.reservoir, item)
+	} else if j := s.rng.IntN(st.population); j < s.config.Size {
+		st.reservoir[j] = item
+	}
+}
+
+// Records returns the sample in input order. Adding records after Records
+// has been called has no effect on the sample.
+func (s *Sampler) Records() []interface{} {
+	s.finish()
+	return s.sample
+}
+
+// Report returns how the sample was drawn along with population counts
+func (s *Sampler) Report() *SampleReport {
+	s.finish()
+	return s.report
+}
+
+// stratumOf returns the stratum key of a record: strings as is, other
+// values as JSON, noStratum when the expression yields nothing or null
+func (s *Sampler) stratumOf(record interface{}) string {
+	if s.key == nil {
+		return ""
+	}
+	value, ok := s.key.Run(record).Next()
+	if !ok || value == nil {
+		return noStratum
+	}
+	switch v := value.(type) {
+	case error:
+		return noStratum
+	case string:
+		return v
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return noStratum
+	}
+	return string(data)
+}
+
+// finish allocates the sample across strata and draws it once
+func (s *Sampler) finish() {
+	if s.report != nil {
+		return
+	}
+
+	keys := make([]string, 0, len(s.strata))
+	for key := range s.strata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	s.allocate(keys)
+
+	var picked []sampledRecord
+	for _, key := range keys {
+		st := s.strata[key]
+		reservoir := st.reservoir
+		if st.quota < len(reservoir) {
+			// A uniform subset of a uniform reservoir is a uniform sample
+			kept := make([]sampledRecord, st.quota)
+			for i, j := range s.rng.Perm(len(reservoir))[:st.quota] {
+				kept[i] = reservoir[j]
+			}
+			reservoir = kept
+		}
+		picked = append(picked, reservoir...)
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].index < picked[j].index })
+
+	s.sample = make([]interface{}, len(picked))
+	for i, item := range picked {
+		s.sample[i] = item.record
+	}
+	s.report = s.buildReport(keys)
+}
+
+// allocate sets each stratum's quota. Without stratification the single
+// stratum keeps its whole reservoir. Otherwise every stratum gets one record
+// and the rest of the sample is shared in proportion to the remaining
+// populations (largest remainder); when there are more strata than sample
+// slots, the most populous strata get one record each.
+func (s *Sampler) allocate(keys []string) {
+	size := s.config.Size
+	if s.key == nil || s.added <= size {
+		for _, st := range s.strata {
+			st.quota = len(st.reservoir)
+		}
+		return
+	}
+
+	if len(keys) >= size {
+		bySize := append([]string{}, keys...)
+		sort.SliceStable(bySize, func(i, j int) bool {
+			return s.strata[bySize[i]].population > s.strata[bySize[j]].population
+		})
+		for i, key := range bySize {
+			if i < size {
+				s.strata[key].quota = 1
+			}
+		}
+		return
+	}
+
+	remaining := size - len(keys)
+	rest := s.added - len(keys)
+	type share struct {
+		key       string
+		remainder int
+	}
+	shares := make([]share, 0, len(keys))
+	assigned := 0
+	for _, key := range keys {
+		st := s.strata[key]
+		extra := (st.population - 1) * remaining
+		st.quota = 1 + extra/rest
+		assigned += extra / rest
+		shares = append(shares, share{key: key, remainder: extra % rest})
+	}
+	sort.SliceStable(shares, func(i, j int) bool { return shares[i].remainder > shares[j].remainder })
+	for i := 0; i < remaining-assigned; i++ {
+		s.strata[shares[i].key].quota++
+	}
+}
+
+// buildReport summarizes the drawn sample
+func (s *Sampler) buildReport(keys []string) *SampleReport {
+	report := &SampleReport{
+		Method:     SampleReservoir,
+		SampleSize: s.config.Size,
+		Seed:       s.config.Seed,
+		Population: s.added,
+		Sampled:    len(s.sample),
+	}
+	if s.key == nil {
+		return report
+	}
+
+	report.Method = SampleStratified
+	report.StratifyBy = s.config.StratifyBy
+	listed := append([]string{}, keys...)
+	if len(listed) > maxReportedStrata {
+		sort.SliceStable(listed, func(i, j int) bool {
+			return s.strata[listed[i]].population > s.strata[listed[j]].population
+		})
+		report.StrataOmitted = len(listed) - maxReportedStrata
+		listed = listed[:maxReportedStrata]
+	}
+	report.Strata = make(map[string]StratumCount, len(listed))
+	for _, key := range listed {
+		st := s.strata[key]
+		report.Strata[key] = StratumCount{Population: st.population, Sampled: st.quota}
+	}
+	return report
+}
@@ -0,0 +1,169 @@
+package query
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// toolRecords returns n records numbered in order, the first `reads` of
+// them Read calls and the rest Bash calls
+func toolRecords(n, reads int) []interface{} {
+	records := make([]interface{}, n)
+	for i := range records {
+		tool := "Bash"
+		if i < reads {
+			tool = "Read"
+		}
+		records[i] = map[string]interface{}{"n": float64(i), "tool_name": tool}
+	}
+	return records
+}
+
+func drawSample(t *testing.T, config SampleConfig, records []interface{}) ([]interface{}, *SampleReport) {
+	t.Helper()
+	sampler, err := NewSampler(config)
+	if err != nil {
+		t.Fatalf("NewSampler failed: %v", err)
+	}
+	for _, record := range records {
+		sampler.Add(record)
+	}
+	return sampler.Records(), sampler.Report()
+}
+
+func TestSamplerReservoir(t *testing.T) {
+	records := toolRecords(1000, 0)
+	sample, report := drawSample(t, SampleConfig{Size: 10, Seed: 42}, records)
+
+	if len(sample) != 10 {
+		t.Fatalf("expected 10 records, got %d", len(sample))
+	}
+	for i := 1; i < len(sample); i++ {
+		if sample[i-1].(map[string]interface{})["n"].(float64) >= sample[i].(map[string]interface{})["n"].(float64) {
+			t.Fatalf("sample should keep input order: %v", sample)
+		}
+	}
+	want := SampleReport{Method: SampleReservoir, SampleSize: 10, Seed: 42, Population: 1000, Sampled: 10}
+	if !reflect.DeepEqual(*report, want) {
+		t.Errorf("unexpected report: %+v", *report)
+	}
+
+	again, _ := drawSample(t, SampleConfig{Size: 10, Seed: 42}, records)
+	if !reflect.DeepEqual(sample, again) {
+		t.Error("the same seed should draw the same sample")
+	}
+	other, _ := drawSample(t, SampleConfig{Size: 10, Seed: 43}, records)
+	if reflect.DeepEqual(sample, other) {
+		t.Error("another seed should draw another sample")
+	}
+
+	// A population smaller than the sample is returned whole
+	all, report := drawSample(t, SampleConfig{Size: 10}, records[:4])
+	if !reflect.DeepEqual(all, records[:4]) || report.Sampled != 4 {
+		t.Errorf("expected all 4 records, got %v", all)
+	}
+}
+
+func TestSamplerStratified(t *testing.T) {
+	// 990 Bash calls drown out 10 Read calls in a uniform sample
+	records := toolRecords(1000, 10)
+	sample, report := drawSample(t, SampleConfig{Size: 10, StratifyBy: "tool_name", Seed: 1}, records)
+
+	counts := map[string]int{}
+	for _, record := range sample {
+		counts[record.(map[string]interface{})["tool_name"].(string)]++
+	}
+	if counts["Read"] != 1 || counts["Bash"] != 9 {
+		t.Errorf("expected 1 Read and 9 Bash records, got %v", counts)
+	}
+	if report.Method != SampleStratified || report.StratifyBy != "tool_name" || report.Population != 1000 || report.Sampled != 10 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	wantStrata := map[string]StratumCount{"Bash": {Population: 990, Sampled: 9}, "Read": {Population: 10, Sampled: 1}}
+	if !reflect.DeepEqual(report.Strata, wantStrata) {
+		t.Errorf("unexpected strata: %v", report.Strata)
+	}
+}
+
+func TestSamplerStrataAllocation(t *testing.T) {
+	records := make([]interface{}, 0)
+	for i, tool := range []string{"A", "A", "A", "A", "A", "A", "B", "B", "B", "C"} {
+		records = append(records, map[string]interface{}{"n": float64(i), "tool": tool})
+	}
+	// Records without the field form their own stratum
+	records = append(records, map[string]interface{}{"n": 10.0})
+
+	tests := []struct {
+		size int
+		want map[string]int
+	}{
+		{2, map[string]int{"A": 1, "B": 1}},
+		{4, map[string]int{"A": 1, "B": 1, "C": 1, noStratum: 1}},
+		{7, map[string]int{"A": 3, "B": 2, "C": 1, noStratum: 1}},
+		{20, map[string]int{"A": 6, "B": 3, "C": 1, noStratum: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.size), func(t *testing.T) {
+			sample, report := drawSample(t, SampleConfig{Size: tt.size, StratifyBy: ".tool"}, records)
+			got := map[string]int{}
+			for key, stratum := range report.Strata {
+				if stratum.Sampled > 0 {
+					got[key] = stratum.Sampled
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected allocation %v, got %v", tt.want, got)
+			}
+			if len(sample) != report.Sampled {
+				t.Errorf("report says %d sampled, got %d records", report.Sampled, len(sample))
+			}
+		})
+	}
+}
+
+func TestNewSamplerErrors(t *testing.T) {
+	if _, err := NewSampler(SampleConfig{}); err == nil {
+		t.Error("expected error for zero sample size")
+	}
+	if _, err := NewSampler(SampleConfig{Size: 1, StratifyBy: "tool_name |"}); err == nil {
+		t.Error("expected error for invalid stratify_by")
+	}
+}
+
+func TestExecuteStage2Query_Sample(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.jsonl")
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf(`{"type":"user","n":%d}`, i))
+	}
+	if err := os.WriteFile(testFile, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	query := &Stage2Query{
+		Files:  []string{testFile},
+		Filter: `select(.type == "user")`,
+		Limit:  3,
+		Sample: &SampleConfig{Size: 5, Seed: 9},
+	}
+	result, err := ExecuteStage2Query(query)
+	if err != nil {
+		t.Fatalf("ExecuteStage2Query failed: %v", err)
+	}
+
+	// Limit applies to the sample, which is drawn from every match
+	if len(result.Results) != 3 || !result.Metadata.Truncated {
+		t.Errorf("expected 3 truncated results, got %d", len(result.Results))
+	}
+	if result.Sampling == nil || result.Sampling.Population != 100 || result.Sampling.Sampled != 5 {
+		t.Fatalf("unexpected sampling report: %+v", result.Sampling)
+	}
+	if result.Metadata.TotalRecordsScanned != 100 {
+		t.Errorf("expected every record scanned, got %d", result.Metadata.TotalRecordsScanned)
+	}
+}
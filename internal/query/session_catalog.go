@@ -0,0 +1,250 @@
+package query
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yaleh/meta-cc/internal/sessionio"
+)
+
+// maxPromptPreview caps the length of SessionHeader.FirstPrompt
+const maxPromptPreview = 200
+
+// TokenUsage totals the token usage reported by assistant messages
+type TokenUsage struct {
+	Input         int64 `json:"input"`
+	Output        int64 `json:"output"`
+	CacheCreation int64 `json:"cache_creation"`
+	CacheRead     int64 `json:"cache_read"`
+}
+
+// Total returns the sum of all token counters
+func (u TokenUsage) Total() int64 {
+	return u.Input + u.Output + u.CacheCreation + u.CacheRead
+}
+
+// SessionHeader summarizes a session file for browsing: what it was about,
+// when it ran and how large it was. It is built by ScanSessionHeader without
+// decoding message bodies beyond what the summary needs.
+type SessionHeader struct {
+	SessionID       string     `json:"session_id"`
+	Path            string     `json:"path"`
+	ProjectPath     string     `json:"project_path,omitempty"`
+	SizeBytes       int64      `json:"size_bytes"`
+	Archived        bool       `json:"archived,omitempty"`
+	FirstPrompt     string     `json:"first_prompt,omitempty"`
+	Summary         string     `json:"summary,omitempty"`
+	StartTime       string     `json:"start_time,omitempty"`
+	EndTime         string     `json:"end_time,omitempty"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	Turns           int        `json:"turns"`
+	Tokens          TokenUsage `json:"tokens"`
+	GitBranches     []string   `json:"git_branches,omitempty"`
+	Versions        []string   `json:"versions,omitempty"`
+}
+
+// headerRecord holds the only fields of a session record ScanSessionHeader reads
+type headerRecord struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	CWD       string `json:"cwd"`
+	GitBranch string `json:"gitBranch"`
+	Version   string `json:"version"`
+	IsMeta    bool   `json:"isMeta"`
+	Summary   string `json:"summary"`
+	Message   *struct {
+		ID      string          `json:"id"`
+		Content json.RawMessage `json:"content"`
+		Usage   *struct {
+			InputTokens              int64 `json:"input_tokens"`
+			OutputTokens             int64 `json:"output_tokens"`
+			CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+}
+
+// ScanSessionHeader reads a session file once and summarizes it.
+// Turns count user prompts (tool results and meta messages excluded); tokens
+// are counted once per assistant message even when Claude Code streams a
+// message over several records. Malformed lines are skipped.
+func ScanSessionHeader(path string) (*SessionHeader, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	file, err := sessionio.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	header := &SessionHeader{
+		SessionID: NewSourceTag(path, nil).SessionID,
+		Path:      path,
+		SizeBytes: info.Size(),
+		Archived:  sessionio.IsCompressed(path),
+	}
+
+	scanner := bufio.NewScanner(file)
+	maxCapacity := 10 * 1024 * 1024 // 10MB max line length
+	scanner.Buffer(make([]byte, 64*1024), maxCapacity)
+
+	var start, end time.Time
+	countedMessages := make(map[string]bool)
+	branches := make(map[string]bool)
+	versions := make(map[string]bool)
+
+	for scanner.Scan() {
+		var record headerRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+
+		if t, err := time.Parse(time.RFC3339, record.Timestamp); err == nil {
+			if start.IsZero() || t.Before(start) {
+				start = t
+			}
+			if end.IsZero() || t.After(end) {
+				end = t
+			}
+		}
+		if header.ProjectPath == "" {
+			header.ProjectPath = record.CWD
+		}
+		if record.GitBranch != "" && !branches[record.GitBranch] {
+			branches[record.GitBranch] = true
+			header.GitBranches = append(header.GitBranches, record.GitBranch)
+		}
+		if record.Version != "" && !versions[record.Version] {
+			versions[record.Version] = true
+			header.Versions = append(header.Versions, record.Version)
+		}
+
+		switch record.Type {
+		case "summary":
+			// Later summaries describe the conversation as it continued
+			if record.Summary != "" {
+				header.Summary = record.Summary
+			}
+		case "user":
+			if record.IsMeta || record.Message == nil {
+				continue
+			}
+			if prompt, ok := promptText(record.Message.Content); ok {
+				header.Turns++
+				if header.FirstPrompt == "" {
+					header.FirstPrompt = truncatePrompt(prompt)
+				}
+			}
+		case "assistant":
+			msg := record.Message
+			if msg == nil || msg.Usage == nil {
+				continue
+			}
+			if msg.ID != "" {
+				if countedMessages[msg.ID] {
+					continue
+				}
+				countedMessages[msg.ID] = true
+			}
+			header.Tokens.Input += msg.Usage.InputTokens
+			header.Tokens.Output += msg.Usage.OutputTokens
+			header.Tokens.CacheCreation += msg.Usage.CacheCreationInputTokens
+			header.Tokens.CacheRead += msg.Usage.CacheReadInputTokens
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	if !start.IsZero() {
+		header.StartTime = start.Format(time.RFC3339)
+		header.EndTime = end.Format(time.RFC3339)
+		header.DurationSeconds = int64(end.Sub(start).Seconds())
+	}
+
+	return header, nil
+}
+
+// ScanSessionHeaders scans several session files, reporting progress per file
+func ScanSessionHeaders(files []string, progress ProgressFunc) ([]SessionHeader, error) {
+	headers := make([]SessionHeader, 0, len(files))
+	var bytesRead int64
+	for _, path := range files {
+		header, err := ScanSessionHeader(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session %s: %w", path, err)
+		}
+		headers = append(headers, *header)
+		bytesRead += header.SizeBytes
+		progress.report(ScanProgress{
+			FilesProcessed: len(headers),
+			TotalFiles:     len(files),
+			BytesRead:      bytesRead,
+		})
+	}
+	return headers, nil
+}
+
+// ReadSessionCWD returns the first "cwd" recorded in a session file, reading
+// only as far as needed. It returns "" when the file has none.
+func ReadSessionCWD(path string) string {
+	file, err := sessionio.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var record struct {
+			CWD string `json:"cwd"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &record) == nil && record.CWD != "" {
+			return record.CWD
+		}
+	}
+	return ""
+}
+
+// promptText extracts the text a user typed from message content, which is
+// either a plain string or an array of content blocks. Content made only of
+// tool results is not a prompt.
+func promptText(content json.RawMessage) (string, bool) {
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return text, strings.TrimSpace(text) != ""
+	}
+
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &blocks); err != nil {
+		return "", false
+	}
+	var parts []string
+	for _, block := range blocks {
+		if block.Type == "text" && strings.TrimSpace(block.Text) != "" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n"), len(parts) > 0
+}
+
+// truncatePrompt collapses whitespace and caps a prompt at maxPromptPreview runes
+func truncatePrompt(prompt string) string {
+	prompt = strings.Join(strings.Fields(prompt), " ")
+	runes := []rune(prompt)
+	if len(runes) <= maxPromptPreview {
+		return prompt
+	}
+	return string(runes[:maxPromptPreview-3]) + "..."
+}
@@ -0,0 +1,105 @@
+package query
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const catalogSession = `{"type":"summary","summary":"Early title","leafUuid":"x"}
+{"type":"user","isMeta":true,"cwd":"/work/api","timestamp":"2025-10-02T10:00:00Z","message":{"role":"user","content":"Caveat: meta"}}
+{"type":"user","cwd":"/work/api","gitBranch":"main","version":"1.0.1","timestamp":"2025-10-02T10:00:05Z","message":{"role":"user","content":"Fix   the\nlogin bug"}}
+{"type":"assistant","gitBranch":"main","version":"1.0.1","timestamp":"2025-10-02T10:00:10Z","message":{"id":"m1","role":"assistant","content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":100}}}
+{"type":"assistant","gitBranch":"main","version":"1.0.1","timestamp":"2025-10-02T10:00:11Z","message":{"id":"m1","role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{}}],"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":100}}}
+{"type":"user","gitBranch":"main","timestamp":"2025-10-02T10:00:20Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"done"}]}}
+not json
+{"type":"user","gitBranch":"feature/x","version":"1.0.2","timestamp":"2025-10-02T10:30:00Z","message":{"role":"user","content":[{"type":"text","text":"Now add tests"}]}}
+{"type":"assistant","timestamp":"2025-10-02T10:31:00Z","message":{"id":"m2","role":"assistant","content":[],"usage":{"input_tokens":20,"output_tokens":7,"cache_creation_input_tokens":3}}}
+{"type":"summary","summary":"Login bug fix and tests","leafUuid":"y"}
+`
+
+func TestScanSessionHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "abc.jsonl")
+	if err := os.WriteFile(path, []byte(catalogSession), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := ScanSessionHeader(path)
+	if err != nil {
+		t.Fatalf("ScanSessionHeader failed: %v", err)
+	}
+
+	if header.SessionID != "abc" || header.ProjectPath != "/work/api" {
+		t.Errorf("unexpected identity: %+v", header)
+	}
+	if header.FirstPrompt != "Fix the login bug" {
+		t.Errorf("unexpected first prompt %q", header.FirstPrompt)
+	}
+	if header.Summary != "Login bug fix and tests" {
+		t.Errorf("expected latest summary, got %q", header.Summary)
+	}
+	if header.Turns != 2 {
+		t.Errorf("expected 2 turns (meta and tool results excluded), got %d", header.Turns)
+	}
+	if header.StartTime != "2025-10-02T10:00:00Z" || header.EndTime != "2025-10-02T10:31:00Z" || header.DurationSeconds != 1860 {
+		t.Errorf("unexpected time range %s - %s (%ds)", header.StartTime, header.EndTime, header.DurationSeconds)
+	}
+
+	want := TokenUsage{Input: 30, Output: 12, CacheCreation: 3, CacheRead: 100}
+	if header.Tokens != want {
+		t.Errorf("expected streamed message usage counted once: got %+v, want %+v", header.Tokens, want)
+	}
+	if header.Tokens.Total() != 145 {
+		t.Errorf("unexpected total %d", header.Tokens.Total())
+	}
+	if !reflect.DeepEqual(header.GitBranches, []string{"main", "feature/x"}) {
+		t.Errorf("unexpected branches %v", header.GitBranches)
+	}
+	if !reflect.DeepEqual(header.Versions, []string{"1.0.1", "1.0.2"}) {
+		t.Errorf("unexpected versions %v", header.Versions)
+	}
+}
+
+func TestScanSessionHeadersTruncatesPrompt(t *testing.T) {
+	dir := t.TempDir()
+	long := strings.Repeat("word ", 100)
+	path := filepath.Join(dir, "long.jsonl")
+	data := `{"type":"user","message":{"role":"user","content":"` + long + `"}}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var progressCalls int
+	headers, err := ScanSessionHeaders([]string{path}, func(ScanProgress) { progressCalls++ })
+	if err != nil {
+		t.Fatalf("ScanSessionHeaders failed: %v", err)
+	}
+	if len(headers) != 1 || progressCalls != 1 {
+		t.Fatalf("expected one header and one progress report, got %d/%d", len(headers), progressCalls)
+	}
+	if n := len([]rune(headers[0].FirstPrompt)); n != maxPromptPreview || !strings.HasSuffix(headers[0].FirstPrompt, "...") {
+		t.Errorf("expected prompt truncated to %d runes, got %d", maxPromptPreview, n)
+	}
+	if headers[0].StartTime != "" || headers[0].DurationSeconds != 0 {
+		t.Errorf("expected no time range without timestamps, got %+v", headers[0])
+	}
+
+	if _, err := ScanSessionHeaders([]string{filepath.Join(dir, "missing.jsonl")}, nil); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestReadSessionCWD(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	if err := os.WriteFile(path, []byte(catalogSession), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := ReadSessionCWD(path); got != "/work/api" {
+		t.Errorf("expected /work/api, got %q", got)
+	}
+	if got := ReadSessionCWD(path + ".missing"); got != "" {
+		t.Errorf("expected empty cwd for missing file, got %q", got)
+	}
+}
@@ -0,0 +1,73 @@
+package query
+
+import (
+	"path/filepath"
+
+	"github.com/yaleh/meta-cc/internal/sessionio"
+)
+
+// Fields added to object results of cross-project queries so each record
+// can be traced back to the project and session it came from
+const (
+	ProjectPathField = "project_path"
+	SessionIDField   = "session_id"
+)
+
+// SourceTag identifies the project and session a session file belongs to
+type SourceTag struct {
+	ProjectPath string
+	SessionID   string
+}
+
+// NewSourceTag derives the tag of a session file. The project path is the
+// first "cwd" found in records; when none is present it falls back to the
+// project directory name (the path hash used by Claude Code).
+func NewSourceTag(path string, records []interface{}) SourceTag {
+	tag := SourceTag{
+		ProjectPath: filepath.Base(filepath.Dir(path)),
+		SessionID:   sessionio.SessionID(path),
+	}
+	for _, record := range records {
+		if cwd := RecordCWD(record); cwd != "" {
+			tag.ProjectPath = cwd
+			break
+		}
+	}
+	return tag
+}
+
+// RecordCWD returns the "cwd" field of a raw session record, if any
+func RecordCWD(record interface{}) string {
+	if m, ok := record.(map[string]interface{}); ok {
+		if cwd, ok := m["cwd"].(string); ok {
+			return cwd
+		}
+	}
+	return ""
+}
+
+// Apply adds the tag fields to an object result without overwriting fields
+// the query produced itself. Non-object results are returned unchanged.
+func (t SourceTag) Apply(value interface{}) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	if _, exists := m[ProjectPathField]; !exists {
+		m[ProjectPathField] = t.ProjectPath
+	}
+	if _, exists := m[SessionIDField]; !exists {
+		m[SessionIDField] = t.SessionID
+	}
+	return m
+}
+
+// SpansProjects reports whether files live in more than one project directory
+func SpansProjects(files []string) bool {
+	for _, f := range files[min(1, len(files)):] {
+		if filepath.Dir(f) != filepath.Dir(files[0]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package query
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSourceTag(t *testing.T) {
+	path := filepath.Join("root", "-work-api", "abc.jsonl")
+
+	tag := NewSourceTag(path, []interface{}{
+		map[string]interface{}{"type": "summary"},
+		map[string]interface{}{"type": "user", "cwd": "/work/api"},
+	})
+	if tag.ProjectPath != "/work/api" || tag.SessionID != "abc" {
+		t.Errorf("unexpected tag %+v", tag)
+	}
+
+	tag = NewSourceTag(path, nil)
+	if tag.ProjectPath != "-work-api" {
+		t.Errorf("expected project directory fallback, got %q", tag.ProjectPath)
+	}
+}
+
+func TestSourceTagApply(t *testing.T) {
+	tag := SourceTag{ProjectPath: "/work/api", SessionID: "abc"}
+
+	record := tag.Apply(map[string]interface{}{"type": "user", SessionIDField: "kept"}).(map[string]interface{})
+	if record[ProjectPathField] != "/work/api" {
+		t.Errorf("expected project_path to be added, got %v", record)
+	}
+	if record[SessionIDField] != "kept" {
+		t.Errorf("existing fields must not be overwritten, got %v", record)
+	}
+
+	if got := tag.Apply("text"); got != "text" {
+		t.Errorf("non-object results must be unchanged, got %v", got)
+	}
+}
+
+func TestSpansProjects(t *testing.T) {
+	if SpansProjects(nil) || SpansProjects([]string{"/r/a/1.jsonl", "/r/a/2.jsonl"}) {
+		t.Error("files of one project must not span projects")
+	}
+	if !SpansProjects([]string{"/r/a/1.jsonl", "/r/b/2.jsonl"}) {
+		t.Error("files of two projects must span projects")
+	}
+}
+
+func TestExecuteStage2Query_TagSources(t *testing.T) {
+	root := t.TempDir()
+	var files []string
+	for _, dir := range []string{"-work-api", "-work-web"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		file := filepath.Join(root, dir, "s-"+dir[6:]+".jsonl")
+		data := `{"type":"user","cwd":"/work/` + dir[6:] + `","message":{"content":"hi"}}` + "\n"
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		files = append(files, file)
+	}
+
+	result, err := ExecuteStage2Query(&Stage2Query{
+		Files:      files,
+		Filter:     `select(.type == "user")`,
+		Transform:  `{type}`,
+		TagSources: true,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteStage2Query failed: %v", err)
+	}
+	if len(result.Results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(result.Results))
+	}
+	for i, want := range []string{"/work/api", "/work/web"} {
+		record := result.Results[i].(map[string]interface{})
+		if record[ProjectPathField] != want || record[SessionIDField] != "s-"+want[6:] {
+			t.Errorf("result %d: unexpected tags %v", i, record)
+		}
+	}
+}
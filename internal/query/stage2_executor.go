@@ -1,14 +1,14 @@
 package query
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/itchyny/gojq"
+	"github.com/yaleh/meta-cc/internal/index"
 )
 
 // Stage2Query represents a Stage 2 query request
@@ -18,12 +18,19 @@ type Stage2Query struct {
 	Sort      string   // jq sort expression (optional)
 	Transform string   // jq transform expression (optional)
 	Limit     int      // Maximum number of results (0 = no limit)
+
+	Sample *SampleConfig // Optional; return a random sample of the results instead of the first ones
+
+	TagSources bool            // Tag object results with project_path and session_id
+	OnProgress ProgressFunc    // Optional per-file progress callback
+	Context    context.Context // Optional; cancelling it stops the scan
 }
 
 // Stage2Result represents the result of a Stage 2 query
 type Stage2Result struct {
 	Results  []interface{} `json:"results"`
 	Metadata QueryMetadata `json:"metadata"`
+	Sampling *SampleReport `json:"sampling,omitempty"`
 }
 
 // QueryMetadata contains metadata about the query execution
@@ -47,25 +54,55 @@ func ExecuteStage2Query(query *Stage2Query) (*Stage2Result, error) {
 		return nil, fmt.Errorf("filter parameter is required")
 	}
 
-	// Build combined jq expression
-	jqExpr := buildJQExpression(query.Filter, query.Sort, query.Transform)
+	// Compile the query into its per-record and per-file parts
+	plan, err := newStage2Plan(query.Filter, query.Sort, query.Transform)
+	if err != nil {
+		return nil, err
+	}
 
 	// Execute query with streaming
-	results, metadata, err := streamFilesWithJQ(query.Files, jqExpr, query.Limit)
+	ctx := query.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// A sample is drawn from every match, so the scan must not stop at limit
+	limit := query.Limit
+	var sampler *Sampler
+	if query.Sample != nil {
+		if sampler, err = NewSampler(*query.Sample); err != nil {
+			return nil, err
+		}
+		limit = 0
+	}
+	results, metadata, err := streamFilesWithJQ(ctx, query.Files, plan, limit, query.TagSources, query.OnProgress, sampler)
 	if err != nil {
 		return nil, err
 	}
 
+	var sampling *SampleReport
+	if sampler != nil {
+		results = sampler.Records()
+		if query.Limit > 0 && len(results) > query.Limit {
+			results = results[:query.Limit]
+			metadata.Truncated = true
+		}
+		metadata.ResultsReturned = len(results)
+		sampling = sampler.Report()
+	}
+
 	// Add execution time to metadata
 	metadata.ExecutionTimeMs = time.Since(start).Milliseconds()
 
 	return &Stage2Result{
 		Results:  results,
 		Metadata: *metadata,
+		Sampling: sampling,
 	}, nil
 }
 
-// buildJQExpression combines filter, sort, and transform into a single jq expression
+// buildJQExpression combines filter, sort, and transform into the single jq
+// expression a Stage 2 query applies to the records of each file. Execution
+// evaluates it incrementally through newStage2Plan.
 func buildJQExpression(filter, sort, transform string) string {
 	// If we have sorting, we need to use a different pipeline:
 	// 1. Collect filtered items into array: [.[] | filter]
@@ -112,103 +149,189 @@ func buildJQExpression(filter, sort, transform string) string {
 	return strings.Join(parts, " | ")
 }
 
-// streamFilesWithJQ executes a jq expression on multiple files with streaming
-func streamFilesWithJQ(files []string, jqExpr string, limit int) ([]interface{}, *QueryMetadata, error) {
-	// Parse jq expression
-	query, err := gojq.Parse(jqExpr)
-	if err != nil {
-		return nil, nil, fmt.Errorf("invalid jq expression '%s': %w", jqExpr, err)
-	}
+// stage2Plan splits a Stage 2 query into the part run on each record as it is
+// read and the part run once per file on the collected matches. Only sorting
+// needs the collected matches; without it every record is processed alone.
+type stage2Plan struct {
+	perRecord *gojq.Code
+	perFile   *gojq.Code // nil unless the query sorts
+	// prefilter holds the record types the filter can match, when its first
+	// stage selects on .type
+	prefilter LinePrefilter
+}
 
-	metadata := &QueryMetadata{
-		FilesProcessed:      0,
-		TotalRecordsScanned: 0,
-		ResultsReturned:     0,
-		Truncated:           false,
+// newStage2Plan compiles the per-record and per-file parts of a query through
+// the shared jq cache. The plan is equivalent to running buildJQExpression on each file's records.
+func newStage2Plan(filter, sort, transform string) (*stage2Plan, error) {
+	perRecord := filter
+	perFile := ""
+	if sort != "" {
+		perFile = sort + " | .[]"
+		if transform != "" {
+			perFile += " | " + transform
+		}
+	} else if transform != "" && filter != "" {
+		perRecord = filter + " | " + transform
+	} else if transform != "" {
+		perRecord = transform
 	}
 
-	var results []interface{}
-
-	// Process each file
-	for _, file := range files {
-		// Read and parse all records from file
-		records, err := readJSONLFile(file)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to read file %s: %w", file, err)
+	plan := &stage2Plan{prefilter: NewLinePrefilter(FilterRecordTypes(filter), nil)}
+	var err error
+	if plan.perRecord, err = CompileJQ(perRecord); err != nil {
+		return nil, err
+	}
+	if perFile != "" {
+		if plan.perFile, err = CompileJQ(perFile); err != nil {
+			return nil, err
 		}
+	}
+	return plan, nil
+}
 
+// streamFilesWithJQ executes a query on multiple files, decoding one line at a
+// time. Files are scanned in parallel and merged in the given order, and
+// scanning stops as soon as limit results are collected.
+// If tagSources is set, object results are tagged with their project and session
+// If progress is non-nil it is notified after each file is scanned
+// If sampler is non-nil every result is offered to it instead of being returned
+func streamFilesWithJQ(ctx context.Context, files []string, plan *stage2Plan, limit int, tagSources bool, progress ProgressFunc, sampler *Sampler) ([]interface{}, *QueryMetadata, error) {
+	metadata := &QueryMetadata{}
+	results := []interface{}{}
+	var bytesRead int64
+	var scanErr error
+
+	scan := func(ctx context.Context, path string) FileScan {
+		return plan.scanFile(ctx, path, limit, tagSources)
+	}
+	ScanFilesOrdered(ctx, files, ScanWorkers(), scan, func(i int, r FileScan) bool {
+		if r.Err != nil {
+			scanErr = fmt.Errorf("failed to read file %s: %w", files[i], r.Err)
+			return false
+		}
+		bytesRead += r.Bytes
 		metadata.FilesProcessed++
-		metadata.TotalRecordsScanned += len(records)
-
-		// Execute jq query on records
-		iter := query.Run(records)
-		for {
-			// Check limit before getting next value
-			if limit > 0 && metadata.ResultsReturned >= limit {
-				metadata.Truncated = true
-				return results, metadata, nil
-			}
-
-			value, ok := iter.Next()
-			if !ok {
-				break
+		metadata.TotalRecordsScanned += r.Records
+		progress.report(ScanProgress{
+			FilesProcessed: metadata.FilesProcessed,
+			TotalFiles:     len(files),
+			RecordsScanned: metadata.TotalRecordsScanned,
+			BytesRead:      bytesRead,
+		})
+
+		for _, value := range r.Results {
+			if sampler != nil {
+				sampler.Add(value)
+				continue
 			}
-
-			// Check for errors
-			if err, ok := value.(error); ok {
-				return nil, nil, fmt.Errorf("jq execution error: %w", err)
-			}
-
-			// Add result
 			results = append(results, value)
 			metadata.ResultsReturned++
-
-			// Check limit after adding result
 			if limit > 0 && metadata.ResultsReturned >= limit {
 				metadata.Truncated = true
-				return results, metadata, nil
+				return false
 			}
 		}
+		return true
+	})
+	if scanErr != nil {
+		return nil, nil, scanErr
 	}
 
 	return results, metadata, nil
 }
 
-// readJSONLFile reads a JSONL file and returns all records as a slice
-func readJSONLFile(filepath string) ([]interface{}, error) {
-	file, err := os.Open(filepath)
-	if err != nil {
-		return nil, err
+// scanFile runs the plan over one file. A file never contributes more than
+// limit results, so its scan stops once it has that many.
+func (p *stage2Plan) scanFile(ctx context.Context, path string, limit int, tagSources bool) FileScan {
+	var scan FileScan
+	var matches []interface{}
+	cwd := ""
+	full := func() bool { return p.perFile == nil && limit > 0 && len(scan.Results) >= limit }
+
+	// A sort runs on the matches of every file, even none, so only unsorted
+	// queries skip files
+	if p.perFile == nil {
+		if records, size, skip := p.prefilter.SkipIndexed(path); skip {
+			return FileScan{Records: records, Bytes: size}
+		}
+	}
+	// Lines are only left undecoded when the index shows every line decodes,
+	// so invalid JSON is still reported; the index has the cwd of those lines
+	var prefilter LinePrefilter
+	if fi, ok := index.Default().Lookup(path); ok && fi.Err() == nil {
+		prefilter = p.prefilter
+		cwd = fi.CWD
 	}
-	defer file.Close()
-
-	var records []interface{}
-	scanner := bufio.NewScanner(file)
-
-	// Increase buffer size to handle large lines (default is 64KB)
-	const maxLineSize = 10 * 1024 * 1024 // 10MB
-	buf := make([]byte, maxLineSize)
-	scanner.Buffer(buf, maxLineSize)
 
-	lineNum := 0
-	for scanner.Scan() {
-		lineNum++
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
+	records, size, err := ForEachIndexedLine(ctx, path, func(lineNum int, line []byte) bool {
+		if !prefilter.Match(line) {
+			return true
 		}
-
 		var record interface{}
-		if err := json.Unmarshal([]byte(line), &record); err != nil {
-			return nil, fmt.Errorf("invalid JSON at line %d: %w", lineNum, err)
+		if err := json.Unmarshal(line, &record); err != nil {
+			scan.Err = fmt.Errorf("invalid JSON at line %d: %w", lineNum, err)
+			return false
+		}
+		if cwd == "" && tagSources {
+			cwd = RecordCWD(record)
 		}
 
-		records = append(records, record)
+		iter := p.perRecord.Run(record)
+		for !full() {
+			value, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := value.(error); ok {
+				scan.Err = fmt.Errorf("jq execution error: %w", err)
+				return false
+			}
+			if p.perFile != nil {
+				matches = append(matches, value)
+			} else {
+				scan.Results = append(scan.Results, value)
+			}
+		}
+		return !full()
+	})
+	scan.Records, scan.Bytes = records, size
+	if err != nil {
+		scan.Err = err
+	}
+	if scan.Err != nil {
+		return scan
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %w", err)
+	if p.perFile != nil {
+		if matches == nil {
+			matches = []interface{}{}
+		}
+		iter := p.perFile.Run(matches)
+		for limit <= 0 || len(scan.Results) < limit {
+			value, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := value.(error); ok {
+				scan.Err = fmt.Errorf("jq execution error: %w", err)
+				return scan
+			}
+			scan.Results = append(scan.Results, value)
+		}
 	}
 
-	return records, nil
+	if tagSources {
+		// A scan that stopped early may not have reached the first cwd
+		if cwd == "" && full() {
+			cwd = ReadSessionCWD(path)
+		}
+		tag := NewSourceTag(path, nil)
+		if cwd != "" {
+			tag.ProjectPath = cwd
+		}
+		for i, value := range scan.Results {
+			scan.Results[i] = tag.Apply(value)
+		}
+	}
+	return scan
 }
@@ -2,10 +2,13 @@ package query
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/itchyny/gojq"
 )
 
 // Test fixtures - sample JSONL data
@@ -399,3 +402,146 @@ func BenchmarkExecuteStage2Query_3MB(b *testing.B) {
 		}
 	}
 }
+
+func TestExecuteStage2Query_ReportsProgress(t *testing.T) {
+	tempDir := t.TempDir()
+	file1 := filepath.Join(tempDir, "a.jsonl")
+	file2 := filepath.Join(tempDir, "b.jsonl")
+	if err := os.WriteFile(file1, []byte(testUser1+"\n"+testAsst1+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(file2, []byte(testUser2+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	var updates []ScanProgress
+	query := &Stage2Query{
+		Files:  []string{file1, file2},
+		Filter: `select(.type == "user")`,
+		OnProgress: func(p ScanProgress) {
+			updates = append(updates, p)
+		},
+	}
+
+	if _, err := ExecuteStage2Query(query); err != nil {
+		t.Fatalf("ExecuteStage2Query failed: %v", err)
+	}
+
+	if len(updates) != 2 {
+		t.Fatalf("Expected 2 progress updates, got %d", len(updates))
+	}
+	last := updates[len(updates)-1]
+	if last.FilesProcessed != 2 || last.TotalFiles != 2 {
+		t.Errorf("Expected 2/2 files, got %d/%d", last.FilesProcessed, last.TotalFiles)
+	}
+	if last.RecordsScanned != 3 {
+		t.Errorf("Expected 3 records scanned, got %d", last.RecordsScanned)
+	}
+	wantBytes := int64(len(testUser1) + len(testAsst1) + len(testUser2) + 3)
+	if last.BytesRead != wantBytes {
+		t.Errorf("Expected %d bytes read, got %d", wantBytes, last.BytesRead)
+	}
+}
+
+func TestStage2PlanMatchesCombinedExpression(t *testing.T) {
+	records := []interface{}{}
+	for _, line := range []string{testUser2, testAsst1, testUser1, testUser3} {
+		var record interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	tests := []struct{ filter, sort, transform string }{
+		{`select(.type == "user")`, "", ""},
+		{`select(.type == "user")`, "", ".timestamp, .type"},
+		{`select(.type == "user")`, "sort_by(.timestamp)", ""},
+		{`select(.type == "user")`, "sort_by(.timestamp) | reverse", "{timestamp}"},
+		{"", "", ".type"},
+	}
+	for _, tt := range tests {
+		want := runJQ(t, buildJQExpression(tt.filter, tt.sort, tt.transform), records)
+
+		plan, err := newStage2Plan(tt.filter, tt.sort, tt.transform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []interface{}
+		for _, record := range records {
+			got = append(got, collectJQ(t, plan.perRecord, record)...)
+		}
+		if plan.perFile != nil {
+			got = collectJQ(t, plan.perFile, got)
+		}
+
+		wantJSON, _ := json.Marshal(want)
+		gotJSON, _ := json.Marshal(got)
+		if string(wantJSON) != string(gotJSON) {
+			t.Errorf("%+v: plan gives %s, combined expression %s", tt, gotJSON, wantJSON)
+		}
+	}
+}
+
+func runJQ(t *testing.T, expr string, input interface{}) []interface{} {
+	t.Helper()
+	code, err := CompileJQ(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return collectJQ(t, code, input)
+}
+
+func collectJQ(t *testing.T, code *gojq.Code, input interface{}) []interface{} {
+	t.Helper()
+	values := []interface{}{}
+	iter := code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return values
+		}
+		if err, ok := v.(error); ok {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+	}
+}
+
+func TestExecuteStage2Query_StopsAtLimitInFileOrder(t *testing.T) {
+	tempDir := t.TempDir()
+	var files []string
+	for i := 0; i < 12; i++ {
+		file := filepath.Join(tempDir, fmt.Sprintf("s%02d.jsonl", i))
+		data := fmt.Sprintf(`{"type":"user","n":%d}`+"\n"+`{"type":"user","n":%d}`+"\n", 2*i, 2*i+1)
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	result, err := ExecuteStage2Query(&Stage2Query{
+		Files:     files,
+		Filter:    `select(.type == "user")`,
+		Transform: ".n",
+		Limit:     3,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteStage2Query failed: %v", err)
+	}
+	if fmt.Sprint(result.Results) != "[0 1 2]" {
+		t.Errorf("expected the first results in file order, got %v", result.Results)
+	}
+	if !result.Metadata.Truncated || result.Metadata.FilesProcessed != 2 {
+		t.Errorf("expected truncation after 2 files, got %+v", result.Metadata)
+	}
+
+	// Errors surface in file order too
+	if err := os.WriteFile(files[1], []byte("not json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ExecuteStage2Query(&Stage2Query{Files: files, Filter: `select(.type == "user")`})
+	if err == nil || !strings.Contains(err.Error(), "s01.jsonl") || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected invalid JSON error for s01.jsonl, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToolErrorsFilter selects user records holding a failed tool_result
+const ToolErrorsFilter = `select(.type == "user" and (.message.content | type == "array")) | ` +
+	`select(.message.content[] | select(.type == "tool_result" and .is_error == true))`
+
+// ToolErrorIDsFilter extracts the tool_use_id of every failed tool_result
+const ToolErrorIDsFilter = `select(.type == "user" and (.message.content | type == "array")) | ` +
+	`.message.content[] | select(.type == "tool_result" and .is_error == true) | .tool_use_id`
+
+// Prefilter tokens of ToolCallsFilter and of the tool error filters
+var (
+	ToolCallTokens  = []string{`"tool_use"`}
+	ToolErrorTokens = []string{`"tool_result"`, `"is_error"`}
+)
+
+// ToolCallsFilter selects assistant records with a tool_use block, optionally
+// one calling tool
+func ToolCallsFilter(tool string) string {
+	filter := `select(.type == "assistant") | select(.message.content[] | .type == "tool_use")`
+	if tool != "" {
+		filter = fmt.Sprintf(`%s | select(.message.content[] | select(.type == "tool_use" and .name == "%s"))`, filter, EscapeJQ(tool))
+	}
+	return filter
+}
+
+// FailedToolUseIDs collects the IDs returned by ToolErrorIDsFilter
+func FailedToolUseIDs(ids []interface{}) map[string]bool {
+	failed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if s, ok := id.(string); ok {
+			failed[s] = true
+		}
+	}
+	return failed
+}
+
+// FilterToolCallsByStatus keeps assistant records with a tool_use block
+// (optionally named toolName) whose outcome matches wantError. Calls without
+// an error result count as successful.
+func FilterToolCallsByStatus(records []interface{}, toolName string, wantError bool, failed map[string]bool, limit int) []interface{} {
+	filtered := make([]interface{}, 0)
+	for _, record := range records {
+		if limit > 0 && len(filtered) >= limit {
+			break
+		}
+		for _, block := range ToolUseBlocks(record) {
+			if toolName != "" && block["name"] != toolName {
+				continue
+			}
+			id, _ := block["id"].(string)
+			if failed[id] == wantError {
+				filtered = append(filtered, record)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// ToolUseBlocks returns the tool_use content blocks of a raw assistant record
+func ToolUseBlocks(record interface{}) []map[string]interface{} {
+	return contentBlocks(record, "tool_use")
+}
+
+// ToolResultBlocks returns the tool_result content blocks of a raw user record
+func ToolResultBlocks(record interface{}) []map[string]interface{} {
+	return contentBlocks(record, "tool_result")
+}
+
+// contentBlocks returns the message content blocks of a raw record with the
+// given type
+func contentBlocks(record interface{}, blockType string) []map[string]interface{} {
+	r, ok := record.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	message, _ := r["message"].(map[string]interface{})
+	raw, _ := message["content"].([]interface{})
+	var blocks []map[string]interface{}
+	for _, b := range raw {
+		block, ok := b.(map[string]interface{})
+		if ok && block["type"] == blockType {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// EscapeJQ escapes special characters in strings for jq expressions
+func EscapeJQ(s string) string {
+	// Escape backslashes first
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	// Escape double quotes
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	// Escape regex special chars for test() function
+	// Note: This is basic escaping - for complex patterns, users should use query_raw
+	return s
+}
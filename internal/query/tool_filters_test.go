@@ -0,0 +1,61 @@
+package query
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const toolFiltersSession = `{"type":"assistant","uuid":"a1","message":{"content":[{"type":"tool_use","id":"t1","name":"Bash"}]}}
+{"type":"user","uuid":"u1","message":{"content":[{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":"exit status 1"}]}}
+{"type":"assistant","uuid":"a2","message":{"content":[{"type":"tool_use","id":"t2","name":"Read"}]}}
+{"type":"user","uuid":"u2","message":{"content":[{"type":"tool_result","tool_use_id":"t2","content":"ok"}]}}
+`
+
+func scanToolFilter(t *testing.T, file, filter string, tokens []string) []interface{} {
+	t.Helper()
+	code, err := CompileJQ(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := RecordScanner{Prefilter: NewLinePrefilter(FilterRecordTypes(filter), tokens)}
+	return scanner.Run(context.Background(), []string{file}, code, 0)
+}
+
+func TestToolFiltersSelectCallsByStatus(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "s.jsonl")
+	if err := os.WriteFile(file, []byte(toolFiltersSession), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := scanToolFilter(t, file, ToolCallsFilter(""), ToolCallTokens)
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(calls))
+	}
+	if got := scanToolFilter(t, file, ToolCallsFilter("Read"), ToolCallTokens); len(got) != 1 {
+		t.Errorf("expected 1 Read call, got %d", len(got))
+	}
+	if got := scanToolFilter(t, file, ToolErrorsFilter, ToolErrorTokens); len(got) != 1 {
+		t.Errorf("expected 1 tool error record, got %d", len(got))
+	}
+
+	failed := FailedToolUseIDs(scanToolFilter(t, file, ToolErrorIDsFilter, ToolErrorTokens))
+	if !failed["t1"] || len(failed) != 1 {
+		t.Fatalf("expected only t1 to fail, got %v", failed)
+	}
+	errored := FilterToolCallsByStatus(calls, "", true, failed, 0)
+	if len(errored) != 1 || errored[0].(map[string]interface{})["uuid"] != "a1" {
+		t.Errorf("expected the failed Bash call, got %v", errored)
+	}
+	succeeded := FilterToolCallsByStatus(calls, "Bash", false, failed, 0)
+	if len(succeeded) != 0 {
+		t.Errorf("expected no successful Bash call, got %v", succeeded)
+	}
+}
+
+func TestToolCallsFilterEscapesToolName(t *testing.T) {
+	if _, err := CompileJQ(ToolCallsFilter(`mcp__x"y\z`)); err != nil {
+		t.Errorf("expected escaped tool name to compile, got %v", err)
+	}
+}
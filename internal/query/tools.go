@@ -21,7 +21,7 @@ var (
 // and pagination according to the provided options, and returns the resulting slice.
 func RunToolsQuery(opts ToolsQueryOptions) ([]parser.ToolCall, error) {
 	pipe := pipelinepkg.NewSessionPipeline(opts.Pipeline)
-	if err := pipe.Load(pipelinepkg.LoadOptions{AutoDetect: true}); err != nil {
+	if err := pipe.Load(pipelinepkg.LoadOptions{AutoDetect: true, Indexed: true}); err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrSessionLoad, err)
 	}
 
@@ -0,0 +1,116 @@
+package redact
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// pattern matches one kind of sensitive value. When the expression has a
+// capture group only the group is masked, keeping the surrounding key or
+// scheme readable.
+type pattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// secretPatterns lists the credential formats of the secrets detector, most
+// specific first so that e.g. an Anthropic key is not reported as a generic
+// assignment
+var secretPatterns = []pattern{
+	{"private_key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"api_key", regexp.MustCompile(`\bsk-(?:ant-)?[A-Za-z0-9_\-]{20,}`)},
+	{"github_token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"aws_access_key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"slack_token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"google_api_key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"bearer_token", regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/=-]{16,})`)},
+	{"url_password", regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`)},
+	{"assignment", regexp.MustCompile(`(?i)\b(?:password|passwd|secret|token|api[_-]?key|access[_-]?key|client[_-]?secret)["']?\s*[:=]\s*["']?([^\s"',;]{6,})`)},
+}
+
+// emailPattern is the email detector
+var emailPattern = pattern{"email", regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)}
+
+// entropyToken matches candidates of the entropy detector: long runs of
+// base64 or URL-safe characters
+var entropyToken = regexp.MustCompile(`[A-Za-z0-9+/=_-]{32,}`)
+
+// minEntropy is the Shannon entropy, in bits per character, above which a
+// token is treated as a generated secret. Hex digests and UUIDs stay below it
+// (at most 4 bits), random base64 is usually above 4.5.
+const minEntropy = 4.3
+
+// wordSegment matches one part of an identifier such as a branch name or a
+// path: a word, optionally followed by a number, a number or a hex digest
+var wordSegment = regexp.MustCompile(`^(?:[A-Za-z]+[0-9]*|[0-9]+|[0-9a-f]+)$`)
+
+// wordSegments reports whether token is made of words separated by / or -,
+// like feature/ADD-1234-NewLoginFlow or /home/me/project. Such tokens reach
+// a high entropy through their length and mixed case alone.
+func wordSegments(token string) bool {
+	if !strings.ContainsAny(token, "/-") {
+		return false
+	}
+	for _, segment := range strings.FieldsFunc(token, func(c rune) bool { return c == '/' || c == '-' }) {
+		if !wordSegment.MatchString(segment) {
+			return false
+		}
+	}
+	return true
+}
+
+// highEntropy reports whether token looks like a generated secret: mixed
+// case, digits and a high per-character entropy, and not a run of words
+func highEntropy(token string) bool {
+	if wordSegments(token) {
+		return false
+	}
+	var upper, lower, digit bool
+	counts := make(map[rune]int)
+	for _, c := range token {
+		switch {
+		case 'A' <= c && c <= 'Z':
+			upper = true
+		case 'a' <= c && c <= 'z':
+			lower = true
+		case '0' <= c && c <= '9':
+			digit = true
+		}
+		counts[c]++
+	}
+	if !upper || !lower || !digit {
+		return false
+	}
+
+	entropy := 0.0
+	n := float64(len(token))
+	for _, count := range counts {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= minEntropy
+}
+
+// replace masks the matches of p in s, counting them in counts
+func (p pattern) replace(s string, counts Counts) string {
+	matches := p.re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+	mask := Mask(p.kind)
+	out := make([]byte, 0, len(s))
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) > 2 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		out = append(out, s[last:start]...)
+		out = append(out, mask...)
+		last = end
+		counts[p.kind]++
+	}
+	return string(append(out, s[last:]...))
+}
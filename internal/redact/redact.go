@@ -0,0 +1,200 @@
+// Package redact masks secrets and personal data in text and decoded JSON.
+//
+// A Redactor combines detectors: known credential formats and private keys
+// (secrets), high-entropy tokens (entropy), email addresses (email) and
+// user-defined regular expressions. Every match is replaced with
+// [REDACTED:<kind>] and counted per kind.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Detector names accepted by Options and META_CC_REDACTION_DETECTORS
+const (
+	DetectorSecrets = "secrets"
+	DetectorEntropy = "entropy"
+	DetectorEmail   = "email"
+)
+
+// Detectors lists the built-in detectors
+var Detectors = []string{DetectorSecrets, DetectorEntropy, DetectorEmail}
+
+// Mask returns the replacement text for a redacted value of kind
+func Mask(kind string) string {
+	return "[REDACTED:" + kind + "]"
+}
+
+// Options selects the detectors of a Redactor
+type Options struct {
+	Secrets bool
+	Entropy bool
+	Email   bool
+	// Patterns are user-defined expressions, reported as kind "custom"
+	Patterns []*regexp.Regexp
+}
+
+// DefaultOptions enables every built-in detector
+func DefaultOptions() Options {
+	return Options{Secrets: true, Entropy: true, Email: true}
+}
+
+// ParseOptions builds Options from detector names and user-defined
+// expressions, failing on unknown names or invalid expressions
+func ParseOptions(detectors, patterns []string) (Options, error) {
+	var opts Options
+	for _, name := range detectors {
+		switch name {
+		case DetectorSecrets:
+			opts.Secrets = true
+		case DetectorEntropy:
+			opts.Entropy = true
+		case DetectorEmail:
+			opts.Email = true
+		default:
+			return Options{}, fmt.Errorf("unknown detector %q (must be one of: %v)", name, Detectors)
+		}
+	}
+	for _, expr := range patterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return Options{}, fmt.Errorf("invalid pattern %q: %v", expr, err)
+		}
+		opts.Patterns = append(opts.Patterns, re)
+	}
+	return opts, nil
+}
+
+// Counts holds the number of redactions per kind
+type Counts map[string]int
+
+// Total returns the number of redactions of every kind
+func (c Counts) Total() int {
+	total := 0
+	for _, n := range c {
+		total += n
+	}
+	return total
+}
+
+// Kinds returns the redacted kinds in name order
+func (c Counts) Kinds() []string {
+	kinds := make([]string, 0, len(c))
+	for kind := range c {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Redactor masks sensitive values; it is safe for concurrent use
+type Redactor struct {
+	patterns []pattern
+	entropy  bool
+}
+
+// New returns a Redactor running the detectors enabled in opts
+func New(opts Options) *Redactor {
+	r := &Redactor{entropy: opts.Entropy}
+	if opts.Secrets {
+		r.patterns = append(r.patterns, secretPatterns...)
+	}
+	for _, re := range opts.Patterns {
+		r.patterns = append(r.patterns, pattern{"custom", re})
+	}
+	if opts.Email {
+		r.patterns = append(r.patterns, emailPattern)
+	}
+	return r
+}
+
+// Enabled reports whether r runs any detector
+func (r *Redactor) Enabled() bool {
+	return r != nil && (len(r.patterns) > 0 || r.entropy)
+}
+
+// String masks the sensitive values in s, adding what it replaced to counts
+func (r *Redactor) String(s string, counts Counts) string {
+	for _, p := range r.patterns {
+		s = p.replace(s, counts)
+	}
+	if r.entropy {
+		s = entropyToken.ReplaceAllStringFunc(s, func(token string) string {
+			if !highEntropy(token) {
+				return token
+			}
+			counts[DetectorEntropy]++
+			return Mask(DetectorEntropy)
+		})
+	}
+	return s
+}
+
+// preservedKeys are identifiers whose values are never redacted: generated
+// IDs that look random but link records together, and the working
+// directory, branch and version of a session, which name rather than hide
+var preservedKeys = map[string]bool{
+	"uuid":        true,
+	"parentUuid":  true,
+	"sessionId":   true,
+	"session_id":  true,
+	"messageId":   true,
+	"requestId":   true,
+	"id":          true,
+	"tool_use_id": true,
+	"toolUseID":   true,
+	"timestamp":   true,
+	"cwd":         true,
+	"gitBranch":   true,
+	"version":     true,
+}
+
+// Value returns a copy of the decoded JSON value v with its strings
+// redacted. Values that are not generic JSON (structs, typed slices) are
+// converted through encoding/json first. v itself is never modified.
+func (r *Redactor) Value(v interface{}, counts Counts) interface{} {
+	switch v := v.(type) {
+	case nil, bool, float64, int, int64, json.Number:
+		return v
+	case string:
+		return r.String(v, counts)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if _, ok := value.(string); ok && preservedKeys[key] {
+				out[key] = value
+				continue
+			}
+			out[key] = r.Value(value, counts)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.Value(item, counts)
+		}
+		return out
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return v
+	}
+	return r.Value(generic, counts)
+}
+
+// Records redacts every record, returning the redacted copies
+func (r *Redactor) Records(records []interface{}, counts Counts) []interface{} {
+	out := make([]interface{}, len(records))
+	for i, record := range records {
+		out[i] = r.Value(record, counts)
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+package scrub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// object is a JSON object that keeps its key order, so that a scrubbed line
+// differs from the original only where values were rewritten
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// get returns the string value of key, or ""
+func (o *object) get(key string) string {
+	s, _ := o.values[key].(string)
+	return s
+}
+
+// decodeValue reads one JSON value from dec, decoding objects as *object and
+// numbers as json.Number
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &object{values: make(map[string]interface{})}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := keyTok.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected object key %v", keyTok)
+			}
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := obj.values[key]; !dup {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = value
+		}
+		_, err := dec.Token() // '}'
+		return obj, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token() // ']'
+		return list, err
+	}
+	return tok, nil
+}
+
+// encodeValue appends v to buf as compact JSON without HTML escaping, the way
+// Claude Code writes session files
+func encodeValue(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case *object:
+		buf.WriteByte('{')
+		for i, key := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeValue(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := encodeValue(buf, v.values[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		var scalar bytes.Buffer
+		enc := json.NewEncoder(&scalar)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		buf.Write(bytes.TrimSuffix(scalar.Bytes(), []byte("\n")))
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+// Package scrub anonymizes session files so they can be shared in bug reports
+// or added to the regression fixtures.
+//
+// A Scrubber rewrites every string of a session consistently: the same path,
+// email or term always maps to the same replacement, and the fields that link
+// entries together (uuid, parentUuid, tool_use_id, ...) are never touched, so
+// the parser and analyzers see the same structure in the scrubbed file.
+package scrub
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yaleh/meta-cc/internal/redact"
+)
+
+// DefaultRootPlaceholder replaces the project root when Options.RootPlaceholder is empty
+const DefaultRootPlaceholder = "/workspace/project"
+
+// Options configures a Scrubber
+type Options struct {
+	// ProjectRoot is the absolute project path; paths under it are rewritten
+	// to RootPlaceholder
+	ProjectRoot     string
+	RootPlaceholder string
+	// HashPaths also replaces each path element under the project root with
+	// a hash, keeping file extensions
+	HashPaths bool
+	// Words are extra literal terms to replace, such as user or company names
+	Words []string
+	// MaxToolOutput truncates tool outputs longer than this many bytes (0 keeps them)
+	MaxToolOutput int
+	// Salt is mixed into every hash; set it to keep replacements unguessable
+	Salt string
+}
+
+// Report counts what a Scrubber replaced
+type Report struct {
+	Lines     int `json:"lines"`
+	Secrets   int `json:"secrets"`
+	Emails    int `json:"emails"`
+	Paths     int `json:"paths"`
+	Words     int `json:"words"`
+	Truncated int `json:"truncated"`
+}
+
+// preservedKeys are structural fields whose string values are kept verbatim
+var preservedKeys = map[string]bool{
+	"uuid":              true,
+	"parentUuid":        true,
+	"leafUuid":          true,
+	"logicalParentUuid": true,
+	"sessionId":         true,
+	"messageId":         true,
+	"requestId":         true,
+	"id":                true,
+	"tool_use_id":       true,
+	"toolUseID":         true,
+	"parentToolUseID":   true,
+	"type":              true,
+	"subtype":           true,
+	"role":              true,
+	"model":             true,
+	"stop_reason":       true,
+	"timestamp":         true,
+	"version":           true,
+	"userType":          true,
+	"level":             true,
+}
+
+// secrets masks credentials; emails are replaced consistently by String instead
+var secrets = redact.New(redact.Options{Secrets: true})
+
+var (
+	emailRe = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)
+	homeRe  = regexp.MustCompile(`(/home/|/Users/)([A-Za-z0-9._-]+)`)
+	// nonAlnumRe matches the characters Claude Code replaces with '-' when it
+	// names a project directory after the project path
+	nonAlnumRe = regexp.MustCompile(`[^A-Za-z0-9]`)
+	// truncatedRe matches output truncated by an earlier run
+	truncatedRe = regexp.MustCompile(`…\[truncated \d+ bytes\]$`)
+)
+
+// Scrubber rewrites session lines; it is not safe for concurrent use
+type Scrubber struct {
+	opts        Options
+	rootRe      *regexp.Regexp
+	rootDir     string // project directory name of ProjectRoot
+	placeholder string // project directory name of RootPlaceholder
+	report      Report
+}
+
+// New returns a Scrubber for opts
+func New(opts Options) *Scrubber {
+	if opts.RootPlaceholder == "" {
+		opts.RootPlaceholder = DefaultRootPlaceholder
+	}
+	s := &Scrubber{opts: opts}
+	root := strings.TrimRight(filepath.ToSlash(opts.ProjectRoot), "/")
+	if len(root) > 1 {
+		s.rootRe = regexp.MustCompile(regexp.QuoteMeta(root) + "((?:/[^\\s\"'`<>|:;,()\\[\\]{}]*)?)")
+		s.rootDir = nonAlnumRe.ReplaceAllString(root, "-")
+		s.placeholder = nonAlnumRe.ReplaceAllString(opts.RootPlaceholder, "-")
+	}
+	return s
+}
+
+// Report returns the counts accumulated so far
+func (s *Scrubber) Report() Report {
+	return s.report
+}
+
+// Session copies the session read from r to w, scrubbing every line
+func (s *Scrubber) Session(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			newline := bytes.HasSuffix(line, []byte("\n"))
+			if _, werr := bw.Write(s.Line(bytes.TrimSuffix(line, []byte("\n")))); werr != nil {
+				return werr
+			}
+			if newline {
+				if werr := bw.WriteByte('\n'); werr != nil {
+					return werr
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read session: %w", err)
+		}
+	}
+	return bw.Flush()
+}
+
+// Line scrubs one JSONL line. Lines that are not JSON are scrubbed as text.
+func (s *Scrubber) Line(line []byte) []byte {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 {
+		return line
+	}
+	s.report.Lines++
+
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+	value, err := decodeValue(dec)
+	if err != nil || dec.InputOffset() != int64(len(trimmed)) {
+		return []byte(s.String(string(line)))
+	}
+
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, s.walk(value, false)); err != nil {
+		return []byte(s.String(string(line)))
+	}
+	return buf.Bytes()
+}
+
+// walk scrubs the strings of a decoded value. inOutput marks tool output,
+// which is truncated to Options.MaxToolOutput.
+func (s *Scrubber) walk(v interface{}, inOutput bool) interface{} {
+	switch v := v.(type) {
+	case *object:
+		for _, key := range v.keys {
+			value := v.values[key]
+			if _, ok := value.(string); ok && s.preserved(v, key) {
+				continue
+			}
+			output := inOutput || key == "toolUseResult" || (key == "content" && v.get("type") == "tool_result")
+			v.values[key] = s.walk(value, output)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = s.walk(item, inOutput)
+		}
+		return v
+	case string:
+		v = s.String(v)
+		if inOutput {
+			v = s.truncate(v)
+		}
+		return v
+	}
+	return v
+}
+
+// preserved reports whether the string value of key in obj is structural
+func (s *Scrubber) preserved(obj *object, key string) bool {
+	if preservedKeys[key] {
+		return true
+	}
+	// Tool names drive most analyses and never hold user data
+	return key == "name" && obj.get("type") == "tool_use"
+}
+
+// String scrubs free text: secrets, paths under the project root, home
+// directories, emails and the configured words
+func (s *Scrubber) String(text string) string {
+	counts := redact.Counts{}
+	text = secrets.String(text, counts)
+	s.report.Secrets += counts.Total()
+
+	if s.rootRe != nil {
+		text = s.replaceRoot(text)
+		if strings.Contains(text, s.rootDir) {
+			s.report.Paths += strings.Count(text, s.rootDir)
+			text = strings.ReplaceAll(text, s.rootDir, s.placeholder)
+		}
+	}
+
+	text = homeRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := homeRe.FindStringSubmatch(m)
+		if sub[2] == "user" {
+			return m
+		}
+		s.report.Paths++
+		return sub[1] + "user"
+	})
+
+	text = emailRe.ReplaceAllStringFunc(text, func(m string) string {
+		local, domain, _ := strings.Cut(m, "@")
+		if local == "git" || domain == "example.com" {
+			return m
+		}
+		s.report.Emails++
+		return "user-" + s.hash(m) + "@example.com"
+	})
+
+	for _, word := range s.opts.Words {
+		if word == "" || !strings.Contains(text, word) {
+			continue
+		}
+		s.report.Words += strings.Count(text, word)
+		text = strings.ReplaceAll(text, word, "redacted-"+s.hash(word))
+	}
+	return text
+}
+
+// replaceRoot rewrites paths under the project root
+func (s *Scrubber) replaceRoot(text string) string {
+	matches := s.rootRe.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		// The root must end at a path boundary: /work/app does not match /work/app2
+		if m[1] < len(text) && m[2] == m[3] && isNameByte(text[m[1]]) {
+			continue
+		}
+		rest := text[m[2]:m[3]]
+		if s.opts.HashPaths {
+			rest = s.hashPath(rest)
+		}
+		b.WriteString(text[last:m[0]])
+		b.WriteString(s.opts.RootPlaceholder)
+		b.WriteString(rest)
+		last = m[1]
+		s.report.Paths++
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// hashPath replaces every element of a relative path with a hash, keeping
+// the extension so that per-file-type analyses still work
+func (s *Scrubber) hashPath(rest string) string {
+	parts := strings.Split(rest, "/")
+	for i, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			continue
+		}
+		ext := filepath.Ext(part)
+		if ext == part {
+			ext = ""
+		}
+		parts[i] = "p" + s.hash(part) + ext
+	}
+	return strings.Join(parts, "/")
+}
+
+// truncate shortens tool output to Options.MaxToolOutput bytes
+func (s *Scrubber) truncate(text string) string {
+	limit := s.opts.MaxToolOutput
+	if limit <= 0 || len(text) <= limit || truncatedRe.MatchString(text) {
+		return text
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	s.report.Truncated++
+	return fmt.Sprintf("%s…[truncated %d bytes]", text[:limit], len(text)-limit)
+}
+
+// hash returns a short, salted digest of value
+func (s *Scrubber) hash(value string) string {
+	sum := sha256.Sum256([]byte(s.opts.Salt + "\x00" + value))
+	return hex.EncodeToString(sum[:4])
+}
+
+// isNameByte reports whether b can continue a file name
+func isNameByte(b byte) bool {
+	return b == '-' || b == '_' || b == '.' ||
+		('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
@@ -0,0 +1,116 @@
+package scrub
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yaleh/meta-cc/internal/analyzer"
+	"github.com/yaleh/meta-cc/internal/parser"
+	"github.com/yaleh/meta-cc/internal/testutil"
+)
+
+func TestLine(t *testing.T) {
+	s := New(Options{ProjectRoot: "/home/alice/work/app", Words: []string{"Acme"}, MaxToolOutput: 8, Salt: "s"})
+	line := `{"type":"user","uuid":"u-1","parentUuid":"u-0","cwd":"/home/alice/work/app","message":{"role":"user","content":[` +
+		`{"type":"tool_result","tool_use_id":"toolu_1","content":"0123456789 /home/alice/work/app2/x"},` +
+		`{"type":"text","text":"see /home/alice/work/app/src/main.go, ~/.claude/projects/-home-alice-work-app <a@acme.io> for Acme"}]}}`
+
+	got := string(s.Line([]byte(line)))
+
+	want := `{"type":"user","uuid":"u-1","parentUuid":"u-0","cwd":"/workspace/project","message":{"role":"user","content":[` +
+		`{"type":"tool_result","tool_use_id":"toolu_1","content":"01234567…[truncated 25 bytes]"},` +
+		`{"type":"text","text":"see /workspace/project/src/main.go, ~/.claude/projects/-workspace-project <user-` + s.hash("a@acme.io") + `@example.com> for redacted-` + s.hash("Acme") + `"}]}}`
+	if got != want {
+		t.Errorf("Line:\n got %s\nwant %s", got, want)
+	}
+
+	report := s.Report()
+	if report.Lines != 1 || report.Emails != 1 || report.Words != 1 || report.Truncated != 1 || report.Paths != 4 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	// Replacements are consistent and scrubbing is idempotent
+	if again := string(s.Line([]byte(got))); again != got {
+		t.Errorf("scrubbing twice changed the line:\n got %s\nwant %s", again, got)
+	}
+}
+
+func TestHashPaths(t *testing.T) {
+	s := New(Options{ProjectRoot: "/work/app", HashPaths: true})
+	a := s.String("edit /work/app/internal/main.go")
+	b := s.String("read /work/app/internal/util.go")
+	if !strings.HasPrefix(a, "edit /workspace/project/p") || !strings.HasSuffix(a, ".go") || strings.Contains(a, "internal") {
+		t.Fatalf("unexpected hashed path %q", a)
+	}
+	dirA := strings.Split(a, "/")[3]
+	dirB := strings.Split(b, "/")[3]
+	if dirA != dirB {
+		t.Errorf("same directory hashed differently: %q vs %q", a, b)
+	}
+}
+
+func TestNonJSONLine(t *testing.T) {
+	s := New(Options{})
+	got := string(s.Line([]byte("not json bob@corp.com")))
+	if strings.Contains(got, "bob@corp.com") {
+		t.Errorf("email kept in %q", got)
+	}
+}
+
+// TestAnalyzersUnchanged checks that analyses of a scrubbed fixture match the
+// original: scrubbing must keep entry linkage, tool names and statuses
+func TestAnalyzersUnchanged(t *testing.T) {
+	for _, fixture := range []string{"sample-session.jsonl", "session-with-errors.jsonl"} {
+		t.Run(fixture, func(t *testing.T) {
+			data := testutil.LoadFixture(t, fixture)
+			var out bytes.Buffer
+			s := New(Options{ProjectRoot: "/home/yale/work/meta-cc", HashPaths: true, MaxToolOutput: 1 << 10})
+			if err := s.Session(bytes.NewReader(data), &out); err != nil {
+				t.Fatalf("Session failed: %v", err)
+			}
+			if bytes.Contains(out.Bytes(), []byte("/home/yale")) {
+				t.Error("scrubbed session still contains the home directory")
+			}
+			if got, want := bytes.Count(out.Bytes(), []byte("\n")), bytes.Count(data, []byte("\n")); got != want {
+				t.Errorf("line count = %d, want %d", got, want)
+			}
+
+			before := analyze(t, data)
+			after := analyze(t, out.Bytes())
+			if !reflect.DeepEqual(before, after) {
+				t.Errorf("analyses differ after scrubbing:\nbefore %+v\nafter  %+v", before, after)
+			}
+		})
+	}
+}
+
+// analysis is the part of the analyzer output scrubbing must preserve
+type analysis struct {
+	Calls     []string
+	Stats     analyzer.SessionStats
+	Patterns  int
+	Sequences int
+}
+
+func analyze(t *testing.T, data []byte) analysis {
+	t.Helper()
+	entries, err := parser.ParseEntriesFromContent(string(data))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	calls := parser.ExtractToolCalls(entries)
+	var summary []string
+	for _, tc := range calls {
+		summary = append(summary, tc.UUID+" "+tc.ToolName+" "+tc.Status+" "+tc.Timestamp)
+	}
+	sort.Strings(summary) // ExtractToolCalls does not keep entry order
+	return analysis{
+		Calls:     summary,
+		Stats:     analyzer.CalculateStats(entries, calls),
+		Patterns:  len(analyzer.DetectErrorPatterns(entries, calls)),
+		Sequences: len(analyzer.DetectToolSequences(entries, 2, 2).Sequences),
+	}
+}
@@ -0,0 +1,297 @@
+package sessionio
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ManifestName is the file, kept in each project directory, that records the
+// sessions archived there
+const ManifestName = "meta-cc-archive.json"
+
+// manifestVersion is bumped whenever Manifest changes incompatibly
+const manifestVersion = 1
+
+// ArchivedSession records one session compressed in place
+type ArchivedSession struct {
+	SessionID    string    `json:"session_id"`
+	Original     string    `json:"original"`
+	Archive      string    `json:"archive"`
+	OriginalSize int64     `json:"original_size"`
+	ArchivedSize int64     `json:"archived_size,omitempty"`
+	SHA256       string    `json:"sha256,omitempty"` // of the original content
+	LastModified time.Time `json:"last_modified"`
+	ArchivedAt   time.Time `json:"archived_at,omitzero"`
+}
+
+// Manifest lists the sessions archived in a project directory. File names are
+// relative to the directory.
+type Manifest struct {
+	Version  int               `json:"version"`
+	Sessions []ArchivedSession `json:"sessions"`
+}
+
+// ReadManifest reads the manifest of dir. A missing manifest is empty.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if os.IsNotExist(err) {
+		return &Manifest{Version: manifestVersion}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid archive manifest in %s: %w", dir, err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported archive manifest version %d in %s", m.Version, dir)
+	}
+	return &m, nil
+}
+
+// WriteManifest replaces the manifest of dir atomically
+func WriteManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".manifest-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, ManifestName))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// record adds or replaces the entry of a session
+func (m *Manifest) record(session ArchivedSession) {
+	for i := range m.Sessions {
+		if m.Sessions[i].SessionID == session.SessionID {
+			m.Sessions[i] = session
+			return
+		}
+	}
+	m.Sessions = append(m.Sessions, session)
+}
+
+// ArchiveOptions selects the sessions ArchiveDir compresses
+type ArchiveOptions struct {
+	// OlderThan is the minimum time since a session was last modified
+	OlderThan time.Duration
+	// DryRun reports the sessions that would be archived without touching them
+	DryRun bool
+	// Now is the reference time (default: time.Now())
+	Now time.Time
+}
+
+// ArchiveDir gzips the plain sessions of dir last modified before
+// opts.Now-opts.OlderThan and records them in the manifest. It returns the
+// sessions archived, oldest first. When a session fails, the ones archived
+// before it are still recorded.
+func ArchiveDir(dir string, opts ArchiveOptions) ([]ArchivedSession, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	cutoff := opts.Now.Add(-opts.OlderThan)
+
+	files, err := Glob(dir)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []ArchivedSession
+	for _, path := range files {
+		if IsCompressed(path) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		candidates = append(candidates, ArchivedSession{
+			SessionID:    SessionID(path),
+			Original:     filepath.Base(path),
+			Archive:      filepath.Base(path) + ".gz",
+			OriginalSize: info.Size(),
+			LastModified: info.ModTime().UTC(),
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].LastModified.Before(candidates[j].LastModified)
+	})
+	if opts.DryRun || len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	archived := make([]ArchivedSession, 0, len(candidates))
+	var archiveErr error
+	for _, candidate := range candidates {
+		session, err := ArchiveFile(filepath.Join(dir, candidate.Original))
+		if err != nil {
+			archiveErr = err
+			break
+		}
+		session.ArchivedAt = opts.Now.UTC()
+		manifest.record(*session)
+		archived = append(archived, *session)
+	}
+	if len(archived) > 0 {
+		if err := WriteManifest(dir, manifest); err != nil && archiveErr == nil {
+			archiveErr = fmt.Errorf("failed to write archive manifest: %w", err)
+		}
+	}
+	return archived, archiveErr
+}
+
+// ArchiveFile replaces a plain session file with a gzip archive next to it
+// (path + ".gz"). The archive is verified against the original before the
+// original is removed, and keeps its modification time. A session written to
+// while it is being archived is left untouched.
+func ArchiveFile(path string) (*ArchivedSession, error) {
+	if ext(path) != PlainExt {
+		return nil, fmt.Errorf("not a plain session file: %s", path)
+	}
+	target := path + ".gz"
+	if _, err := os.Stat(target); err == nil {
+		return nil, fmt.Errorf("archive already exists: %s", target)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".archive-*")
+	if err != nil {
+		return nil, err
+	}
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	sum, err := compress(path, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	if err := verify(tmp.Name(), sum, before.Size()); err != nil {
+		return nil, fmt.Errorf("failed to verify archive of %s: %w", path, err)
+	}
+
+	after, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if after.Size() != before.Size() || !after.ModTime().Equal(before.ModTime()) {
+		return nil, fmt.Errorf("session changed while archiving: %s", path)
+	}
+
+	if err := os.Chmod(tmp.Name(), before.Mode().Perm()); err != nil {
+		return nil, err
+	}
+	if err := os.Chtimes(tmp.Name(), before.ModTime(), before.ModTime()); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return nil, err
+	}
+	keep = true
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("archived %s but failed to remove it: %w", path, err)
+	}
+
+	archive, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchivedSession{
+		SessionID:    SessionID(path),
+		Original:     filepath.Base(path),
+		Archive:      filepath.Base(target),
+		OriginalSize: before.Size(),
+		ArchivedSize: archive.Size(),
+		SHA256:       hex.EncodeToString(sum),
+		LastModified: before.ModTime().UTC(),
+	}, nil
+}
+
+// compress gzips the file at path into w, returning the SHA-256 of the input
+func compress(path string, w io.Writer) ([]byte, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	buffered := bufio.NewWriter(w)
+	zw, err := gzip.NewWriterLevel(buffered, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	zw.Name = filepath.Base(path)
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(zw, h), src); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// verify checks that a gzip archive decompresses to size bytes hashing to sum
+func verify(path string, sum []byte, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, zr)
+	if err != nil {
+		return err
+	}
+	if n != size || !bytes.Equal(h.Sum(nil), sum) {
+		return errors.New("content mismatch")
+	}
+	return nil
+}
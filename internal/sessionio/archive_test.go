@@ -0,0 +1,140 @@
+package sessionio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yaleh/meta-cc/internal/testutil"
+)
+
+func TestArchiveFile(t *testing.T) {
+	data := testutil.LoadFixture(t, "session-with-errors.jsonl")
+	modTime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	path := filepath.Join(t.TempDir(), "s1"+PlainExt)
+	testutil.WriteSessionAt(t, path, string(data), modTime)
+	// Archives keep the mode of the session
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := ArchiveFile(path)
+	if err != nil {
+		t.Fatalf("ArchiveFile failed: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("original still present: %v", err)
+	}
+	archive := path + ".gz"
+	if got := readAll(t, archive); !bytes.Equal(got, data) {
+		t.Error("archive does not decompress to the original")
+	}
+	info, err := os.Stat(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("archive mtime = %v, want %v", info.ModTime(), modTime)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("archive mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if session.SessionID != "s1" || session.Archive != "s1.jsonl.gz" || session.OriginalSize != int64(len(data)) {
+		t.Errorf("unexpected session record: %+v", session)
+	}
+	if session.ArchivedSize == 0 || session.ArchivedSize >= session.OriginalSize || len(session.SHA256) != 64 {
+		t.Errorf("unexpected archive size or digest: %+v", session)
+	}
+}
+
+func TestArchiveFileRefusesExistingArchive(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "s1"+PlainExt)
+	testutil.WriteSessionAt(t, path, "{}\n", time.Now())
+	if err := os.WriteFile(path+".gz", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ArchiveFile(path); err == nil {
+		t.Fatal("expected an error when the archive exists")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("original must be kept: %v", err)
+	}
+	if _, err := ArchiveFile(path + ".gz"); err == nil {
+		t.Error("expected an error archiving an archive")
+	}
+}
+
+func TestArchiveDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	testutil.WriteSessionAt(t, filepath.Join(dir, "old"+PlainExt), "{\"type\":\"user\"}\n", now.Add(-40*24*time.Hour))
+	testutil.WriteSessionAt(t, filepath.Join(dir, "older"+PlainExt), "{\"type\":\"assistant\"}\n", now.Add(-50*24*time.Hour))
+	testutil.WriteSessionAt(t, filepath.Join(dir, "recent"+PlainExt), "{}\n", now.Add(-time.Hour))
+	opts := ArchiveOptions{OlderThan: 30 * 24 * time.Hour, Now: now}
+
+	// Dry run reports candidates, oldest first, and changes nothing
+	dry := opts
+	dry.DryRun = true
+	candidates, err := ArchiveDir(dir, dry)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].SessionID != "older" || candidates[1].SessionID != "old" {
+		t.Fatalf("unexpected candidates: %+v", candidates)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ManifestName)); !os.IsNotExist(err) {
+		t.Error("dry run must not write a manifest")
+	}
+
+	archived, err := ArchiveDir(dir, opts)
+	if err != nil {
+		t.Fatalf("ArchiveDir failed: %v", err)
+	}
+	if len(archived) != 2 {
+		t.Fatalf("archived %d sessions, want 2", len(archived))
+	}
+
+	files, err := Glob(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"old.jsonl.gz", "older.jsonl.gz", "recent.jsonl"}
+	for i, name := range want {
+		if i >= len(files) || filepath.Base(files[i]) != name {
+			t.Fatalf("files = %v, want %v", files, want)
+		}
+	}
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if len(manifest.Sessions) != 2 || manifest.Sessions[0].ArchivedAt.IsZero() {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+
+	// A second run finds nothing left to archive and keeps the manifest
+	archived, err = ArchiveDir(dir, opts)
+	if err != nil || len(archived) != 0 {
+		t.Errorf("second run: got %v, %v", archived, err)
+	}
+	if manifest, err = ReadManifest(dir); err != nil || len(manifest.Sessions) != 2 {
+		t.Errorf("manifest after second run: %+v, %v", manifest, err)
+	}
+}
+
+func TestReadManifestMissing(t *testing.T) {
+	manifest, err := ReadManifest(t.TempDir())
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if manifest.Version != manifestVersion || len(manifest.Sessions) != 0 {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+}
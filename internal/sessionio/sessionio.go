@@ -0,0 +1,144 @@
+// Package sessionio opens Claude Code session files, which are plain JSONL or
+// JSONL archived with gzip (.jsonl.gz) or zstd (.jsonl.zst), and archives
+// old sessions in place.
+package sessionio
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Session file extensions
+const (
+	PlainExt = ".jsonl"
+	GzipExt  = ".jsonl.gz"
+	ZstdExt  = ".jsonl.zst"
+)
+
+// zstdMaxWindow bounds the window a zstd session may declare, and so the
+// memory decoding it takes. 128 MB is the zstd CLI's default limit (--long=27).
+const zstdMaxWindow = 128 << 20
+
+// extensions lists every session file extension, plain first
+var extensions = []string{PlainExt, GzipExt, ZstdExt}
+
+// IsSessionFile reports whether a file name has a session file extension
+func IsSessionFile(name string) bool {
+	return ext(name) != ""
+}
+
+// IsCompressed reports whether a session file is archived
+func IsCompressed(path string) bool {
+	e := ext(path)
+	return e != "" && e != PlainExt
+}
+
+// SessionID returns the session ID of a session file: its base name without
+// the session file extension
+func SessionID(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, ext(base))
+}
+
+// ext returns the session file extension of name, or "" if it has none
+func ext(name string) string {
+	for _, e := range extensions[1:] {
+		if strings.HasSuffix(name, e) {
+			return e
+		}
+	}
+	if strings.HasSuffix(name, PlainExt) {
+		return PlainExt
+	}
+	return ""
+}
+
+// Open opens a session file for reading, decompressing archived sessions. An
+// empty archive, like an empty plain file, is an empty session.
+func Open(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		return file, nil
+	}
+
+	switch ext(path) {
+	case GzipExt:
+		zr, err := gzip.NewReader(bufio.NewReader(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read gzip session %s: %w", path, err)
+		}
+		return &readCloser{Reader: zr, closers: []io.Closer{zr, file}}, nil
+	case ZstdExt:
+		// One goroutine per reader: queries already decode files in parallel
+		zr, err := zstd.NewReader(file, zstd.WithDecoderMaxWindow(zstdMaxWindow), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read zstd session %s: %w", path, err)
+		}
+		return &readCloser{Reader: zr, closers: []io.Closer{zr.IOReadCloser(), file}}, nil
+	default:
+		return file, nil
+	}
+}
+
+// readCloser closes a decompressor along with its file
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes every underlying reader, returning the first error
+func (r *readCloser) Close() error {
+	var first error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Glob returns the session files directly inside dir, sorted by name. When a
+// session exists both plain and archived (an interrupted archive run), only
+// the plain file is returned. A missing dir has no sessions.
+func Glob(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !IsSessionFile(name) {
+			continue
+		}
+		id := SessionID(name)
+		if existing, ok := byID[id]; ok && ext(existing) == PlainExt {
+			continue
+		}
+		byID[id] = name
+	}
+
+	files := make([]string, 0, len(byID))
+	for _, name := range byID {
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
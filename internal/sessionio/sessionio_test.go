@@ -0,0 +1,157 @@
+package sessionio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yaleh/meta-cc/internal/testutil"
+)
+
+// writeGzip writes data gzipped to path
+func writeGzip(t *testing.T, path string, data []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readAll reads a session file through Open
+func readAll(t *testing.T, path string) []byte {
+	t.Helper()
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open(%s) failed: %v", path, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %s failed: %v", path, err)
+	}
+	return data
+}
+
+func TestOpen(t *testing.T) {
+	want := testutil.LoadFixture(t, "session-with-errors.jsonl")
+	dir := t.TempDir()
+	writeGzip(t, filepath.Join(dir, "gz.jsonl.gz"), want)
+
+	paths := []string{
+		filepath.Join(testutil.FixtureDir(), "session-with-errors.jsonl"),
+		filepath.Join(testutil.FixtureDir(), "session-with-errors.jsonl.zst"),
+		filepath.Join(dir, "gz.jsonl.gz"),
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			if got := readAll(t, path); !bytes.Equal(got, want) {
+				t.Errorf("read %d bytes, want %d matching bytes", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestOpenInvalidGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.jsonl.gz")
+	if err := os.WriteFile(path, []byte(`{"type":"user"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("expected an error for a plain file named .jsonl.gz")
+	}
+}
+
+func TestOpenEmptyArchive(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"empty.jsonl.zst", "empty.jsonl.gz"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := readAll(t, path); len(got) != 0 {
+			t.Errorf("%s: expected an empty session, got %d bytes", name, len(got))
+		}
+	}
+}
+
+func TestOpenRejectsOversizedZstdWindow(t *testing.T) {
+	// A frame declaring a 2 GB window (Window_Descriptor exponent 21) followed
+	// by an empty last raw block
+	frame := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 21 << 3, 0x01, 0x00, 0x00}
+	path := filepath.Join(t.TempDir(), "huge.jsonl.zst")
+	if err := os.WriteFile(path, frame, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(path)
+	if err == nil {
+		_, err = io.ReadAll(r)
+		r.Close()
+	}
+	if err == nil {
+		t.Error("expected a zstd window above the limit to be rejected")
+	}
+}
+
+func TestSessionID(t *testing.T) {
+	tests := map[string]string{
+		"/p/abc.jsonl":     "abc",
+		"/p/abc.jsonl.gz":  "abc",
+		"/p/abc.jsonl.zst": "abc",
+		"abc.txt":          "abc.txt",
+	}
+	for path, want := range tests {
+		if got := SessionID(path); got != want {
+			t.Errorf("SessionID(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	if IsSessionFile("abc.gz") || !IsSessionFile("abc.jsonl.zst") {
+		t.Error("IsSessionFile misclassified a name")
+	}
+	if IsCompressed("abc.jsonl") || !IsCompressed("abc.jsonl.gz") {
+		t.Error("IsCompressed misclassified a name")
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.jsonl", "b.jsonl.gz", "c.jsonl.zst", "a.jsonl.gz", "notes.txt", ".archive-1"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "d.jsonl"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Glob(dir)
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	// The plain copy of a half-archived session wins
+	want := []string{"a.jsonl", "b.jsonl.gz", "c.jsonl.zst"}
+	if len(files) != len(want) {
+		t.Fatalf("got %v, want %v", files, want)
+	}
+	for i, name := range want {
+		if files[i] != filepath.Join(dir, name) {
+			t.Errorf("files[%d] = %s, want %s", i, files[i], name)
+		}
+	}
+
+	files, err = Glob(filepath.Join(dir, "missing"))
+	if err != nil || len(files) != 0 {
+		t.Errorf("missing dir: got %v, %v", files, err)
+	}
+}
@@ -0,0 +1,44 @@
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// indexDirEnv mirrors index.EnvDir (testutil cannot import index: parser
+// tests use testutil and index imports parser)
+const indexDirEnv = "META_CC_INDEX_DIR"
+
+// bundlesDirEnv mirrors the locator's META_CC_BUNDLES_DIR
+const bundlesDirEnv = "META_CC_BUNDLES_DIR"
+
+// outputDirEnv mirrors the MCP server's META_CC_OUTPUT_DIR
+const outputDirEnv = "META_CC_OUTPUT_DIR"
+
+// RunWithTempIndex runs a package's tests with the session index, imported
+// session bundles and file_ref outputs stored in a temporary directory, so
+// tests never read or write the user's cache and config dirs.
+// Use it from TestMain: os.Exit(testutil.RunWithTempIndex(m))
+func RunWithTempIndex(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "meta-cc-index-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create index dir: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	env := map[string]string{
+		indexDirEnv:   filepath.Join(dir, "index"),
+		bundlesDirEnv: filepath.Join(dir, "bundles"),
+		outputDirEnv:  filepath.Join(dir, "outputs"),
+	}
+	for key, value := range env {
+		if err := os.Setenv(key, value); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set %s: %v\n", key, err)
+			return 1
+		}
+	}
+	return m.Run()
+}
@@ -0,0 +1,67 @@
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// projectsRootEnv mirrors the locator's META_CC_PROJECTS_ROOT
+const projectsRootEnv = "META_CC_PROJECTS_ROOT"
+
+// SetupProjectsRoot points META_CC_PROJECTS_ROOT at an empty temporary
+// directory for the duration of the test and returns it. Imported bundles
+// are session roots too, so META_CC_BUNDLES_DIR gets an empty one as well.
+func SetupProjectsRoot(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	t.Setenv(projectsRootEnv, root)
+	t.Setenv(bundlesDirEnv, t.TempDir())
+	return root
+}
+
+// SetupProject creates a temporary project directory and writes sessions
+// (session ID -> JSONL content) into its session dir under projectsRoot.
+// It returns the project path and the session dir.
+func SetupProject(t *testing.T, projectsRoot string, sessions map[string]string) (projectPath, sessionDir string) {
+	t.Helper()
+
+	projectPath = t.TempDir()
+	resolved, err := filepath.EvalSymlinks(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to resolve project path: %v", err)
+	}
+
+	// Same hashing as the locator's pathToHash
+	hash := strings.NewReplacer("\\", "-", "/", "-", ":", "-").Replace(resolved)
+	sessionDir = filepath.Join(projectsRoot, hash)
+	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		t.Fatalf("Failed to create session dir: %v", err)
+	}
+	for id, content := range sessions {
+		WriteSession(t, filepath.Join(sessionDir, id+".jsonl"), content)
+	}
+	return projectPath, sessionDir
+}
+
+// WriteSession writes a session file, replacing any existing content
+func WriteSession(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write session %s: %v", path, err)
+	}
+}
+
+// WriteSessionAt writes a session file last modified at modTime
+func WriteSessionAt(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	WriteSession(t, path, content)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set modification time of %s: %v", path, err)
+	}
+}
@@ -0,0 +1,31 @@
+// Package version holds build metadata injected at link time.
+//
+// Release builds set these with -ldflags, for example:
+//
+//	go build -ldflags "-X github.com/yaleh/meta-cc/internal/version.Version=v2.3.5" ./cmd/mcp-server
+package version
+
+import "runtime/debug"
+
+var (
+	// Version is the release version (git tag). Default: "dev"
+	Version = "dev"
+
+	// Commit is the short git commit hash. Default: "unknown"
+	Commit = "unknown"
+
+	// BuildTime is the UTC build timestamp. Default: "unknown"
+	BuildTime = "unknown"
+)
+
+// String returns the version, falling back to the module version recorded by
+// `go install module@version` when no ldflags were supplied.
+func String() string {
+	if Version != "dev" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return Version
+}
@@ -0,0 +1,20 @@
+package version
+
+import "testing"
+
+func TestStringPrefersInjectedVersion(t *testing.T) {
+	orig := Version
+	defer func() { Version = orig }()
+
+	Version = "v9.9.9"
+	if got := String(); got != "v9.9.9" {
+		t.Errorf("String() = %s, want v9.9.9", got)
+	}
+}
+
+func TestStringDefaultsToDev(t *testing.T) {
+	// Test binaries have no module version, so the default is returned
+	if got := String(); got != "dev" {
+		t.Errorf("String() = %s, want dev", got)
+	}
+}
@@ -0,0 +1,225 @@
+package metacc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yaleh/meta-cc/internal/analyzer"
+	"github.com/yaleh/meta-cc/internal/query"
+	"github.com/yaleh/meta-cc/internal/stats"
+)
+
+// SessionStats summarizes turns, tool usage and errors
+type SessionStats struct {
+	TurnCount          int            `json:"turn_count"`
+	UserTurnCount      int            `json:"user_turn_count"`
+	AssistantTurnCount int            `json:"assistant_turn_count"`
+	ToolCallCount      int            `json:"tool_call_count"`
+	ErrorCount         int            `json:"error_count"`
+	ErrorRate          float64        `json:"error_rate"` // Percentage of failed tool calls
+	DurationSeconds    int64          `json:"duration_seconds"`
+	ToolFrequency      map[string]int `json:"tool_frequency"`
+}
+
+// Stats computes session statistics for src
+func Stats(src Source) (SessionStats, error) {
+	entries, calls, err := src.load()
+	if err != nil {
+		return SessionStats{}, err
+	}
+	s := analyzer.CalculateStats(entries, calls)
+	return SessionStats{
+		TurnCount:          s.TurnCount,
+		UserTurnCount:      s.UserTurnCount,
+		AssistantTurnCount: s.AssistantTurnCount,
+		ToolCallCount:      s.ToolCallCount,
+		ErrorCount:         s.ErrorCount,
+		ErrorRate:          s.ErrorRate,
+		DurationSeconds:    s.DurationSeconds,
+		ToolFrequency:      s.ToolFrequency,
+	}, nil
+}
+
+// ErrorPattern is an error that repeated at least three times
+type ErrorPattern struct {
+	Signature       string `json:"signature"`
+	ToolName        string `json:"tool_name"`
+	Occurrences     int    `json:"occurrences"`
+	ErrorText       string `json:"error_text"` // Text of the first occurrence
+	FirstSeen       string `json:"first_seen"`
+	LastSeen        string `json:"last_seen"`
+	TimeSpanSeconds int    `json:"time_span_seconds"`
+}
+
+// ErrorPatterns groups repeated tool errors by signature
+func ErrorPatterns(src Source) ([]ErrorPattern, error) {
+	entries, calls, err := src.load()
+	if err != nil {
+		return nil, err
+	}
+	found := analyzer.DetectErrorPatterns(entries, calls)
+	patterns := make([]ErrorPattern, 0, len(found))
+	for _, p := range found {
+		patterns = append(patterns, ErrorPattern{
+			Signature:       p.Signature,
+			ToolName:        p.ToolName,
+			Occurrences:     p.Occurrences,
+			ErrorText:       p.ErrorText,
+			FirstSeen:       p.FirstSeen,
+			LastSeen:        p.LastSeen,
+			TimeSpanSeconds: p.TimeSpanSeconds,
+		})
+	}
+	return patterns, nil
+}
+
+// SequenceOptions configures ToolSequences
+type SequenceOptions struct {
+	MinOccurrences int    // Minimum occurrences to report a sequence (default 3)
+	Pattern        string // Only report this sequence, e.g. "Read -> Edit"
+	IncludeBuiltin bool   // Include built-in tools such as Read and Bash
+}
+
+// SequencePattern is a repeated sequence of tool calls
+type SequencePattern struct {
+	Pattern         string               `json:"pattern"` // Tool names joined by " → "
+	Count           int                  `json:"count"`
+	TimeSpanMinutes int                  `json:"time_span_minutes"`
+	Occurrences     []SequenceOccurrence `json:"occurrences"`
+}
+
+// SequenceOccurrence locates one occurrence of a sequence by turn number
+type SequenceOccurrence struct {
+	StartTurn int `json:"start_turn"`
+	EndTurn   int `json:"end_turn"`
+}
+
+// ToolSequences finds repeated tool call sequences
+func ToolSequences(src Source, opts SequenceOptions) ([]SequencePattern, error) {
+	if opts.MinOccurrences == 0 {
+		opts.MinOccurrences = 3
+	}
+	entries, _, err := src.load()
+	if err != nil {
+		return nil, err
+	}
+	result, err := query.BuildToolSequenceQuery(entries, opts.MinOccurrences, opts.Pattern, opts.IncludeBuiltin)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := make([]SequencePattern, 0, len(result.Sequences))
+	for _, s := range result.Sequences {
+		p := SequencePattern{
+			Pattern:         s.Pattern,
+			Count:           s.Count,
+			TimeSpanMinutes: s.TimeSpanMin,
+			Occurrences:     make([]SequenceOccurrence, 0, len(s.Occurrences)),
+		}
+		for _, o := range s.Occurrences {
+			p.Occurrences = append(p.Occurrences, SequenceOccurrence{StartTurn: o.StartTurn, EndTurn: o.EndTurn})
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// FileStat counts operations on one file
+type FileStat struct {
+	FilePath   string  `json:"file_path"`
+	ReadCount  int     `json:"read_count"`
+	EditCount  int     `json:"edit_count"`
+	WriteCount int     `json:"write_count"`
+	ErrorCount int     `json:"error_count"`
+	TotalOps   int     `json:"total_ops"`
+	ErrorRate  float64 `json:"error_rate"`
+}
+
+// FileStats counts Read, Edit and Write operations per file, most used first
+func FileStats(src Source) ([]FileStat, error) {
+	_, calls, err := src.load()
+	if err != nil {
+		return nil, err
+	}
+	found := stats.AnalyzeFileStats(calls)
+	stats.SortFileStats(found, "total_ops")
+
+	result := make([]FileStat, 0, len(found))
+	for _, f := range found {
+		result = append(result, FileStat(f))
+	}
+	return result, nil
+}
+
+// Group is one group of an aggregation
+type Group struct {
+	Value   string             `json:"value"`
+	Metrics map[string]float64 `json:"metrics"`
+}
+
+// Aggregate groups tool calls by "tool", "status" or "uuid" and computes
+// metrics per group ("count", "error_rate"; default "count"). Groups are
+// ordered by count, largest first, when count is requested.
+func Aggregate(src Source, groupBy string, metrics ...string) ([]Group, error) {
+	if len(metrics) == 0 {
+		metrics = []string{"count"}
+	}
+	_, calls, err := src.load()
+	if err != nil {
+		return nil, err
+	}
+	found, err := stats.Aggregate(calls, stats.AggregateConfig{GroupBy: groupBy, Metrics: metrics})
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrInvalidInput)
+	}
+
+	groups := make([]Group, 0, len(found))
+	for _, g := range found {
+		values := make(map[string]float64, len(g.Metrics))
+		for name, v := range g.Metrics {
+			switch n := v.(type) {
+			case int:
+				values[name] = float64(n)
+			case float64:
+				values[name] = n
+			}
+		}
+		groups = append(groups, Group{Value: g.GroupValue, Metrics: values})
+	}
+	return groups, nil
+}
+
+// Point is one bucket of a time series
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// TimeSeries buckets tool calls by interval ("hour", "day" or "week") and
+// computes metric ("tool-calls" or "error-rate") per bucket
+func TimeSeries(src Source, metric, interval string) ([]Point, error) {
+	switch metric {
+	case "tool-calls", "error-rate":
+	default:
+		return nil, fmt.Errorf("unsupported metric: %s (must be tool-calls or error-rate): %w", metric, ErrInvalidInput)
+	}
+	switch interval {
+	case "hour", "day", "week":
+	default:
+		return nil, fmt.Errorf("unsupported interval: %s (must be hour, day or week): %w", interval, ErrInvalidInput)
+	}
+	_, calls, err := src.load()
+	if err != nil {
+		return nil, err
+	}
+	found, err := stats.AnalyzeTimeSeries(calls, stats.TimeSeriesConfig{Metric: metric, Interval: interval})
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrInvalidInput)
+	}
+
+	points := make([]Point, 0, len(found))
+	for _, p := range found {
+		points = append(points, Point{Time: p.Timestamp, Value: p.Value})
+	}
+	return points, nil
+}
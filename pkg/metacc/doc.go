@@ -0,0 +1,41 @@
+// Package metacc is the supported Go API for reading and analyzing Claude
+// Code session history.
+//
+// Open a single session or every session of a project, then stream its
+// entries and tool calls or run the same analyses the meta-cc MCP server
+// and CLI use:
+//
+//	project, err := metacc.OpenProject("/home/me/work/app")
+//	if err != nil {
+//		return err
+//	}
+//	for call, err := range project.ToolCalls() {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(call.ToolName, call.Status)
+//	}
+//	stats, err := metacc.Stats(project)
+//
+// Sessions are located under the Claude projects root: META_CC_PROJECTS_ROOT
+// when set, otherwise ~/.claude/projects. Use WithProjectsRoot to override it.
+//
+// # Compatibility
+//
+// This package follows the semantic versioning of the meta-cc module. Within
+// a major version:
+//
+//   - Exported identifiers are not removed or renamed, and function
+//     signatures do not change.
+//   - Struct types may gain new fields and interfaces defined here may gain
+//     methods, so use keyed struct literals and do not implement Source
+//     outside this package (it is sealed).
+//   - JSON field names of exported types do not change.
+//   - Results of analyses may improve (e.g. better error signatures) but
+//     keep their documented meaning.
+//
+// Types here are defined by this package rather than aliased from meta-cc's
+// internal packages, so internal refactoring never changes this API. Error
+// values wrap the exported sentinels (ErrNotFound, ErrInvalidInput) and can
+// be tested with errors.Is.
+package metacc
@@ -0,0 +1,197 @@
+package metacc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaleh/meta-cc/internal/testutil"
+)
+
+const sessionOne = `{"type":"user","uuid":"u1","sessionId":"s1","timestamp":"2025-01-01T10:00:00Z","message":{"role":"user","content":"fix the build"}}
+{"type":"file-history-snapshot","messageId":"x"}
+{"type":"assistant","uuid":"a1","sessionId":"s1","timestamp":"2025-01-01T10:00:01Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go build"}}]}}
+{"type":"user","uuid":"u2","sessionId":"s1","timestamp":"2025-01-01T10:00:02Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":"exit status 1"}]}}
+{"type":"assistant","uuid":"a2","sessionId":"s1","timestamp":"2025-01-01T10:00:03Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"Read","input":{"file_path":"main.go"}}]}}
+{"type":"user","uuid":"u3","sessionId":"s1","timestamp":"2025-01-01T10:00:04Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":"package main"}]}}
+`
+
+const sessionTwo = `{"type":"assistant","uuid":"b1","sessionId":"s2","timestamp":"2025-01-02T09:00:00Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t3","name":"Bash","input":{"command":"go test"}}]}}
+{"type":"user","uuid":"b2","sessionId":"s2","timestamp":"2025-01-02T09:00:01Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t3","content":"ok"}]}}
+`
+
+// setupProject writes two sessions for a temp project under a temp projects
+// root and returns both paths
+func setupProject(t *testing.T) (projectsRoot, projectPath string) {
+	t.Helper()
+
+	projectsRoot = t.TempDir()
+	projectPath, sessionDir := testutil.SetupProject(t, projectsRoot, map[string]string{"s1": sessionOne, "s2": sessionTwo})
+
+	older := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(sessionDir, "s1.jsonl"), older, older))
+	return projectsRoot, projectPath
+}
+
+func TestOpenProject(t *testing.T) {
+	root, projectPath := setupProject(t)
+
+	project, err := OpenProject(projectPath, WithProjectsRoot(root))
+	require.NoError(t, err)
+	require.Len(t, project.Sessions, 2)
+	assert.Equal(t, "s1", project.Sessions[0].ID, "sessions are ordered oldest first")
+	assert.Equal(t, "s2", project.Latest().ID)
+
+	_, err = OpenProject(t.TempDir(), WithProjectsRoot(root))
+	assert.True(t, errors.Is(err, ErrNotFound), "unexpected error: %v", err)
+}
+
+func TestOpenSession(t *testing.T) {
+	root, _ := setupProject(t)
+
+	session, err := OpenSession("s1", WithProjectsRoot(root))
+	require.NoError(t, err)
+	assert.Equal(t, "s1", session.ID)
+	assert.Positive(t, session.Size)
+
+	_, err = OpenSession("missing", WithProjectsRoot(root))
+	assert.True(t, errors.Is(err, ErrNotFound), "unexpected error: %v", err)
+
+	_, err = OpenSession("")
+	assert.True(t, errors.Is(err, ErrInvalidInput), "unexpected error: %v", err)
+}
+
+func TestSessionEntries(t *testing.T) {
+	root, _ := setupProject(t)
+	session, err := OpenSession("s1", WithProjectsRoot(root))
+	require.NoError(t, err)
+
+	var uuids []string
+	for entry, err := range session.Entries() {
+		require.NoError(t, err)
+		uuids = append(uuids, entry.UUID)
+	}
+	assert.Equal(t, []string{"u1", "a1", "u2", "a2", "u3"}, uuids, "non-message records are skipped")
+
+	// Breaking out of the loop stops the stream
+	count := 0
+	for entry := range session.Entries() {
+		count++
+		require.NotNil(t, entry.Message)
+		assert.Equal(t, "fix the build", entry.Message.Content[0].Text)
+		break
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestEntriesReadsLongLines(t *testing.T) {
+	// A single record well past bufio.Scanner's default and former 2MB caps
+	long := strings.Repeat("x", 3*1024*1024)
+	data := `{"type":"user","uuid":"big","timestamp":"2025-01-01T10:00:00Z","message":{"role":"user","content":"` + long + `"}}` + "\n" + sessionTwo
+	path := filepath.Join(t.TempDir(), "long.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	session, err := OpenFile(path)
+	require.NoError(t, err)
+
+	var uuids []string
+	for entry, err := range session.Entries() {
+		require.NoError(t, err)
+		uuids = append(uuids, entry.UUID)
+	}
+	assert.Equal(t, []string{"big", "b1", "b2"}, uuids)
+}
+
+func TestEntriesReportsParseErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(sessionTwo+"{not json\n"), 0644))
+	session, err := OpenFile(path)
+	require.NoError(t, err)
+
+	var gotErr error
+	entries := 0
+	for _, err := range session.Entries() {
+		if err != nil {
+			gotErr = err
+			continue
+		}
+		entries++
+	}
+	assert.Equal(t, 2, entries)
+	require.Error(t, gotErr)
+	assert.Contains(t, gotErr.Error(), "line 3")
+}
+
+func TestProjectToolCalls(t *testing.T) {
+	root, projectPath := setupProject(t)
+	project, err := OpenProject(projectPath, WithProjectsRoot(root))
+	require.NoError(t, err)
+
+	var calls []ToolCall
+	for call, err := range project.ToolCalls() {
+		require.NoError(t, err)
+		calls = append(calls, call)
+	}
+	require.Len(t, calls, 3)
+	assert.Equal(t, []string{"Bash", "Read", "Bash"}, []string{calls[0].ToolName, calls[1].ToolName, calls[2].ToolName})
+	assert.True(t, calls[0].Failed())
+	assert.False(t, calls[1].Failed())
+	assert.Equal(t, "s2", calls[2].SessionID)
+}
+
+func TestAnalyses(t *testing.T) {
+	root, projectPath := setupProject(t)
+	project, err := OpenProject(projectPath, WithProjectsRoot(root))
+	require.NoError(t, err)
+
+	stats, err := Stats(project)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.ToolCallCount)
+	assert.Equal(t, 1, stats.ErrorCount)
+	assert.Equal(t, 2, stats.ToolFrequency["Bash"])
+
+	groups, err := Aggregate(project, "tool", "count", "error_rate")
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, "Bash", groups[0].Value)
+	assert.Equal(t, 2.0, groups[0].Metrics["count"])
+	assert.Equal(t, 0.5, groups[0].Metrics["error_rate"])
+
+	files, err := FileStats(project)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "main.go", files[0].FilePath)
+
+	sequences, err := ToolSequences(project.Sessions[0], SequenceOptions{MinOccurrences: 1, IncludeBuiltin: true})
+	require.NoError(t, err)
+	require.NotEmpty(t, sequences)
+	assert.Equal(t, "Bash → Read", sequences[0].Pattern)
+
+	points, err := TimeSeries(project, "tool-calls", "day")
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, 2.0, points[0].Value)
+
+	patterns, err := ErrorPatterns(project)
+	require.NoError(t, err)
+	assert.Empty(t, patterns, "a single failure is not a pattern")
+}
+
+func TestAnalysesRejectInvalidInput(t *testing.T) {
+	root, projectPath := setupProject(t)
+	project, err := OpenProject(projectPath, WithProjectsRoot(root))
+	require.NoError(t, err)
+
+	_, err = Aggregate(project, "model")
+	assert.True(t, errors.Is(err, ErrInvalidInput), "unexpected error: %v", err)
+
+	_, err = TimeSeries(project, "tokens", "day")
+	assert.True(t, errors.Is(err, ErrInvalidInput), "unexpected error: %v", err)
+
+	_, err = TimeSeries(project, "tool-calls", "month")
+	assert.True(t, errors.Is(err, ErrInvalidInput), "unexpected error: %v", err)
+}
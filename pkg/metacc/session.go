@@ -0,0 +1,264 @@
+package metacc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	mcerrors "github.com/yaleh/meta-cc/internal/errors"
+	"github.com/yaleh/meta-cc/internal/index"
+	"github.com/yaleh/meta-cc/internal/locator"
+	"github.com/yaleh/meta-cc/internal/parser"
+	"github.com/yaleh/meta-cc/internal/query"
+	"github.com/yaleh/meta-cc/internal/sessionio"
+)
+
+// Sentinel errors returned (wrapped) by this package
+var (
+	// ErrNotFound reports that a project or session does not exist
+	ErrNotFound = mcerrors.ErrNotFound
+	// ErrInvalidInput reports an invalid argument such as an unknown metric
+	ErrInvalidInput = mcerrors.ErrInvalidInput
+)
+
+// Source is a set of sessions that can be streamed and analyzed. It is
+// implemented by *Session and *Project only.
+type Source interface {
+	// Entries streams user and assistant entries in file order
+	Entries() iter.Seq2[Entry, error]
+	// ToolCalls streams tool calls ordered by timestamp within each session
+	ToolCalls() iter.Seq2[ToolCall, error]
+
+	// load returns the entry skeletons (see index.FileIndex.Skeletons) and
+	// the complete tool calls, in the internal representation of analyzers
+	load() (skeletons []parser.SessionEntry, calls []parser.ToolCall, err error)
+}
+
+// Option configures how sessions are located
+type Option func(*options)
+
+type options struct {
+	projectsRoot string
+}
+
+// WithProjectsRoot locates sessions under dir instead of META_CC_PROJECTS_ROOT
+// or ~/.claude/projects
+func WithProjectsRoot(dir string) Option {
+	return func(o *options) { o.projectsRoot = dir }
+}
+
+// newLocator builds a locator honoring the options
+func newLocator(opts []Option) *locator.SessionLocator {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.projectsRoot != "" {
+		return locator.NewSessionLocatorWithRoot(o.projectsRoot)
+	}
+	return locator.NewSessionLocator()
+}
+
+// Session is a single session file
+type Session struct {
+	ID       string    // Session ID (file name without .jsonl)
+	Path     string    // Absolute path of the session file
+	Size     int64     // File size in bytes
+	Modified time.Time // Last modification time
+}
+
+// OpenFile opens a session file by path
+func OpenFile(path string) (*Session, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve session path %s: %w", path, ErrInvalidInput)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session file %s: %w", path, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to stat session file %s: %w", path, mcerrors.ErrFileIO)
+	}
+	return &Session{
+		ID:       sessionio.SessionID(abs),
+		Path:     abs,
+		Size:     info.Size(),
+		Modified: info.ModTime(),
+	}, nil
+}
+
+// OpenSession finds a session by ID in any project. If several projects
+// contain the ID, the most recently modified file wins.
+func OpenSession(sessionID string, opts ...Option) (*Session, error) {
+	if sessionID == "" {
+		return nil, fmt.Errorf("session ID is required: %w", ErrInvalidInput)
+	}
+	path, err := newLocator(opts).FromSessionID(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrNotFound)
+	}
+	return OpenFile(path)
+}
+
+// Entries streams user and assistant entries in file order. Iteration stops
+// after the first error.
+func (s *Session) Entries() iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		// Same line reader as the query tools: no cap on the line size, which
+		// tool results can push to many megabytes
+		var parseErr error
+		_, _, err := query.ForEachLine(context.Background(), s.Path, func(lineNum int, line []byte) bool {
+			var entry parser.SessionEntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				parseErr = fmt.Errorf("%s line %d: %v: %w", s.Path, lineNum, err, mcerrors.ErrParseError)
+				return false
+			}
+			return !entry.IsMessage() || yield(newEntry(entry), nil)
+		})
+		switch {
+		case parseErr != nil:
+			yield(Entry{}, parseErr)
+		case err != nil:
+			yield(Entry{}, fmt.Errorf("failed to read session file %s: %v: %w", s.Path, err, mcerrors.ErrFileIO))
+		}
+	}
+}
+
+// ToolCalls streams tool calls ordered by timestamp. Results are paired with
+// their invocations, so the session is read fully before the first call.
+func (s *Session) ToolCalls() iter.Seq2[ToolCall, error] {
+	return func(yield func(ToolCall, error) bool) {
+		_, calls, err := s.load()
+		if err != nil {
+			yield(ToolCall{}, err)
+			return
+		}
+		for _, tc := range calls {
+			if !yield(newToolCall(tc, s.ID), nil) {
+				return
+			}
+		}
+	}
+}
+
+// load implements Source. Analyses read the session index, so unchanged
+// sessions are decoded only once.
+func (s *Session) load() ([]parser.SessionEntry, []parser.ToolCall, error) {
+	fi, err := index.Default().File(s.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v: %w", s.Path, err, mcerrors.ErrFileIO)
+	}
+	if err := fi.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v: %w", s.Path, err, mcerrors.ErrParseError)
+	}
+	return fi.Skeletons, sortedToolCalls(fi.ToolCalls), nil
+}
+
+// sortedToolCalls copies tool calls in a deterministic order. Session files
+// mark failures with is_error rather than a status, so Status is normalized to
+// "error" or "success" for every analysis.
+func sortedToolCalls(indexed []parser.ToolCall) []parser.ToolCall {
+	calls := append([]parser.ToolCall(nil), indexed...)
+	for i := range calls {
+		if calls[i].Status == "" {
+			if calls[i].Error != "" {
+				calls[i].Status = "error"
+			} else {
+				calls[i].Status = "success"
+			}
+		}
+	}
+	sort.SliceStable(calls, func(i, j int) bool {
+		if calls[i].Timestamp != calls[j].Timestamp {
+			return calls[i].Timestamp < calls[j].Timestamp
+		}
+		return calls[i].UUID < calls[j].UUID
+	})
+	return calls
+}
+
+// Project is every session recorded for one project directory
+type Project struct {
+	Path     string     // Absolute project directory
+	Sessions []*Session // Sessions, oldest first
+}
+
+// OpenProject opens all sessions recorded for the project directory
+func OpenProject(projectPath string, opts ...Option) (*Project, error) {
+	abs, err := filepath.Abs(projectPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve project path %s: %w", projectPath, ErrInvalidInput)
+	}
+	paths, err := newLocator(opts).AllSessionsFromProject(abs)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrNotFound)
+	}
+
+	project := &Project{Path: abs}
+	for _, path := range paths {
+		session, err := OpenFile(path)
+		if err != nil {
+			return nil, err
+		}
+		project.Sessions = append(project.Sessions, session)
+	}
+	sort.SliceStable(project.Sessions, func(i, j int) bool {
+		return project.Sessions[i].Modified.Before(project.Sessions[j].Modified)
+	})
+	return project, nil
+}
+
+// Latest returns the most recently modified session
+func (p *Project) Latest() *Session {
+	if len(p.Sessions) == 0 {
+		return nil
+	}
+	return p.Sessions[len(p.Sessions)-1]
+}
+
+// Entries streams the entries of every session, oldest session first
+func (p *Project) Entries() iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		for _, s := range p.Sessions {
+			for entry, err := range s.Entries() {
+				if !yield(entry, err) || err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ToolCalls streams the tool calls of every session, oldest session first
+func (p *Project) ToolCalls() iter.Seq2[ToolCall, error] {
+	return func(yield func(ToolCall, error) bool) {
+		for _, s := range p.Sessions {
+			for call, err := range s.ToolCalls() {
+				if !yield(call, err) || err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// load implements Source
+func (p *Project) load() ([]parser.SessionEntry, []parser.ToolCall, error) {
+	var skeletons []parser.SessionEntry
+	var calls []parser.ToolCall
+	for _, s := range p.Sessions {
+		e, c, err := s.load()
+		if err != nil {
+			return nil, nil, err
+		}
+		skeletons = append(skeletons, e...)
+		calls = append(calls, c...)
+	}
+	return skeletons, calls, nil
+}
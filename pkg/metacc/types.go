@@ -0,0 +1,124 @@
+package metacc
+
+import (
+	"github.com/yaleh/meta-cc/internal/parser"
+)
+
+// Entry is one user or assistant message of a session. Other record types
+// (such as file-history-snapshot) are skipped.
+type Entry struct {
+	Type       string   `json:"type"` // "user" or "assistant"
+	Timestamp  string   `json:"timestamp"`
+	UUID       string   `json:"uuid"`
+	ParentUUID string   `json:"parent_uuid"`
+	SessionID  string   `json:"session_id"`
+	CWD        string   `json:"cwd"`
+	Version    string   `json:"version"` // Claude Code version
+	GitBranch  string   `json:"git_branch"`
+	Message    *Message `json:"message,omitempty"`
+}
+
+// Message is the content of an entry
+type Message struct {
+	ID         string                 `json:"id,omitempty"`
+	Role       string                 `json:"role"`
+	Model      string                 `json:"model,omitempty"`
+	Content    []ContentBlock         `json:"content"`
+	StopReason string                 `json:"stop_reason,omitempty"`
+	Usage      map[string]interface{} `json:"usage,omitempty"`
+}
+
+// ContentBlock is a text, tool_use or tool_result part of a message
+type ContentBlock struct {
+	Type       string      `json:"type"`
+	Text       string      `json:"text,omitempty"`
+	ToolUse    *ToolUse    `json:"tool_use,omitempty"`
+	ToolResult *ToolResult `json:"tool_result,omitempty"`
+}
+
+// ToolUse is a tool invocation requested by the assistant
+type ToolUse struct {
+	ID    string                 `json:"id"`
+	Name  string                 `json:"name"`
+	Input map[string]interface{} `json:"input"`
+}
+
+// ToolResult is the outcome of a tool invocation
+type ToolResult struct {
+	ToolUseID string `json:"tool_use_id"`
+	Content   string `json:"content"`
+	IsError   bool   `json:"is_error"`
+}
+
+// ToolCall pairs a tool invocation with its result
+type ToolCall struct {
+	UUID      string                 `json:"uuid"` // UUID of the entry containing the tool_use
+	SessionID string                 `json:"session_id"`
+	ToolName  string                 `json:"tool_name"`
+	Input     map[string]interface{} `json:"input"`
+	Output    string                 `json:"output"`
+	Status    string                 `json:"status"` // "error" or "success"
+	Error     string                 `json:"error,omitempty"`
+	Timestamp string                 `json:"timestamp"`
+}
+
+// Failed reports whether the tool call returned an error
+func (c ToolCall) Failed() bool {
+	return c.Status == "error" || c.Error != ""
+}
+
+// newEntry converts a parsed entry to the public type
+func newEntry(e parser.SessionEntry) Entry {
+	entry := Entry{
+		Type:       e.Type,
+		Timestamp:  e.Timestamp,
+		UUID:       e.UUID,
+		ParentUUID: e.ParentUUID,
+		SessionID:  e.SessionID,
+		CWD:        e.CWD,
+		Version:    e.Version,
+		GitBranch:  e.GitBranch,
+	}
+	if e.Message == nil {
+		return entry
+	}
+
+	msg := &Message{
+		ID:         e.Message.ID,
+		Role:       e.Message.Role,
+		Model:      e.Message.Model,
+		StopReason: e.Message.StopReason,
+		Usage:      e.Message.Usage,
+		Content:    make([]ContentBlock, 0, len(e.Message.Content)),
+	}
+	for _, b := range e.Message.Content {
+		block := ContentBlock{Type: b.Type, Text: b.Text}
+		if b.ToolUse != nil {
+			block.ToolUse = &ToolUse{ID: b.ToolUse.ID, Name: b.ToolUse.Name, Input: b.ToolUse.Input}
+		}
+		if b.ToolResult != nil {
+			block.ToolResult = &ToolResult{
+				ToolUseID: b.ToolResult.ToolUseID,
+				Content:   b.ToolResult.Content,
+				IsError:   b.ToolResult.IsError,
+			}
+		}
+		msg.Content = append(msg.Content, block)
+	}
+	entry.Message = msg
+	return entry
+}
+
+// newToolCall converts an extracted tool call to the public type
+func newToolCall(tc parser.ToolCall, sessionID string) ToolCall {
+	return ToolCall{
+		UUID:      tc.UUID,
+		SessionID: sessionID,
+		ToolName:  tc.ToolName,
+		Input:     tc.Input,
+		Output:    tc.Output,
+		Status:    tc.Status,
+		Error:     tc.Error,
+		Timestamp: tc.Timestamp,
+	}
+}
@@ -5,10 +5,20 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/yaleh/meta-cc/internal/parser"
 )
 
+// Chunk grouping keys of SplitRecords
+const (
+	ChunkBySession = "session_id"
+	ChunkByDay     = "day"
+)
+
+// maxChunkKeys caps the group keys listed per chunk
+const maxChunkKeys = 10
+
 // ChunkMetadata contains metadata for a single chunk
 type ChunkMetadata struct {
 	Index       int    `json:"index"`
@@ -16,14 +26,24 @@ type ChunkMetadata struct {
 	Records     int    `json:"records"`
 	SizeBytes   int64  `json:"size_bytes"`
 	TotalChunks int    `json:"total_chunks"`
+	// Keys are the sessions or days of the chunk (see ChunkManifest.GroupedBy),
+	// at most maxChunkKeys of KeyCount
+	Keys     []string `json:"keys,omitempty"`
+	KeyCount int      `json:"key_count,omitempty"`
+	// From and To are the earliest and latest record timestamps
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
 }
 
 // ChunkManifest contains metadata for all chunks
 type ChunkManifest struct {
-	TotalRecords int             `json:"total_records"`
-	ChunkSize    int             `json:"chunk_size"`
-	NumChunks    int             `json:"num_chunks"`
-	Chunks       []ChunkMetadata `json:"chunks"`
+	TotalRecords int `json:"total_records"`
+	ChunkSize    int `json:"chunk_size"`
+	NumChunks    int `json:"num_chunks"`
+	// GroupedBy is how chunk boundaries were chosen: session_id, day, or
+	// empty when by size only
+	GroupedBy string          `json:"grouped_by,omitempty"`
+	Chunks    []ChunkMetadata `json:"chunks"`
 }
 
 // SplitIntoChunks splits a slice of ToolCalls into chunks of specified size
@@ -181,3 +201,156 @@ func getExtension(format string) string {
 		return "txt"
 	}
 }
+
+// RecordChunkKey picks how decoded records are grouped into chunks: by
+// session when every record names one (session_id, as tagged by scope=all
+// queries, or the sessionId of raw session records) and they span several,
+// otherwise by the day of their timestamps, otherwise "" (size only)
+func RecordChunkKey(records []interface{}) string {
+	for _, groupBy := range []string{ChunkBySession, ChunkByDay} {
+		first, several := "", false
+		for _, record := range records {
+			key := chunkKeyOf(record, groupBy)
+			if key == "" {
+				first, several = "", false
+				break
+			}
+			if first == "" {
+				first = key
+			} else if key != first {
+				several = true
+			}
+		}
+		if several {
+			return groupBy
+		}
+	}
+	return ""
+}
+
+// chunkKeyOf returns the group of a record, or "" when it has none
+func chunkKeyOf(record interface{}, groupBy string) string {
+	m, ok := record.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	switch groupBy {
+	case ChunkBySession:
+		if sessionID, _ := m["session_id"].(string); sessionID != "" {
+			return sessionID
+		}
+		// Records of single-scope queries carry the session field as written
+		sessionID, _ := m["sessionId"].(string)
+		return sessionID
+	case ChunkByDay:
+		if t, ok := recordTime(m); ok {
+			return t.UTC().Format(time.DateOnly)
+		}
+	}
+	return ""
+}
+
+// recordTime parses the timestamp field of a record
+func recordTime(m map[string]interface{}) (time.Time, bool) {
+	timestamp, _ := m["timestamp"].(string)
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	return t, err == nil
+}
+
+// SplitRecords splits decoded records into chunks of about maxBytes of
+// JSONL each, generalizing SplitIntoChunks to arbitrary records. Chunk
+// boundaries fall between groups (see RecordChunkKey) where possible: a
+// chunk holds whole runs of consecutive records of one session or day, and
+// only a run larger than maxBytes is cut inside. Record order is preserved.
+//
+// Returns the chunks and the grouping used.
+func SplitRecords(records []interface{}, maxBytes int) ([][]interface{}, string) {
+	groupBy := RecordChunkKey(records)
+	if maxBytes <= 0 || len(records) == 0 {
+		return [][]interface{}{records}, groupBy
+	}
+
+	var chunks [][]interface{}
+	var current []interface{}
+	currentSize := 0
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, current)
+			current, currentSize = nil, 0
+		}
+	}
+
+	for start := 0; start < len(records); {
+		// The run of records sharing the group of records[start]
+		end, runSize := start, 0
+		key := chunkKeyOf(records[start], groupBy)
+		sizes := make([]int, 0)
+		for end < len(records) && (end == start || groupBy != "" && chunkKeyOf(records[end], groupBy) == key) {
+			size := recordJSONLSize(records[end])
+			sizes = append(sizes, size)
+			runSize += size
+			end++
+		}
+
+		if currentSize > 0 && currentSize+runSize > maxBytes {
+			flush()
+		}
+		for i, size := range sizes {
+			// Runs larger than a chunk are cut by size
+			if currentSize > 0 && currentSize+size > maxBytes {
+				flush()
+			}
+			current = append(current, records[start+i])
+			currentSize += size
+		}
+		start = end
+	}
+	flush()
+	return chunks, groupBy
+}
+
+// recordJSONLSize returns the size of a record as a JSONL line
+func recordJSONLSize(record interface{}) int {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return 0
+	}
+	return len(data) + 1
+}
+
+// DescribeRecordChunk returns the metadata of a chunk of decoded records
+// other than its file and size: record count, group keys and time range
+func DescribeRecordChunk(index, totalChunks int, records []interface{}, groupBy string) ChunkMetadata {
+	meta := ChunkMetadata{Index: index, Records: len(records), TotalChunks: totalChunks}
+
+	seen := make(map[string]bool)
+	var from, to time.Time
+	for _, record := range records {
+		if key := chunkKeyOf(record, groupBy); key != "" && !seen[key] {
+			seen[key] = true
+			if len(meta.Keys) < maxChunkKeys {
+				meta.Keys = append(meta.Keys, key)
+			}
+		}
+		m, ok := record.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if t, ok := recordTime(m); ok {
+			if from.IsZero() || t.Before(from) {
+				from = t
+			}
+			if to.IsZero() || t.After(to) {
+				to = t
+			}
+		}
+	}
+	if len(seen) > len(meta.Keys) {
+		meta.KeyCount = len(seen)
+	}
+	if !from.IsZero() {
+		meta.From = from.Format(time.RFC3339Nano)
+		meta.To = to.Format(time.RFC3339Nano)
+	}
+	return meta
+}
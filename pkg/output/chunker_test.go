@@ -297,3 +297,94 @@ func TestChunkDifferentFormats(t *testing.T) {
 		})
 	}
 }
+
+// timedRecords returns one record per timestamp (about 60 bytes each), all of session
+func timedRecords(session string, timestamps ...string) []interface{} {
+	records := make([]interface{}, len(timestamps))
+	for i, ts := range timestamps {
+		records[i] = map[string]interface{}{"session_id": session, "timestamp": ts}
+	}
+	return records
+}
+
+func TestRecordChunkKey(t *testing.T) {
+	a := timedRecords("a", "2025-10-01T10:00:00Z", "2025-10-02T10:00:00Z")
+	b := timedRecords("b", "2025-10-02T11:00:00Z")
+
+	if got := RecordChunkKey(append(append([]interface{}{}, a...), b...)); got != ChunkBySession {
+		t.Errorf("several sessions: got %q, want %q", got, ChunkBySession)
+	}
+	// One session spanning days falls back to days
+	if got := RecordChunkKey(a); got != ChunkByDay {
+		t.Errorf("one session: got %q, want %q", got, ChunkByDay)
+	}
+	if got := RecordChunkKey([]interface{}{map[string]interface{}{"id": 1.0}, "text"}); got != "" {
+		t.Errorf("no keys: got %q, want size only", got)
+	}
+
+	// Untagged session records name their session in sessionId
+	untagged := []interface{}{
+		map[string]interface{}{"sessionId": "a", "timestamp": "2025-10-01T10:00:00Z"},
+		map[string]interface{}{"sessionId": "b", "timestamp": "2025-10-01T11:00:00Z"},
+	}
+	if got := RecordChunkKey(untagged); got != ChunkBySession {
+		t.Errorf("untagged sessions: got %q, want %q", got, ChunkBySession)
+	}
+	chunks, groupBy := SplitRecords(untagged, recordJSONLSize(untagged[0]))
+	if groupBy != ChunkBySession || len(chunks) != 2 || chunkKeyOf(chunks[1][0], ChunkBySession) != "b" {
+		t.Errorf("expected one chunk per untagged session, got %v by %q", chunks, groupBy)
+	}
+	if meta := DescribeRecordChunk(1, len(chunks), chunks[1], groupBy); fmt.Sprint(meta.Keys) != "[b]" {
+		t.Errorf("unexpected chunk keys: %v", meta.Keys)
+	}
+}
+
+func TestSplitRecords(t *testing.T) {
+	var records []interface{}
+	records = append(records, timedRecords("a", "2025-10-01T10:00:00Z", "2025-10-01T10:01:00Z")...)
+	records = append(records, timedRecords("b", "2025-10-01T11:00:00Z")...)
+	records = append(records, timedRecords("c", "2025-10-02T09:00:00Z", "2025-10-02T09:01:00Z", "2025-10-02T09:02:00Z", "2025-10-02T09:03:00Z", "2025-10-02T09:04:00Z")...)
+	size := recordJSONLSize(records[0])
+
+	// Sessions a and b fit one chunk; c is cut by size
+	chunks, groupBy := SplitRecords(records, 3*size)
+	if groupBy != ChunkBySession {
+		t.Fatalf("expected grouping by session, got %q", groupBy)
+	}
+	var lengths []int
+	total := 0
+	for _, chunk := range chunks {
+		lengths = append(lengths, len(chunk))
+		total += len(chunk)
+	}
+	if fmt.Sprint(lengths) != "[3 3 2]" || total != len(records) {
+		t.Errorf("unexpected chunk lengths %v", lengths)
+	}
+
+	// A session never shares a chunk it does not fit in
+	chunks, _ = SplitRecords(records, 4*size)
+	if len(chunks[0]) != 3 || chunkKeyOf(chunks[1][0], ChunkBySession) != "c" {
+		t.Errorf("expected session c to start a chunk, got %v", chunks)
+	}
+
+	meta := DescribeRecordChunk(0, len(chunks), chunks[0], groupBy)
+	if fmt.Sprint(meta.Keys) != "[a b]" || meta.Records != 3 || meta.From != "2025-10-01T10:00:00Z" || meta.To != "2025-10-01T11:00:00Z" {
+		t.Errorf("unexpected chunk metadata: %+v", meta)
+	}
+
+	// No limit: one chunk
+	if chunks, _ := SplitRecords(records, 0); len(chunks) != 1 || len(chunks[0]) != len(records) {
+		t.Errorf("expected a single chunk without a limit, got %d", len(chunks))
+	}
+}
+
+func TestDescribeRecordChunkCapsKeys(t *testing.T) {
+	var records []interface{}
+	for i := 0; i < 15; i++ {
+		records = append(records, timedRecords(fmt.Sprintf("s%02d", i), "2025-10-01T10:00:00Z")...)
+	}
+	meta := DescribeRecordChunk(0, 1, records, ChunkBySession)
+	if len(meta.Keys) != maxChunkKeys || meta.KeyCount != 15 {
+		t.Errorf("expected %d of 15 keys, got %d (key_count %d)", maxChunkKeys, len(meta.Keys), meta.KeyCount)
+	}
+}
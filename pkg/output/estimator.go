@@ -80,3 +80,38 @@ func EstimateStatsSize(format string) SizeEstimate {
 		RecordCount:    1, // Stats report is a single record
 	}
 }
+
+// BytesPerToken is the average number of bytes of JSON output per LLM token.
+// JSON keys, punctuation and identifiers tokenize at roughly 4 bytes a token.
+const BytesPerToken = 4
+
+// EstimateTokens estimates the LLM tokens of n bytes of output
+func EstimateTokens(n int) int {
+	return (n + BytesPerToken - 1) / BytesPerToken
+}
+
+// EstimateRecordsSize estimates the JSONL output size of arbitrary records
+// (e.g. MCP query results). Every record is serialized, so the estimate is
+// exact for JSONL.
+func EstimateRecordsSize(records []interface{}) SizeEstimate {
+	sizeBytes := 0
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+		sizeBytes += len(data) + 1 // newline
+	}
+
+	return SizeEstimate{
+		EstimatedBytes: sizeBytes,
+		EstimatedKB:    float64(sizeBytes) / 1024.0,
+		Format:         "jsonl",
+		RecordCount:    len(records),
+	}
+}
+
+// Tokens returns the estimated LLM tokens of the output
+func (e SizeEstimate) Tokens() int {
+	return EstimateTokens(e.EstimatedBytes)
+}
@@ -160,3 +160,30 @@ func TestEstimateSizeUnsupportedFormat(t *testing.T) {
 		t.Error("expected positive estimate for unsupported format")
 	}
 }
+
+func TestEstimateRecordsSize(t *testing.T) {
+	records := []interface{}{
+		map[string]interface{}{"tool_name": "Bash", "status": "error"},
+		map[string]interface{}{"tool_name": "Read"},
+	}
+
+	estimate := EstimateRecordsSize(records)
+	expected := 0
+	for _, r := range records {
+		data, _ := json.Marshal(r)
+		expected += len(data) + 1
+	}
+	if estimate.EstimatedBytes != expected {
+		t.Errorf("expected %d bytes, got %d", expected, estimate.EstimatedBytes)
+	}
+	if estimate.RecordCount != 2 || estimate.Format != "jsonl" {
+		t.Errorf("unexpected estimate: %+v", estimate)
+	}
+	if estimate.Tokens() != (expected+3)/4 {
+		t.Errorf("expected %d tokens, got %d", (expected+3)/4, estimate.Tokens())
+	}
+
+	if EstimateTokens(0) != 0 || EstimateTokens(1) != 1 || EstimateTokens(8) != 2 {
+		t.Errorf("unexpected EstimateTokens rounding")
+	}
+}
@@ -225,7 +225,7 @@ func formatProjectedCSV(projected []ProjectedToolCall) string {
 // escapeCSV escapes a CSV field value
 func escapeCSV(s string) string {
 	// If the string contains comma, quotes, or newlines, wrap in quotes and escape quotes
-	if strings.ContainsAny(s, ",\"\n") {
+	if strings.ContainsAny(s, ",\"\r\n") {
 		s = strings.ReplaceAll(s, "\"", "\"\"")
 		return "\"" + s + "\""
 	}
@@ -0,0 +1,218 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Record formats accepted by FormatRecords
+const (
+	FormatJSONLRecords    = "jsonl"
+	FormatTSVRecords      = "tsv"
+	FormatCSVRecords      = "csv"
+	FormatMarkdownRecords = "markdown"
+	FormatColumnarRecords = "columnar"
+)
+
+// RecordFormats lists the formats FormatRecords accepts
+var RecordFormats = []string{FormatJSONLRecords, FormatTSVRecords, FormatCSVRecords, FormatMarkdownRecords, FormatColumnarRecords}
+
+// ColumnarRecords is the compact JSON form of records: field names once,
+// then one row of values per record (null where a record lacks the field)
+type ColumnarRecords struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// RecordField returns the value at a dotted path (e.g. "input.command") of a
+// decoded JSON record
+func RecordField(record interface{}, path string) (interface{}, bool) {
+	value := record
+	for _, part := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// IsErrorRecord reports whether a decoded record describes a failure: status
+// "error", is_error true or a non-empty error field
+func IsErrorRecord(record interface{}) bool {
+	m, ok := record.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if status, _ := m["status"].(string); status == "error" {
+		return true
+	}
+	if isError, _ := m["is_error"].(bool); isError {
+		return true
+	}
+	errText, _ := m["error"].(string)
+	return errText != ""
+}
+
+// ProjectRecords applies field projection to decoded JSON records, the
+// generic counterpart of ProjectToolCalls. Fields are dotted paths; a nested
+// value is kept under its full path (e.g. "input.command"). IfErrorInclude
+// fields are added to records for which IsErrorRecord holds. Records that are
+// not objects are returned unchanged.
+func ProjectRecords(records []interface{}, config ProjectionConfig) []interface{} {
+	if len(config.Fields) == 0 {
+		return records
+	}
+
+	projected := make([]interface{}, len(records))
+	for i, record := range records {
+		if _, ok := record.(map[string]interface{}); !ok {
+			projected[i] = record
+			continue
+		}
+		fields := config.Fields
+		if len(config.IfErrorInclude) > 0 && IsErrorRecord(record) {
+			fields = append(append([]string{}, fields...), config.IfErrorInclude...)
+		}
+		m := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			if value, ok := RecordField(record, field); ok {
+				m[field] = value
+			}
+		}
+		projected[i] = m
+	}
+	return projected
+}
+
+// RecordColumns returns the columns of a table of records: the preferred
+// fields in order, then every other field sorted. Records that are not
+// objects form a single "value" column.
+func RecordColumns(records []interface{}, preferred []string) []string {
+	seen := make(map[string]bool)
+	columns := make([]string, 0, len(preferred))
+	for _, field := range preferred {
+		if !seen[field] {
+			seen[field] = true
+			columns = append(columns, field)
+		}
+	}
+
+	var rest []string
+	for _, record := range records {
+		for field := range recordRow(record) {
+			if !seen[field] {
+				seen[field] = true
+				rest = append(rest, field)
+			}
+		}
+	}
+	sort.Strings(rest)
+	return append(columns, rest...)
+}
+
+// FormatRecords renders decoded JSON records in a record format
+//
+// Returns:
+//   - jsonl: the records unchanged
+//   - tsv, csv, markdown: a table as text, one row per record
+//   - columnar: ColumnarRecords
+func FormatRecords(records []interface{}, columns []string, format string) (interface{}, error) {
+	switch format {
+	case "", FormatJSONLRecords:
+		return records, nil
+
+	case FormatColumnarRecords:
+		rows := make([][]interface{}, len(records))
+		for i, record := range records {
+			m := recordRow(record)
+			rows[i] = make([]interface{}, len(columns))
+			for j, column := range columns {
+				rows[i][j] = m[column]
+			}
+		}
+		return ColumnarRecords{Columns: columns, Rows: rows}, nil
+
+	case FormatTSVRecords:
+		return formatRecordTable(records, columns, "", "\t", "", escapeTSV), nil
+
+	case FormatCSVRecords:
+		return formatRecordTable(records, columns, "", ",", "", escapeCSV), nil
+
+	case FormatMarkdownRecords:
+		if len(records) == 0 {
+			return "No data", nil
+		}
+		table := formatRecordTable(records, columns, "| ", " | ", " |", escapeMarkdownCell)
+		// Separator row after the header
+		header, body, _ := strings.Cut(table, "\n")
+		separator := "|" + strings.Repeat(" --- |", len(columns))
+		return header + "\n" + separator + "\n" + body, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported record format: %s (must be one of: %s)", format, strings.Join(RecordFormats, ", "))
+	}
+}
+
+// formatRecordTable writes a header row and one row per record
+func formatRecordTable(records []interface{}, columns []string, prefix, sep, suffix string, escape func(string) string) string {
+	var sb strings.Builder
+	writeRow := func(cells []string) {
+		sb.WriteString(prefix)
+		for i, cell := range cells {
+			if i > 0 {
+				sb.WriteString(sep)
+			}
+			sb.WriteString(escape(cell))
+		}
+		sb.WriteString(suffix)
+		sb.WriteString("\n")
+	}
+
+	writeRow(columns)
+	cells := make([]string, len(columns))
+	for _, record := range records {
+		m := recordRow(record)
+		for i, column := range columns {
+			cells[i] = cellText(m[column])
+		}
+		writeRow(cells)
+	}
+	return sb.String()
+}
+
+// recordRow returns the fields of a record; other values become {"value": v}
+func recordRow(record interface{}) map[string]interface{} {
+	if m, ok := record.(map[string]interface{}); ok {
+		return m
+	}
+	return map[string]interface{}{"value": record}
+}
+
+// cellText renders a value for a table cell: strings as is, null as empty,
+// everything else as JSON
+func cellText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+// escapeMarkdownCell keeps a value inside one Markdown table cell
+func escapeMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\r\n", "<br>")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
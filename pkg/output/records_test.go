@@ -0,0 +1,104 @@
+package output
+
+import (
+	"reflect"
+	"testing"
+)
+
+func sampleRecords() []interface{} {
+	return []interface{}{
+		map[string]interface{}{"uuid": "u1", "status": "success", "input": map[string]interface{}{"command": "ls"}},
+		map[string]interface{}{"uuid": "u2", "status": "error", "error": "exit 1, \"boom\"", "input": map[string]interface{}{"command": "make | tee"}},
+	}
+}
+
+func TestProjectRecords(t *testing.T) {
+	projected := ProjectRecords(sampleRecords(), ProjectionConfig{
+		Fields:         []string{"uuid", "input.command"},
+		IfErrorInclude: []string{"error"},
+	})
+
+	want := []interface{}{
+		map[string]interface{}{"uuid": "u1", "input.command": "ls"},
+		map[string]interface{}{"uuid": "u2", "input.command": "make | tee", "error": "exit 1, \"boom\""},
+	}
+	if !reflect.DeepEqual(projected, want) {
+		t.Errorf("unexpected projection:\n got %v\nwant %v", projected, want)
+	}
+
+	// No fields: records unchanged
+	if got := ProjectRecords(sampleRecords(), ProjectionConfig{IfErrorInclude: []string{"error"}}); !reflect.DeepEqual(got, sampleRecords()) {
+		t.Errorf("expected records unchanged, got %v", got)
+	}
+}
+
+func TestRecordColumns(t *testing.T) {
+	columns := RecordColumns(sampleRecords(), []string{"uuid", "missing"})
+	want := []string{"uuid", "missing", "error", "input", "status"}
+	if !reflect.DeepEqual(columns, want) {
+		t.Errorf("expected %v, got %v", want, columns)
+	}
+
+	if got := RecordColumns([]interface{}{"a", 1.0}, nil); !reflect.DeepEqual(got, []string{"value"}) {
+		t.Errorf("expected value column for scalars, got %v", got)
+	}
+}
+
+func TestFormatRecords(t *testing.T) {
+	records := ProjectRecords(sampleRecords(), ProjectionConfig{
+		Fields:         []string{"uuid", "input.command"},
+		IfErrorInclude: []string{"error"},
+	})
+	columns := RecordColumns(records, []string{"uuid", "input.command"})
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatCSVRecords, "uuid,input.command,error\nu1,ls,\nu2,make | tee,\"exit 1, \"\"boom\"\"\"\n"},
+		{FormatTSVRecords, "uuid\tinput.command\terror\nu1\tls\t\nu2\tmake | tee\texit 1, \"boom\"\n"},
+		{FormatMarkdownRecords, "| uuid | input.command | error |\n| --- | --- | --- |\n| u1 | ls |  |\n| u2 | make \\| tee | exit 1, \"boom\" |\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := FormatRecords(records, columns, tt.format)
+			if err != nil {
+				t.Fatalf("FormatRecords failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("unexpected output:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+
+	columnar, err := FormatRecords(records, columns, FormatColumnarRecords)
+	if err != nil {
+		t.Fatalf("FormatRecords failed: %v", err)
+	}
+	wantColumnar := ColumnarRecords{
+		Columns: columns,
+		Rows:    [][]interface{}{{"u1", "ls", nil}, {"u2", "make | tee", "exit 1, \"boom\""}},
+	}
+	if !reflect.DeepEqual(columnar, wantColumnar) {
+		t.Errorf("unexpected columnar output: %v", columnar)
+	}
+
+	if _, err := FormatRecords(records, columns, "xml"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestFormatRecordsCellValues(t *testing.T) {
+	records := []interface{}{
+		map[string]interface{}{"n": 3.0, "ok": true, "tags": []interface{}{"a"}, "text": "line1\nline2"},
+	}
+	got, _ := FormatRecords(records, []string{"n", "ok", "tags", "text"}, FormatMarkdownRecords)
+	want := "| n | ok | tags | text |\n| --- | --- | --- | --- |\n| 3 | true | [\"a\"] | line1<br>line2 |\n"
+	if got != want {
+		t.Errorf("unexpected output:\n got %q\nwant %q", got, want)
+	}
+
+	if got, _ := FormatRecords(nil, nil, FormatMarkdownRecords); got != "No data" {
+		t.Errorf("expected 'No data' for empty markdown table, got %q", got)
+	}
+}
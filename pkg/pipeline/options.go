@@ -11,4 +11,8 @@ type GlobalOptions struct {
 type LoadOptions struct {
 	AutoDetect bool // Whether to auto-detect session from current directory
 	Validate   bool // Whether to validate session file after loading
+	// Indexed loads entry skeletons and tool calls from the session index
+	// instead of decoding every file. Only Skeletons are available then, not
+	// Entries; tool calls are complete.
+	Indexed bool
 }
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"fmt"
 
+	"github.com/yaleh/meta-cc/internal/index"
 	"github.com/yaleh/meta-cc/internal/locator"
 	"github.com/yaleh/meta-cc/internal/parser"
 )
@@ -12,7 +13,9 @@ import (
 type SessionPipeline struct {
 	opts      GlobalOptions         // Pipeline configuration
 	session   string                // Loaded session identifier/path
-	entries   []parser.SessionEntry // Parsed session entries
+	entries   []parser.SessionEntry // Parsed session entries (full loads only)
+	skeletons []parser.SessionEntry // Entry skeletons (indexed loads only)
+	toolCalls []parser.ToolCall     // Pre-extracted tool calls (indexed loads only)
 	turnIndex map[string]int        // Cached UUID → turn index mapping
 }
 
@@ -31,6 +34,8 @@ func (p *SessionPipeline) Load(loadOpts LoadOptions) error {
 
 	// Reset cached state on each load attempt
 	p.entries = nil
+	p.skeletons = nil
+	p.toolCalls = nil
 	p.session = ""
 	p.turnIndex = make(map[string]int)
 
@@ -44,15 +49,14 @@ func (p *SessionPipeline) Load(loadOpts LoadOptions) error {
 
 		var allEntries []parser.SessionEntry
 		for _, sessionPath := range sessionPaths {
-			sessionParser := parser.NewSessionParser(sessionPath)
-			entries, parseErr := sessionParser.ParseEntries()
+			entries, parseErr := p.parse(sessionPath, loadOpts)
 			if parseErr != nil {
 				return fmt.Errorf("JSONL parsing failed for %s: %w", sessionPath, parseErr)
 			}
 			allEntries = append(allEntries, entries...)
 		}
 
-		p.entries = allEntries
+		p.keep(allEntries, loadOpts)
 		p.session = fmt.Sprintf("<project:%s (%d sessions)>", p.opts.ProjectPath, len(sessionPaths))
 		return nil
 	}
@@ -66,8 +70,7 @@ func (p *SessionPipeline) Load(loadOpts LoadOptions) error {
 		return fmt.Errorf("session location failed: %w", err)
 	}
 
-	sessionParser := parser.NewSessionParser(sessionPath)
-	entries, err := sessionParser.ParseEntries()
+	entries, err := p.parse(sessionPath, loadOpts)
 	if err != nil {
 		return fmt.Errorf("JSONL parsing failed: %w", err)
 	}
@@ -76,14 +79,44 @@ func (p *SessionPipeline) Load(loadOpts LoadOptions) error {
 		return fmt.Errorf("session file is empty or contains no valid entries")
 	}
 
-	p.entries = entries
+	p.keep(entries, loadOpts)
 	p.session = sessionPath
 
 	return nil
 }
 
+// parse returns the entries of one session file, or their skeletons from the
+// session index when loadOpts.Indexed is set (collecting its tool calls as well)
+func (p *SessionPipeline) parse(sessionPath string, loadOpts LoadOptions) ([]parser.SessionEntry, error) {
+	if !loadOpts.Indexed {
+		return parser.NewSessionParser(sessionPath).ParseEntries()
+	}
+
+	fi, err := index.Default().File(sessionPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := fi.Err(); err != nil {
+		return nil, err
+	}
+	p.toolCalls = append(p.toolCalls, fi.ToolCalls...)
+	return fi.Skeletons, nil
+}
+
+// keep stores loaded entries as full entries or, for indexed loads, skeletons
+func (p *SessionPipeline) keep(entries []parser.SessionEntry, loadOpts LoadOptions) {
+	if loadOpts.Indexed {
+		p.skeletons = entries
+		return
+	}
+	p.entries = entries
+}
+
 // ExtractToolCalls extracts all tool calls from the currently loaded entries.
 func (p *SessionPipeline) ExtractToolCalls() []parser.ToolCall {
+	if p.toolCalls != nil {
+		return p.toolCalls
+	}
 	if len(p.entries) == 0 {
 		return []parser.ToolCall{}
 	}
@@ -96,7 +129,7 @@ func (p *SessionPipeline) BuildTurnIndex() map[string]int {
 		return p.turnIndex
 	}
 
-	for i, entry := range p.entries {
+	for i, entry := range p.Skeletons() {
 		p.turnIndex[entry.UUID] = i
 	}
 
@@ -108,12 +141,25 @@ func (p *SessionPipeline) SessionPath() string {
 	return p.session
 }
 
-// EntryCount returns the number of parsed entries currently loaded.
+// EntryCount returns the number of entries currently loaded, complete or
+// skeletons.
 func (p *SessionPipeline) EntryCount() int {
-	return len(p.entries)
+	return len(p.Skeletons())
 }
 
-// Entries returns the parsed session entries.
+// Entries returns the parsed session entries. It is empty after an indexed
+// load; use Skeletons.
 func (p *SessionPipeline) Entries() []parser.SessionEntry {
 	return p.entries
 }
+
+// Skeletons returns the entries reduced to metadata and tool_use IDs and
+// names, which is all turn, timing and tool sequence analyses read. After an
+// indexed load these come from the session index (see index.FileIndex) and
+// must not be modified; after a full load the complete entries are returned.
+func (p *SessionPipeline) Skeletons() []parser.SessionEntry {
+	if p.skeletons != nil {
+		return p.skeletons
+	}
+	return p.entries
+}
@@ -436,3 +436,49 @@ func TestSessionPipeline_Entries(t *testing.T) {
 		}
 	}
 }
+
+func TestSessionPipeline_Load_Indexed(t *testing.T) {
+	t.Setenv("META_CC_PROJECTS_ROOT", t.TempDir())
+	sessionID := "test-indexed-session"
+	createTestSession(t, sessionID, "/test/indexed")
+
+	full := NewSessionPipeline(GlobalOptions{SessionID: sessionID})
+	if err := full.Load(LoadOptions{}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ { // second load is served from the index
+		indexed := NewSessionPipeline(GlobalOptions{SessionID: sessionID})
+		if err := indexed.Load(LoadOptions{Indexed: true}); err != nil {
+			t.Fatalf("indexed Load failed: %v", err)
+		}
+		if indexed.EntryCount() != full.EntryCount() {
+			t.Errorf("expected %d entries, got %d", full.EntryCount(), indexed.EntryCount())
+		}
+		if len(indexed.Entries()) != 0 || len(indexed.Skeletons()) != full.EntryCount() {
+			t.Errorf("expected skeletons only, got %d entries and %d skeletons", len(indexed.Entries()), len(indexed.Skeletons()))
+		}
+
+		tools := indexed.ExtractToolCalls()
+		if len(tools) != 1 || tools[0].ToolName != "Bash" || tools[0].Output != "file1.txt\nfile2.txt" {
+			t.Errorf("expected Bash call with its output, got %+v", tools)
+		}
+	}
+}
+
+func TestSessionPipeline_Load_IndexedInvalidJSONL(t *testing.T) {
+	t.Setenv("META_CC_PROJECTS_ROOT", t.TempDir())
+	sessionDir := filepath.Join(os.Getenv("META_CC_PROJECTS_ROOT"), "-test-invalid")
+	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		t.Fatalf("failed to create session dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sessionDir, "bad.jsonl"), []byte("invalid json\n"), 0644); err != nil {
+		t.Fatalf("failed to write session file: %v", err)
+	}
+
+	p := NewSessionPipeline(GlobalOptions{SessionID: "bad"})
+	err := p.Load(LoadOptions{Indexed: true})
+	if err == nil || !strings.Contains(err.Error(), "failed to parse line 1") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
@@ -72,6 +72,14 @@ install_binaries() {
         chmod +x "$INSTALL_DIR/meta-cc-mcp"
     fi
 
+    # Copy the CLI if the package ships it
+    if [ -f "bin/meta-cc${BINARY_EXT}" ]; then
+        cp "bin/meta-cc${BINARY_EXT}" "$INSTALL_DIR/" || error_exit "Failed to copy meta-cc binary"
+        if [ "$PLATFORM" != "windows" ]; then
+            chmod +x "$INSTALL_DIR/meta-cc"
+        fi
+    fi
+
     info "Binary installed to $INSTALL_DIR"
 }
 
@@ -33,6 +33,7 @@ echo ""
 # Remove binaries
 if [ -f "$INSTALL_DIR/meta-cc-mcp" ]; then
     rm -f "$INSTALL_DIR/meta-cc-mcp" 2>/dev/null || true
+    rm -f "$INSTALL_DIR/meta-cc" 2>/dev/null || true
     info "Binary removed from $INSTALL_DIR"
 else
     warn "No binary found in $INSTALL_DIR"